@@ -0,0 +1,153 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package miniokr
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	fs "github.com/imxw/miniokr/internal/miniokr/services/field"
+	"github.com/imxw/miniokr/internal/pkg/bitable"
+	"github.com/imxw/miniokr/internal/pkg/bitable/field"
+	larkToken "github.com/imxw/miniokr/internal/pkg/bitable/token"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// newBitableCommand 创建 `miniokr bitable` 命令，用于管理飞书多维表格.
+func newBitableCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bitable",
+		Short: "Manage the Feishu Bitable base used by miniokr",
+	}
+	cmd.AddCommand(newBitableInitCommand())
+	return cmd
+}
+
+// newBitableInitCommand 创建 `miniokr bitable init` 命令.
+func newBitableInitCommand() *cobra.Command {
+	var (
+		tenantName string
+		opts       fs.BootstrapOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create the objective and key result tables in the configured Bitable base",
+		Long: `Create the objective and key result tables with the fields miniokr expects
+in the Bitable base configured by feishu.app-token, then print the table IDs
+and the configuration snippet to use.
+
+Running it again only adds what is missing: existing tables are matched by
+name, missing fields are created and missing single-select options are added.`,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return initBitable(cmd.Context(), cmd.OutOrStdout(), tenantName, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&tenantName, "tenant", "", "Initialize the base of the tenant with this name. Defaults to the first tenant.")
+	cmd.Flags().StringVar(&opts.ObjectiveTableName, "objective-table", fs.DefaultObjectiveTableName, "Name of the objective table.")
+	cmd.Flags().StringVar(&opts.KeyResultTableName, "key-result-table", fs.DefaultKeyResultTableName, "Name of the key result table.")
+	cmd.Flags().StringSliceVar(&opts.Owners, "owner", nil, "Employee names to add as options of the owner field.")
+
+	return cmd
+}
+
+// initBitable 在一个公司的多维表格中创建或补齐 OKR 表，并输出对应的配置.
+func initBitable(ctx context.Context, w io.Writer, tenantName string, opts fs.BootstrapOptions) error {
+	configs, err := feishuTenantConfigs()
+	if err != nil {
+		return err
+	}
+
+	index := 0
+	if tenantName != "" {
+		index = -1
+		for i, cfg := range configs {
+			if cfg.Name == tenantName {
+				index = i
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("tenant %q not found", tenantName)
+		}
+	}
+	cfg := configs[index]
+	if cfg.AppToken == "" {
+		return fmt.Errorf("app-token of tenant %q is empty", cfg.Name)
+	}
+
+	// 考核月份的选项与本地模式一致: 过去一年到下个月
+	if opts.Months, err = fs.NewLocalFieldService().GetValidDates(ctx); err != nil {
+		return err
+	}
+
+	client := lark.NewClient(cfg.AppID, cfg.AppSecret)
+	tm := larkToken.NewManager(&larkToken.LarkTokenService{Client: client}, larkToken.NewMemoryStorage(),
+		larkToken.NewRealClock(), cfg.AppID, cfg.AppSecret)
+	invoker := newFeishuInvoker()
+
+	tables := bitable.NewTableManager(client, cfg.AppToken, tm)
+	tables.SetInvoker(invoker)
+	fields := field.NewManager(client, cfg.AppToken, tm)
+	fields.SetInvoker(invoker)
+	fields.SetCache(field.NewMemoryCache())
+
+	result, err := fs.NewBootstrapper(tables, fields).Init(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Changes) == 0 {
+		fmt.Fprintln(w, "表结构已完整，无需修改")
+	}
+	for _, c := range result.Changes {
+		fmt.Fprintln(w, "+", c)
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintln(w, "!", warning)
+	}
+
+	fmt.Fprintf(w, "\n目标表: %s\n关键结果表: %s\n\n", result.OTableID, result.KrTableID)
+	if viper.IsSet("feishu.tenants") {
+		fmt.Fprintf(w, "请更新 feishu.tenants 中 %s 的配置:\n", cfg.Name)
+	} else {
+		fmt.Fprintln(w, "请更新配置:")
+		fmt.Fprintln(w, "feishu:")
+	}
+	printBitableConfig(w, "  ", cfg.AppToken, result)
+
+	return nil
+}
+
+// printBitableConfig 输出表 ID 和按字段名声明的字段绑定.
+func printBitableConfig(w io.Writer, indent, appToken string, result *fs.BootstrapResult) {
+	fmt.Fprintf(w, "%sapp-token: %q\n", indent, appToken)
+	fmt.Fprintf(w, "%so-table-id: %q\n", indent, result.OTableID)
+	fmt.Fprintf(w, "%skr-table-id: %q\n", indent, result.KrTableID)
+	fmt.Fprintf(w, "%sfields:\n", indent)
+
+	sections := []struct {
+		key     string
+		schema  interface{}
+		binding field.Binding
+	}{
+		{"objective", &v1.ObjectiveField{}, result.Objective},
+		{"key-result", &v1.KeyResultField{}, result.KeyResult},
+	}
+	for _, s := range sections {
+		fmt.Fprintf(w, "%s  %s:\n", indent, s.key)
+		for _, r := range field.Resolve(nil, s.schema, s.binding) {
+			fmt.Fprintf(w, "%s    %s: %s\n", indent, r.Key, r.Ref)
+		}
+	}
+}
@@ -6,15 +6,25 @@
 package miniokr
 
 import (
+	"github.com/imxw/miniokr/internal/miniokr/controller/v1/account"
 	"github.com/imxw/miniokr/internal/miniokr/controller/v1/auth"
+	"github.com/imxw/miniokr/internal/miniokr/controller/v1/delegation"
+	"github.com/imxw/miniokr/internal/miniokr/controller/v1/event"
 	"github.com/imxw/miniokr/internal/miniokr/controller/v1/field"
 	"github.com/imxw/miniokr/internal/miniokr/controller/v1/okr"
+	"github.com/imxw/miniokr/internal/miniokr/controller/v1/policy"
+	"github.com/imxw/miniokr/internal/miniokr/controller/v1/role"
 	"github.com/imxw/miniokr/internal/miniokr/controller/v1/user"
 )
 
 type ServiceContainer struct {
-	AuthController  *auth.Controller
-	FieldController *field.Controller
-	OkrController   *okr.Controller
-	UserController  *user.Controller
+	AccountController    *account.Controller
+	AuthController       *auth.Controller
+	DelegationController *delegation.Controller
+	EventController      *event.Controller
+	FieldController      *field.Controller
+	OkrController        *okr.Controller
+	PolicyController     *policy.Controller
+	RoleController       *role.Controller
+	UserController       *user.Controller
 }
@@ -0,0 +1,86 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package account
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/auth"
+	"github.com/imxw/miniokr/internal/pkg/core"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// Controller 处理本地账号的管理接口，路由需要 admin 角色
+type Controller struct {
+	ps *auth.PasswordAuthService
+}
+
+func New(ps *auth.PasswordAuthService) *Controller {
+	return &Controller{ps: ps}
+}
+
+// Create 处理 `POST /api/v1/admin/accounts` 请求，创建本地账号.
+func (ctrl *Controller) Create(c *gin.Context) {
+	var r v1.CreateAccountRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+	log.C(c).Infow("Create account function called", "username", r.Username, "kind", r.Kind, "operator", c.GetString(known.XUserIDKey))
+
+	uid, err := ctrl.ps.CreateAccount(c, auth.Account{
+		Username: r.Username,
+		Name:     r.Name,
+		Password: r.Password,
+		Kind:     r.Kind,
+	})
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, v1.CreateAccountResponse{UserID: uid})
+}
+
+// Disable 处理 `PUT /api/v1/admin/accounts/:username/disable` 请求，停用本地账号.
+func (ctrl *Controller) Disable(c *gin.Context) {
+	ctrl.setDisabled(c, true)
+}
+
+// Enable 处理 `PUT /api/v1/admin/accounts/:username/enable` 请求，重新启用本地账号.
+func (ctrl *Controller) Enable(c *gin.Context) {
+	ctrl.setDisabled(c, false)
+}
+
+func (ctrl *Controller) setDisabled(c *gin.Context, disabled bool) {
+	username := c.Param("username")
+	log.C(c).Infow("Set account disabled function called", "username", username, "disabled", disabled, "operator", c.GetString(known.XUserIDKey))
+
+	if err := ctrl.ps.SetDisabled(c, username, disabled); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
+
+// ResetPassword 处理 `PUT /api/v1/admin/accounts/:username/password` 请求，重置密码并解除锁定.
+func (ctrl *Controller) ResetPassword(c *gin.Context) {
+	var r v1.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+	username := c.Param("username")
+	log.C(c).Infow("Reset password function called", "username", username, "operator", c.GetString(known.XUserIDKey))
+
+	if err := ctrl.ps.ResetPassword(c, username, r.Password); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
@@ -6,25 +6,40 @@
 package auth
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 
 	"github.com/imxw/miniokr/internal/miniokr/services/auth"
 	"github.com/imxw/miniokr/internal/pkg/core"
 	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
 	"github.com/imxw/miniokr/internal/pkg/log"
 	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
 )
 
 type Controller struct {
-	a auth.Authenticator
+	providers map[string]auth.Authenticator
+	password  *auth.PasswordAuthService
+	sessions  *auth.SessionService
 }
 
-func New(a auth.Authenticator) *Controller {
-	return &Controller{a: a}
+// New 创建 Controller，providers 的 key 为登录方式，如 auth.ProviderDingTalk，password 用于本地账号登录，
+// 登录成功后由 sessions 签发访问令牌和刷新令牌
+func New(providers map[string]auth.Authenticator, password *auth.PasswordAuthService, sessions *auth.SessionService) *Controller {
+	return &Controller{providers: providers, password: password, sessions: sessions}
 }
 
+// Auth 处理 `POST /api/v1/auth/:provider` 请求，按登录方式选择对应的 Authenticator.
 func (ctrl *Controller) Auth(c *gin.Context) {
-	log.C(c).Infow("Auth function called")
+	provider := c.Param("provider")
+	log.C(c).Infow("Auth function called", "provider", provider)
+
+	a, ok := ctrl.providers[provider]
+	if !ok {
+		core.WriteResponse(c, errno.ErrPageNotFound, nil)
+		return
+	}
 
 	var r v1.AuthRequest
 	if err := c.ShouldBindJSON(&r); err != nil {
@@ -32,17 +47,105 @@ func (ctrl *Controller) Auth(c *gin.Context) {
 		core.WriteResponse(c, errno.ErrBind, nil)
 		return
 	}
-	userid, username, err := ctrl.a.Fetch(c, r.AuthCode)
+	var userid, username string
+	var err error
+	if sf, ok := a.(auth.StateFetcher); ok {
+		userid, username, err = sf.FetchWithState(c, r.AuthCode, r.State)
+	} else {
+		userid, username, err = a.Fetch(c, r.AuthCode)
+	}
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	ctrl.issue(c, userid, username)
+}
+
+// Authorize 处理 `GET /api/v1/auth/:provider/authorize` 请求，跳转到登录方式的授权页面.
+// 授权后前端从回调地址中取出 code 和 state，调用 `POST /api/v1/auth/:provider` 完成登录.
+func (ctrl *Controller) Authorize(c *gin.Context) {
+	provider := c.Param("provider")
+	log.C(c).Infow("Authorize function called", "provider", provider)
+
+	r, ok := ctrl.providers[provider].(auth.Redirector)
+	if !ok {
+		core.WriteResponse(c, errno.ErrPageNotFound, nil)
+		return
+	}
+
+	u, err := r.AuthCodeURL(c)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	c.Redirect(http.StatusFound, u)
+}
+
+// Password 处理 `POST /api/v1/auth/password` 请求，使用本地账号的用户名密码登录.
+func (ctrl *Controller) Password(c *gin.Context) {
+	var r v1.PasswordAuthRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+	log.C(c).Infow("Password function called", "username", r.Username)
+
+	userid, username, err := ctrl.password.Login(c, r.Username, r.Password)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	ctrl.issue(c, userid, username)
+}
+
+// Refresh 处理 `POST /api/v1/auth/refresh` 请求，使用刷新令牌换取新的令牌，旧的刷新令牌随即失效.
+func (ctrl *Controller) Refresh(c *gin.Context) {
+	var r v1.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+
+	pair, err := ctrl.sessions.Refresh(c, r.RefreshToken)
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 		return
 	}
+	core.WriteResponse(c, nil, v1.AuthResponse{Token: pair.AccessToken, RefreshToken: pair.RefreshToken})
+}
+
+// Logout 处理 `POST /api/v1/auth/logout` 请求，吊销当前访问令牌和所属会话的刷新令牌.
+func (ctrl *Controller) Logout(c *gin.Context) {
+	log.C(c).Infow("Logout function called", "userID", c.GetString(known.XUserIDKey))
+
+	if err := ctrl.sessions.Logout(c, c.GetString(known.XTokenIDKey), c.GetString(known.XSessionIDKey), c.GetTime(known.XTokenExpiresAtKey)); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
+
+// RevokeUserSessions 处理 `DELETE /api/v1/admin/users/:id/sessions` 请求，吊销用户的全部会话，如员工离职.
+func (ctrl *Controller) RevokeUserSessions(c *gin.Context) {
+	userID := c.Param("id")
+	log.C(c).Infow("Revoke user sessions function called", "userID", userID, "operator", c.GetString(known.XUserIDKey))
+
+	if err := ctrl.sessions.RevokeUser(c, userID); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
 
-	token, err := ctrl.a.IssueToken(c, userid, username)
-	log.C(c).Infow("获取用户token", "token", token, "username", username)
+// issue 为登录成功的用户创建会话并返回令牌.
+func (ctrl *Controller) issue(c *gin.Context, userid string, username string) {
+	pair, err := ctrl.sessions.Issue(c, userid, username)
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 		return
 	}
-	core.WriteResponse(c, nil, v1.AuthResponse{Token: token})
+	log.C(c).Infow("获取用户token", "userID", userid, "username", username)
+	core.WriteResponse(c, nil, v1.AuthResponse{Token: pair.AccessToken, RefreshToken: pair.RefreshToken})
 }
@@ -0,0 +1,87 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package delegation
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/delegation"
+	"github.com/imxw/miniokr/internal/pkg/core"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// Controller 处理主管委托的接口，委托人和被委托人只能看到与自己有关的委托
+type Controller struct {
+	ds *delegation.DelegationService
+}
+
+func New(ds *delegation.DelegationService) *Controller {
+	return &Controller{ds: ds}
+}
+
+// Create 处理 `POST /api/v1/delegations` 请求，当前用户将管理范围内部门的权限委托给他人.
+func (ctrl *Controller) Create(c *gin.Context) {
+	var r v1.CreateDelegationRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+	userID := c.GetString(known.XUserIDKey)
+	log.C(c).Infow("Create delegation function called", "grantor", userID, "grantee", r.GranteeID, "scope", r.Scope)
+
+	d, err := ctrl.ds.Create(c, userID, &r)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, d)
+}
+
+// List 处理 `GET /api/v1/delegations` 请求，返回当前用户授予他人或被授予的委托.
+func (ctrl *Controller) List(c *gin.Context) {
+	ds, err := ctrl.ds.List(c, c.GetString(known.XUserIDKey))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, v1.ListDelegationResponse{Delegations: ds})
+}
+
+// Revoke 处理 `DELETE /api/v1/delegations/:id` 请求，委托人吊销委托.
+func (ctrl *Controller) Revoke(c *gin.Context) {
+	var r v1.DelegationURI
+	if err := c.ShouldBindUri(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+	userID := c.GetString(known.XUserIDKey)
+	log.C(c).Infow("Revoke delegation function called", "id", r.ID, "operator", userID)
+
+	if err := ctrl.ds.Revoke(c, userID, r.ID); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
+
+// ListActions 处理 `GET /api/v1/delegations/:id/actions` 请求，返回被委托人代为执行的操作.
+func (ctrl *Controller) ListActions(c *gin.Context) {
+	var r v1.DelegationURI
+	if err := c.ShouldBindUri(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+
+	actions, err := ctrl.ds.ListActions(c, c.GetString(known.XUserIDKey), r.ID)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, v1.ListDelegatedActionResponse{Actions: actions})
+}
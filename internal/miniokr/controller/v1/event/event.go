@@ -0,0 +1,84 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package event
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	larkevent "github.com/larksuite/oapi-sdk-go/v3/event"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+
+	"github.com/imxw/miniokr/internal/pkg/core"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/log"
+)
+
+// MaxClockSkew 是请求时间戳与服务器时间允许的最大偏差，超出的请求视为重放
+const MaxClockSkew = 5 * time.Minute
+
+// Controller 接收飞书事件回调，按路径中的 app ID 交给对应应用的 EventDispatcher 校验签名、解密并处理
+type Controller struct {
+	dispatchers map[string]*dispatcher.EventDispatcher
+	now         func() time.Time
+}
+
+// New 创建 Controller，dispatchers 的 key 为飞书应用的 app ID
+func New(dispatchers map[string]*dispatcher.EventDispatcher) *Controller {
+	return &Controller{dispatchers: dispatchers, now: time.Now}
+}
+
+// Handle 处理 `POST /api/v1/feishu/events/:appID` 请求.
+func (ctrl *Controller) Handle(c *gin.Context) {
+	d, ok := ctrl.dispatchers[c.Param("appID")]
+	if !ok {
+		core.WriteResponse(c, errno.ErrPageNotFound, nil)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+
+	// 事件请求的时间戳参与签名，拒绝超出时间窗口的请求以防重放；URL 校验请求不带签名头
+	if ts := c.GetHeader(larkevent.EventRequestTimestamp); ts != "" && !ctrl.fresh(ts) {
+		log.C(c).Warnw("Reject stale feishu event", "timestamp", ts)
+		core.WriteResponse(c, errno.ErrUnauthorized, nil)
+		return
+	}
+
+	resp := d.Handle(c, &larkevent.EventReq{
+		Header:     c.Request.Header,
+		Body:       body,
+		RequestURI: c.Request.RequestURI,
+	})
+	if resp.StatusCode != http.StatusOK {
+		log.C(c).Errorw("Failed to handle feishu event", "status", resp.StatusCode, "response", string(resp.Body))
+	}
+
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			c.Writer.Header().Add(k, v)
+		}
+	}
+	c.Status(resp.StatusCode)
+	_, _ = c.Writer.Write(resp.Body)
+}
+
+// fresh 判断以秒为单位的请求时间戳是否在允许的时间窗口内
+func (ctrl *Controller) fresh(ts string) bool {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	skew := ctrl.now().Sub(time.Unix(sec, 0))
+	return skew <= MaxClockSkew && skew >= -MaxClockSkew
+}
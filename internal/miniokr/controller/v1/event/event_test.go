@@ -0,0 +1,209 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	larkevent "github.com/larksuite/oapi-sdk-go/v3/event"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/event"
+)
+
+// testdata 中的请求使用以下配置加密和签名
+const (
+	testAppID             = "cli_test"
+	testAppToken          = "app-token"
+	testVerificationToken = "test-verification-token"
+	testEncryptKey        = "test-encrypt-key"
+	testTimestamp         = 1717200000
+)
+
+// recordedRequest 是 testdata 中录制的回调请求
+type recordedRequest struct {
+	Timestamp string `json:"timestamp"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+	Body      string `json:"body"`
+}
+
+func loadRequest(t *testing.T, name string) recordedRequest {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	var r recordedRequest
+	require.NoError(t, json.Unmarshal(data, &r))
+	return r
+}
+
+// fakeFieldCache 记录被清除缓存的表
+type fakeFieldCache struct {
+	mu          sync.Mutex
+	invalidated []string
+}
+
+func (f *fakeFieldCache) Invalidate(_ context.Context, tableID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.invalidated = append(f.invalidated, tableID)
+}
+
+func newTestRouter(t *testing.T, cache event.FieldCache) *gin.Engine {
+	t.Helper()
+
+	d, err := event.NewHandler(testAppToken, testVerificationToken, cache).Dispatcher(testEncryptKey)
+	require.NoError(t, err)
+
+	ctrl := New(map[string]*dispatcher.EventDispatcher{testAppID: d})
+	ctrl.now = func() time.Time { return time.Unix(testTimestamp, 0).Add(30 * time.Second) }
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.POST("/api/v1/feishu/events/:appID", ctrl.Handle)
+	return g
+}
+
+func serve(g *gin.Engine, appID string, r recordedRequest) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/feishu/events/"+appID, strings.NewReader(r.Body))
+	req.Header.Set("Content-Type", "application/json")
+	if r.Timestamp != "" {
+		req.Header.Set(larkevent.EventRequestTimestamp, r.Timestamp)
+		req.Header.Set(larkevent.EventRequestNonce, r.Nonce)
+		req.Header.Set(larkevent.EventSignature, r.Signature)
+	}
+
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandle_URLVerification(t *testing.T) {
+	g := newTestRouter(t, &fakeFieldCache{})
+
+	w := serve(g, testAppID, loadRequest(t, "url_verification.json"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"challenge":"ajls384kdjx98XX"}`, w.Body.String())
+}
+
+func TestHandle_FieldChanged(t *testing.T) {
+	cache := &fakeFieldCache{}
+	g := newTestRouter(t, cache)
+
+	w := serve(g, testAppID, loadRequest(t, "field_changed.json"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{"tblObjective"}, cache.invalidated)
+}
+
+func TestHandle_FieldChangedOfOtherBitable(t *testing.T) {
+	cache := &fakeFieldCache{}
+	g := newTestRouter(t, cache)
+
+	w := serve(g, testAppID, loadRequest(t, "field_changed_other_base.json"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, cache.invalidated)
+}
+
+func TestHandle_RecordChanged(t *testing.T) {
+	cache := &fakeFieldCache{}
+	g := newTestRouter(t, cache)
+
+	w := serve(g, testAppID, loadRequest(t, "record_changed.json"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, cache.invalidated)
+}
+
+func TestHandle_Rejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		appID  string
+		file   string
+		mutate func(r *recordedRequest)
+		code   int
+	}{
+		{
+			name:   "tampered signature",
+			file:   "field_changed.json",
+			mutate: func(r *recordedRequest) { r.Signature = strings.Repeat("0", 64) },
+			code:   http.StatusInternalServerError,
+		},
+		{
+			name:   "tampered body",
+			file:   "field_changed.json",
+			mutate: func(r *recordedRequest) { r.Body = strings.Replace(r.Body, "M", "N", 1) },
+			code:   http.StatusInternalServerError,
+		},
+		{
+			name:   "replayed nonce",
+			file:   "field_changed.json",
+			mutate: func(r *recordedRequest) { r.Nonce = "another-nonce" },
+			code:   http.StatusInternalServerError,
+		},
+		{
+			name: "invalid verification token",
+			file: "field_changed_invalid_token.json",
+			code: http.StatusInternalServerError,
+		},
+		{
+			name:   "stale timestamp",
+			file:   "field_changed.json",
+			mutate: func(r *recordedRequest) { r.Timestamp = "1717100000" },
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:  "unknown app",
+			appID: "cli_unknown",
+			file:  "field_changed.json",
+			code:  http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &fakeFieldCache{}
+			g := newTestRouter(t, cache)
+
+			r := loadRequest(t, tt.file)
+			if tt.mutate != nil {
+				tt.mutate(&r)
+			}
+			appID := tt.appID
+			if appID == "" {
+				appID = testAppID
+			}
+
+			w := serve(g, appID, r)
+			assert.Equal(t, tt.code, w.Code)
+			assert.Empty(t, cache.invalidated)
+		})
+	}
+}
+
+func TestFresh(t *testing.T) {
+	ctrl := New(nil)
+	ctrl.now = func() time.Time { return time.Unix(testTimestamp, 0) }
+
+	assert.True(t, ctrl.fresh("1717200000"))
+	assert.True(t, ctrl.fresh("1717200299"))
+	assert.True(t, ctrl.fresh("1717199701"))
+	assert.False(t, ctrl.fresh("1717200301"))
+	assert.False(t, ctrl.fresh("1717199000"))
+	assert.False(t, ctrl.fresh("not-a-number"))
+}
@@ -1,13 +1,17 @@
 package v1
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
 
+	"github.com/imxw/miniokr/internal/miniokr/services/okr"
 	"github.com/imxw/miniokr/internal/miniokr/services/user"
 	"github.com/imxw/miniokr/internal/pkg/core"
 	"github.com/imxw/miniokr/internal/pkg/errno"
 	"github.com/imxw/miniokr/internal/pkg/known"
 	"github.com/imxw/miniokr/internal/pkg/log"
+	"github.com/imxw/miniokr/internal/pkg/model"
 )
 
 // Contains checks if an element is present in a slice.
@@ -21,35 +25,137 @@ func Contains[T comparable](slice []T, element T) bool {
 	return false
 }
 
-func CheckPermission(c *gin.Context, SrcUserId string, roles []string, targetUserId string, userService user.Service) bool {
+// Authorizer 判断角色是否被允许访问资源.
+type Authorizer interface {
+	AuthorizeRoles(roles []string, obj, act string) (bool, error)
+}
 
-	if SrcUserId == targetUserId {
-		return true
+// Delegator 查找主管授予当前用户的委托，并记录通过委托执行的操作.
+type Delegator interface {
+	FindDelegation(ctx context.Context, granteeID, targetUserID, scope string) (*model.Delegation, error)
+	RecordAction(ctx context.Context, d *model.Delegation, targetUserID, method, path string) error
+}
+
+// CheckPermission 判断用户 SrcUserId 能否访问 targetUserId 的 OKR：本人、
+// 被授权访问 known.AnyUserOkrObject 的角色、目标用户的主管，以及主管授予了 scope 权限的被委托人.
+// scope 为空表示该操作不能委托. 无权限时写入错误响应.
+func CheckPermission(c *gin.Context, authz Authorizer, delegations Delegator, scope string, SrcUserId string, roles []string, targetUserId string, userService user.Service) bool {
+	ok, err := permitted(c, authz, delegations, userService, c.Request.Method, c.Request.URL.Path, scope, SrcUserId, roles, targetUserId)
+	if err != nil {
+		core.WriteResponse(c, errno.InternalServerError, nil)
+		return false
+	}
+	if !ok {
+		core.WriteResponse(c, errno.ErrForbidden, nil)
 	}
+	return ok
+}
 
-	for _, role := range roles {
-		if role == known.AdminRoleName {
-			return true
+// permitted 是 CheckPermission 的判断逻辑，method 和 path 用于授权和记录委托操作
+func permitted(ctx context.Context, authz Authorizer, delegations Delegator, userService user.Service,
+	method, path, scope, srcUserID string, roles []string, targetUserID string) (bool, error) {
+	if srcUserID == targetUserID {
+		return true, nil
+	}
+
+	allowed, err := authz.AuthorizeRoles(roles, known.AnyUserOkrObject, method)
+	if err != nil {
+		log.C(ctx).Errorw("failed to authorize", "err", err)
+		return false, err
+	}
+	if allowed {
+		return true, nil
+	}
+	// 目标用户未知时只允许可以访问任何用户 OKR 的角色
+	if targetUserID == "" {
+		return false, nil
+	}
+
+	if Contains(roles, known.LeaderRoleName) {
+		managedUserIDs, err := userService.GetManagedUserIDs(ctx, srcUserID)
+		if err != nil {
+			log.C(ctx).Errorw("failed to get managed user IDs", "err", err)
+			return false, err
+		}
+		if Contains(managedUserIDs, targetUserID) {
+			return true, nil
 		}
 	}
 
-	for _, role := range roles {
-		if role == known.LeaderRoleName {
-			managedUserIDs, err := userService.GetManagedUserIDs(c, SrcUserId)
-			if err != nil {
-				log.C(c).Errorw("failed to get managed user IDs", "err", err)
-				core.WriteResponse(c, errno.InternalServerError, nil)
-				return false
+	if scope != "" && Contains(roles, known.DelegateRoleName) {
+		d, err := delegations.FindDelegation(ctx, srcUserID, targetUserID, scope)
+		if err != nil {
+			log.C(ctx).Errorw("failed to find delegation", "err", err)
+			return false, err
+		}
+		if d != nil {
+			// 记录被委托人代为执行的操作，记录失败时拒绝访问
+			if err := delegations.RecordAction(ctx, d, targetUserID, method, path); err != nil {
+				log.C(ctx).Errorw("failed to record delegated action", "err", err)
+				return false, err
 			}
+			log.C(ctx).Infow("Delegated access", "delegation", d.ID, "grantor", d.GrantorID, "delegate", srcUserID, "target", targetUserID)
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-			for _, managerUserID := range managedUserIDs {
-				if targetUserId == managerUserID {
-					return true
-				}
-			}
-			break
+// OwnerAuthorizer 实现 okr.OwnerAuthorizer，按记录负责人对应的用户 ID 校验当前用户的权限.
+// 负责人名称对应多个同名用户时，当前用户是其中之一或对其中之一有权限即可修改；
+// 不对应任何用户时，只有可以访问任何用户 OKR 的角色能修改
+type OwnerAuthorizer struct {
+	authz       Authorizer
+	delegations Delegator
+	users       user.Service
+}
+
+// 确保 OwnerAuthorizer 实现了 okr.OwnerAuthorizer 接口.
+var _ okr.OwnerAuthorizer = (*OwnerAuthorizer)(nil)
+
+// NewOwnerAuthorizer 创建按记录负责人校验权限的 OwnerAuthorizer
+func NewOwnerAuthorizer(authz Authorizer, delegations Delegator, users user.Service) *OwnerAuthorizer {
+	return &OwnerAuthorizer{authz: authz, delegations: delegations, users: users}
+}
+
+// AuthorizeOwner 从 ctx 中读取当前用户和角色，判断其能否对负责人为 owner 的记录执行 act
+func (a *OwnerAuthorizer) AuthorizeOwner(ctx context.Context, owner string, act string, scope string) error {
+	userID, _ := ctx.Value(known.XUserIDKey).(string)
+	if userID == "" {
+		return errno.ErrForbidden
+	}
+	roles, _ := ctx.Value(known.UserRolesKey).([]string)
+
+	ownerIDs, err := a.users.GetUserIDsByName(ctx, owner)
+	if err != nil {
+		log.C(ctx).Errorw("failed to get user IDs by name", "owner", owner, "err", err)
+		return errno.InternalServerError
+	}
+	// 负责人名称对应多个同名用户时，当前用户是其中之一即视为本人
+	if Contains(ownerIDs, userID) {
+		return nil
+	}
+	if len(ownerIDs) > 1 {
+		log.C(ctx).Warnw("Owner name matches multiple users", "owner", owner, "count", len(ownerIDs))
+	}
+	if len(ownerIDs) == 0 {
+		ownerIDs = []string{""}
+	}
+
+	path := ""
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		path = c.Request.URL.Path
+	}
+	// 对任一同名用户有权限(如主管管理其中之一)即允许
+	for _, ownerID := range ownerIDs {
+		ok, err := permitted(ctx, a.authz, a.delegations, a.users, act, path, scope, userID, roles, ownerID)
+		if err != nil {
+			return errno.InternalServerError
+		}
+		if ok {
+			return nil
 		}
 	}
-	core.WriteResponse(c, errno.ErrForbidden, nil)
-	return false
+	log.C(ctx).Warnw("Modify record of other user denied", "owner", owner, "ownerIDs", ownerIDs)
+	return errno.ErrForbidden
 }
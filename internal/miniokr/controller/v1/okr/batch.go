@@ -0,0 +1,128 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package okr
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/okr"
+	"github.com/imxw/miniokr/internal/pkg/core"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// 一次性保存 Objective 及其 KeyResults
+func (ctrl *Controller) BatchSaveOkr(c *gin.Context) {
+	log.C(c).Infow("BatchSaveOkr function Called")
+
+	// 获取参数
+	var req v1.BatchSaveOkrRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+
+	// 校验用户
+	username, ok := c.MustGet(known.XUsernameKey).(string)
+	if !ok {
+		core.WriteResponse(c, errors.New("无法获取用户名"), nil)
+		return
+	}
+	userID, ok := c.MustGet(known.XUserIDKey).(string)
+	if !ok {
+		core.WriteResponse(c, errno.InternalServerError, nil)
+		return
+	}
+
+	owner, ownerID := username, userID
+	if req.UserId != "" {
+		// 查询目标用户名
+		user, err := ctrl.us.GetUserByID(c, req.UserId)
+		if err != nil {
+			core.WriteResponse(c, errno.ErrUserNotFound, nil)
+			return
+		}
+		owner, ownerID = user.Name, user.UserID
+	}
+
+	date, err := standardizeMonthFormat(req.Objective.Date)
+	if err != nil {
+		core.WriteResponse(c, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	// 转换
+	objective := model.Objective{
+		ID:     trimIDPrefix(req.Objective.ID),
+		Title:  req.Objective.Title,
+		Owner:  owner,
+		Date:   date,
+		Weight: req.Objective.Weight,
+	}
+
+	krs := make([]model.KeyResult, 0, len(req.KeyResults))
+	for _, item := range req.KeyResults {
+		krDate, err := standardizeMonthFormat(item.Date)
+		if err != nil {
+			core.WriteResponse(c, errno.ErrInvalidParameter, nil)
+			return
+		}
+		kr := model.KeyResult{
+			ID:         trimIDPrefix(item.ID),
+			Title:      item.Title,
+			Owner:      owner,
+			Date:       krDate,
+			Weight:     item.Weight,
+			Completed:  item.Completed,
+			SelfRating: item.SelfRating,
+			Reason:     item.Reason,
+			Criteria:   item.Criteria,
+		}
+		// 与单条接口一致，上级评分只在更新已有记录时写入
+		if kr.ID != "" {
+			kr.LeaderRating = item.LeaderRating
+		}
+		krs = append(krs, kr)
+	}
+
+	// 记录保存在负责人所属公司的多维表格中，okr.Service 在保存前校验当前用户能否修改负责人的记录
+	svc, ok := ctrl.okrService(c, ownerID)
+	if !ok {
+		return
+	}
+
+	result, err := svc.SaveOkr(c, objective, krs)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, toBatchSaveOkrResponse(c, result))
+}
+
+func toBatchSaveOkrResponse(c *gin.Context, result *okr.SaveOkrResult) v1.BatchSaveOkrResponse {
+	resp := v1.BatchSaveOkrResponse{
+		ObjectiveID: okr.OPrefix + result.ObjectiveID,
+		KeyResults:  make([]v1.BatchSaveResult, 0, len(result.KeyResults)),
+	}
+	for _, r := range result.KeyResults {
+		item := v1.BatchSaveResult{}
+		if r.ID != "" {
+			item.ID = okr.KrPrefix + r.ID
+		}
+		if r.Err != nil {
+			log.C(c).Errorw("failed to save key result", "err", r.Err, "id", r.ID)
+			_, _, item.Error = errno.Decode(r.Err)
+		}
+		resp.KeyResults = append(resp.KeyResults, item)
+	}
+	return resp
+}
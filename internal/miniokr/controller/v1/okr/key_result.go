@@ -10,7 +10,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	ctrlV1 "github.com/imxw/miniokr/internal/miniokr/controller/v1"
 	"github.com/imxw/miniokr/internal/pkg/core"
 	"github.com/imxw/miniokr/internal/pkg/errno"
 	"github.com/imxw/miniokr/internal/pkg/known"
@@ -56,20 +55,6 @@ func (ctrl *Controller) CreateKeyResult(c *gin.Context) {
 	}
 
 	if req.UserId != "" {
-		// 校验权限
-		userID, ok := c.MustGet(known.XUserIDKey).(string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		roles, ok := c.MustGet(known.UserRolesKey).([]string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		if !ctrlV1.CheckPermission(c, userID, roles, req.UserId, ctrl.us) {
-			return
-		}
 		// 查询目标用户名
 		user, err := ctrl.us.GetUserByID(c, req.UserId)
 		if err != nil {
@@ -81,7 +66,11 @@ func (ctrl *Controller) CreateKeyResult(c *gin.Context) {
 		kr.Owner = username
 	}
 
-	id, err := ctrl.os.CreateKeyResult(c, kr)
+	svc, ok := ctrl.okrService(c, ownerID(c, req.UserId))
+	if !ok {
+		return
+	}
+	id, err := svc.CreateKeyResult(c, kr)
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 		return
@@ -134,20 +123,6 @@ func (ctrl *Controller) UpdateKeyResult(c *gin.Context) {
 	}
 
 	if req.UserId != "" {
-		// 校验权限
-		userID, ok := c.MustGet(known.XUserIDKey).(string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		roles, ok := c.MustGet(known.UserRolesKey).([]string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		if !ctrlV1.CheckPermission(c, userID, roles, req.UserId, ctrl.us) {
-			return
-		}
 		// 查询目标用户名
 		user, err := ctrl.us.GetUserByID(c, req.UserId)
 		if err != nil {
@@ -163,7 +138,12 @@ func (ctrl *Controller) UpdateKeyResult(c *gin.Context) {
 		kr.LeaderRating = req.LeaderRating
 	}
 
-	if err := ctrl.os.UpdateKeyResult(c, kr); err != nil {
+	// 记录保存在负责人所属公司的多维表格中，okr.Service 在修改前校验当前用户能否修改负责人的记录
+	svc, ok := ctrl.okrService(c, ownerID(c, req.UserId))
+	if !ok {
+		return
+	}
+	if err := svc.UpdateKeyResult(c, kr); err != nil {
 
 		core.WriteResponse(c, err, nil)
 		return
@@ -174,13 +154,23 @@ func (ctrl *Controller) UpdateKeyResult(c *gin.Context) {
 // 删除 KeyResult
 func (ctrl *Controller) DeleteKeyResult(c *gin.Context) {
 	log.C(c).Infow("DeleteKeyResult function Called")
-	var req v1.DeleteRecordReq
+	var req v1.DeleteKeyResultReq
 	if err := c.ShouldBindUri(&req); err != nil {
 		core.WriteResponse(c, errno.ErrBind, nil)
 		return
 	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+
+	// 记录保存在负责人所属公司的多维表格中，okr.Service 在删除前校验当前用户能否删除
+	svc, ok := ctrl.okrService(c, ownerID(c, req.UserId))
+	if !ok {
+		return
+	}
 
-	if err := ctrl.os.DeleteKeyResultByID(c, trimIDPrefix(req.ID)); err != nil {
+	if err := svc.DeleteKeyResultByID(c, trimIDPrefix(req.ID)); err != nil {
 		core.WriteResponse(c, err, nil)
 		return
 	}
@@ -68,7 +68,7 @@ func (ctrl *Controller) ListOkrByUsernameAndMonths(c *gin.Context) {
 			core.WriteResponse(c, errno.InternalServerError, nil)
 			return
 		}
-		if !ctrlV1.CheckPermission(c, userID, roles, req.UserID, ctrl.us) {
+		if !ctrlV1.CheckPermission(c, ctrl.az, ctrl.dg, model.DelegationScopeView, userID, roles, req.UserID, ctrl.us) {
 			return
 		}
 		owner = req.UserID
@@ -76,7 +76,7 @@ func (ctrl *Controller) ListOkrByUsernameAndMonths(c *gin.Context) {
 		owner = userID
 	}
 
-	objData, krData, err := ctrl.fetchData(c, owner, req.SortBy, req.OrderBy)
+	objData, krData, err := ctrl.fetchData(c, owner, filterMonths(req.Months), req.SortBy, req.OrderBy)
 	if err != nil {
 
 		if errors.Is(err, bitable.ErrInvalidUser) {
@@ -159,18 +159,36 @@ func (ctrl *Controller) validateMonths(reqMonths []string) ([]string, error) {
 	return months, nil
 }
 
-func (ctrl *Controller) fetchData(c *gin.Context, userid string, sortBy string, orderBy string) ([]model.Objective, []model.KeyResult, error) {
+// filterMonths 将请求中的月份统一为 "2006年1月" 格式，用于服务端过滤.
+// 无法识别时返回 nil，即不过滤，由 constructResponse 兜底
+func filterMonths(reqMonths []string) []string {
+	months := make([]string, 0, len(reqMonths))
+	for _, v := range reqMonths {
+		date, err := standardizeMonthFormat(v)
+		if err != nil {
+			return nil
+		}
+		months = append(months, date)
+	}
+	return months
+}
+
+func (ctrl *Controller) fetchData(c *gin.Context, userid string, months []string, sortBy string, orderBy string) ([]model.Objective, []model.KeyResult, error) {
 
 	user, err := ctrl.us.GetUserByID(c, userid)
 	if err != nil {
 		return nil, nil, err
 	}
+	svc, err := ctrl.os.ForUser(c, userid)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	objData, err := ctrl.os.ListObjectivesByOwner(c, user.Name, sortBy, orderBy)
+	objData, err := svc.ListObjectivesByOwner(c, user.Name, months, sortBy, orderBy)
 	if err != nil {
 		return nil, nil, err
 	}
-	krData, err := ctrl.os.ListKeyResultsByOwner(c, user.Name, sortBy, orderBy)
+	krData, err := svc.ListKeyResultsByOwner(c, user.Name, months, sortBy, orderBy)
 
 	if err != nil {
 		return nil, nil, err
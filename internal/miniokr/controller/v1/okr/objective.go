@@ -10,7 +10,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	ctrlV1 "github.com/imxw/miniokr/internal/miniokr/controller/v1"
 	"github.com/imxw/miniokr/internal/pkg/core"
 	"github.com/imxw/miniokr/internal/pkg/errno"
 	"github.com/imxw/miniokr/internal/pkg/known"
@@ -47,21 +46,6 @@ func (ctrl *Controller) CreateObjective(c *gin.Context) {
 	}
 
 	if req.UserId != "" {
-
-		// 校验权限
-		userID, ok := c.MustGet(known.XUserIDKey).(string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		roles, ok := c.MustGet(known.UserRolesKey).([]string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		if !ctrlV1.CheckPermission(c, userID, roles, req.UserId, ctrl.us) {
-			return
-		}
 		// 查询目标用户名
 		user, err := ctrl.us.GetUserByID(c, req.UserId)
 		if err != nil {
@@ -73,7 +57,11 @@ func (ctrl *Controller) CreateObjective(c *gin.Context) {
 		objective.Owner = username
 	}
 
-	id, err := ctrl.os.CreateObjective(c, objective)
+	svc, ok := ctrl.okrService(c, ownerID(c, req.UserId))
+	if !ok {
+		return
+	}
+	id, err := svc.CreateObjective(c, objective)
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 		return
@@ -117,20 +105,6 @@ func (ctrl *Controller) UpdateObjective(c *gin.Context) {
 	}
 
 	if req.UserId != "" {
-		// 校验权限
-		userID, ok := c.MustGet(known.XUserIDKey).(string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		roles, ok := c.MustGet(known.UserRolesKey).([]string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		if !ctrlV1.CheckPermission(c, userID, roles, req.UserId, ctrl.us) {
-			return
-		}
 		// 查询目标用户名
 		user, err := ctrl.us.GetUserByID(c, req.UserId)
 		if err != nil {
@@ -142,7 +116,12 @@ func (ctrl *Controller) UpdateObjective(c *gin.Context) {
 		objective.Owner = username
 	}
 
-	err := ctrl.os.UpdateObjective(c, objective)
+	// 记录保存在负责人所属公司的多维表格中，okr.Service 在修改前校验当前用户能否修改负责人的记录
+	svc, ok := ctrl.okrService(c, ownerID(c, req.UserId))
+	if !ok {
+		return
+	}
+	err := svc.UpdateObjective(c, objective)
 	if err != nil {
 		core.WriteResponse(c, err, nil)
 		return
@@ -166,32 +145,18 @@ func (ctrl *Controller) DeleteObjective(c *gin.Context) {
 		return
 	}
 
-	if req.UserId != "" {
-		// 校验权限
-		userID, ok := c.MustGet(known.XUserIDKey).(string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		roles, ok := c.MustGet(known.UserRolesKey).([]string)
-		if !ok {
-			core.WriteResponse(c, errno.InternalServerError, nil)
-			return
-		}
-		if !ctrlV1.CheckPermission(c, userID, roles, req.UserId, ctrl.us) {
-			return
-		}
-
-	}
-
-	// TODO: 更加精细地检查权限，如O或KR的owner是不是自己或自己的下属
-
 	trimmedIDs := make([]string, len(req.KeyResultIDs))
 	for i, id := range req.KeyResultIDs {
 		trimmedIDs[i] = trimIDPrefix(id)
 	}
 
-	if err := ctrl.os.DeleteObjectiveByID(c, trimIDPrefix(req.ID), trimmedIDs); err != nil {
+	// okr.Service 在删除前校验当前用户能否删除该目标及一并删除的关键结果
+	svc, ok := ctrl.okrService(c, ownerID(c, req.UserId))
+	if !ok {
+		return
+	}
+
+	if err := svc.DeleteObjectiveByID(c, trimIDPrefix(req.ID), trimmedIDs); err != nil {
 		core.WriteResponse(c, err, nil)
 		return
 	}
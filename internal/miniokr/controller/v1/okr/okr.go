@@ -6,17 +6,42 @@
 package okr
 
 import (
+	"github.com/gin-gonic/gin"
+
+	ctrlV1 "github.com/imxw/miniokr/internal/miniokr/controller/v1"
 	"github.com/imxw/miniokr/internal/miniokr/services/field"
 	"github.com/imxw/miniokr/internal/miniokr/services/okr"
 	"github.com/imxw/miniokr/internal/miniokr/services/user"
+	"github.com/imxw/miniokr/internal/pkg/core"
+	"github.com/imxw/miniokr/internal/pkg/known"
 )
 
 type Controller struct {
 	fs field.Service
-	os okr.Service
+	os okr.Resolver
 	us user.Service
+	az ctrlV1.Authorizer
+	dg ctrlV1.Delegator
+}
+
+func New(fs field.Service, os okr.Resolver, us user.Service, az ctrlV1.Authorizer, dg ctrlV1.Delegator) *Controller {
+	return &Controller{fs: fs, os: os, us: us, az: az, dg: dg}
+}
+
+// okrService 返回负责人 userID 所属公司的 okr.Service，失败时写入错误响应
+func (ctrl *Controller) okrService(c *gin.Context, userID string) (okr.Service, bool) {
+	svc, err := ctrl.os.ForUser(c, userID)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return nil, false
+	}
+	return svc, true
 }
 
-func New(fs field.Service, os okr.Service, us user.Service) *Controller {
-	return &Controller{fs: fs, os: os, us: us}
+// ownerID 返回请求的 OKR 负责人，请求中未指定时为当前用户
+func ownerID(c *gin.Context, reqUserID string) string {
+	if reqUserID != "" {
+		return reqUserID
+	}
+	return c.GetString(known.XUserIDKey)
 }
@@ -0,0 +1,353 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package okr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+
+	ctrlV1 "github.com/imxw/miniokr/internal/miniokr/controller/v1"
+	"github.com/imxw/miniokr/internal/miniokr/services/okr"
+	"github.com/imxw/miniokr/internal/miniokr/services/user"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// fakeOkrService 只实现修改前校验负责人需要的方法
+type fakeOkrService struct {
+	okr.Service
+	objectives map[string]model.Objective
+	krs        map[string]model.KeyResult
+	deleted    []string
+	saved      []string
+}
+
+func (f *fakeOkrService) ForUser(ctx context.Context, userID string) (okr.Service, error) {
+	return f, nil
+}
+
+// fakeTenants 模拟多公司部署，subsidiary 中的用户的 OKR 保存在子公司的多维表格中
+type fakeTenants struct {
+	group      *fakeOkrService
+	subsidiary *fakeOkrService
+	members    map[string]bool
+}
+
+func (f *fakeTenants) ForUser(ctx context.Context, userID string) (okr.Service, error) {
+	if f.members[userID] {
+		return f.subsidiary, nil
+	}
+	return f.group, nil
+}
+
+func (f *fakeOkrService) GetObjective(ctx context.Context, id string) (*model.Objective, error) {
+	o, ok := f.objectives[id]
+	if !ok {
+		return nil, errno.ErrRecordNotFound
+	}
+	return &o, nil
+}
+
+func (f *fakeOkrService) GetKeyResult(ctx context.Context, id string) (*model.KeyResult, error) {
+	kr, ok := f.krs[id]
+	if !ok {
+		return nil, errno.ErrRecordNotFound
+	}
+	return &kr, nil
+}
+
+func (f *fakeOkrService) CreateObjective(ctx context.Context, o model.Objective) (string, error) {
+	f.saved = append(f.saved, o.Owner)
+	return "recNew", nil
+}
+
+func (f *fakeOkrService) UpdateObjective(ctx context.Context, o model.Objective) error {
+	f.saved = append(f.saved, o.ID)
+	return nil
+}
+
+func (f *fakeOkrService) CreateKeyResult(ctx context.Context, kr model.KeyResult) (string, error) {
+	f.saved = append(f.saved, kr.Owner)
+	return "recNew", nil
+}
+
+func (f *fakeOkrService) UpdateKeyResult(ctx context.Context, kr model.KeyResult) error {
+	f.saved = append(f.saved, kr.ID)
+	return nil
+}
+
+func (f *fakeOkrService) DeleteObjectiveByID(ctx context.Context, id string, krIDs []string) error {
+	f.deleted = append(append(f.deleted, id), krIDs...)
+	return nil
+}
+
+func (f *fakeOkrService) DeleteKeyResultByID(ctx context.Context, id string) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+// fakeUserService 中 leader 管理 alice 和 sam1 所在部门，sam1 和 sam2 同名
+type fakeUserService struct {
+	user.Service
+}
+
+var testUsers = map[string][]string{
+	"Alice":  {"alice"},
+	"Bob":    {"bob"},
+	"Leader": {"leader"},
+	"Admin":  {"admin"},
+	"Deputy": {"deputy"},
+	"Sam":    {"sam1", "sam2"},
+}
+
+func (fakeUserService) GetUserByID(ctx context.Context, id string) (*v1.UserResponse, error) {
+	for name, ids := range testUsers {
+		for _, userID := range ids {
+			if userID == id {
+				return &v1.UserResponse{UserID: id, Name: name}, nil
+			}
+		}
+	}
+	return nil, errno.ErrUserNotFound
+}
+
+func (fakeUserService) GetUserIDsByName(ctx context.Context, name string) ([]string, error) {
+	return testUsers[name], nil
+}
+
+func (fakeUserService) GetManagedUserIDs(ctx context.Context, userID string) ([]string, error) {
+	if userID == "leader" {
+		return []string{"leader", "alice", "sam1"}, nil
+	}
+	return nil, nil
+}
+
+// fakeAuthorizer 只允许 admin 访问任何用户的 OKR
+type fakeAuthorizer struct{}
+
+func (fakeAuthorizer) AuthorizeRoles(roles []string, obj, act string) (bool, error) {
+	for _, role := range roles {
+		if role == known.AdminRoleName && obj == known.AnyUserOkrObject {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeDelegator 中 leader 将 alice 的查看和评分权限委托给 deputy
+type fakeDelegator struct{}
+
+func (fakeDelegator) FindDelegation(ctx context.Context, granteeID, targetUserID, scope string) (*model.Delegation, error) {
+	if granteeID == "deputy" && targetUserID == "alice" {
+		return &model.Delegation{ID: 1, GrantorID: "leader", GranteeID: "deputy", Scope: model.DelegationScopeRate}, nil
+	}
+	return nil, nil
+}
+
+func (fakeDelegator) RecordAction(ctx context.Context, d *model.Delegation, targetUserID, method, path string) error {
+	return nil
+}
+
+// ownerCheckedResolver 与 tenant.OkrService 一致，返回修改前校验负责人的 okr.Service
+type ownerCheckedResolver struct {
+	okr.Resolver
+}
+
+func (r ownerCheckedResolver) ForUser(ctx context.Context, userID string) (okr.Service, error) {
+	svc, err := r.Resolver.ForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	owners := ctrlV1.NewOwnerAuthorizer(fakeAuthorizer{}, fakeDelegator{}, fakeUserService{})
+	return okr.NewOwnerCheckedService(svc, owners), nil
+}
+
+func newTestRouter(os okr.Resolver, userID, username string, roles ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		_ = v.RegisterValidation("monthYearFormat", func(validator.FieldLevel) bool { return true })
+	}
+	ctrl := New(nil, ownerCheckedResolver{os}, fakeUserService{}, fakeAuthorizer{}, fakeDelegator{})
+
+	g := gin.New()
+	g.Use(func(c *gin.Context) {
+		c.Set(known.XUserIDKey, userID)
+		c.Set(known.XUsernameKey, username)
+		c.Set(known.UserRolesKey, roles)
+	})
+	g.POST("/objectives", ctrl.CreateObjective)
+	g.PUT("/objectives/:id", ctrl.UpdateObjective)
+	g.POST("/keyresults", ctrl.CreateKeyResult)
+	g.PUT("/keyresults/:id", ctrl.UpdateKeyResult)
+	g.DELETE("/objectives/:id", ctrl.DeleteObjective)
+	g.DELETE("/keyresults/:id", ctrl.DeleteKeyResult)
+	return g
+}
+
+func newFakeOkrService() *fakeOkrService {
+	return &fakeOkrService{
+		objectives: map[string]model.Objective{
+			"recO1": {ID: "o-recO1", Owner: "Alice", KrsIds: []string{"kr-recKR1"}},
+			"recO2": {ID: "o-recO2", Owner: "Sam"},
+		},
+		krs: map[string]model.KeyResult{
+			"recKR1": {ID: "kr-recKR1", Owner: "Alice"},
+			"recKR2": {ID: "kr-recKR2", Owner: "Bob"},
+			"recKR3": {ID: "kr-recKR3", Owner: "Departed"},
+			"recKR4": {ID: "kr-recKR4", Owner: "Sam"},
+		},
+	}
+}
+
+func TestDeleteKeyResultOwnership(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		username string
+		roles    []string
+		id       string
+		expect   int
+	}{
+		{"owner", "alice", "Alice", nil, "kr-recKR1", http.StatusOK},
+		{"leader of owner", "leader", "Leader", []string{known.LeaderRoleName}, "kr-recKR1", http.StatusOK},
+		{"admin", "admin", "Admin", []string{known.AdminRoleName}, "kr-recKR2", http.StatusOK},
+		{"admin on unknown owner", "admin", "Admin", []string{known.AdminRoleName}, "kr-recKR3", http.StatusOK},
+		{"other member", "bob", "Bob", nil, "kr-recKR1", http.StatusForbidden},
+		{"leader of other department", "leader", "Leader", []string{known.LeaderRoleName}, "kr-recKR2", http.StatusForbidden},
+		{"member on unknown owner", "bob", "Bob", nil, "kr-recKR3", http.StatusForbidden},
+		// 负责人名称对应多个同名用户时，同名用户和管理其中之一的主管都可以删除
+		{"same name on ambiguous owner", "sam2", "Sam", nil, "kr-recKR4", http.StatusOK},
+		{"leader of one ambiguous owner", "leader", "Leader", []string{known.LeaderRoleName}, "kr-recKR4", http.StatusOK},
+		{"other member on ambiguous owner", "bob", "Bob", nil, "kr-recKR4", http.StatusForbidden},
+		{"admin on ambiguous owner", "admin", "Admin", []string{known.AdminRoleName}, "kr-recKR4", http.StatusOK},
+		// 委托只包含查看和评分，不能代为删除
+		{"delegate", "deputy", "Deputy", []string{known.DelegateRoleName}, "kr-recKR1", http.StatusForbidden},
+		{"missing record", "alice", "Alice", nil, "kr-recMissing", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os := newFakeOkrService()
+			g := newTestRouter(os, tt.userID, tt.username, tt.roles...)
+
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/keyresults/"+tt.id, nil))
+
+			assert.Equal(t, tt.expect, w.Code)
+			if tt.expect == http.StatusOK {
+				assert.Equal(t, []string{trimIDPrefix(tt.id)}, os.deleted)
+			} else {
+				assert.Empty(t, os.deleted)
+			}
+		})
+	}
+}
+
+func TestDeleteObjectiveOwnership(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		username string
+		roles    []string
+		body     string
+		expect   int
+	}{
+		{"owner", "alice", "Alice", nil, `{"keyResultIds":["kr-recKR1"]}`, http.StatusOK},
+		{"leader of owner", "leader", "Leader", []string{known.LeaderRoleName}, `{"keyResultIds":["kr-recKR1"]}`, http.StatusOK},
+		{"admin", "admin", "Admin", []string{known.AdminRoleName}, `{"keyResultIds":["kr-recKR2"]}`, http.StatusOK},
+		{"other member", "bob", "Bob", nil, `{}`, http.StatusForbidden},
+		{"delegate", "deputy", "Deputy", []string{known.DelegateRoleName}, `{"keyResultIds":["kr-recKR1"]}`, http.StatusForbidden},
+		// 不属于该目标的他人关键结果不能随目标一起删除
+		{"owner with other's key result", "alice", "Alice", nil, `{"keyResultIds":["kr-recKR1","kr-recKR2"]}`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os := newFakeOkrService()
+			g := newTestRouter(os, tt.userID, tt.username, tt.roles...)
+
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/objectives/o-recO1", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expect, w.Code)
+			if tt.expect == http.StatusOK {
+				assert.Equal(t, "recO1", os.deleted[0])
+			} else {
+				assert.Empty(t, os.deleted)
+			}
+		})
+	}
+}
+
+func TestDeleteKeyResultAcrossTenants(t *testing.T) {
+	// alice 属于子公司，leader 和 admin 属于集团
+	tenants := &fakeTenants{
+		group:      &fakeOkrService{},
+		subsidiary: newFakeOkrService(),
+		members:    map[string]bool{"alice": true},
+	}
+	g := newTestRouter(tenants, "leader", "Leader", known.LeaderRoleName)
+
+	// 未指定负责人时在当前用户所属公司中查找
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/keyresults/kr-recKR1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/keyresults/kr-recKR1?userId=alice", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"recKR1"}, tenants.subsidiary.deleted)
+	assert.Empty(t, tenants.group.deleted)
+}
+
+func TestSameNameOwner(t *testing.T) {
+	// sam1 和 sam2 同名，新建和修改自己名下的记录时负责人名称对应两个用户
+	tests := []struct {
+		name   string
+		userID string
+		method string
+		path   string
+		body   string
+		expect int
+	}{
+		{"create objective", "sam2", http.MethodPost, "/objectives", `{"title":"O","date":"2024年5月"}`, http.StatusOK},
+		{"create key result", "sam1", http.MethodPost, "/keyresults",
+			`{"title":"KR","date":"2024年5月","weight":50,"completed":"未开始"}`, http.StatusOK},
+		{"update objective", "sam2", http.MethodPut, "/objectives/o-recO2", `{"title":"O","date":"2024年5月"}`, http.StatusOK},
+		{"update key result", "sam1", http.MethodPut, "/keyresults/kr-recKR4",
+			`{"title":"KR","date":"2024年5月","weight":50,"completed":"未开始"}`, http.StatusOK},
+		// 同名用户不能把他人的记录改到自己名下
+		{"update other's key result", "sam2", http.MethodPut, "/keyresults/kr-recKR2",
+			`{"title":"KR","date":"2024年5月","weight":50,"completed":"未开始"}`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os := newFakeOkrService()
+			g := newTestRouter(os, tt.userID, "Sam")
+
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expect, w.Code)
+			if tt.expect == http.StatusOK {
+				assert.Len(t, os.saved, 1)
+			} else {
+				assert.Empty(t, os.saved)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package policy
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/imxw/miniokr/internal/pkg/core"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+	authpkg "github.com/imxw/miniokr/pkg/auth"
+)
+
+// Controller 处理授权策略的管理接口，路由需要 admin 角色
+type Controller struct {
+	a *authpkg.Authz
+}
+
+func New(a *authpkg.Authz) *Controller {
+	return &Controller{a: a}
+}
+
+// List 处理 `GET /api/v1/admin/policies` 请求，返回全部授权策略.
+func (ctrl *Controller) List(c *gin.Context) {
+	rules, err := ctrl.a.GetPolicy()
+	if err != nil {
+		log.C(c).Errorw("Failed to list policies", "err", err)
+		core.WriteResponse(c, errno.InternalServerError, nil)
+		return
+	}
+
+	policies := make([]v1.Policy, 0, len(rules))
+	for _, rule := range rules {
+		if len(rule) < 3 {
+			continue
+		}
+		policies = append(policies, v1.Policy{Role: rule[0], Path: rule[1], Method: rule[2]})
+	}
+	core.WriteResponse(c, nil, v1.ListPolicyResponse{Policies: policies})
+}
+
+// Create 处理 `POST /api/v1/admin/policies` 请求，添加授权策略.
+func (ctrl *Controller) Create(c *gin.Context) {
+	var r v1.Policy
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+	log.C(c).Infow("Create policy function called", "policy", r, "operator", c.GetString(known.XUserIDKey))
+
+	added, err := ctrl.a.AddPolicy(r.Role, r.Path, r.Method)
+	if err != nil {
+		log.C(c).Errorw("Failed to add policy", "err", err)
+		core.WriteResponse(c, errno.InternalServerError, nil)
+		return
+	}
+	if !added {
+		core.WriteResponse(c, errno.ErrPolicyAlreadyExist, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
+
+// Delete 处理 `DELETE /api/v1/admin/policies` 请求，删除授权策略.
+func (ctrl *Controller) Delete(c *gin.Context) {
+	var r v1.Policy
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+	log.C(c).Infow("Delete policy function called", "policy", r, "operator", c.GetString(known.XUserIDKey))
+
+	removed, err := ctrl.a.RemovePolicy(r.Role, r.Path, r.Method)
+	if err != nil {
+		log.C(c).Errorw("Failed to remove policy", "err", err)
+		core.WriteResponse(c, errno.InternalServerError, nil)
+		return
+	}
+	if !removed {
+		core.WriteResponse(c, errno.ErrPolicyNotFound, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
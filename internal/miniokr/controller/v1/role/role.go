@@ -0,0 +1,96 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package role
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/role"
+	"github.com/imxw/miniokr/internal/pkg/core"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// Controller 处理角色和用户角色的管理接口，路由需要 admin 角色
+type Controller struct {
+	rs *role.RoleService
+}
+
+func New(rs *role.RoleService) *Controller {
+	return &Controller{rs: rs}
+}
+
+// List 处理 `GET /api/v1/admin/roles` 请求，返回全部角色.
+func (ctrl *Controller) List(c *gin.Context) {
+	roles, err := ctrl.rs.ListRoles(c)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, v1.ListRoleResponse{Roles: roles})
+}
+
+// Create 处理 `POST /api/v1/admin/roles` 请求，创建角色.
+func (ctrl *Controller) Create(c *gin.Context) {
+	var r v1.CreateRoleRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+	log.C(c).Infow("Create role function called", "role", r.Name, "operator", c.GetString(known.XUserIDKey))
+
+	if err := ctrl.rs.CreateRole(c, r.Name); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
+
+// ListUserRoles 处理 `GET /api/v1/admin/users/:id/roles` 请求，返回用户的角色.
+func (ctrl *Controller) ListUserRoles(c *gin.Context) {
+	roles, err := ctrl.rs.ListUserRoles(c, c.Param("id"))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, v1.ListUserRoleResponse{Roles: roles})
+}
+
+// AssignUserRole 处理 `POST /api/v1/admin/users/:id/roles` 请求，手动授予用户角色.
+func (ctrl *Controller) AssignUserRole(c *gin.Context) {
+	var r v1.AssignUserRoleRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errno.ErrBind, nil)
+		return
+	}
+
+	if err := ctrl.rs.AssignUserRole(c, c.GetString(known.XUserIDKey), c.Param("id"), r.Role); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
+
+// RemoveUserRole 处理 `DELETE /api/v1/admin/users/:id/roles/:role` 请求，撤销用户手动授予的角色.
+func (ctrl *Controller) RemoveUserRole(c *gin.Context) {
+	if err := ctrl.rs.RemoveUserRole(c, c.GetString(known.XUserIDKey), c.Param("id"), c.Param("role")); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, nil)
+}
+
+// ListRoleChanges 处理 `GET /api/v1/admin/users/:id/roles/changes` 请求，返回用户的角色变更记录.
+func (ctrl *Controller) ListRoleChanges(c *gin.Context) {
+	changes, err := ctrl.rs.ListRoleChanges(c, c.Param("id"))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	core.WriteResponse(c, nil, v1.ListRoleChangeResponse{Changes: changes})
+}
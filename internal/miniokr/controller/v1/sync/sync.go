@@ -2,10 +2,12 @@ package sync
 
 import (
 	"context"
+	"errors"
 
 	"github.com/robfig/cron/v3"
 
 	"github.com/imxw/miniokr/internal/miniokr/services/sync"
+	"github.com/imxw/miniokr/internal/pkg/log"
 )
 
 type Controller struct {
@@ -24,3 +26,42 @@ func (c *Controller) StartCronJob() {
 	})
 	cronScheduler.Start()
 }
+
+// defaultOkrSyncSchedule 是未配置 okr.sync.schedule 时的同步周期
+const defaultOkrSyncSchedule = "*/10 * * * *"
+
+// OkrSyncController 负责定时执行本地 OKR 与飞书多维表格之间的同步
+type OkrSyncController struct {
+	okrSyncer sync.OkrSyncer
+	mode      string
+	schedule  string
+}
+
+func NewOkrSyncController(okrSyncer sync.OkrSyncer, mode, schedule string) *OkrSyncController {
+	if schedule == "" {
+		schedule = defaultOkrSyncSchedule
+	}
+	return &OkrSyncController{okrSyncer: okrSyncer, mode: mode, schedule: schedule}
+}
+
+// StartCronJob 定时执行同步. 上一次同步未结束时跳过本次，
+// 多副本部署时由 OkrSyncer 的同步租约保证同一时间只有一个副本在同步
+func (c *OkrSyncController) StartCronJob() error {
+	cronScheduler := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+	_, err := cronScheduler.AddFunc(c.schedule, func() {
+		ctx := context.Background()
+		_, err := c.okrSyncer.Sync(ctx, c.mode)
+		if errors.Is(err, sync.ErrOkrSyncInProgress) {
+			log.Infow("OKR sync is running on another instance, skipped", "mode", c.mode)
+			return
+		}
+		if err != nil {
+			log.Errorw("OKR sync job failed", "mode", c.mode, "err", err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	cronScheduler.Start()
+	return nil
+}
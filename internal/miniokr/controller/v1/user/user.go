@@ -88,7 +88,8 @@ func (ctrl *Controller) GetDepartmentsTree(c *gin.Context) {
 		core.WriteResponse(c, nil, tree)
 
 	} else if ctrlV1.Contains(roles, known.LeaderRoleName) {
-		tree, err := ctrl.us.GetUserDepartmentTree(c, userID)
+		// 主管只能看到管理范围内的部门，与查看下属 OKR 的权限一致
+		tree, err := ctrl.us.GetManagedDepartmentTree(c, userID)
 		if err != nil {
 			core.WriteResponse(c, errno.InternalServerError, nil)
 			return
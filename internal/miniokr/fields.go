@@ -0,0 +1,148 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package miniokr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable/field"
+	larkToken "github.com/imxw/miniokr/internal/pkg/bitable/token"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// maxPrintedOptions 是 inspect 输出中每个字段最多展示的选项数.
+const maxPrintedOptions = 5
+
+// newFieldsCommand 创建 `miniokr fields` 命令，用于查看飞书多维表格的字段.
+func newFieldsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fields",
+		Short: "Inspect the fields of the Feishu Bitable tables",
+	}
+	cmd.AddCommand(newFieldsInspectCommand())
+	return cmd
+}
+
+// newFieldsInspectCommand 创建 `miniokr fields inspect` 命令.
+func newFieldsInspectCommand() *cobra.Command {
+	var tenantName string
+
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Print the schema of the configured tables and how fields are bound",
+		Long: `Print every field of the configured objective and key result tables,
+together with how each miniokr field is bound to them.
+
+Fields that cannot be resolved can be bound by field name or field ID under
+feishu.fields (or feishu.tenants[].fields), for example:
+
+	feishu:
+	  fields:
+	    objective:
+	      date: 考核月份`,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return inspectFields(cmd.Context(), cmd.OutOrStdout(), tenantName)
+		},
+	}
+
+	cmd.Flags().StringVar(&tenantName, "tenant", "", "Only inspect the tenant with this name.")
+
+	return cmd
+}
+
+// inspectFields 输出每个公司两张表的字段和字段绑定的解析结果.
+func inspectFields(ctx context.Context, w io.Writer, tenantName string) error {
+	configs, err := feishuTenantConfigs()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, cfg := range configs {
+		if tenantName != "" && cfg.Name != tenantName {
+			continue
+		}
+		found = true
+
+		// 命令行只运行一次，令牌保存在内存中，不影响服务使用的令牌文件
+		client := lark.NewClient(cfg.AppID, cfg.AppSecret)
+		tm := larkToken.NewManager(&larkToken.LarkTokenService{Client: client}, larkToken.NewMemoryStorage(),
+			larkToken.NewRealClock(), cfg.AppID, cfg.AppSecret)
+		fm := newFieldManager(client, tm, newFeishuInvoker(), cfg)
+		fm.SetCache(field.NewMemoryCache())
+
+		prefix := "feishu.fields"
+		if viper.IsSet("feishu.tenants") {
+			prefix = fmt.Sprintf("feishu.tenants[%d].fields", i)
+		}
+
+		tables := []struct {
+			key, title, tableID string
+			schema              interface{}
+		}{
+			{"objective", "目标表", cfg.OTableID, &v1.ObjectiveField{}},
+			{"key-result", "关键结果表", cfg.KrTableID, &v1.KeyResultField{}},
+		}
+		for _, t := range tables {
+			fields, err := fm.ListFields(ctx, t.tableID)
+			if err != nil {
+				return fmt.Errorf("failed to list fields of table %s: %w", t.tableID, err)
+			}
+
+			fmt.Fprintf(w, "== %s %s %s ==\n", cfg.Name, t.title, t.tableID)
+			printFields(w, fields)
+			fmt.Fprintf(w, "\n字段绑定 (%s.%s):\n", prefix, t.key)
+			printResolutions(w, field.Resolve(fields, t.schema, fm.Binding(t.tableID)))
+			fmt.Fprintln(w)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("tenant %q not found", tenantName)
+	}
+	return nil
+}
+
+// printFields 以表格形式输出字段列表.
+func printFields(w io.Writer, fields []field.Field) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "字段 ID\t字段名\t类型\t选项")
+	for _, f := range fields {
+		names := make([]string, 0, len(f.Options))
+		for _, opt := range f.Options {
+			names = append(names, opt.Name)
+		}
+		if len(names) > maxPrintedOptions {
+			names = append(names[:maxPrintedOptions], fmt.Sprintf("...(共 %d 个)", len(f.Options)))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.FieldID, f.FieldName, field.TypeName(f.Type), strings.Join(names, ", "))
+	}
+	_ = tw.Flush()
+}
+
+// printResolutions 以表格形式输出字段绑定的解析结果，未找到的字段需要在配置中重新绑定.
+func printResolutions(w io.Writer, resolutions []field.Resolution) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\t绑定\t字段 ID\t字段名")
+	for _, r := range resolutions {
+		if r.Field == nil {
+			fmt.Fprintf(tw, "%s\t%s\t-\t(未找到)\n", r.Key, r.Ref)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Key, r.Ref, r.Field.FieldID, r.Field.FieldName)
+	}
+	_ = tw.Flush()
+}
@@ -6,22 +6,41 @@
 package miniokr
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/zhaoyunxing92/dingtalk/v2"
 	"gorm.io/gorm"
 
+	"github.com/imxw/miniokr/internal/miniokr/services/auth"
+	"github.com/imxw/miniokr/internal/miniokr/services/event"
+	fs "github.com/imxw/miniokr/internal/miniokr/services/field"
 	"github.com/imxw/miniokr/internal/miniokr/services/notify"
+	okrs "github.com/imxw/miniokr/internal/miniokr/services/okr"
 	"github.com/imxw/miniokr/internal/miniokr/services/sync"
+	"github.com/imxw/miniokr/internal/miniokr/services/tenant"
 	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/bitable"
+	"github.com/imxw/miniokr/internal/pkg/bitable/field"
+	larkToken "github.com/imxw/miniokr/internal/pkg/bitable/token"
+	"github.com/imxw/miniokr/internal/pkg/known"
 	"github.com/imxw/miniokr/internal/pkg/log"
+	"github.com/imxw/miniokr/internal/pkg/retry"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+	authpkg "github.com/imxw/miniokr/pkg/auth"
 	"github.com/imxw/miniokr/pkg/db"
+	"github.com/imxw/miniokr/pkg/token"
 )
 
 const (
@@ -30,6 +49,27 @@ const (
 
 	// defaultConfigName 指定了 miniokr 服务的默认配置文件名.
 	defaultConfigName = "miniokr.yaml"
+
+	// okrBackendFeishu 表示 OKR 数据存储在飞书多维表格中.
+	okrBackendFeishu = "feishu"
+
+	// okrBackendLocal 表示 OKR 数据存储在本地数据库中.
+	okrBackendLocal = "local"
+
+	// tokenStorageFile 表示飞书令牌保存在本地文件中.
+	tokenStorageFile = "file"
+
+	// tokenStorageDB 表示飞书令牌保存在数据库中.
+	tokenStorageDB = "db"
+
+	// fieldCacheFile 表示字段映射缓存在本地文件中.
+	fieldCacheFile = "file"
+
+	// fieldCacheMemory 表示字段映射只缓存在内存中.
+	fieldCacheMemory = "memory"
+
+	// fieldCacheDB 表示字段映射缓存在数据库中.
+	fieldCacheDB = "db"
 )
 
 // initConfig 设置需要读取的配置文件名、环境变量，并读取配置文件内容到 viper 中.
@@ -162,3 +202,500 @@ func initSyncService(db *gorm.DB, dingClient *dingtalk.DingTalk) (*sync.SyncServ
 
 	return syncService, nil
 }
+
+// initOkrServices 根据 `okr.backend` 配置初始化各公司的 Field 服务和 Okr 服务，返回按部门路由的 Router，
+// 以及开启 `feishu.event.enabled` 时按 app ID 索引的飞书事件处理器.
+func initOkrServices(ctx context.Context) (*tenant.Router, map[string]*dispatcher.EventDispatcher, error) {
+	var tenants []*tenant.Tenant
+	events := make(map[string]*dispatcher.EventDispatcher)
+
+	backend := viper.GetString("okr.backend")
+	switch backend {
+	case "", okrBackendFeishu:
+		configs, err := feishuTenantConfigs()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, cfg := range configs {
+			fieldService, okrService, err := initFeishuServices(ctx, cfg)
+			if err != nil {
+				return nil, nil, err
+			}
+			if viper.GetBool("feishu.event.enabled") {
+				if events[cfg.AppID], err = initFeishuEvents(ctx, cfg, fieldService.FieldManager); err != nil {
+					return nil, nil, err
+				}
+			}
+			tenants = append(tenants, &tenant.Tenant{
+				Name:          cfg.Name,
+				DepartmentIDs: cfg.Departments,
+				FieldService:  fieldService,
+				OkrService:    okrService,
+			})
+		}
+	case okrBackendLocal:
+		okrService, err := okrs.NewLocalOkrService(store.S.Okrs())
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Infow("Using local okr backend")
+		tenants = append(tenants, &tenant.Tenant{
+			Name:         viper.GetString("company-name"),
+			FieldService: fs.NewLocalFieldService(),
+			OkrService:   okrService,
+		})
+	default:
+		return nil, nil, fmt.Errorf("unsupported okr backend: %q", backend)
+	}
+
+	router, err := tenant.NewRouter(store.S.Users(), tenants)
+	return router, events, err
+}
+
+// feishuTenantConfig 是一个公司的飞书多维表格配置.
+type feishuTenantConfig struct {
+	Name          string `mapstructure:"name"`
+	Departments   []int  `mapstructure:"departments"`
+	AppID         string `mapstructure:"app-id"`
+	AppSecret     string `mapstructure:"app-secret"`
+	AppToken      string `mapstructure:"app-token"`
+	OTableID      string `mapstructure:"o-table-id"`
+	KrTableID     string `mapstructure:"kr-table-id"`
+	TokenFilePath string `mapstructure:"token-file-path"`
+	// Fields 按字段 ID 或字段名声明两张表的字段绑定，未声明的字段使用 v1.ObjectiveField 等结构体 field 标签中的字段 ID
+	Fields feishuFieldsConfig `mapstructure:"fields"`
+	// Event 是飞书开发者后台「事件订阅」中的加密配置
+	Event feishuEventConfig `mapstructure:"event"`
+}
+
+// feishuEventConfig 是校验和解密飞书事件回调所需的配置.
+type feishuEventConfig struct {
+	VerificationToken string `mapstructure:"verification-token"`
+	EncryptKey        string `mapstructure:"encrypt-key"`
+}
+
+// feishuFieldsConfig 是目标表和关键结果表的字段绑定，key 为 v1.ObjectiveField 等结构体字段的 json 名.
+type feishuFieldsConfig struct {
+	Objective map[string]string `mapstructure:"objective"`
+	KeyResult map[string]string `mapstructure:"key-result"`
+}
+
+// feishuTenantConfigs 读取 `feishu.tenants` 中的多公司配置，未配置时使用 `feishu` 下的单个公司配置.
+func feishuTenantConfigs() ([]feishuTenantConfig, error) {
+	if !viper.IsSet("feishu.tenants") {
+		return []feishuTenantConfig{{
+			Name:          viper.GetString("company-name"),
+			AppID:         viper.GetString("feishu.app-id"),
+			AppSecret:     viper.GetString("feishu.app-secret"),
+			AppToken:      viper.GetString("feishu.app-token"),
+			OTableID:      viper.GetString("feishu.o-table-id"),
+			KrTableID:     viper.GetString("feishu.kr-table-id"),
+			TokenFilePath: viper.GetString("feishu.token.file-path"),
+			Fields: feishuFieldsConfig{
+				Objective: viper.GetStringMapString("feishu.fields.objective"),
+				KeyResult: viper.GetStringMapString("feishu.fields.key-result"),
+			},
+			Event: feishuEventConfig{
+				VerificationToken: viper.GetString("feishu.event.verification-token"),
+				EncryptKey:        viper.GetString("feishu.event.encrypt-key"),
+			},
+		}}, nil
+	}
+
+	var configs []feishuTenantConfig
+	if err := viper.UnmarshalKey("feishu.tenants", &configs); err != nil {
+		return nil, fmt.Errorf("invalid feishu.tenants: %w", err)
+	}
+	if len(configs) == 0 {
+		return nil, errors.New("feishu.tenants is empty")
+	}
+
+	names := make(map[string]bool, len(configs))
+	for i := range configs {
+		cfg := &configs[i]
+		if cfg.Name == "" || names[cfg.Name] {
+			return nil, fmt.Errorf("feishu.tenants[%d]: name is empty or duplicated", i)
+		}
+		names[cfg.Name] = true
+
+		// 多个公司使用文件保存令牌时，按 app ID 区分文件
+		if cfg.TokenFilePath == "" {
+			cfg.TokenFilePath = fmt.Sprintf("tenant_access_token_%s.json", cfg.AppID)
+		}
+	}
+
+	return configs, nil
+}
+
+// initFeishuServices 初始化一个公司基于飞书多维表格的 Field 服务和 Okr 服务.
+// ctx 决定后台令牌刷新任务的生命周期，应在服务退出时取消.
+func initFeishuServices(ctx context.Context, cfg feishuTenantConfig) (*fs.FeishuFieldService, *okrs.FeishuOkrService, error) {
+	client, fm, err := feishuAppFor(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fieldCache, err := newFieldCache(cfg.AppToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	invoker := feishuInvokerFor(cfg.AppToken)
+	fieldManager := newFieldManager(client, fm, invoker, cfg)
+	fieldManager.SetCache(fieldCache)
+	// 字段被删除、重建或改名导致绑定失效时通过钉钉告警
+	fieldManager.SetNotifier(notify.NewDingTalkNotifier(viper.GetString("dingtalk.webhook-url")))
+
+	// 初始化缓存，按字段绑定解析并校验表结构，字段未能绑定时启动失败，避免以空字段名写入
+	for _, tableID := range []string{cfg.OTableID, cfg.KrTableID} {
+		if err := fieldManager.LoadOrRefreshFieldMapping(ctx, tableID); err != nil {
+			return nil, nil, fmt.Errorf("load field mapping of tenant %q: %w", cfg.Name, err)
+		}
+	}
+
+	// 初始化Field服务
+	fieldService, err := fs.NewFeishufieldService(cfg.OTableID, cfg.KrTableID, fieldManager)
+	if err != nil {
+		log.Errorw("Failed to Initialize Feishufieldservice", "error", err, "tenant", cfg.Name)
+		return nil, nil, err
+	}
+
+	rm := bitable.NewRecordManager(client, cfg.AppToken, fm)
+	rm.SetInvoker(invoker)
+	rm.SetSearchOptions(
+		bitable.WithPageSize(viper.GetInt("feishu.search.page-size")),
+		bitable.WithMaxPages(viper.GetInt("feishu.search.max-pages")),
+	)
+	// 初始化Okr服务
+	okrService, err := okrs.NewFeishuOkrService(cfg.OTableID, cfg.KrTableID, fieldManager, rm)
+	if err != nil {
+		log.Errorw("Failed to Initialize FeishuOkrService", "error", err, "tenant", cfg.Name)
+		return nil, nil, err
+	}
+
+	return fieldService, okrService, nil
+}
+
+// feishuApp 是一个飞书应用的客户端和令牌管理器.
+type feishuApp struct {
+	client *lark.Client
+	tokens *larkToken.Manager
+}
+
+// feishuApps 按 app ID 缓存已初始化的飞书应用，feishuInvokers 按多维表格 app token 缓存限流器.
+// 同一应用的各公司和 OKR 同步任务共用令牌和调用配额，只在启动时初始化，无需加锁
+var (
+	feishuApps     = make(map[string]*feishuApp)
+	feishuInvokers = make(map[string]*bitable.Invoker)
+)
+
+// feishuAppFor 返回 cfg 对应飞书应用的客户端和令牌管理器，首次调用时初始化令牌并启动后台刷新.
+// ctx 决定后台令牌刷新任务的生命周期，应在服务退出时取消.
+func feishuAppFor(ctx context.Context, cfg feishuTenantConfig) (*lark.Client, *larkToken.Manager, error) {
+	if app, ok := feishuApps[cfg.AppID]; ok {
+		return app.client, app.tokens, nil
+	}
+
+	client := lark.NewClient(cfg.AppID, cfg.AppSecret)
+
+	feishuToken := &larkToken.LarkTokenService{Client: client}
+
+	tokenStore, err := newTokenStorage(cfg.AppID)
+	if err != nil {
+		return nil, nil, err
+	}
+	clock := larkToken.NewRealClock()
+	fm := larkToken.NewManager(feishuToken, tokenStore, clock, cfg.AppID, cfg.AppSecret)
+	if cfg.TokenFilePath != "" {
+		fm.FilePath = cfg.TokenFilePath
+	}
+	if viper.IsSet("feishu.token.refresh-ahead") {
+		fm.RefreshAhead = viper.GetDuration("feishu.token.refresh-ahead")
+	}
+	if viper.IsSet("feishu.token.refresh-interval") {
+		fm.TokenRefresherInterval = viper.GetDuration("feishu.token.refresh-interval")
+	}
+	if err := fm.Initialize(ctx); err != nil {
+		log.Fatalw("Failed to Initialize", "error", err, "tenant", cfg.Name)
+	}
+	// 后台提前刷新令牌，ctx 取消时退出
+	go fm.StartTokenRefresher(ctx)
+
+	feishuApps[cfg.AppID] = &feishuApp{client: client, tokens: fm}
+	return client, fm, nil
+}
+
+// feishuInvokerFor 返回多维表格 appToken 共用的限流器，首次调用时按配置创建.
+func feishuInvokerFor(appToken string) *bitable.Invoker {
+	invoker, ok := feishuInvokers[appToken]
+	if !ok {
+		invoker = newFeishuInvoker()
+		feishuInvokers[appToken] = invoker
+	}
+	return invoker
+}
+
+// initFeishuEvents 创建一个公司的飞书事件处理器，并订阅多维表格的变更事件.
+// 字段变更时清除字段缓存，使新增的考核月份等选项立即生效.
+func initFeishuEvents(ctx context.Context, cfg feishuTenantConfig, fieldManager *field.Manager) (*dispatcher.EventDispatcher, error) {
+	d, err := event.NewHandler(cfg.AppToken, cfg.Event.VerificationToken, fieldManager).Dispatcher(cfg.Event.EncryptKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feishu event config of tenant %q: %w", cfg.Name, err)
+	}
+
+	// 订阅失败时服务仍可运行，字段缓存按 UpdateInterval 过期
+	if err := fieldManager.Subscribe(ctx); err != nil {
+		log.Errorw("Failed to subscribe bitable events", "error", err, "tenant", cfg.Name)
+	}
+	log.Infow("Feishu event callback enabled", "tenant", cfg.Name, "path", "/api/v1/feishu/events/"+cfg.AppID)
+
+	return d, nil
+}
+
+// newFieldManager 创建一个公司的字段管理器，登记两张表的结构和配置中的字段绑定.
+func newFieldManager(client *lark.Client, tp larkToken.Provider, invoker *bitable.Invoker, cfg feishuTenantConfig) *field.Manager {
+	fieldManager := field.NewManager(client, cfg.AppToken, tp)
+	fieldManager.SetInvoker(invoker)
+
+	fieldManager.RegisterSchema(cfg.OTableID, &v1.ObjectiveField{})
+	fieldManager.RegisterSchema(cfg.KrTableID, &v1.KeyResultField{})
+	fieldManager.SetBinding(cfg.OTableID, cfg.Fields.Objective)
+	fieldManager.SetBinding(cfg.KrTableID, cfg.Fields.KeyResult)
+
+	return fieldManager
+}
+
+// newTokenStorage 根据 `feishu.token.storage` 配置创建飞书令牌的存储，
+// 多副本部署或容器文件系统只读时应使用 db.
+func newTokenStorage(appID string) (larkToken.Storage, error) {
+	switch backend := viper.GetString("feishu.token.storage"); backend {
+	case "", tokenStorageFile:
+		return larkToken.NewTokenStorage(), nil
+	case tokenStorageDB:
+		log.Infow("Using database token storage", "appID", appID)
+		return store.NewTokenStore(store.S.DB(), appID), nil
+	default:
+		return nil, fmt.Errorf("unsupported feishu token storage: %q", backend)
+	}
+}
+
+// feishuLoginConfig 返回飞书登录使用的应用，未配置 `feishu.login.app-id` 时使用单公司配置中的飞书应用.
+// 多公司部署时各公司的飞书应用不同，需要单独配置一个用于登录的应用.
+func feishuLoginConfig() auth.Config {
+	if viper.IsSet("feishu.login.app-id") {
+		return auth.Config{
+			ClientId:     viper.GetString("feishu.login.app-id"),
+			ClientSecret: viper.GetString("feishu.login.app-secret"),
+		}
+	}
+	return auth.Config{
+		ClientId:     viper.GetString("feishu.app-id"),
+		ClientSecret: viper.GetString("feishu.app-secret"),
+	}
+}
+
+// oidcConfig 读取 `oidc` 下的通用 OIDC 登录配置，claims 中未配置的字段使用 OIDC 标准 claim.
+func oidcConfig() auth.OIDCConfig {
+	claims := auth.DefaultClaimMapping()
+	for key, field := range map[string]*string{
+		"user-id":         &claims.UserID,
+		"name":            &claims.Name,
+		"mobile":          &claims.Mobile,
+		"mobile-verified": &claims.MobileVerified,
+		"job-number":      &claims.JobNumber,
+		"title":           &claims.Title,
+		"avatar":          &claims.Avatar,
+	} {
+		if viper.IsSet("oidc.claims." + key) {
+			*field = viper.GetString("oidc.claims." + key)
+		}
+	}
+
+	return auth.OIDCConfig{
+		Issuer:       viper.GetString("oidc.issuer"),
+		ClientID:     viper.GetString("oidc.client-id"),
+		ClientSecret: viper.GetString("oidc.client-secret"),
+		RedirectURL:  viper.GetString("oidc.redirect-url"),
+		Scopes:       viper.GetStringSlice("oidc.scopes"),
+		Claims:       claims,
+		CreateUsers:  viper.GetBool("oidc.create-users"),
+	}
+}
+
+// newSessionService 读取 `jwt.refresh-expiration` 配置，创建会话服务.
+func newSessionService() *auth.SessionService {
+	return auth.NewSessionService(store.S.Sessions(), store.S.Users(), store.S.Credentials(), viper.GetDuration("jwt.refresh-expiration"))
+}
+
+// newPasswordAuthService 读取 `auth.password` 下的本地账号锁定配置，创建本地账号登录服务.
+func newPasswordAuthService(sessions auth.SessionRevoker) *auth.PasswordAuthService {
+	return auth.NewPasswordAuthService(auth.PasswordConfig{
+		MaxFailedAttempts: viper.GetInt("auth.password.max-failed-attempts"),
+		LockoutDuration:   viper.GetDuration("auth.password.lockout-duration"),
+	}, store.S.Credentials(), store.S.Users(), sessions)
+}
+
+// defaultPolicies 是每次启动时补齐的授权策略，与引入授权策略前的行为一致：
+// 登录用户可访问 OKR 和组织架构接口，管理员可访问全部接口及任何用户的 OKR.
+var defaultPolicies = [][]string{
+	{known.MemberRoleName, "/api/v1/auth/logout", "POST"},
+	{known.MemberRoleName, "/api/v1/fields", "GET"},
+	{known.MemberRoleName, "/api/v1/okrs", "GET|POST"},
+	{known.MemberRoleName, "/api/v1/okrs/batch", "POST"},
+	{known.MemberRoleName, "/api/v1/objectives", "POST"},
+	{known.MemberRoleName, "/api/v1/objectives/*", "PUT|DELETE"},
+	{known.MemberRoleName, "/api/v1/keyresults", "POST"},
+	{known.MemberRoleName, "/api/v1/keyresults/*", "PUT|DELETE"},
+	{known.MemberRoleName, "/api/v1/users/*", "GET"},
+	{known.MemberRoleName, "/api/v1/user/*", "GET"},
+	{known.MemberRoleName, "/api/v1/me", "GET"},
+	{known.MemberRoleName, "/api/v1/delegations", "GET|POST"},
+	{known.MemberRoleName, "/api/v1/delegations/*", "GET|DELETE"},
+	{known.AdminRoleName, "/api/v1/*", ".*"},
+	{known.AdminRoleName, known.AnyUserOkrObject, ".*"},
+}
+
+// initAuthz 创建基于 casbin 的授权器，并写入缺少的 defaultPolicies.
+func initAuthz(db *gorm.DB) (*authpkg.Authz, error) {
+	authz, err := authpkg.NewAuthz(db)
+	if err != nil {
+		return nil, err
+	}
+
+	seeded, err := authz.SeedPolicies(defaultPolicies)
+	if err != nil {
+		return nil, err
+	}
+	if seeded > 0 {
+		log.Infow("Default authorization policies created", "count", seeded)
+	}
+
+	return authz, nil
+}
+
+// jwtKeyConfig 是 `jwt.keys` 中的一个签名密钥.
+type jwtKeyConfig struct {
+	Kid  string `mapstructure:"kid"`
+	File string `mapstructure:"file"`
+	// NotAfter 为 RFC3339 时间，之后不再接受该密钥签发的 token
+	NotAfter string `mapstructure:"not-after"`
+}
+
+// initTokenKeys 根据 `jwt` 配置初始化 token 包的密钥.
+// 配置了 `jwt.key-dir` 时定期重新加载目录完成轮换，ctx 取消时停止；
+// 否则使用 `jwt.keys` 中的密钥，第一个用于签发. 两者都未配置时使用 `jwt.secret` 共享密钥.
+func initTokenKeys(ctx context.Context) error {
+	expiration := viper.GetDuration("jwt.expiration")
+	grace := viper.GetDuration("jwt.grace-period")
+	if grace <= 0 {
+		// 默认宽限期等于访问令牌有效期，轮换前签发的 token 在过期前都可用
+		grace = expiration
+	}
+
+	var keys *token.KeySet
+	switch dir := viper.GetString("jwt.key-dir"); {
+	case dir != "":
+		ks, err := token.LoadKeyDir(dir, grace, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to load jwt.key-dir: %w", err)
+		}
+		keys = ks
+
+		interval := viper.GetDuration("jwt.reload-interval")
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		go token.WatchKeyDir(ctx, dir, grace, interval, func(err error) {
+			log.Errorw("Failed to reload jwt keys", "dir", dir, "error", err)
+		})
+	case viper.IsSet("jwt.keys"):
+		var configs []jwtKeyConfig
+		if err := viper.UnmarshalKey("jwt.keys", &configs); err != nil {
+			return fmt.Errorf("invalid jwt.keys: %w", err)
+		}
+		if len(configs) == 0 {
+			return errors.New("jwt.keys is empty")
+		}
+		loaded := make([]*token.Key, 0, len(configs))
+		for i, cfg := range configs {
+			key, err := token.LoadKeyFile(cfg.Kid, cfg.File)
+			if err != nil {
+				return fmt.Errorf("jwt.keys[%d]: %w", i, err)
+			}
+			if cfg.NotAfter != "" {
+				if key.NotAfter, err = time.Parse(time.RFC3339, cfg.NotAfter); err != nil {
+					return fmt.Errorf("jwt.keys[%d].not-after: %w", i, err)
+				}
+			}
+			loaded = append(loaded, key)
+		}
+		keys = token.NewKeySet(loaded[0], loaded[1:]...)
+	default:
+		secret := viper.GetString("jwt.secret")
+		if secret == "" {
+			return errors.New("one of jwt.key-dir, jwt.keys or jwt.secret must be set")
+		}
+		keys = token.NewKeySet(token.NewHMACKey(secret))
+	}
+
+	log.Infow("JWT signing key loaded", "kid", keys.SigningKeyID())
+	token.Init(keys, expiration)
+	return nil
+}
+
+// newFieldCache 根据 `feishu.field-cache.storage` 配置创建字段映射的缓存，
+// 多副本部署时应使用 db，使各副本共享表结构并同时感知字段变更.
+func newFieldCache(appToken string) (field.Cache, error) {
+	switch backend := viper.GetString("feishu.field-cache.storage"); backend {
+	case "", fieldCacheFile:
+		return field.NewFileCache(viper.GetString("feishu.field-cache.dir")), nil
+	case fieldCacheMemory:
+		return field.NewMemoryCache(), nil
+	case fieldCacheDB:
+		log.Infow("Using database field cache", "appToken", appToken)
+		return store.NewFieldCacheStore(store.S.DB(), appToken), nil
+	default:
+		return nil, fmt.Errorf("unsupported feishu field cache storage: %q", backend)
+	}
+}
+
+// newFeishuInvoker 读取飞书接口的限流和重试配置，未配置时使用默认值.
+func newFeishuInvoker() *bitable.Invoker {
+	policy := retry.DefaultPolicy()
+	if viper.IsSet("feishu.retry.max-attempts") {
+		policy.MaxAttempts = viper.GetInt("feishu.retry.max-attempts")
+	}
+	if viper.IsSet("feishu.retry.base-delay") {
+		policy.BaseDelay = viper.GetDuration("feishu.retry.base-delay")
+	}
+	if viper.IsSet("feishu.retry.max-delay") {
+		policy.MaxDelay = viper.GetDuration("feishu.retry.max-delay")
+	}
+
+	qps, burst := float64(bitable.DefaultQPS), bitable.DefaultBurst
+	if viper.IsSet("feishu.rate-limit.qps") {
+		qps = viper.GetFloat64("feishu.rate-limit.qps")
+	}
+	if viper.IsSet("feishu.rate-limit.burst") {
+		burst = viper.GetInt("feishu.rate-limit.burst")
+	}
+
+	return bitable.NewInvoker(qps, burst, policy)
+}
+
+// initOkrSyncService 初始化本地 OKR 存储与飞书多维表格之间的同步服务.
+func initOkrSyncService(ctx context.Context, db *gorm.DB) (*sync.OkrSyncService, error) {
+	// 本地存储只对应一个公司，与第一个飞书配置同步.
+	// 与该公司的其他飞书调用共用令牌管理器和限流器
+	configs, err := feishuTenantConfigs()
+	if err != nil {
+		return nil, err
+	}
+	_, remote, err := initFeishuServices(ctx, configs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	notifier := notify.NewDingTalkNotifier(viper.GetString("dingtalk.webhook-url"))
+
+	return sync.NewOkrSyncService(store.NewOkrStore(db), store.NewOkrSyncStore(db), remote, notifier), nil
+}
@@ -18,28 +18,30 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
-	lark "github.com/larksuite/oapi-sdk-go/v3"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	ctrlV1 "github.com/imxw/miniokr/internal/miniokr/controller/v1"
+	acc "github.com/imxw/miniokr/internal/miniokr/controller/v1/account"
 	ac "github.com/imxw/miniokr/internal/miniokr/controller/v1/auth"
+	dc "github.com/imxw/miniokr/internal/miniokr/controller/v1/delegation"
+	ec "github.com/imxw/miniokr/internal/miniokr/controller/v1/event"
 	fc "github.com/imxw/miniokr/internal/miniokr/controller/v1/field"
 	oc "github.com/imxw/miniokr/internal/miniokr/controller/v1/okr"
+	pc "github.com/imxw/miniokr/internal/miniokr/controller/v1/policy"
+	rc "github.com/imxw/miniokr/internal/miniokr/controller/v1/role"
 	syncv1 "github.com/imxw/miniokr/internal/miniokr/controller/v1/sync"
 	uc "github.com/imxw/miniokr/internal/miniokr/controller/v1/user"
 	"github.com/imxw/miniokr/internal/miniokr/services/auth"
-	fs "github.com/imxw/miniokr/internal/miniokr/services/field"
-	okrs "github.com/imxw/miniokr/internal/miniokr/services/okr"
+	"github.com/imxw/miniokr/internal/miniokr/services/delegation"
+	"github.com/imxw/miniokr/internal/miniokr/services/role"
+	"github.com/imxw/miniokr/internal/miniokr/services/tenant"
 	users "github.com/imxw/miniokr/internal/miniokr/services/user"
 	"github.com/imxw/miniokr/internal/miniokr/store"
 	repo "github.com/imxw/miniokr/internal/miniokr/store"
-	"github.com/imxw/miniokr/internal/pkg/bitable"
-	"github.com/imxw/miniokr/internal/pkg/bitable/field"
-	larkToken "github.com/imxw/miniokr/internal/pkg/bitable/token"
 	"github.com/imxw/miniokr/internal/pkg/log"
 	"github.com/imxw/miniokr/internal/pkg/middleware"
 	mw "github.com/imxw/miniokr/internal/pkg/middleware"
-	"github.com/imxw/miniokr/pkg/token"
 	"github.com/imxw/miniokr/pkg/version/verflag"
 )
 
@@ -97,11 +99,17 @@ Find more miniokr information at:
 	// 添加 --version 标志
 	verflag.AddFlags(cmd.PersistentFlags())
 
+	// 运维子命令
+	cmd.AddCommand(newFieldsCommand(), newBitableCommand(), newUserCommand())
+
 	return cmd
 }
 
 // run 函数是实际的业务代码入口函数.
 func run() error {
+	// 后台任务(如飞书令牌刷新)的 ctx，服务退出时取消
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
 
 	// 初始化 store 层
 	db, err := initStore()
@@ -154,59 +162,83 @@ func run() error {
 		log.Fatalw("Failed to initialize DingTalk AuthService", "error", err)
 		return err
 	}
-
-	ctx := context.Background()
-	fsAppID := viper.GetString("feishu.app-id")
-	fsAppSecret := viper.GetString("feishu.app-secret")
-	fsAppToken := viper.GetString("feishu.app-token")
-	client := lark.NewClient(fsAppID, fsAppSecret)
-
-	feishuToken := &larkToken.LarkTokenService{Client: client}
-
-	store := larkToken.NewTokenStorage()
-	clock := larkToken.NewRealClock()
-	fm := larkToken.NewManager(feishuToken, store, clock, fsAppID, fsAppSecret)
-	err = fm.Initialize(ctx)
-	if err != nil {
-		log.Fatalw("Failed to Initialize", "error", err)
+	authenticators := map[string]auth.Authenticator{auth.ProviderDingTalk: as}
+	if viper.GetBool("feishu.login.enabled") {
+		authenticators[auth.ProviderFeishu] = auth.NewFeishuAuthService(feishuLoginConfig(), repo.S.Users())
 	}
-	fieldManager := field.NewManager(client, fsAppToken, fm)
-	oTableID := viper.GetString("feishu.o-table-id")
-	krTableID := viper.GetString("feishu.kr-table-id")
-
-	// 初始化缓存
-	fieldManager.LoadOrRefreshFieldMapping(ctx, oTableID)
-	fieldManager.LoadOrRefreshFieldMapping(ctx, krTableID)
+	if viper.GetBool("oidc.enabled") {
+		authenticators[auth.ProviderOIDC] = auth.NewOIDCAuthService(oidcConfig(), repo.S.Users())
+	}
+	sessionService := newSessionService()
+	// 本地账号登录始终可用，钉钉不可用时管理员仍可登录
+	passwordService := newPasswordAuthService(sessionService)
+	go sessionService.StartCleaner(bgCtx, time.Hour)
 
-	// 初始化Field服务
-	fieldService, err := fs.NewFeishufieldService(oTableID, krTableID, fieldManager)
+	// 根据配置初始化各公司的 Field 服务和 Okr 服务，请求按用户所属公司路由
+	tenants, events, err := initOkrServices(bgCtx)
 	if err != nil {
-		log.Fatalw("Failed to Initialize Feishufieldservice", "error", err)
+		log.Fatalw("Failed to initialize okr services", "error", err)
 		return err
 	}
 
-	rm := bitable.NewRecordManager(client, fsAppToken, fm)
-	// 初始化Okr服务
-	okrService, err := okrs.NewFeishuOkrService(oTableID, krTableID, fieldManager, rm)
+	// 本地模式下可选地与飞书多维表格保持同步
+	if viper.GetString("okr.backend") == okrBackendLocal && viper.GetBool("okr.sync.enabled") {
+		okrSyncService, err := initOkrSyncService(bgCtx, db)
+		if err != nil {
+			log.Fatalw("Failed to initialize okr sync service", "error", err)
+			return err
+		}
+		okrSyncController := syncv1.NewOkrSyncController(okrSyncService, viper.GetString("okr.sync.mode"), viper.GetString("okr.sync.schedule"))
+		if err := okrSyncController.StartCronJob(); err != nil {
+			log.Fatalw("Failed to start okr sync job", "error", err)
+			return err
+		}
+	}
 
 	// 初始化用户服务
 	userService := users.NewUserService(repo.S.Users())
+	userService.SetCompanyResolver(tenants)
+	userService.SetLeaderScopeDepth(viper.GetInt("leader.scope-depth"))
+
+	// 主管委托他人代为查看或评分下属 OKR
+	delegationService := delegation.NewDelegationService(repo.S.Delegations(), userService)
+
+	authz, err := initAuthz(db)
+	if err != nil {
+		log.Fatalw("Failed to initialize authorizer", "error", err)
+		return err
+	}
+
+	fieldService := tenant.NewFieldService(tenants)
+	// 修改 OKR 前按记录负责人校验权限，不经过 HTTP 接口的调用同样受限
+	okrService := tenant.NewOkrService(tenants, ctrlV1.NewOwnerAuthorizer(authz, delegationService, userService))
 
 	container := &ServiceContainer{
-		AuthController:  ac.New(as),
-		FieldController: fc.New(fieldService),
-		OkrController:   oc.New(fieldService, okrService, userService),
-		UserController:  uc.New(userService),
+		AccountController:    acc.New(passwordService),
+		AuthController:       ac.New(authenticators, passwordService, sessionService),
+		DelegationController: dc.New(delegationService),
+		EventController:      ec.New(events),
+		FieldController:      fc.New(fieldService),
+		OkrController:        oc.New(fieldService, okrService, userService, authz, delegationService),
+		PolicyController:     pc.New(authz),
+		RoleController:       rc.New(role.NewRoleService(repo.S.Roles(), userService)),
+		UserController:       uc.New(userService),
 	}
 
 	msc := &middleware.MiddlewareServiceContainer{
 		UserService: userService,
+		Revocation:  sessionService,
+		Authz:       authz,
+		Delegations: delegationService,
 	}
 
 	// 初始化飞书服务
 
 	// 设置 token 包的签发密钥，用于 token 包 token 的签发和解析
-	token.Init(viper.GetString("jwt.secret"), viper.GetDuration("jwt.expiration"))
+	if err := initTokenKeys(bgCtx); err != nil {
+		log.Fatalw("Failed to initialize jwt keys", "error", err)
+		return err
+	}
 
 	// 设置 Gin 模式
 	gin.SetMode(viper.GetString("runmode"))
@@ -247,6 +279,9 @@ func run() error {
 	<-quit                                               // 阻塞在此，当接收到上述两种信号时才会往下执行
 	log.Infow("Shutting down server ...")
 
+	// 停止后台任务
+	stopBackground()
+
 	// 创建 ctx 用于通知服务器 goroutine, 它有 10 秒时间完成当前正在处理的请求
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -12,6 +12,7 @@ import (
 	"github.com/imxw/miniokr/internal/pkg/core"
 	"github.com/imxw/miniokr/internal/pkg/log"
 	"github.com/imxw/miniokr/internal/pkg/middleware"
+	"github.com/imxw/miniokr/pkg/token"
 )
 
 // installRouters 安装 miniokr 接口路由.
@@ -38,16 +39,29 @@ func installRouters(g *gin.Engine, sc *ServiceContainer, msc *middleware.Middlew
 		core.WriteResponse(c, nil, map[string]string{"status": "ok"})
 	})
 
+	// 注册 JWKS 接口，其他服务使用其中的公钥校验 miniokr 签发的 token
+	g.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		core.WriteResponse(c, nil, token.JWKS())
+	})
+
 	// 注册 pprof 路由
 	pprof.Register(g)
 
 	// 创建v1路由分组
 	v1 := g.Group("/api/v1")
-	v1.POST("/auth/dingtalk", sc.AuthController.Auth)
-	v1.Use(middleware.Authn(msc))
+	v1.POST("/auth/password", sc.AuthController.Password)
+	v1.POST("/auth/refresh", sc.AuthController.Refresh)
+	v1.POST("/auth/:provider", sc.AuthController.Auth)
+	// OIDC 等需要跳转登录页面的登录方式由此发起登录
+	v1.GET("/auth/:provider/authorize", sc.AuthController.Authorize)
+	// 飞书事件回调通过签名校验身份，不经过登录认证
+	v1.POST("/feishu/events/:appID", sc.EventController.Handle)
+	v1.Use(middleware.Authn(msc), middleware.Authz(msc.Authz))
+	v1.POST("/auth/logout", sc.AuthController.Logout)
 	v1.GET("/fields", sc.FieldController.List)
 	v1.GET("/okrs", sc.OkrController.ListOkrByUsernameAndMonths)
 	v1.POST("/okrs", sc.OkrController.ListOkrByUsernameAndMonths)
+	v1.POST("/okrs/batch", sc.OkrController.BatchSaveOkr)
 	v1.POST("/objectives", sc.OkrController.CreateObjective)
 	v1.PUT("/objectives/:id", sc.OkrController.UpdateObjective)
 	v1.DELETE("/objectives/:id", sc.OkrController.DeleteObjective)
@@ -58,6 +72,27 @@ func installRouters(g *gin.Engine, sc *ServiceContainer, msc *middleware.Middlew
 	v1.GET("/users/:id/departments/tree", sc.UserController.GetUserDepartmentsTree)
 	v1.GET("/user/departments/tree", sc.UserController.GetDepartmentsTree)
 	v1.GET("/me", sc.UserController.GetCurrentUser)
+	v1.GET("/delegations", sc.DelegationController.List)
+	v1.POST("/delegations", sc.DelegationController.Create)
+	v1.DELETE("/delegations/:id", sc.DelegationController.Revoke)
+	v1.GET("/delegations/:id/actions", sc.DelegationController.ListActions)
+
+	// 管理接口，默认策略只允许管理员访问
+	admin := v1.Group("/admin")
+	admin.POST("/accounts", sc.AccountController.Create)
+	admin.PUT("/accounts/:username/disable", sc.AccountController.Disable)
+	admin.PUT("/accounts/:username/enable", sc.AccountController.Enable)
+	admin.PUT("/accounts/:username/password", sc.AccountController.ResetPassword)
+	admin.DELETE("/users/:id/sessions", sc.AuthController.RevokeUserSessions)
+	admin.GET("/policies", sc.PolicyController.List)
+	admin.POST("/policies", sc.PolicyController.Create)
+	admin.DELETE("/policies", sc.PolicyController.Delete)
+	admin.GET("/roles", sc.RoleController.List)
+	admin.POST("/roles", sc.RoleController.Create)
+	admin.GET("/users/:id/roles", sc.RoleController.ListUserRoles)
+	admin.POST("/users/:id/roles", sc.RoleController.AssignUserRole)
+	admin.DELETE("/users/:id/roles/:role", sc.RoleController.RemoveUserRole)
+	admin.GET("/users/:id/roles/changes", sc.RoleController.ListRoleChanges)
 
 	return nil
 }
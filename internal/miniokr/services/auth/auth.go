@@ -9,15 +9,28 @@ import (
 	"context"
 )
 
+// 登录方式，对应 `POST /api/v1/auth/:provider` 中的 provider
+const (
+	ProviderDingTalk = "dingtalk"
+	ProviderFeishu   = "feishu"
+	ProviderOIDC     = "oidc"
+)
+
+// Authenticator 是一种登录方式，登录成功后由 SessionService 签发令牌
 type Authenticator interface {
-	TokenIssuer
 	UserFetcher
 }
 
-type TokenIssuer interface {
-	IssueToken(ctx context.Context, userid string, username string) (string, error)
-}
-
 type UserFetcher interface {
 	Fetch(ctx context.Context, code string) (string, string, error)
 }
+
+// Redirector 由需要跳转到登录页面的登录方式实现，如 OIDC
+type Redirector interface {
+	AuthCodeURL(ctx context.Context) (string, error)
+}
+
+// StateFetcher 由需要校验登录请求 state 的登录方式实现，如 OIDC
+type StateFetcher interface {
+	FetchWithState(ctx context.Context, code string, state string) (string, string, error)
+}
@@ -11,10 +11,7 @@ import (
 	"github.com/zhaoyunxing92/dingtalk/v2"
 	"github.com/zhaoyunxing92/dingtalk/v2/request"
 
-	"github.com/imxw/miniokr/internal/pkg/errno"
-	"github.com/imxw/miniokr/internal/pkg/known"
 	"github.com/imxw/miniokr/internal/pkg/log"
-	"github.com/imxw/miniokr/pkg/token"
 )
 
 type Config struct {
@@ -60,20 +57,6 @@ func (d *DingTalkAuthService) Fetch(ctx context.Context, code string) (string, s
 	return userinfo.UserInfo.UserId, userDetail.Name, nil
 }
 
-func (d *DingTalkAuthService) IssueToken(ctx context.Context, userid string, username string) (string, error) {
-
-	claims := map[string]interface{}{
-		known.XUserIDKey:   userid,
-		known.XUsernameKey: username,
-	}
-
-	t, err := token.Sign(claims)
-	if err != nil {
-		return "", errno.ErrSignToken
-	}
-	return t, nil
-}
-
 // 一个用于检查和获取映射值的辅助函数
 // func getMappingValue(mapping map[string]string, key string) (string, error) {
 // 	value, ok := mapping[key]
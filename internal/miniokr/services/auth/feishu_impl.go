@@ -0,0 +1,105 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkauthen "github.com/larksuite/oapi-sdk-go/v3/service/authen/v1"
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// UserFinder 按手机号或工号查找从钉钉同步的用户，未找到时返回 gorm.ErrRecordNotFound
+type UserFinder interface {
+	GetUserByMobile(ctx context.Context, mobile string) (*model.User, error)
+	GetUserByJobNumber(ctx context.Context, jobNumber string) (*model.User, error)
+}
+
+// FeishuAuthService 使用飞书网页应用登录，
+// 用户身份以钉钉同步的通讯录为准，飞书用户按手机号或工号对应到 model.User
+type FeishuAuthService struct {
+	client *lark.Client
+	users  UserFinder
+}
+
+// NewFeishuAuthService 创建飞书登录服务，cfg 为飞书应用的 app ID 和 app secret
+func NewFeishuAuthService(cfg Config, users UserFinder, opts ...lark.ClientOptionFunc) *FeishuAuthService {
+	return &FeishuAuthService{
+		client: lark.NewClient(cfg.ClientId, cfg.ClientSecret, opts...),
+		users:  users,
+	}
+}
+
+// Fetch 使用飞书登录预授权码获取用户信息，返回对应的钉钉用户 id 和姓名
+func (f *FeishuAuthService) Fetch(ctx context.Context, code string) (string, string, error) {
+	tokenReq := larkauthen.NewCreateOidcAccessTokenReqBuilder().
+		Body(larkauthen.NewCreateOidcAccessTokenReqBodyBuilder().
+			GrantType("authorization_code").
+			Code(code).
+			Build()).
+		Build()
+	tokenResp, err := f.client.Authen.OidcAccessToken.Create(ctx, tokenReq)
+	if err != nil {
+		log.C(ctx).Errorw("Failed to create feishu user access token", "err", err)
+		return "", "", err
+	}
+	if !tokenResp.Success() || tokenResp.Data == nil || tokenResp.Data.AccessToken == nil {
+		log.C(ctx).Warnw("Invalid feishu auth code", "code", tokenResp.Code, "msg", tokenResp.Msg)
+		return "", "", errno.ErrUnauthorized
+	}
+
+	infoResp, err := f.client.Authen.UserInfo.Get(ctx, larkcore.WithUserAccessToken(*tokenResp.Data.AccessToken))
+	if err != nil {
+		log.C(ctx).Errorw("Failed to fetch feishu userinfo", "err", err)
+		return "", "", err
+	}
+	if !infoResp.Success() || infoResp.Data == nil {
+		log.C(ctx).Errorw("Failed to fetch feishu userinfo", "code", infoResp.Code, "msg", infoResp.Msg)
+		return "", "", errno.ErrUnauthorized
+	}
+
+	user, err := findUser(ctx, f.users, larkcore.StringValue(infoResp.Data.Mobile), larkcore.StringValue(infoResp.Data.EmployeeNo))
+	if err != nil {
+		log.C(ctx).Warnw("Feishu user not found in synced users", "openID", larkcore.StringValue(infoResp.Data.OpenId), "err", err)
+		return "", "", err
+	}
+
+	return user.UserID, user.Name, nil
+}
+
+// findUser 依次按手机号和工号查找用户.
+// 飞书和 OIDC 返回的手机号可能带国家码(+86)，钉钉同步的国内手机号不带，两种格式都会尝试
+func findUser(ctx context.Context, users UserFinder, mobile, jobNumber string) (*model.User, error) {
+	lookups := make([]func() (*model.User, error), 0, 3)
+	if mobile != "" {
+		lookups = append(lookups, func() (*model.User, error) { return users.GetUserByMobile(ctx, mobile) })
+		if local := strings.TrimPrefix(mobile, "+86"); local != mobile {
+			lookups = append(lookups, func() (*model.User, error) { return users.GetUserByMobile(ctx, local) })
+		}
+	}
+	if jobNumber != "" {
+		lookups = append(lookups, func() (*model.User, error) { return users.GetUserByJobNumber(ctx, jobNumber) })
+	}
+
+	for _, lookup := range lookups {
+		user, err := lookup()
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return nil, errno.ErrUserNotFound
+}
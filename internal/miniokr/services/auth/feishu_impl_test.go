@@ -0,0 +1,118 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// fakeUsers 模拟钉钉同步的用户
+type fakeUsers []model.User
+
+func (f fakeUsers) GetUserByMobile(_ context.Context, mobile string) (*model.User, error) {
+	for i := range f {
+		if f[i].Mobile == mobile {
+			return &f[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f fakeUsers) GetUserByJobNumber(_ context.Context, jobNumber string) (*model.User, error) {
+	for i := range f {
+		if f[i].JobNumber == jobNumber {
+			return &f[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// newFakeFeishu 模拟飞书的登录接口，code 为 "valid" 时返回 userinfo 中的用户信息
+func newFakeFeishu(t *testing.T, userinfo map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	write := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	mux.HandleFunc("/open-apis/auth/v3/app_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		write(w, map[string]interface{}{"code": 0, "msg": "ok", "app_access_token": "a-test", "expire": 7200})
+	})
+	mux.HandleFunc("/open-apis/authen/v1/oidc/access_token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer a-test", r.Header.Get("Authorization"))
+		var body struct {
+			Code string `json:"code"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Code != "valid" {
+			write(w, map[string]interface{}{"code": 20003, "msg": "invalid code"})
+			return
+		}
+		write(w, map[string]interface{}{"code": 0, "msg": "ok", "data": map[string]interface{}{"access_token": "u-test", "expires_in": 7200}})
+	})
+	mux.HandleFunc("/open-apis/authen/v1/user_info", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer u-test", r.Header.Get("Authorization"))
+		write(w, map[string]interface{}{"code": 0, "msg": "ok", "data": userinfo})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFeishuAuthService_Fetch(t *testing.T) {
+	users := fakeUsers{
+		{UserID: "ding-1", Name: "张三", Mobile: "13800000001", JobNumber: "A001"},
+		{UserID: "ding-2", Name: "李四", Mobile: "+85291234567", JobNumber: "A002"},
+	}
+
+	tests := []struct {
+		name     string
+		userinfo map[string]interface{}
+		code     string
+		userID   string
+		err      error
+	}{
+		{name: "mobile with country code", userinfo: map[string]interface{}{"mobile": "+8613800000001"}, code: "valid", userID: "ding-1"},
+		{name: "mobile as synced", userinfo: map[string]interface{}{"mobile": "+85291234567"}, code: "valid", userID: "ding-2"},
+		{name: "job number", userinfo: map[string]interface{}{"mobile": "+8613900000000", "employee_no": "A002"}, code: "valid", userID: "ding-2"},
+		{name: "not synced", userinfo: map[string]interface{}{"mobile": "+8613900000000", "employee_no": "B001"}, code: "valid", err: errno.ErrUserNotFound},
+		{name: "no mobile and job number", userinfo: map[string]interface{}{"open_id": "ou_1"}, code: "valid", err: errno.ErrUserNotFound},
+		{name: "invalid code", code: "expired", err: errno.ErrUnauthorized},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeFeishu(t, tt.userinfo)
+			// SDK 按 app ID 缓存 app_access_token，每个用例使用不同的 app ID
+			a := NewFeishuAuthService(Config{ClientId: "cli_login_" + string(rune('a'+i)), ClientSecret: "secret"}, users, lark.WithOpenBaseUrl(srv.URL))
+
+			userID, username, err := a.Fetch(context.Background(), tt.code)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.userID, userID)
+			for _, u := range users {
+				if u.UserID == userID {
+					assert.Equal(t, u.Name, username)
+				}
+			}
+		})
+	}
+}
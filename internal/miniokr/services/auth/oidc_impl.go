@@ -0,0 +1,445 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	"github.com/imxw/miniokr/pkg/token"
+)
+
+const (
+	// defaultOIDCStateTTL 是登录请求 state 的有效期，超过后用户需要重新发起登录
+	defaultOIDCStateTTL = 10 * time.Minute
+
+	// jwksRefreshInterval 是遇到未知 kid 时重新获取 JWKS 的最小间隔，避免伪造的 kid 导致频繁请求
+	jwksRefreshInterval = time.Minute
+)
+
+// OIDCConfig 是通用 OIDC 登录的配置，如公司的 Keycloak
+type OIDCConfig struct {
+	// Issuer 是 OIDC 提供方的地址，{Issuer}/.well-known/openid-configuration 用于服务发现
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL 是前端的登录回调地址，需要在 OIDC 提供方登记
+	RedirectURL string
+	// Scopes 默认为 openid profile email phone
+	Scopes []string
+	Claims ClaimMapping
+	// CreateUsers 为 true 时，未能对应到钉钉同步用户的 OIDC 用户会以映射后的信息创建为本地用户，
+	// 用户 id 为 oidc:<issuer>:<sub>，不会与钉钉同步的用户冲突
+	CreateUsers bool
+}
+
+// ClaimMapping 是 ID token 中的 claim 到 model.User 字段的映射，为空的字段不映射
+type ClaimMapping struct {
+	UserID string
+	Name   string
+	Mobile string
+	// MobileVerified 是手机号已验证的 claim，为 true 时才使用手机号，为空时不使用手机号
+	MobileVerified string
+	JobNumber      string
+	Title          string
+	Avatar         string
+}
+
+// DefaultClaimMapping 返回 OIDC 标准 claim 的映射
+func DefaultClaimMapping() ClaimMapping {
+	return ClaimMapping{
+		UserID:         "sub",
+		Name:           "name",
+		Mobile:         "phone_number",
+		MobileVerified: "phone_number_verified",
+		Avatar:         "picture",
+	}
+}
+
+// UserCreator 查找和创建 OIDC 登录时创建的本地用户，用户 id 已存在时 CreateUser 返回错误
+type UserCreator interface {
+	GetUserByID(ctx context.Context, id string) (*model.User, error)
+	CreateUser(ctx context.Context, user *model.User) error
+}
+
+// OIDCUserStore 按手机号或工号查找用户，并创建新登录的用户
+type OIDCUserStore interface {
+	UserFinder
+	UserCreator
+}
+
+// oidcDiscovery 是 OIDC 服务发现返回的配置中用到的字段
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcLogin 是一次登录请求的 PKCE code_verifier 和 nonce
+type oidcLogin struct {
+	verifier string
+	nonce    string
+	expireAt time.Time
+}
+
+// OIDCAuthService 使用授权码模式 + PKCE 登录通用 OIDC 提供方.
+// 登录请求的 state 保存在内存中，多副本部署时需要会话保持
+type OIDCAuthService struct {
+	cfg    OIDCConfig
+	users  OIDCUserStore
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]token.JSONWebKey
+	keysAt    time.Time
+	logins    map[string]oidcLogin
+}
+
+// NewOIDCAuthService 创建 OIDC 登录服务，服务发现和 JWKS 在第一次登录时获取
+func NewOIDCAuthService(cfg OIDCConfig, users OIDCUserStore) *OIDCAuthService {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email", "phone"}
+	}
+	if cfg.Claims.UserID == "" {
+		cfg.Claims.UserID = "sub"
+	}
+	return &OIDCAuthService{
+		cfg:    cfg,
+		users:  users,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+		logins: make(map[string]oidcLogin),
+	}
+}
+
+// AuthCodeURL 生成一次登录请求，返回跳转到 OIDC 提供方的授权地址
+func (o *OIDCAuthService) AuthCodeURL(ctx context.Context) (string, error) {
+	d, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, verifier, nonce := randomString(), randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	o.mu.Lock()
+	now := o.now()
+	for s, l := range o.logins {
+		if now.After(l.expireAt) {
+			delete(o.logins, s)
+		}
+	}
+	o.logins[state] = oidcLogin{verifier: verifier, nonce: nonce, expireAt: now.Add(defaultOIDCStateTTL)}
+	o.mu.Unlock()
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", o.cfg.ClientID)
+	q.Set("redirect_uri", o.cfg.RedirectURL)
+	q.Set("scope", strings.Join(o.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Fetch 需要登录请求的 state，OIDC 登录应使用 FetchWithState
+func (o *OIDCAuthService) Fetch(ctx context.Context, code string) (string, string, error) {
+	return o.FetchWithState(ctx, code, "")
+}
+
+// FetchWithState 使用授权码和 code_verifier 换取 ID token，校验后按 claim 映射返回用户 id 和姓名
+func (o *OIDCAuthService) FetchWithState(ctx context.Context, code string, state string) (string, string, error) {
+	o.mu.Lock()
+	login, ok := o.logins[state]
+	delete(o.logins, state)
+	o.mu.Unlock()
+	if !ok || o.now().After(login.expireAt) {
+		log.C(ctx).Warnw("Unknown or expired oidc state")
+		return "", "", errno.ErrUnauthorized
+	}
+
+	rawIDToken, err := o.exchange(ctx, code, login.verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	claims, err := o.verify(ctx, rawIDToken, login.nonce)
+	if err != nil {
+		log.C(ctx).Warnw("Invalid oidc id token", "err", err)
+		return "", "", errno.ErrUnauthorized
+	}
+
+	mapped := o.cfg.Claims.user(claims)
+	if mapped.UserID == "" {
+		log.C(ctx).Warnw("Oidc id token has no user id claim", "claim", o.cfg.Claims.UserID)
+		return "", "", errno.ErrUnauthorized
+	}
+
+	user, err := findUser(ctx, o.users, mapped.Mobile, mapped.JobNumber)
+	if err == nil {
+		return user.UserID, user.Name, nil
+	}
+	if !errors.Is(err, errno.ErrUserNotFound) || !o.cfg.CreateUsers {
+		log.C(ctx).Warnw("Oidc user not found in synced users", "sub", mapped.UserID, "err", err)
+		return "", "", err
+	}
+
+	// 创建的用户以提供方和 sub 命名，不会覆盖钉钉同步的用户或其他提供方的用户
+	sub := mapped.UserID
+	mapped.UserID = oidcUserID(o.cfg.Issuer, sub)
+	if user, err := o.users.GetUserByID(ctx, mapped.UserID); err == nil {
+		return user.UserID, user.Name, nil
+	}
+	if mapped.Name == "" {
+		mapped.Name = sub
+	}
+	if err := o.users.CreateUser(ctx, mapped); err != nil {
+		log.C(ctx).Errorw("Failed to create oidc user", "userID", mapped.UserID, "err", err)
+		return "", "", err
+	}
+	return mapped.UserID, mapped.Name, nil
+}
+
+// oidcUserID 返回 OIDC 登录时创建的用户 id
+func oidcUserID(issuer, sub string) string {
+	return "oidc:" + issuer + ":" + sub
+}
+
+// discover 获取并缓存 OIDC 提供方的配置
+func (o *OIDCAuthService) discover(ctx context.Context) (*oidcDiscovery, error) {
+	o.mu.Lock()
+	d := o.discovery
+	o.mu.Unlock()
+	if d != nil {
+		return d, nil
+	}
+
+	d = &oidcDiscovery{}
+	if err := o.getJSON(ctx, strings.TrimSuffix(o.cfg.Issuer, "/")+"/.well-known/openid-configuration", d); err != nil {
+		log.C(ctx).Errorw("Failed to discover oidc provider", "issuer", o.cfg.Issuer, "err", err)
+		return nil, err
+	}
+	if d.Issuer != o.cfg.Issuer {
+		return nil, fmt.Errorf("oidc issuer mismatch: configured %q, discovered %q", o.cfg.Issuer, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc provider %q has incomplete discovery document", o.cfg.Issuer)
+	}
+
+	o.mu.Lock()
+	o.discovery = d
+	o.mu.Unlock()
+	return d, nil
+}
+
+// exchange 使用授权码和 code_verifier 换取 ID token
+func (o *OIDCAuthService) exchange(ctx context.Context, code, verifier string) (string, error) {
+	d, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", o.cfg.RedirectURL)
+	form.Set("client_id", o.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if o.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		log.C(ctx).Errorw("Failed to exchange oidc auth code", "err", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		log.C(ctx).Warnw("Invalid oidc auth code", "status", resp.StatusCode, "error", body.Error, "description", body.ErrorDescription)
+		return "", errno.ErrUnauthorized
+	}
+	return body.IDToken, nil
+}
+
+// verify 校验 ID token 的签名、签发方、受众、有效期和 nonce
+func (o *OIDCAuthService) verify(ctx context.Context, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %q", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return o.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(o.cfg.Issuer, true) {
+		return nil, errors.New("issuer mismatch")
+	}
+	if !claims.VerifyAudience(o.cfg.ClientID, true) {
+		return nil, errors.New("audience mismatch")
+	}
+	if !claims.VerifyExpiresAt(o.now().Unix(), true) {
+		return nil, errors.New("token is expired")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+// publicKey 返回 kid 对应的公钥，缓存中没有时重新获取 JWKS 以支持提供方轮换密钥
+func (o *OIDCAuthService) publicKey(ctx context.Context, kid string) (interface{}, error) {
+	o.mu.Lock()
+	key, ok := o.lookupKey(kid)
+	stale := o.now().Sub(o.keysAt) >= jwksRefreshInterval
+	o.mu.Unlock()
+	if ok {
+		return key.PublicKey()
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	d, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set token.JSONWebKeySet
+	if err := o.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		log.C(ctx).Errorw("Failed to fetch oidc jwks", "uri", d.JWKSURI, "err", err)
+		return nil, err
+	}
+
+	keys := make(map[string]token.JSONWebKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "" || k.Use == "sig" {
+			keys[k.Kid] = k
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.keys, o.keysAt = keys, o.now()
+	if key, ok = o.lookupKey(kid); !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key.PublicKey()
+}
+
+// lookupKey 按 kid 查找公钥，ID token 没有 kid 时仅在 JWKS 只有一个公钥时使用该公钥，调用方需持有 o.mu
+func (o *OIDCAuthService) lookupKey(kid string) (token.JSONWebKey, bool) {
+	if kid == "" && len(o.keys) == 1 {
+		for _, k := range o.keys {
+			return k, true
+		}
+	}
+	k, ok := o.keys[kid]
+	return k, ok
+}
+
+func (o *OIDCAuthService) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// user 按映射从 claims 中取出用户信息，手机号未经提供方验证时不使用
+func (m ClaimMapping) user(claims jwt.MapClaims) *model.User {
+	get := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		switch v := claims[name].(type) {
+		case nil:
+			return ""
+		case string:
+			return v
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	mobile := ""
+	if verified, _ := claims[m.MobileVerified].(bool); m.MobileVerified != "" && verified {
+		mobile = get(m.Mobile)
+	}
+	return &model.User{
+		UserID:    get(m.UserID),
+		Name:      get(m.Name),
+		Mobile:    mobile,
+		JobNumber: get(m.JobNumber),
+		Title:     get(m.Title),
+		Avatar:    get(m.Avatar),
+		Status:    "active",
+	}
+}
+
+// randomString 生成 32 字节的随机字符串，用作 state、nonce 和 code_verifier
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
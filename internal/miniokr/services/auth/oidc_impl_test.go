@@ -0,0 +1,372 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	"github.com/imxw/miniokr/pkg/token"
+)
+
+// fakeOIDCUsers 模拟钉钉同步的用户，并记录 OIDC 登录时创建的用户
+type fakeOIDCUsers struct {
+	fakeUsers
+	saved []model.User
+}
+
+func (f *fakeOIDCUsers) GetUserByID(_ context.Context, id string) (*model.User, error) {
+	for _, u := range append(f.fakeUsers, f.saved...) {
+		if u.UserID == id {
+			return &u, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeOIDCUsers) CreateUser(ctx context.Context, user *model.User) error {
+	if _, err := f.GetUserByID(ctx, user.UserID); err == nil {
+		return gorm.ErrDuplicatedKey
+	}
+	f.saved = append(f.saved, *user)
+	return nil
+}
+
+// mockSigningKey 是模拟 OIDC 提供方的一个签名密钥
+type mockSigningKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+// mockOIDC 是进程内的 OIDC 提供方，支持服务发现、授权码 + PKCE 和 JWKS
+type mockOIDC struct {
+	t      *testing.T
+	srv    *httptest.Server
+	secret string
+
+	mu   sync.Mutex
+	keys []mockSigningKey // 最后一个用于签名
+	// rogue 不为空时使用不在 JWKS 中的密钥签名
+	rogue  *mockSigningKey
+	codes  map[string]url.Values
+	claims map[string]interface{}
+	// mutate 在签名前修改 ID token 的 claims，用于构造非法的 ID token
+	mutate func(jwt.MapClaims)
+}
+
+func newMockOIDC(t *testing.T, claims map[string]interface{}) *mockOIDC {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	m := &mockOIDC{
+		t:      t,
+		secret: "secret",
+		keys:   []mockSigningKey{{kid: "rsa-1", method: jwt.SigningMethodRS256, key: rsaKey}},
+		codes:  make(map[string]url.Values),
+		claims: claims,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		m.write(w, http.StatusOK, map[string]string{
+			"issuer":                 m.srv.URL,
+			"authorization_endpoint": m.srv.URL + "/authorize",
+			"token_endpoint":         m.srv.URL + "/token",
+			"jwks_uri":               m.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", m.handleJWKS)
+	mux.HandleFunc("/token", m.handleToken)
+
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+// login 模拟用户在授权页面登录，返回授权码
+func (m *mockOIDC) login(authURL string) string {
+	u, err := url.Parse(authURL)
+	require.NoError(m.t, err)
+	assert.Equal(m.t, m.srv.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+
+	q := u.Query()
+	assert.Equal(m.t, "code", q.Get("response_type"))
+	assert.Equal(m.t, "S256", q.Get("code_challenge_method"))
+	assert.NotEmpty(m.t, q.Get("state"))
+
+	code := randomString()
+	m.mu.Lock()
+	m.codes[code] = q
+	m.mu.Unlock()
+	return code
+}
+
+// rotate 新增一个签名密钥，之后签发的 ID token 使用该密钥
+func (m *mockOIDC) rotate(k mockSigningKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = append(m.keys, k)
+}
+
+func (m *mockOIDC) handleToken(w http.ResponseWriter, r *http.Request) {
+	require.NoError(m.t, r.ParseForm())
+	id, secret, _ := r.BasicAuth()
+	if id != "miniokr" || secret != m.secret {
+		m.write(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	auth, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != auth.Get("code_challenge") ||
+		r.PostForm.Get("redirect_uri") != auth.Get("redirect_uri") {
+		m.write(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   m.srv.URL,
+		"aud":   "miniokr",
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": auth.Get("nonce"),
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	if m.mutate != nil {
+		m.mutate(claims)
+	}
+
+	m.mu.Lock()
+	k := m.keys[len(m.keys)-1]
+	if m.rogue != nil {
+		k = *m.rogue
+	}
+	m.mu.Unlock()
+	t := jwt.NewWithClaims(k.method, claims)
+	t.Header["kid"] = k.kid
+	idToken, err := t.SignedString(k.key)
+	require.NoError(m.t, err)
+
+	m.write(w, http.StatusOK, map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": idToken})
+}
+
+func (m *mockOIDC) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enc := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	var set token.JSONWebKeySet
+	for _, k := range m.keys {
+		switch pub := k.key.Public().(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, token.JSONWebKey{Kid: k.kid, Kty: "RSA", Use: "sig", N: enc(pub.N.Bytes()), E: enc([]byte{1, 0, 1})})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, token.JSONWebKey{Kid: k.kid, Kty: "EC", Use: "sig", Crv: "P-256", X: enc(pub.X.Bytes()), Y: enc(pub.Y.Bytes())})
+		}
+	}
+	m.write(w, http.StatusOK, set)
+}
+
+func (m *mockOIDC) write(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newTestOIDCAuthService(m *mockOIDC, users OIDCUserStore, createUsers bool) *OIDCAuthService {
+	claims := DefaultClaimMapping()
+	claims.JobNumber = "employee_id"
+	return NewOIDCAuthService(OIDCConfig{
+		Issuer:       m.srv.URL,
+		ClientID:     "miniokr",
+		ClientSecret: m.secret,
+		RedirectURL:  "https://okr.example.com/login/oidc",
+		Claims:       claims,
+		CreateUsers:  createUsers,
+	}, users)
+}
+
+func TestOIDCAuthService_FetchWithState(t *testing.T) {
+	synced := fakeUsers{
+		{UserID: "ding-1", Name: "张三", Mobile: "13800000001", JobNumber: "A001"},
+		{UserID: "ding-2", Name: "李四", Mobile: "13800000002", JobNumber: "1002"},
+	}
+
+	tests := []struct {
+		name        string
+		claims      map[string]interface{}
+		mutate      func(jwt.MapClaims)
+		createUsers bool
+		userID      string
+		username    string
+		saved       bool
+		err         error
+	}{
+		{name: "mobile", claims: map[string]interface{}{"sub": "kc-1", "phone_number": "+8613800000001", "phone_number_verified": true}, userID: "ding-1", username: "张三"},
+		{name: "unverified mobile", claims: map[string]interface{}{"sub": "kc-1", "phone_number": "+8613800000001"}, err: errno.ErrUserNotFound},
+		{name: "non-boolean verified claim", claims: map[string]interface{}{"sub": "kc-1", "phone_number": "13800000001", "phone_number_verified": "true"}, createUsers: true, userID: oidcUserID("{issuer}", "kc-1"), username: "kc-1", saved: true},
+		{name: "numeric job number", claims: map[string]interface{}{"sub": "kc-2", "employee_id": 1002}, userID: "ding-2", username: "李四"},
+		{name: "not synced", claims: map[string]interface{}{"sub": "kc-3", "name": "王五"}, err: errno.ErrUserNotFound},
+		{name: "create contractor", claims: map[string]interface{}{"sub": "kc-3", "name": "王五"}, createUsers: true, userID: oidcUserID("{issuer}", "kc-3"), username: "王五", saved: true},
+		{name: "sub matches synced user id", claims: map[string]interface{}{"sub": "ding-1", "name": "冒名"}, createUsers: true, userID: oidcUserID("{issuer}", "ding-1"), username: "冒名", saved: true},
+		{name: "no user id claim", claims: map[string]interface{}{"name": "王五"}, createUsers: true, err: errno.ErrUnauthorized},
+		{name: "wrong audience", claims: map[string]interface{}{"sub": "kc-1"}, mutate: func(c jwt.MapClaims) { c["aud"] = "other" }, err: errno.ErrUnauthorized},
+		{name: "wrong issuer", claims: map[string]interface{}{"sub": "kc-1"}, mutate: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, err: errno.ErrUnauthorized},
+		{name: "wrong nonce", claims: map[string]interface{}{"sub": "kc-1"}, mutate: func(c jwt.MapClaims) { c["nonce"] = "replayed" }, err: errno.ErrUnauthorized},
+		{name: "expired", claims: map[string]interface{}{"sub": "kc-1"}, mutate: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, err: errno.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockOIDC(t, tt.claims)
+			m.mutate = tt.mutate
+			users := &fakeOIDCUsers{fakeUsers: synced}
+			a := newTestOIDCAuthService(m, users, tt.createUsers)
+
+			authURL, err := a.AuthCodeURL(context.Background())
+			require.NoError(t, err)
+			code := m.login(authURL)
+			state := mustQuery(t, authURL, "state")
+
+			userID, username, err := a.FetchWithState(context.Background(), code, state)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			expectID := strings.ReplaceAll(tt.userID, "{issuer}", m.srv.URL)
+			assert.Equal(t, expectID, userID)
+			assert.Equal(t, tt.username, username)
+			if tt.saved {
+				require.Len(t, users.saved, 1)
+				assert.Equal(t, expectID, users.saved[0].UserID)
+				assert.Empty(t, users.saved[0].Mobile)
+				// 钉钉同步的用户不会被覆盖
+				assert.Equal(t, synced, users.fakeUsers)
+			} else {
+				assert.Empty(t, users.saved)
+			}
+		})
+	}
+}
+
+func TestOIDCAuthService_ReturningUser(t *testing.T) {
+	m := newMockOIDC(t, map[string]interface{}{"sub": "kc-3", "name": "王五"})
+	users := &fakeOIDCUsers{}
+	a := newTestOIDCAuthService(m, users, true)
+	ctx := context.Background()
+
+	login := func() string {
+		authURL, err := a.AuthCodeURL(ctx)
+		require.NoError(t, err)
+		userID, _, err := a.FetchWithState(ctx, m.login(authURL), mustQuery(t, authURL, "state"))
+		require.NoError(t, err)
+		return userID
+	}
+	assert.Equal(t, login(), login())
+	assert.Len(t, users.saved, 1)
+}
+
+func TestOIDCAuthService_State(t *testing.T) {
+	m := newMockOIDC(t, map[string]interface{}{"sub": "kc-1", "phone_number": "13800000001", "phone_number_verified": true})
+	a := newTestOIDCAuthService(m, &fakeOIDCUsers{fakeUsers: fakeUsers{{UserID: "ding-1", Mobile: "13800000001"}}}, false)
+	ctx := context.Background()
+
+	first, err := a.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	second, err := a.AuthCodeURL(ctx)
+	require.NoError(t, err)
+
+	// 没有 state 时无法完成 PKCE
+	_, _, err = a.Fetch(ctx, m.login(first))
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+
+	// 授权码与另一次登录请求的 code_verifier 不匹配
+	_, _, err = a.FetchWithState(ctx, m.login(first), mustQuery(t, second, "state"))
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+
+	// state 只能使用一次
+	code := m.login(first)
+	userID, _, err := a.FetchWithState(ctx, code, mustQuery(t, first, "state"))
+	require.NoError(t, err)
+	assert.Equal(t, "ding-1", userID)
+	_, _, err = a.FetchWithState(ctx, m.login(first), mustQuery(t, first, "state"))
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+
+	// state 过期
+	third, err := a.AuthCodeURL(ctx)
+	require.NoError(t, err)
+	a.now = func() time.Time { return time.Now().Add(defaultOIDCStateTTL + time.Second) }
+	_, _, err = a.FetchWithState(ctx, m.login(third), mustQuery(t, third, "state"))
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+}
+
+func TestOIDCAuthService_KeyRotation(t *testing.T) {
+	m := newMockOIDC(t, map[string]interface{}{"sub": "kc-1", "phone_number": "13800000001", "phone_number_verified": true})
+	a := newTestOIDCAuthService(m, &fakeOIDCUsers{fakeUsers: fakeUsers{{UserID: "ding-1", Mobile: "13800000001"}}}, false)
+	ctx := context.Background()
+
+	login := func() error {
+		authURL, err := a.AuthCodeURL(ctx)
+		require.NoError(t, err)
+		_, _, err = a.FetchWithState(ctx, m.login(authURL), mustQuery(t, authURL, "state"))
+		return err
+	}
+	require.NoError(t, login())
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	m.rotate(mockSigningKey{kid: "ec-1", method: jwt.SigningMethodES256, key: ecKey})
+
+	// 刚获取过 JWKS，未知 kid 不会立即重新获取
+	assert.ErrorIs(t, login(), errno.ErrUnauthorized)
+
+	a.now = func() time.Time { return time.Now().Add(jwksRefreshInterval) }
+	assert.NoError(t, login())
+
+	// 不在 JWKS 中的密钥签发的 ID token 不被接受
+	rogue, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	m.mu.Lock()
+	m.rogue = &mockSigningKey{kid: "ec-1", method: jwt.SigningMethodRS256, key: rogue}
+	m.mu.Unlock()
+	a.now = func() time.Time { return time.Now().Add(2 * jwksRefreshInterval) }
+	assert.ErrorIs(t, login(), errno.ErrUnauthorized)
+}
+
+func mustQuery(t *testing.T, rawURL, key string) string {
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return u.Query().Get(key)
+}
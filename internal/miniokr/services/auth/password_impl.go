@@ -0,0 +1,227 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	authpkg "github.com/imxw/miniokr/pkg/auth"
+)
+
+const (
+	// DefaultMaxFailedAttempts 是锁定本地账号前允许的连续密码错误次数
+	DefaultMaxFailedAttempts = 5
+	// DefaultLockoutDuration 是本地账号被锁定的时长
+	DefaultLockoutDuration = 15 * time.Minute
+	// MinPasswordLength 是本地账号密码的最小长度，与 errno.ErrPasswordTooShort 一致
+	MinPasswordLength = 8
+
+	// localUserIDPrefix 是本地账号对应用户的 ID 前缀，避免与钉钉用户 ID 冲突
+	localUserIDPrefix = "local-"
+)
+
+// PasswordConfig 是本地账号登录的配置
+type PasswordConfig struct {
+	MaxFailedAttempts int
+	LockoutDuration   time.Duration
+}
+
+// Account 是创建本地账号的参数
+type Account struct {
+	Username string
+	Name     string
+	Password string
+	// Kind 为 model.CredentialKindAdmin 或 model.CredentialKindService
+	Kind string
+}
+
+// UserGetter 按 ID 获取用户
+type UserGetter interface {
+	GetUserByID(ctx context.Context, id string) (*model.User, error)
+}
+
+// SessionRevoker 吊销用户的全部会话
+type SessionRevoker interface {
+	RevokeUser(ctx context.Context, userID string) error
+}
+
+// PasswordAuthService 使用本地账号的用户名密码登录，
+// 用于钉钉不可用时的管理员登录和系统集成使用的服务账号
+type PasswordAuthService struct {
+	cfg      PasswordConfig
+	creds    store.CredentialStore
+	users    UserGetter
+	sessions SessionRevoker
+	now      func() time.Time
+
+	// dummyHash 用于账号不存在时也执行一次 bcrypt 比较，使响应时间与密码错误时一致
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// NewPasswordAuthService 创建本地账号登录服务，cfg 中未设置的值使用默认值.
+// 停用账号时通过 sessions 吊销账号的全部会话
+func NewPasswordAuthService(cfg PasswordConfig, creds store.CredentialStore, users UserGetter, sessions SessionRevoker) *PasswordAuthService {
+	if cfg.MaxFailedAttempts <= 0 {
+		cfg.MaxFailedAttempts = DefaultMaxFailedAttempts
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = DefaultLockoutDuration
+	}
+	return &PasswordAuthService{cfg: cfg, creds: creds, users: users, sessions: sessions, now: time.Now}
+}
+
+// Login 校验用户名密码，返回账号对应的用户 id 和姓名.
+// 连续 MaxFailedAttempts 次密码错误后账号锁定 LockoutDuration
+func (p *PasswordAuthService) Login(ctx context.Context, username string, password string) (string, string, error) {
+	cred, err := p.creds.GetCredential(ctx, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		p.dummyOnce.Do(func() { p.dummyHash, _ = authpkg.Encrypt("miniokr-dummy-password") })
+		_ = authpkg.Compare(p.dummyHash, password)
+		return "", "", errno.ErrPasswordIncorrect
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	now := p.now()
+	if cred.LockedUntil != nil && now.Before(*cred.LockedUntil) {
+		log.C(ctx).Warnw("Login to locked account", "username", username, "lockedUntil", cred.LockedUntil)
+		return "", "", errno.ErrAccountLocked
+	}
+
+	if err := authpkg.Compare(cred.PasswordHash, password); err != nil {
+		if err := p.creds.RecordFailedLogin(ctx, username, p.cfg.MaxFailedAttempts, now.Add(p.cfg.LockoutDuration)); err != nil {
+			log.C(ctx).Errorw("Failed to record failed login", "username", username, "err", err)
+		}
+		log.C(ctx).Warnw("Incorrect password", "username", username)
+		return "", "", errno.ErrPasswordIncorrect
+	}
+
+	// 密码正确后才提示账号已停用，避免泄露账号状态
+	if cred.Disabled {
+		return "", "", errno.ErrAccountDisabled
+	}
+
+	if err := p.creds.UpdateCredential(ctx, username, map[string]interface{}{
+		"failed_attempts": 0,
+		"locked_until":    nil,
+		"last_login_at":   now,
+	}); err != nil {
+		log.C(ctx).Errorw("Failed to update credential after login", "username", username, "err", err)
+	}
+
+	name := username
+	if user, err := p.users.GetUserByID(ctx, cred.UserID); err == nil {
+		name = user.Name
+	}
+	return cred.UserID, name, nil
+}
+
+// CreateAccount 创建本地账号及其对应的用户，管理员账号同时授予 admin 角色，返回用户 id
+func (p *PasswordAuthService) CreateAccount(ctx context.Context, account Account) (string, error) {
+	var roleName string
+	switch account.Kind {
+	case model.CredentialKindAdmin:
+		roleName = known.AdminRoleName
+	case model.CredentialKindService:
+	default:
+		return "", errno.ErrInvalidParameter
+	}
+	if err := validatePassword(account.Password); err != nil {
+		return "", err
+	}
+
+	hash, err := authpkg.Encrypt(account.Password)
+	if err != nil {
+		return "", err
+	}
+
+	name := account.Name
+	if name == "" {
+		name = account.Username
+	}
+	user := &model.User{
+		UserID: localUserIDPrefix + account.Username,
+		Name:   name,
+		Status: "active",
+	}
+	cred := &model.Credential{
+		Username:     account.Username,
+		UserID:       user.UserID,
+		PasswordHash: hash,
+		Kind:         account.Kind,
+	}
+
+	if err := p.creds.CreateCredential(ctx, user, cred, roleName); err != nil {
+		if errors.Is(err, store.ErrCredentialExists) {
+			return "", errno.ErrAccountAlreadyExist
+		}
+		log.C(ctx).Errorw("Failed to create local account", "username", account.Username, "err", err)
+		return "", err
+	}
+	log.C(ctx).Infow("Local account created", "username", account.Username, "kind", account.Kind)
+
+	return user.UserID, nil
+}
+
+// SetDisabled 停用或启用本地账号，停用时吊销账号的全部会话，已签发的访问令牌立即失效
+func (p *PasswordAuthService) SetDisabled(ctx context.Context, username string, disabled bool) error {
+	if err := p.update(ctx, username, map[string]interface{}{"disabled": disabled}); err != nil {
+		return err
+	}
+	if !disabled {
+		return nil
+	}
+
+	cred, err := p.creds.GetCredential(ctx, username)
+	if err != nil {
+		return err
+	}
+	return p.sessions.RevokeUser(ctx, cred.UserID)
+}
+
+// ResetPassword 重置本地账号的密码，并解除锁定
+func (p *PasswordAuthService) ResetPassword(ctx context.Context, username string, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	hash, err := authpkg.Encrypt(password)
+	if err != nil {
+		return err
+	}
+
+	return p.update(ctx, username, map[string]interface{}{
+		"password_hash":   hash,
+		"failed_attempts": 0,
+		"locked_until":    nil,
+	})
+}
+
+func (p *PasswordAuthService) update(ctx context.Context, username string, fields map[string]interface{}) error {
+	err := p.creds.UpdateCredential(ctx, username, fields)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errno.ErrAccountNotFound
+	}
+	return err
+}
+
+func validatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return errno.ErrPasswordTooShort
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// newPasswordTestDB 创建一个内存 sqlite 数据库并迁移本地账号用到的表
+func newPasswordTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.User{}, &model.Role{}, &model.UserRole{}, &model.RoleChange{}, &model.Credential{},
+		&model.RefreshToken{}, &model.RevokedToken{}, &model.UserTokenRevocation{}))
+	return db
+}
+
+// userGetter 按 ID 查找 users 表中的用户
+type userGetter struct {
+	db *gorm.DB
+}
+
+func (g userGetter) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	var user model.User
+	if err := g.db.WithContext(ctx).First(&user, "user_id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func newTestPasswordAuthService(t *testing.T) (*PasswordAuthService, *gorm.DB) {
+	db := newPasswordTestDB(t)
+	creds := store.NewCredentialStore(db)
+	sessions := NewSessionService(store.NewSessionStore(db), userGetter{db}, creds, time.Hour)
+	p := NewPasswordAuthService(PasswordConfig{MaxFailedAttempts: 3, LockoutDuration: time.Minute}, creds, userGetter{db}, sessions)
+	return p, db
+}
+
+func TestPasswordAuthService_CreateAccount(t *testing.T) {
+	p, db := newTestPasswordAuthService(t)
+	ctx := context.Background()
+
+	uid, err := p.CreateAccount(ctx, Account{Username: "ops", Name: "运维", Password: "correct-horse", Kind: model.CredentialKindAdmin})
+	require.NoError(t, err)
+	assert.Equal(t, "local-ops", uid)
+
+	var cred model.Credential
+	require.NoError(t, db.First(&cred, "username = ?", "ops").Error)
+	assert.NotEqual(t, "correct-horse", cred.PasswordHash)
+
+	var roles []string
+	require.NoError(t, db.Table("user_roles").Joins("JOIN roles ON roles.role_id = user_roles.role_id").
+		Where("user_roles.user_id = ?", uid).Pluck("roles.role_name", &roles).Error)
+	assert.Equal(t, []string{"admin"}, roles)
+
+	// 服务账号不授予角色
+	uid, err = p.CreateAccount(ctx, Account{Username: "ci", Password: "service-secret", Kind: model.CredentialKindService})
+	require.NoError(t, err)
+	var count int64
+	require.NoError(t, db.Model(&model.UserRole{}).Where("user_id = ?", uid).Count(&count).Error)
+	assert.Zero(t, count)
+
+	_, err = p.CreateAccount(ctx, Account{Username: "ops", Password: "another-pass", Kind: model.CredentialKindService})
+	assert.ErrorIs(t, err, errno.ErrAccountAlreadyExist)
+	_, err = p.CreateAccount(ctx, Account{Username: "short", Password: "1234567", Kind: model.CredentialKindService})
+	assert.ErrorIs(t, err, errno.ErrPasswordTooShort)
+	_, err = p.CreateAccount(ctx, Account{Username: "root", Password: "correct-horse", Kind: "root"})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+}
+
+func TestPasswordAuthService_Login(t *testing.T) {
+	p, _ := newTestPasswordAuthService(t)
+	ctx := context.Background()
+	_, err := p.CreateAccount(ctx, Account{Username: "ops", Name: "运维", Password: "correct-horse", Kind: model.CredentialKindAdmin})
+	require.NoError(t, err)
+
+	userID, username, err := p.Login(ctx, "ops", "correct-horse")
+	require.NoError(t, err)
+	assert.Equal(t, "local-ops", userID)
+	assert.Equal(t, "运维", username)
+
+	_, _, err = p.Login(ctx, "nobody", "correct-horse")
+	assert.ErrorIs(t, err, errno.ErrPasswordIncorrect)
+
+	// 停用后即使密码正确也不能登录，启用后恢复
+	require.NoError(t, p.SetDisabled(ctx, "ops", true))
+	_, _, err = p.Login(ctx, "ops", "correct-horse")
+	assert.ErrorIs(t, err, errno.ErrAccountDisabled)
+	_, _, err = p.Login(ctx, "ops", "wrong")
+	assert.ErrorIs(t, err, errno.ErrPasswordIncorrect)
+	require.NoError(t, p.SetDisabled(ctx, "ops", false))
+	_, _, err = p.Login(ctx, "ops", "correct-horse")
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, p.SetDisabled(ctx, "nobody", true), errno.ErrAccountNotFound)
+}
+
+func TestPasswordAuthService_Lockout(t *testing.T) {
+	p, _ := newTestPasswordAuthService(t)
+	ctx := context.Background()
+	_, err := p.CreateAccount(ctx, Account{Username: "ops", Password: "correct-horse", Kind: model.CredentialKindAdmin})
+	require.NoError(t, err)
+
+	// 登录成功会清零失败次数
+	for i := 0; i < 2; i++ {
+		_, _, err = p.Login(ctx, "ops", "wrong")
+		assert.ErrorIs(t, err, errno.ErrPasswordIncorrect)
+	}
+	_, _, err = p.Login(ctx, "ops", "correct-horse")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, _, err = p.Login(ctx, "ops", "wrong")
+		assert.ErrorIs(t, err, errno.ErrPasswordIncorrect)
+	}
+	_, _, err = p.Login(ctx, "ops", "correct-horse")
+	assert.ErrorIs(t, err, errno.ErrAccountLocked)
+
+	// 锁定到期后可以登录
+	now := time.Now()
+	p.now = func() time.Time { return now.Add(time.Minute + time.Second) }
+	_, _, err = p.Login(ctx, "ops", "correct-horse")
+	assert.NoError(t, err)
+
+	// 重置密码解除锁定，旧密码失效
+	p.now = time.Now
+	for i := 0; i < 3; i++ {
+		_, _, _ = p.Login(ctx, "ops", "wrong")
+	}
+	assert.ErrorIs(t, p.ResetPassword(ctx, "ops", "short"), errno.ErrPasswordTooShort)
+	require.NoError(t, p.ResetPassword(ctx, "ops", "battery-staple"))
+	_, _, err = p.Login(ctx, "ops", "correct-horse")
+	assert.ErrorIs(t, err, errno.ErrPasswordIncorrect)
+	_, _, err = p.Login(ctx, "ops", "battery-staple")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	"github.com/imxw/miniokr/pkg/token"
+)
+
+// DefaultRefreshExpiration 是刷新令牌的默认有效期
+const DefaultRefreshExpiration = 7 * 24 * time.Hour
+
+// TokenPair 是登录或刷新后返回的访问令牌和刷新令牌
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// CredentialGetter 按用户 id 获取本地账号，用户不是本地账号时返回 gorm.ErrRecordNotFound
+type CredentialGetter interface {
+	GetCredentialByUserID(ctx context.Context, userID string) (*model.Credential, error)
+}
+
+// SessionService 签发短期访问令牌和保存在服务端的刷新令牌.
+// 每次刷新都会轮换刷新令牌，已轮换的令牌再次使用时视为泄露，整个会话被吊销
+type SessionService struct {
+	store             store.SessionStore
+	users             UserGetter
+	creds             CredentialGetter
+	refreshExpiration time.Duration
+	now               func() time.Time
+}
+
+// NewSessionService 创建会话服务，刷新时通过 users 和 creds 校验用户仍然存在且账号未被停用.
+// refreshExpiration 不大于 0 时使用 DefaultRefreshExpiration
+func NewSessionService(s store.SessionStore, users UserGetter, creds CredentialGetter, refreshExpiration time.Duration) *SessionService {
+	if refreshExpiration <= 0 {
+		refreshExpiration = DefaultRefreshExpiration
+	}
+	return &SessionService{store: s, users: users, creds: creds, refreshExpiration: refreshExpiration, now: time.Now}
+}
+
+// Issue 为登录成功的用户创建新会话
+func (s *SessionService) Issue(ctx context.Context, userid string, username string) (*TokenPair, error) {
+	return s.issue(ctx, "", &model.RefreshToken{
+		SessionID: uuid.New().String(),
+		UserID:    userid,
+		Username:  username,
+	})
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌和刷新令牌
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	hash := hashRefreshToken(refreshToken)
+	current, err := s.store.GetRefreshToken(ctx, hash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errno.ErrTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if current.RevokedAt != nil {
+		// 已轮换的刷新令牌被再次使用，说明令牌可能泄露，吊销整个会话
+		log.C(ctx).Warnw("Reuse of rotated refresh token", "userID", current.UserID, "sessionID", current.SessionID)
+		if err := s.store.RevokeSession(ctx, current.SessionID); err != nil {
+			return nil, err
+		}
+		return nil, errno.ErrTokenInvalid
+	}
+	if !s.now().Before(current.ExpiresAt) {
+		return nil, errno.ErrTokenInvalid
+	}
+	if err := s.checkAccount(ctx, current.UserID); err != nil {
+		return nil, err
+	}
+
+	pair, err := s.issue(ctx, hash, &model.RefreshToken{
+		SessionID: current.SessionID,
+		UserID:    current.UserID,
+		Username:  current.Username,
+	})
+	if errors.Is(err, store.ErrRefreshTokenUsed) {
+		// 并发刷新时只有一个请求成功
+		return nil, errno.ErrTokenInvalid
+	}
+	return pair, err
+}
+
+// Logout 吊销当前访问令牌和它所属的会话
+func (s *SessionService) Logout(ctx context.Context, jti string, sessionID string, expiresAt time.Time) error {
+	if jti != "" {
+		if err := s.store.RevokeToken(ctx, jti, expiresAt); err != nil {
+			return err
+		}
+	}
+	if sessionID != "" {
+		return s.store.RevokeSession(ctx, sessionID)
+	}
+	return nil
+}
+
+// RevokeUser 吊销用户的全部会话，已签发的访问令牌立即失效，如员工离职
+func (s *SessionService) RevokeUser(ctx context.Context, userID string) error {
+	log.C(ctx).Infow("Revoke all sessions of user", "userID", userID)
+	return s.store.RevokeUserSessions(ctx, userID, s.now())
+}
+
+// IsRevoked 判断访问令牌是否已被吊销，claims 为 token.Parse 解析出的内容
+func (s *SessionService) IsRevoked(ctx context.Context, claims map[string]interface{}) (bool, error) {
+	jti, _ := claims["jti"].(string)
+	userID, _ := claims[known.XUserIDKey].(string)
+	iat, _ := claims["iat"].(float64)
+	return s.store.IsTokenRevoked(ctx, jti, userID, time.UnixMilli(int64(math.Round(iat*1e3))))
+}
+
+// StartCleaner 每隔 interval 清理已过期的刷新令牌和吊销记录，ctx 取消时退出
+func (s *SessionService) StartCleaner(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if err := s.store.DeleteExpired(ctx, s.now()); err != nil {
+				log.Errorw("Failed to delete expired sessions", "error", err)
+			}
+		}
+	}
+}
+
+// checkAccount 校验用户仍然存在，且本地账号未被停用
+func (s *SessionService) checkAccount(ctx context.Context, userID string) error {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		log.C(ctx).Warnw("Refresh token of unknown user", "userID", userID, "err", err)
+		return errno.ErrTokenInvalid
+	}
+
+	cred, err := s.creds.GetCredentialByUserID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cred.Disabled {
+		log.C(ctx).Warnw("Refresh token of disabled account", "userID", userID)
+		return errno.ErrAccountDisabled
+	}
+	return nil
+}
+
+// issue 签发访问令牌并保存 next 对应的刷新令牌，rotated 不为空时同时吊销被轮换的刷新令牌
+func (s *SessionService) issue(ctx context.Context, rotated string, next *model.RefreshToken) (*TokenPair, error) {
+	accessToken, err := token.Sign(map[string]interface{}{
+		known.XUserIDKey:    next.UserID,
+		known.XUsernameKey:  next.Username,
+		known.XSessionIDKey: next.SessionID,
+	})
+	if err != nil {
+		return nil, errno.ErrSignToken
+	}
+
+	refreshToken := randomString()
+	next.TokenHash = hashRefreshToken(refreshToken)
+	next.CreatedAt = s.now()
+	next.ExpiresAt = next.CreatedAt.Add(s.refreshExpiration)
+
+	if rotated == "" {
+		err = s.store.CreateRefreshToken(ctx, next)
+	} else {
+		err = s.store.RotateRefreshToken(ctx, rotated, next)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// hashRefreshToken 返回刷新令牌的摘要，数据库泄露时无法直接使用其中的令牌
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	"github.com/imxw/miniokr/pkg/token"
+)
+
+// testJWTSecret 是测试中签发访问令牌的密钥
+const testJWTSecret = "session-test-secret"
+
+func newTestSessionService(t *testing.T) *SessionService {
+	token.Init(token.NewKeySet(token.NewHMACKey(testJWTSecret)), time.Minute)
+
+	db := newPasswordTestDB(t)
+	require.NoError(t, db.Create([]model.User{{UserID: "ding-1", Name: "张三"}, {UserID: "ding-2", Name: "李四"}}).Error)
+	return NewSessionService(store.NewSessionStore(db), userGetter{db}, store.NewCredentialStore(db), time.Hour)
+}
+
+func parseToken(t *testing.T, accessToken string) map[string]interface{} {
+	claims, err := token.Parse(accessToken)
+	require.NoError(t, err)
+	return claims
+}
+
+func assertRevoked(t *testing.T, s *SessionService, accessToken string, want bool) {
+	revoked, err := s.IsRevoked(context.Background(), parseToken(t, accessToken))
+	require.NoError(t, err)
+	assert.Equal(t, want, revoked)
+}
+
+func TestSessionService_Refresh(t *testing.T) {
+	s := newTestSessionService(t)
+	ctx := context.Background()
+
+	pair, err := s.Issue(ctx, "ding-1", "张三")
+	require.NoError(t, err)
+	claims := parseToken(t, pair.AccessToken)
+	assert.Equal(t, "ding-1", claims[known.XUserIDKey])
+	assert.NotEmpty(t, claims["jti"])
+	assertRevoked(t, s, pair.AccessToken, false)
+
+	// 刷新后返回新的令牌，属于同一个会话
+	next, err := s.Refresh(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, pair.RefreshToken, next.RefreshToken)
+	assert.Equal(t, claims[known.XSessionIDKey], parseToken(t, next.AccessToken)[known.XSessionIDKey])
+	assert.Equal(t, "张三", parseToken(t, next.AccessToken)[known.XUsernameKey])
+
+	// 再次使用已轮换的刷新令牌视为泄露，整个会话被吊销
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, errno.ErrTokenInvalid)
+	_, err = s.Refresh(ctx, next.RefreshToken)
+	assert.ErrorIs(t, err, errno.ErrTokenInvalid)
+
+	_, err = s.Refresh(ctx, "unknown")
+	assert.ErrorIs(t, err, errno.ErrTokenInvalid)
+
+	// 过期的刷新令牌
+	pair, err = s.Issue(ctx, "ding-1", "张三")
+	require.NoError(t, err)
+	s.now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, errno.ErrTokenInvalid)
+}
+
+func TestSessionService_Logout(t *testing.T) {
+	s := newTestSessionService(t)
+	ctx := context.Background()
+
+	pair, err := s.Issue(ctx, "ding-1", "张三")
+	require.NoError(t, err)
+	other, err := s.Issue(ctx, "ding-1", "张三")
+	require.NoError(t, err)
+
+	claims := parseToken(t, pair.AccessToken)
+	exp := time.Unix(int64(claims["exp"].(float64)), 0)
+	require.NoError(t, s.Logout(ctx, claims["jti"].(string), claims[known.XSessionIDKey].(string), exp))
+
+	assertRevoked(t, s, pair.AccessToken, true)
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, errno.ErrTokenInvalid)
+
+	// 同一用户的其他会话不受影响
+	assertRevoked(t, s, other.AccessToken, false)
+	_, err = s.Refresh(ctx, other.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestSessionService_RevokeUser(t *testing.T) {
+	s := newTestSessionService(t)
+	ctx := context.Background()
+
+	first, err := s.Issue(ctx, "ding-1", "张三")
+	require.NoError(t, err)
+	second, err := s.Issue(ctx, "ding-1", "张三")
+	require.NoError(t, err)
+	colleague, err := s.Issue(ctx, "ding-2", "李四")
+	require.NoError(t, err)
+
+	require.NoError(t, s.RevokeUser(ctx, "ding-1"))
+
+	for _, pair := range []*TokenPair{first, second} {
+		assertRevoked(t, s, pair.AccessToken, true)
+		_, err = s.Refresh(ctx, pair.RefreshToken)
+		assert.ErrorIs(t, err, errno.ErrTokenInvalid)
+	}
+	assertRevoked(t, s, colleague.AccessToken, false)
+	_, err = s.Refresh(ctx, colleague.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestSessionService_RevokeUserPrecision(t *testing.T) {
+	s := newTestSessionService(t)
+	ctx := context.Background()
+
+	before, err := s.Issue(ctx, "ding-1", "张三")
+	require.NoError(t, err)
+	require.NoError(t, s.RevokeUser(ctx, "ding-1"))
+	time.Sleep(2 * time.Millisecond)
+
+	// 吊销之后签发的令牌不受影响，即使与吊销在同一秒内
+	after, err := s.Issue(ctx, "ding-1", "张三")
+	require.NoError(t, err)
+	assertRevoked(t, s, before.AccessToken, true)
+	assertRevoked(t, s, after.AccessToken, false)
+}
+
+func TestSessionService_RefreshChecksAccount(t *testing.T) {
+	token.Init(token.NewKeySet(token.NewHMACKey(testJWTSecret)), time.Minute)
+	p, db := newTestPasswordAuthService(t)
+	s := p.sessions.(*SessionService)
+	ctx := context.Background()
+
+	uid, err := p.CreateAccount(ctx, Account{Username: "ops", Password: "correct-horse", Kind: model.CredentialKindAdmin})
+	require.NoError(t, err)
+	ci, err := p.CreateAccount(ctx, Account{Username: "ci", Password: "service-secret", Kind: model.CredentialKindService})
+	require.NoError(t, err)
+
+	// 停用账号时吊销全部会话
+	pair, err := s.Issue(ctx, uid, "ops")
+	require.NoError(t, err)
+	require.NoError(t, p.SetDisabled(ctx, "ops", true))
+	assertRevoked(t, s, pair.AccessToken, true)
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, errno.ErrTokenInvalid)
+
+	// 绕过 SetDisabled 停用的账号也不能刷新
+	pair, err = s.Issue(ctx, ci, "ci")
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&model.Credential{}).Where("username = ?", "ci").Update("disabled", true).Error)
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, errno.ErrAccountDisabled)
+
+	// 已删除的用户不能刷新
+	require.NoError(t, db.Create(&model.User{UserID: "ding-1", Name: "张三"}).Error)
+	pair, err = s.Issue(ctx, "ding-1", "张三")
+	require.NoError(t, err)
+	require.NoError(t, db.Delete(&model.User{UserID: "ding-1"}).Error)
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, errno.ErrTokenInvalid)
+}
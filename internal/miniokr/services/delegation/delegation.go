@@ -0,0 +1,261 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package delegation
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/user"
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// activeCacheTTL 是 HasActiveDelegation 结果的缓存时间. 认证中间件每个请求都会调用，
+// 委托实际使用时由 FindDelegation 重新查询，缓存只影响请求是否带有 delegate 角色
+const activeCacheTTL = 30 * time.Second
+
+// activeEntry 是缓存的 HasActiveDelegation 结果
+type activeEntry struct {
+	active    bool
+	checkedAt time.Time
+}
+
+// DelegationService 管理主管授予他人代为查看或评分下属 OKR 的委托.
+// 委托只在主管仍管理被访问用户时生效，主管调岗后委托随之失效
+type DelegationService struct {
+	store store.DelegationStore
+	users user.Service
+	now   func() time.Time
+
+	mu     sync.Mutex
+	active map[string]activeEntry
+}
+
+// NewDelegationService 创建委托服务
+func NewDelegationService(s store.DelegationStore, users user.Service) *DelegationService {
+	return &DelegationService{store: s, users: users, now: time.Now, active: make(map[string]activeEntry)}
+}
+
+// Create 创建 grantorID 授予他人的委托，委托的部门必须在 grantorID 的管理范围内
+func (s *DelegationService) Create(ctx context.Context, grantorID string, r *v1.CreateDelegationRequest) (*v1.Delegation, error) {
+	if r.GranteeID == grantorID || !r.ExpiresAt.After(s.now()) {
+		return nil, errno.ErrInvalidParameter
+	}
+	if _, err := s.users.GetUserByID(ctx, r.GranteeID); err != nil {
+		return nil, errno.ErrUserNotFound
+	}
+
+	roles, err := s.users.GetUserRolesByID(ctx, grantorID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(roles, known.LeaderRoleName) {
+		return nil, errno.ErrForbidden
+	}
+	managed, err := s.users.GetManagedDepartmentIDs(ctx, grantorID)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &model.Delegation{
+		GrantorID: grantorID,
+		GranteeID: r.GranteeID,
+		Scope:     r.Scope,
+		ExpiresAt: r.ExpiresAt,
+	}
+	for _, id := range r.DepartmentIDs {
+		if !slices.Contains(managed, id) {
+			return nil, errno.ErrForbidden
+		}
+		if !slices.Contains(d.DepartmentIDs(), id) {
+			d.Departments = append(d.Departments, model.DelegationDepartment{DepartmentID: id})
+		}
+	}
+	if err := s.store.Create(ctx, d); err != nil {
+		return nil, err
+	}
+	s.forget(d.GranteeID)
+
+	resp := toDelegation(d)
+	return &resp, nil
+}
+
+// List 返回 userID 授予他人或被授予的全部委托，包括已失效的委托
+func (s *DelegationService) List(ctx context.Context, userID string) ([]v1.Delegation, error) {
+	ds, err := s.store.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]v1.Delegation, 0, len(ds))
+	for i := range ds {
+		resp = append(resp, toDelegation(&ds[i]))
+	}
+	return resp, nil
+}
+
+// Revoke 吊销委托，只有委托人可以吊销
+func (s *DelegationService) Revoke(ctx context.Context, userID string, id uint) error {
+	d, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if d.GrantorID != userID {
+		return errno.ErrDelegationNotFound
+	}
+	if err := s.store.Revoke(ctx, id, s.now()); err != nil {
+		return err
+	}
+	s.forget(d.GranteeID)
+	return nil
+}
+
+// ListActions 返回委托下被委托人的操作记录，委托人和被委托人可以查看
+func (s *DelegationService) ListActions(ctx context.Context, userID string, id uint) ([]v1.DelegatedAction, error) {
+	d, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.GrantorID != userID && d.GranteeID != userID {
+		return nil, errno.ErrDelegationNotFound
+	}
+
+	actions, err := s.store.ListActions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]v1.DelegatedAction, 0, len(actions))
+	for _, a := range actions {
+		resp = append(resp, v1.DelegatedAction{
+			GranteeID:    a.GranteeID,
+			TargetUserID: a.TargetUserID,
+			Method:       a.Method,
+			Path:         a.Path,
+			CreatedAt:    a.CreatedAt,
+		})
+	}
+	return resp, nil
+}
+
+// HasActiveDelegation 判断 userID 是否被授予了仍有效的委托，结果缓存 activeCacheTTL.
+// 本实例创建或吊销委托时立即失效，其他实例的变更最多延迟 activeCacheTTL 生效
+func (s *DelegationService) HasActiveDelegation(ctx context.Context, userID string) (bool, error) {
+	now := s.now()
+	s.mu.Lock()
+	e, ok := s.active[userID]
+	s.mu.Unlock()
+	if age := now.Sub(e.checkedAt); ok && age >= 0 && age < activeCacheTTL {
+		return e.active, nil
+	}
+
+	ds, err := s.store.ListActive(ctx, userID, now)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.active[userID] = activeEntry{active: len(ds) > 0, checkedAt: now}
+	s.mu.Unlock()
+	return len(ds) > 0, nil
+}
+
+// forget 清除 granteeID 缓存的 HasActiveDelegation 结果
+func (s *DelegationService) forget(granteeID string) {
+	s.mu.Lock()
+	delete(s.active, granteeID)
+	s.mu.Unlock()
+}
+
+// FindDelegation 返回授予 granteeID 访问 targetUserID 的 OKR 且包含 scope 权限的委托，没有时返回 nil.
+// rate 委托同时包含 view 权限
+func (s *DelegationService) FindDelegation(ctx context.Context, granteeID, targetUserID, scope string) (*model.Delegation, error) {
+	ds, err := s.store.ListActive(ctx, granteeID, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ds {
+		d := &ds[i]
+		if d.Scope != scope && d.Scope != model.DelegationScopeRate {
+			continue
+		}
+		ok, err := s.covers(ctx, d, targetUserID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+// RecordAction 记录被委托人通过委托 d 访问 targetUserID 的 OKR 的请求
+func (s *DelegationService) RecordAction(ctx context.Context, d *model.Delegation, targetUserID, method, path string) error {
+	return s.store.CreateAction(ctx, &model.DelegatedAction{
+		DelegationID: d.ID,
+		GrantorID:    d.GrantorID,
+		GranteeID:    d.GranteeID,
+		TargetUserID: targetUserID,
+		Method:       method,
+		Path:         path,
+	})
+}
+
+// covers 判断 targetUserID 是否在委托的部门中，且仍由委托人管理
+func (s *DelegationService) covers(ctx context.Context, d *model.Delegation, targetUserID string) (bool, error) {
+	userIDs, err := s.users.GetDepartmentUserIDs(ctx, d.DepartmentIDs())
+	if err != nil {
+		return false, err
+	}
+	if !slices.Contains(userIDs, targetUserID) {
+		return false, nil
+	}
+
+	// 委托人不再是主管时委托不再生效
+	roles, err := s.users.GetUserRolesByID(ctx, d.GrantorID)
+	if err != nil {
+		return false, err
+	}
+	if !slices.Contains(roles, known.LeaderRoleName) {
+		return false, nil
+	}
+	managed, err := s.users.GetManagedUserIDs(ctx, d.GrantorID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(managed, targetUserID), nil
+}
+
+func (s *DelegationService) get(ctx context.Context, id uint) (*model.Delegation, error) {
+	d, err := s.store.Get(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errno.ErrDelegationNotFound
+	}
+	return d, err
+}
+
+func toDelegation(d *model.Delegation) v1.Delegation {
+	return v1.Delegation{
+		ID:            d.ID,
+		GrantorID:     d.GrantorID,
+		GranteeID:     d.GranteeID,
+		Scope:         d.Scope,
+		DepartmentIDs: d.DepartmentIDs(),
+		ExpiresAt:     d.ExpiresAt,
+		RevokedAt:     d.RevokedAt,
+		CreatedAt:     d.CreatedAt,
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package delegation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/user"
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+func intPtr(i int) *int { return &i }
+
+// newTestDB 创建组织架构：公司(1) > 研发(2) > 后端(3)，公司(1) > 销售(5).
+// lead 是研发部门的主管，deputy 是销售部门的成员
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&model.Department{}, &model.User{}, &model.UserDepartment{}, &model.Role{}, &model.UserRole{},
+		&model.SyncGeneration{}, &model.Delegation{}, &model.DelegationDepartment{}, &model.DelegatedAction{},
+	))
+
+	require.NoError(t, db.Create([]model.Department{
+		{DepartmentID: 1, Name: "公司"},
+		{DepartmentID: 2, Name: "研发", ParentID: intPtr(1)},
+		{DepartmentID: 3, Name: "后端", ParentID: intPtr(2)},
+		{DepartmentID: 5, Name: "销售", ParentID: intPtr(1)},
+	}).Error)
+	require.NoError(t, db.Create([]model.User{
+		{UserID: "lead", Name: "Lead"},
+		{UserID: "u3", Name: "U3"},
+		{UserID: "u5", Name: "U5"},
+		{UserID: "deputy", Name: "Deputy"},
+	}).Error)
+	require.NoError(t, db.Create([]model.UserDepartment{
+		{UserID: "lead", DepartmentID: 2, IsLeader: model.True},
+		{UserID: "u3", DepartmentID: 3},
+		{UserID: "u5", DepartmentID: 5},
+		{UserID: "deputy", DepartmentID: 5},
+	}).Error)
+	require.NoError(t, db.Create(&model.Role{RoleID: 2, RoleName: known.LeaderRoleName}).Error)
+	require.NoError(t, db.Create(&model.UserRole{UserID: "lead", RoleID: 2}).Error)
+	return db
+}
+
+func TestDelegation(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	ds := store.NewStore(db)
+	s := NewDelegationService(ds.Delegations(), user.NewUserService(ds.Users()))
+	expiresAt := time.Now().Add(24 * time.Hour)
+
+	t.Run("create", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			grantor string
+			req     v1.CreateDelegationRequest
+			expect  error
+		}{
+			{"not a leader", "deputy", v1.CreateDelegationRequest{GranteeID: "u5", Scope: model.DelegationScopeView, DepartmentIDs: []int{5}, ExpiresAt: expiresAt}, errno.ErrForbidden},
+			{"department not managed", "lead", v1.CreateDelegationRequest{GranteeID: "deputy", Scope: model.DelegationScopeView, DepartmentIDs: []int{5}, ExpiresAt: expiresAt}, errno.ErrForbidden},
+			{"grant to self", "lead", v1.CreateDelegationRequest{GranteeID: "lead", Scope: model.DelegationScopeView, DepartmentIDs: []int{3}, ExpiresAt: expiresAt}, errno.ErrInvalidParameter},
+			{"already expired", "lead", v1.CreateDelegationRequest{GranteeID: "deputy", Scope: model.DelegationScopeView, DepartmentIDs: []int{3}, ExpiresAt: time.Now()}, errno.ErrInvalidParameter},
+			{"unknown grantee", "lead", v1.CreateDelegationRequest{GranteeID: "nobody", Scope: model.DelegationScopeView, DepartmentIDs: []int{3}, ExpiresAt: expiresAt}, errno.ErrUserNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := s.Create(ctx, tt.grantor, &tt.req)
+				assert.Equal(t, tt.expect, err)
+			})
+		}
+	})
+
+	// lead 将后端部门的查看权限委托给 deputy
+	d, err := s.Create(ctx, "lead", &v1.CreateDelegationRequest{
+		GranteeID:     "deputy",
+		Scope:         model.DelegationScopeView,
+		DepartmentIDs: []int{3},
+		ExpiresAt:     expiresAt,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3}, d.DepartmentIDs)
+
+	t.Run("find", func(t *testing.T) {
+		active, err := s.HasActiveDelegation(ctx, "deputy")
+		require.NoError(t, err)
+		assert.True(t, active)
+
+		found, err := s.FindDelegation(ctx, "deputy", "u3", model.DelegationScopeView)
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, d.ID, found.ID)
+
+		// 查看委托不包含评分权限，也不包含委托部门以外的用户
+		for _, c := range []struct{ target, scope string }{
+			{"u3", model.DelegationScopeRate},
+			{"lead", model.DelegationScopeView},
+			{"u5", model.DelegationScopeView},
+		} {
+			found, err := s.FindDelegation(ctx, "deputy", c.target, c.scope)
+			require.NoError(t, err)
+			assert.Nil(t, found, c)
+		}
+	})
+
+	t.Run("actions", func(t *testing.T) {
+		found, err := s.FindDelegation(ctx, "deputy", "u3", model.DelegationScopeView)
+		require.NoError(t, err)
+		require.NoError(t, s.RecordAction(ctx, found, "u3", "GET", "/api/v1/okrs"))
+
+		actions, err := s.ListActions(ctx, "lead", d.ID)
+		require.NoError(t, err)
+		require.Len(t, actions, 1)
+		assert.Equal(t, "deputy", actions[0].GranteeID)
+		assert.Equal(t, "u3", actions[0].TargetUserID)
+
+		_, err = s.ListActions(ctx, "u5", d.ID)
+		assert.Equal(t, errno.ErrDelegationNotFound, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		s.now = func() time.Time { return expiresAt.Add(time.Second) }
+		defer func() { s.now = time.Now }()
+
+		active, err := s.HasActiveDelegation(ctx, "deputy")
+		require.NoError(t, err)
+		assert.False(t, active)
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		active, err := s.HasActiveDelegation(ctx, "u5")
+		require.NoError(t, err)
+		assert.False(t, active)
+
+		// 其他实例创建的委托在缓存过期后生效
+		require.NoError(t, ds.Delegations().Create(ctx, &model.Delegation{
+			GrantorID: "lead", GranteeID: "u5", Scope: model.DelegationScopeView, ExpiresAt: expiresAt,
+			Departments: []model.DelegationDepartment{{DepartmentID: 3}},
+		}))
+		active, err = s.HasActiveDelegation(ctx, "u5")
+		require.NoError(t, err)
+		assert.False(t, active)
+
+		now := time.Now().Add(activeCacheTTL)
+		s.now = func() time.Time { return now }
+		defer func() { s.now = time.Now }()
+		active, err = s.HasActiveDelegation(ctx, "u5")
+		require.NoError(t, err)
+		assert.True(t, active)
+	})
+
+	t.Run("grantor no longer leader", func(t *testing.T) {
+		require.NoError(t, db.Where("user_id = ?", "lead").Delete(&model.UserRole{}).Error)
+		defer func() { require.NoError(t, db.Create(&model.UserRole{UserID: "lead", RoleID: 2}).Error) }()
+
+		found, err := s.FindDelegation(ctx, "deputy", "u3", model.DelegationScopeView)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		assert.Equal(t, errno.ErrDelegationNotFound, s.Revoke(ctx, "deputy", d.ID))
+		require.NoError(t, s.Revoke(ctx, "lead", d.ID))
+
+		active, err := s.HasActiveDelegation(ctx, "deputy")
+		require.NoError(t, err)
+		assert.False(t, active)
+
+		ds, err := s.List(ctx, "deputy")
+		require.NoError(t, err)
+		require.Len(t, ds, 1)
+		assert.NotNil(t, ds[0].RevokedAt)
+	})
+}
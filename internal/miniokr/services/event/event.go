@@ -0,0 +1,120 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+// Package event 处理飞书开放平台推送的多维表格变更事件，使字段缓存在表结构变化后及时失效.
+package event // import "github.com/imxw/miniokr/internal/miniokr/services/event"
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	larkevent "github.com/larksuite/oapi-sdk-go/v3/event"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	larkdrive "github.com/larksuite/oapi-sdk-go/v3/service/drive/v1"
+
+	"github.com/imxw/miniokr/internal/pkg/log"
+)
+
+const (
+	// TypeFieldChanged 是多维表格字段变更事件
+	TypeFieldChanged = "drive.file.bitable_field_changed_v1"
+	// TypeRecordChanged 是多维表格记录变更事件
+	TypeRecordChanged = "drive.file.bitable_record_changed_v1"
+)
+
+// ErrInvalidToken 表示事件中的 verification token 与配置不一致
+var ErrInvalidToken = errors.New("invalid event verification token")
+
+// FieldCache 是可以按表清除的字段缓存，field.Manager 满足该接口
+type FieldCache interface {
+	Invalidate(ctx context.Context, tableID string)
+}
+
+// Handler 处理一个飞书应用推送的多维表格事件，只处理 appToken 对应的多维表格
+type Handler struct {
+	appToken          string
+	verificationToken string
+	fields            FieldCache
+}
+
+// NewHandler 创建 Handler，verificationToken 为飞书开发者后台「事件订阅」中的 Verification Token
+func NewHandler(appToken, verificationToken string, fields FieldCache) *Handler {
+	return &Handler{appToken: appToken, verificationToken: verificationToken, fields: fields}
+}
+
+// Dispatcher 创建处理回调请求的 EventDispatcher.
+// encryptKey 用于解密事件和校验请求签名，为空时飞书推送明文事件且不带签名，因此必须配置
+func (h *Handler) Dispatcher(encryptKey string) (*dispatcher.EventDispatcher, error) {
+	if h.verificationToken == "" || encryptKey == "" {
+		return nil, errors.New("verification token and encrypt key are required")
+	}
+
+	return dispatcher.NewEventDispatcher(h.verificationToken, encryptKey).
+		OnP2FileBitableFieldChangedV1(h.onFieldChanged).
+		OnCustomizedEvent(TypeRecordChanged, func(ctx context.Context, req *larkevent.EventReq) error {
+			return h.onRecordChanged(ctx, req, encryptKey)
+		}), nil
+}
+
+// onFieldChanged 在字段被增删改(包括单选选项变化)时清除该表的字段缓存
+func (h *Handler) onFieldChanged(ctx context.Context, event *larkdrive.P2FileBitableFieldChangedV1) error {
+	if event.EventV2Base == nil || event.EventV2Base.Header == nil || !validToken(event.EventV2Base.Header.Token, h.verificationToken) {
+		return ErrInvalidToken
+	}
+	if event.Event == nil || event.Event.FileToken == nil || event.Event.TableId == nil {
+		return fmt.Errorf("invalid %s event", TypeFieldChanged)
+	}
+
+	fileToken, tableID := *event.Event.FileToken, *event.Event.TableId
+	if fileToken != h.appToken {
+		log.C(ctx).Warnw("Ignore event of unknown bitable", "eventType", TypeFieldChanged, "fileToken", fileToken)
+		return nil
+	}
+
+	log.C(ctx).Infow("Bitable fields changed, invalidate field cache", "tableID", tableID, "eventID", event.EventV2Base.Header.EventID)
+	h.fields.Invalidate(ctx, tableID)
+	return nil
+}
+
+// recordChangedEvent 是记录变更事件中用到的字段，SDK 中没有对应的结构体
+type recordChangedEvent struct {
+	Header *larkevent.EventHeader `json:"header"`
+	Event  struct {
+		FileToken string `json:"file_token"`
+		TableID   string `json:"table_id"`
+	} `json:"event"`
+}
+
+// onRecordChanged 处理记录变更事件. 目前记录都是实时查询的，没有需要清除的记录缓存.
+// 自定义事件的处理器收到的是原始请求，需要自行解密
+func (h *Handler) onRecordChanged(ctx context.Context, req *larkevent.EventReq, encryptKey string) error {
+	var msg larkevent.EventEncryptMsg
+	if err := json.Unmarshal(req.Body, &msg); err != nil {
+		return fmt.Errorf("invalid %s event: %w", TypeRecordChanged, err)
+	}
+	plain, err := larkevent.EventDecrypt(msg.Encrypt, encryptKey)
+	if err != nil {
+		return err
+	}
+
+	var event recordChangedEvent
+	if err := json.Unmarshal(plain, &event); err != nil {
+		return fmt.Errorf("invalid %s event: %w", TypeRecordChanged, err)
+	}
+	if event.Header == nil || !validToken(event.Header.Token, h.verificationToken) {
+		return ErrInvalidToken
+	}
+
+	log.C(ctx).Debugw("Bitable records changed", "fileToken", event.Event.FileToken, "tableID", event.Event.TableID)
+	return nil
+}
+
+// validToken 以常量时间比较事件中的 verification token
+func validToken(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -0,0 +1,260 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"context"
+	"fmt"
+
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable"
+	"github.com/imxw/miniokr/internal/pkg/bitable/field"
+	"github.com/imxw/miniokr/internal/pkg/log"
+)
+
+const (
+	// DefaultObjectiveTableName 是初始化时创建的目标表名称
+	DefaultObjectiveTableName = "目标"
+	// DefaultKeyResultTableName 是初始化时创建的关键结果表名称
+	DefaultKeyResultTableName = "关键结果"
+)
+
+// CompletedOptions 是完成情况字段的选项，与接口参数校验保持一致
+var CompletedOptions = []string{"未开始", "已完成", "未完成"}
+
+// BootstrapOptions 是初始化多维表格的参数
+type BootstrapOptions struct {
+	ObjectiveTableName string
+	KeyResultTableName string
+	// Months 是考核月份字段的选项
+	Months []string
+	// Owners 是员工姓名字段的选项，为空时在写入记录时由飞书自动添加
+	Owners []string
+}
+
+// BootstrapResult 是初始化多维表格的结果
+type BootstrapResult struct {
+	OTableID  string
+	KrTableID string
+	// Objective 和 KeyResult 是按字段名声明的字段绑定，可直接写入配置
+	Objective field.Binding
+	KeyResult field.Binding
+	// Changes 记录本次创建或更新的表和字段，表结构已完整时为空
+	Changes []string
+	// Warnings 记录需要人工处理的问题
+	Warnings []string
+}
+
+// Bootstrapper 在飞书多维表格中创建 OKR 所需的目标表和关键结果表，重复执行时只补齐缺失的部分
+type Bootstrapper struct {
+	tables *bitable.TableManager
+	fields *field.Manager
+}
+
+func NewBootstrapper(tables *bitable.TableManager, fields *field.Manager) *Bootstrapper {
+	return &Bootstrapper{tables: tables, fields: fields}
+}
+
+// objectiveSpecs 返回目标表除双向关联外的字段定义，第一个字段为索引列
+func objectiveSpecs(opts BootstrapOptions) []field.Spec {
+	names := DefaultObjectiveFields
+	return []field.Spec{
+		{Name: names.Title, Type: field.TypeText},
+		{Name: names.Owner, Type: field.TypeSelect, Options: toOptions(opts.Owners)},
+		{Name: names.Date, Type: field.TypeSelect, Options: toOptions(opts.Months)},
+		{Name: names.Weight, Type: field.TypeNumber, Formatter: "0%"},
+	}
+}
+
+// keyResultSpecs 返回关键结果表除双向关联外的字段定义，第一个字段为索引列
+func keyResultSpecs(opts BootstrapOptions) []field.Spec {
+	names := DefaultKeyResultFields
+	return []field.Spec{
+		{Name: names.Title, Type: field.TypeText},
+		{Name: names.Owner, Type: field.TypeSelect, Options: toOptions(opts.Owners)},
+		{Name: names.Date, Type: field.TypeSelect, Options: toOptions(opts.Months)},
+		{Name: names.Weight, Type: field.TypeNumber, Formatter: "0%"},
+		{Name: names.Completed, Type: field.TypeSelect, Options: toOptions(CompletedOptions)},
+		{Name: names.SelfRating, Type: field.TypeNumber, Formatter: "0.0"},
+		{Name: names.Criteria, Type: field.TypeText},
+		{Name: names.Reason, Type: field.TypeText},
+		{Name: names.Leader, Type: field.TypeText},
+		{Name: names.LeaderRating, Type: field.TypeNumber, Formatter: "0.0"},
+		{Name: names.Department, Type: field.TypeText},
+	}
+}
+
+// Init 创建或补齐目标表和关键结果表
+func (b *Bootstrapper) Init(ctx context.Context, opts BootstrapOptions) (*BootstrapResult, error) {
+	if opts.ObjectiveTableName == "" {
+		opts.ObjectiveTableName = DefaultObjectiveTableName
+	}
+	if opts.KeyResultTableName == "" {
+		opts.KeyResultTableName = DefaultKeyResultTableName
+	}
+
+	result := &BootstrapResult{
+		Objective: field.BindingOf(DefaultObjectiveFields),
+		KeyResult: field.BindingOf(DefaultKeyResultFields),
+	}
+
+	tables, err := b.tables.ListTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	oSpecs, krSpecs := objectiveSpecs(opts), keyResultSpecs(opts)
+	if result.OTableID, err = b.ensureTable(ctx, result, tables, opts.ObjectiveTableName, oSpecs); err != nil {
+		return nil, err
+	}
+	if result.KrTableID, err = b.ensureTable(ctx, result, tables, opts.KeyResultTableName, krSpecs); err != nil {
+		return nil, err
+	}
+
+	oFields, err := b.ensureFields(ctx, result, result.OTableID, oSpecs)
+	if err != nil {
+		return nil, err
+	}
+	krFields, err := b.ensureFields(ctx, result, result.KrTableID, krSpecs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.ensureLink(ctx, result, oFields, krFields); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ensureTable 按名称查找数据表，不存在时以 specs[0] 为索引列创建
+func (b *Bootstrapper) ensureTable(ctx context.Context, result *BootstrapResult, tables []bitable.Table, name string, specs []field.Spec) (string, error) {
+	for _, t := range tables {
+		if t.Name == name {
+			return t.TableID, nil
+		}
+	}
+
+	tableID, err := b.tables.CreateTable(ctx, name, []*larkbitable.AppTableCreateHeader{specs[0].Header()})
+	if err != nil {
+		return "", err
+	}
+	log.C(ctx).Infow("Bitable table created", "name", name, "tableID", tableID)
+	result.Changes = append(result.Changes, fmt.Sprintf("创建数据表 %s(%s)", name, tableID))
+	return tableID, nil
+}
+
+// ensureFields 创建缺失的字段，并为单选字段补齐缺失的选项，返回按字段名索引的表字段
+func (b *Bootstrapper) ensureFields(ctx context.Context, result *BootstrapResult, tableID string, specs []field.Spec) (map[string]field.Field, error) {
+	fields, err := b.fields.ListFields(ctx, tableID)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]field.Field, len(fields))
+	for _, f := range fields {
+		byName[f.FieldName] = f
+	}
+
+	for _, spec := range specs {
+		existing, ok := byName[spec.Name]
+		if !ok {
+			created, err := b.fields.CreateField(ctx, tableID, spec)
+			if err != nil {
+				return nil, err
+			}
+			byName[spec.Name] = created
+			result.Changes = append(result.Changes, fmt.Sprintf("表 %s 创建字段 %s", tableID, spec.Name))
+			if computedField(spec.Name) {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("字段 %s 创建为文本字段，如需自动带出请在飞书中改为引用人员表的查找引用字段", spec.Name))
+			}
+			continue
+		}
+
+		if computedField(spec.Name) {
+			continue
+		}
+		if existing.Type != spec.Type {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("表 %s 的字段 %s 类型为 %s，期望为 %s",
+				tableID, spec.Name, field.TypeName(existing.Type), field.TypeName(spec.Type)))
+			continue
+		}
+
+		options, added := mergeOptions(existing.Options, spec.Options)
+		if added == 0 {
+			continue
+		}
+		spec.Options = options
+		updated, err := b.fields.UpdateField(ctx, tableID, existing.FieldID, spec)
+		if err != nil {
+			return nil, err
+		}
+		byName[spec.Name] = updated
+		result.Changes = append(result.Changes, fmt.Sprintf("表 %s 的字段 %s 新增 %d 个选项", tableID, spec.Name, added))
+	}
+
+	return byName, nil
+}
+
+// ensureLink 在目标表中创建指向关键结果表的双向关联，飞书会同时在关键结果表中创建对应字段
+func (b *Bootstrapper) ensureLink(ctx context.Context, result *BootstrapResult, oFields, krFields map[string]field.Field) error {
+	linkName, backName := DefaultObjectiveFields.KeyResultIDs, DefaultKeyResultFields.ObjectiveID
+
+	_, hasLink := oFields[linkName]
+	_, hasBack := krFields[backName]
+	switch {
+	case hasLink && !hasBack:
+		result.Warnings = append(result.Warnings, fmt.Sprintf("关键结果表中缺少与 %s 对应的双向关联字段 %s", linkName, backName))
+		return nil
+	case hasLink:
+		return nil
+	case hasBack:
+		return fmt.Errorf("关键结果表中已存在字段 %s，但目标表中没有 %s，请删除该字段或手动建立双向关联", backName, linkName)
+	}
+
+	_, err := b.fields.CreateField(ctx, result.OTableID, field.Spec{
+		Name:          linkName,
+		Type:          field.TypeDuplexLink,
+		LinkTableID:   result.KrTableID,
+		BackFieldName: backName,
+		Multiple:      true,
+	})
+	if err != nil {
+		return err
+	}
+	result.Changes = append(result.Changes, fmt.Sprintf("创建双向关联 %s <-> %s", linkName, backName))
+	return nil
+}
+
+// computedField 判断字段在原表中是否为引用人员表的查找引用字段，这类字段初始化时创建为文本字段，已存在时不校验类型
+func computedField(name string) bool {
+	return name == DefaultKeyResultFields.Leader || name == DefaultKeyResultFields.Department
+}
+
+// mergeOptions 在已有选项后追加缺失的选项，返回合并后的选项和新增的数量
+func mergeOptions(existing, wanted []field.Option) ([]field.Option, int) {
+	names := make(map[string]bool, len(existing))
+	for _, opt := range existing {
+		names[opt.Name] = true
+	}
+
+	merged := append([]field.Option{}, existing...)
+	for _, opt := range wanted {
+		if !names[opt.Name] {
+			names[opt.Name] = true
+			merged = append(merged, opt)
+		}
+	}
+	return merged, len(merged) - len(existing)
+}
+
+func toOptions(names []string) []field.Option {
+	options := make([]field.Option, 0, len(names))
+	for _, name := range names {
+		options = append(options, field.Option{Name: name})
+	}
+	return options
+}
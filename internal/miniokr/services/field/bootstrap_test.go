@@ -0,0 +1,286 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable"
+	"github.com/imxw/miniokr/internal/pkg/bitable/field"
+	"github.com/imxw/miniokr/internal/pkg/retry"
+)
+
+type staticTokenProvider string
+
+func (p staticTokenProvider) EnsureValidToken(context.Context) (string, error) {
+	return string(p), nil
+}
+
+type fakeField struct {
+	ID      string
+	Name    string
+	Type    int
+	options []map[string]interface{} // 带 ID 的选项
+}
+
+type fakeTable struct {
+	ID     string
+	Name   string
+	Fields []*fakeField
+}
+
+// fakeBase 模拟飞书多维表格的数据表和字段接口
+type fakeBase struct {
+	mu     sync.Mutex
+	seq    int
+	tables []*fakeTable
+	writes int // 创建或更新的次数
+}
+
+func (b *fakeBase) nextID(prefix string) string {
+	b.seq++
+	return fmt.Sprintf("%s%d", prefix, b.seq)
+}
+
+func (b *fakeBase) table(id string) *fakeTable {
+	for _, t := range b.tables {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// newField 根据请求体创建字段，为选项分配 ID
+func (b *fakeBase) newField(name string, typ int, property map[string]interface{}) *fakeField {
+	f := &fakeField{ID: b.nextID("fld"), Name: name, Type: typ}
+	b.setOptions(f, property)
+	return f
+}
+
+func (b *fakeBase) setOptions(f *fakeField, property map[string]interface{}) {
+	opts, _ := property["options"].([]interface{})
+	f.options = nil
+	for _, o := range opts {
+		opt := o.(map[string]interface{})
+		if _, ok := opt["id"]; !ok {
+			opt["id"] = b.nextID("opt")
+		}
+		f.options = append(f.options, opt)
+	}
+}
+
+func (f *fakeField) json() map[string]interface{} {
+	m := map[string]interface{}{"field_id": f.ID, "field_name": f.Name, "type": f.Type}
+	if len(f.options) > 0 {
+		m["property"] = map[string]interface{}{"options": f.options}
+	}
+	return m
+}
+
+func (b *fakeBase) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// /open-apis/bitable/v1/apps/:app_token/tables[/:table_id/fields[/:field_id]]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/open-apis/bitable/v1/apps/"), "/")[1:]
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	var data interface{}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		items := make([]map[string]interface{}, 0, len(b.tables))
+		for _, t := range b.tables {
+			items = append(items, map[string]interface{}{"table_id": t.ID, "name": t.Name})
+		}
+		data = map[string]interface{}{"items": items, "has_more": false}
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		b.writes++
+		req := body["table"].(map[string]interface{})
+		t := &fakeTable{ID: b.nextID("tbl"), Name: req["name"].(string)}
+		for _, h := range req["fields"].([]interface{}) {
+			header := h.(map[string]interface{})
+			property, _ := header["property"].(map[string]interface{})
+			t.Fields = append(t.Fields, b.newField(header["field_name"].(string), int(header["type"].(float64)), property))
+		}
+		b.tables = append(b.tables, t)
+		data = map[string]interface{}{"table_id": t.ID}
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		items := make([]map[string]interface{}, 0)
+		for _, f := range b.table(parts[1]).Fields {
+			items = append(items, f.json())
+		}
+		data = map[string]interface{}{"items": items, "has_more": false}
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		b.writes++
+		t := b.table(parts[1])
+		property, _ := body["property"].(map[string]interface{})
+		f := b.newField(body["field_name"].(string), int(body["type"].(float64)), property)
+		t.Fields = append(t.Fields, f)
+		// 双向关联会在关联表中创建对应字段
+		if f.Type == field.TypeDuplexLink {
+			linked := b.table(property["table_id"].(string))
+			linked.Fields = append(linked.Fields, b.newField(property["back_field_name"].(string), field.TypeDuplexLink, nil))
+		}
+		data = map[string]interface{}{"field": f.json()}
+	case len(parts) == 4 && r.Method == http.MethodPut:
+		b.writes++
+		for _, f := range b.table(parts[1]).Fields {
+			if f.ID == parts[3] {
+				property, _ := body["property"].(map[string]interface{})
+				b.setOptions(f, property)
+				data = map[string]interface{}{"field": f.json()}
+			}
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "msg": "success", "data": data})
+}
+
+func (b *fakeBase) fieldNames(tableName string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, t := range b.tables {
+		if t.Name == tableName {
+			var names []string
+			for _, f := range t.Fields {
+				names = append(names, f.Name)
+			}
+			return names
+		}
+	}
+	return nil
+}
+
+func newTestBootstrapper(t *testing.T, base *fakeBase) *Bootstrapper {
+	srv := httptest.NewServer(base)
+	t.Cleanup(srv.Close)
+
+	client := lark.NewClient("cli_test", "secret", lark.WithOpenBaseUrl(srv.URL), lark.WithEnableTokenCache(false))
+	invoker := bitable.NewInvoker(0, 1, retry.Policy{MaxAttempts: 1})
+	tables := bitable.NewTableManager(client, "app-token", staticTokenProvider("t-test"))
+	tables.SetInvoker(invoker)
+	fields := field.NewManager(client, "app-token", staticTokenProvider("t-test"))
+	fields.SetInvoker(invoker)
+	return NewBootstrapper(tables, fields)
+}
+
+func TestBootstrapper_Init(t *testing.T) {
+	base := &fakeBase{}
+	b := newTestBootstrapper(t, base)
+	ctx := context.Background()
+	opts := BootstrapOptions{Months: []string{"2024年5月", "2024年6月"}}
+
+	result, err := b.Init(ctx, opts)
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.OTableID)
+	assert.NotEmpty(t, result.KrTableID)
+	require.Len(t, base.tables, 2)
+
+	// 目标表的索引列是标题，双向关联在两张表中各有一个字段
+	oNames := base.fieldNames(DefaultObjectiveTableName)
+	assert.Equal(t, DefaultObjectiveFields.Title, oNames[0])
+	assert.Contains(t, oNames, DefaultObjectiveFields.KeyResultIDs)
+	krNames := base.fieldNames(DefaultKeyResultTableName)
+	assert.Contains(t, krNames, DefaultKeyResultFields.ObjectiveID)
+	assert.Contains(t, krNames, DefaultKeyResultFields.Completed)
+
+	// 按字段名声明的绑定可以解析两张表的全部字段
+	fields, err := b.fields.ListFields(ctx, result.KrTableID)
+	require.NoError(t, err)
+	assert.NoError(t, field.ValidateSchema(result.KrTableID, fields, &DefaultKeyResultFields, result.KeyResult))
+	fields, err = b.fields.ListFields(ctx, result.OTableID)
+	require.NoError(t, err)
+	assert.NoError(t, field.ValidateSchema(result.OTableID, fields, &DefaultObjectiveFields, result.Objective))
+
+	for _, f := range fields {
+		if f.FieldName == DefaultObjectiveFields.Date {
+			assert.Len(t, f.Options, 2)
+		}
+	}
+}
+
+func TestBootstrapper_InitIsIdempotent(t *testing.T) {
+	base := &fakeBase{}
+	b := newTestBootstrapper(t, base)
+	ctx := context.Background()
+	opts := BootstrapOptions{Months: []string{"2024年5月"}}
+
+	first, err := b.Init(ctx, opts)
+	require.NoError(t, err)
+	writes := base.writes
+
+	second, err := b.Init(ctx, opts)
+	require.NoError(t, err)
+	assert.Empty(t, second.Changes)
+	assert.Equal(t, writes, base.writes)
+	assert.Equal(t, first.OTableID, second.OTableID)
+	assert.Equal(t, first.KrTableID, second.KrTableID)
+	assert.Len(t, base.tables, 2)
+
+	// 新的考核月份只补充选项，已有选项保留
+	opts.Months = append(opts.Months, "2024年6月")
+	third, err := b.Init(ctx, opts)
+	require.NoError(t, err)
+	assert.Len(t, third.Changes, 2) // 两张表的考核月份字段
+
+	fields, err := b.fields.ListFields(ctx, first.OTableID)
+	require.NoError(t, err)
+	for _, f := range fields {
+		if f.FieldName == DefaultObjectiveFields.Date {
+			require.Len(t, f.Options, 2)
+			assert.Equal(t, "2024年5月", f.Options[0].Name)
+			assert.Equal(t, "2024年6月", f.Options[1].Name)
+		}
+	}
+}
+
+func TestBootstrapper_InitCompletesExistingTable(t *testing.T) {
+	base := &fakeBase{}
+	base.tables = []*fakeTable{{ID: "tblExisting", Name: DefaultObjectiveTableName, Fields: []*fakeField{
+		{ID: "fldTitle", Name: DefaultObjectiveFields.Title, Type: field.TypeText},
+		{ID: "fldWeight", Name: DefaultObjectiveFields.Weight, Type: field.TypeText},
+	}}}
+	b := newTestBootstrapper(t, base)
+
+	result, err := b.Init(context.Background(), BootstrapOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "tblExisting", result.OTableID)
+	assert.Len(t, base.tables, 2)
+	assert.Contains(t, base.fieldNames(DefaultObjectiveTableName), DefaultObjectiveFields.Owner)
+
+	// 类型不一致的字段不会被修改，只给出提示
+	require.NotEmpty(t, result.Warnings)
+	assert.Contains(t, strings.Join(result.Warnings, "\n"), DefaultObjectiveFields.Weight)
+}
+
+func TestBootstrapper_InitRejectsDanglingBackLink(t *testing.T) {
+	base := &fakeBase{}
+	base.tables = []*fakeTable{{ID: "tblKR", Name: DefaultKeyResultTableName, Fields: []*fakeField{
+		{ID: "fldTitle", Name: DefaultKeyResultFields.Title, Type: field.TypeText},
+		{ID: "fldO", Name: DefaultKeyResultFields.ObjectiveID, Type: field.TypeLink},
+	}}}
+	b := newTestBootstrapper(t, base)
+
+	_, err := b.Init(context.Background(), BootstrapOptions{})
+	assert.Error(t, err)
+}
@@ -64,7 +64,12 @@ func (f *FeishuFieldService) GetFieldDefinitions(ctx context.Context) (v1.FieldM
 
 func (f *FeishuFieldService) GetValidDates(ctx context.Context) ([]string, error) {
 
-	dates, err := f.FieldManager.ExtractFieldOptions(ctx, f.OTableID, "考核月份")
+	var objectiveFields v1.ObjectiveField
+	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, f.OTableID, &objectiveFields); err != nil {
+		return nil, err
+	}
+
+	dates, err := f.FieldManager.ExtractFieldOptions(ctx, f.OTableID, objectiveFields.Date)
 	if err != nil {
 		return nil, fmt.Errorf("获取考核月份列表失败: %v", err)
 	}
@@ -93,7 +98,12 @@ func (f *FeishuFieldService) GetValidDates(ctx context.Context) ([]string, error
 
 func (f *FeishuFieldService) GetValidUsers(ctx context.Context) ([]string, error) {
 
-	users, err := f.FieldManager.ExtractFieldOptions(ctx, f.OTableID, "员工姓名")
+	var objectiveFields v1.ObjectiveField
+	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, f.OTableID, &objectiveFields); err != nil {
+		return nil, err
+	}
+
+	users, err := f.FieldManager.ExtractFieldOptions(ctx, f.OTableID, objectiveFields.Owner)
 	if err != nil {
 		return nil, fmt.Errorf("获取用户列表失败: %v", err)
 	}
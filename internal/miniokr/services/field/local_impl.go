@@ -0,0 +1,76 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// DefaultObjectiveFields 是本地模式下目标表的字段名称
+var DefaultObjectiveFields = v1.ObjectiveField{
+	Title:        "O 的内容填写",
+	Owner:        "员工姓名",
+	Date:         "考核月份",
+	Weight:       "O 的权重",
+	KeyResultIDs: "关键结果",
+}
+
+// DefaultKeyResultFields 是本地模式下关键结果表的字段名称
+var DefaultKeyResultFields = v1.KeyResultField{
+	Title:        "KR 的内容",
+	Owner:        "员工姓名",
+	Date:         "考核月份",
+	Weight:       "KR 的权重",
+	Completed:    "完成情况",
+	SelfRating:   "自评分",
+	Criteria:     "衡量标准",
+	ObjectiveID:  "目标",
+	Reason:       "未完成原因",
+	Leader:       "直属上级",
+	LeaderRating: "上级评分",
+	Department:   "部门",
+}
+
+// localMonthsBack 指定本地模式下可选考核月份向前追溯的月数
+const localMonthsBack = 12
+
+var _ Service = (*LocalFieldService)(nil)
+
+// LocalFieldService 是本地模式下的字段服务，不依赖飞书多维表格的表结构
+type LocalFieldService struct {
+	now func() time.Time
+}
+
+func NewLocalFieldService() *LocalFieldService {
+	return &LocalFieldService{now: time.Now}
+}
+
+func (l *LocalFieldService) GetFieldDefinitions(ctx context.Context) (v1.FieldMappingsResponse, error) {
+	return v1.FieldMappingsResponse{
+		Objective: DefaultObjectiveFields,
+		KeyResult: DefaultKeyResultFields,
+	}, nil
+}
+
+// GetValidDates 返回过去一年到下个月的考核月份，按时间升序排列
+func (l *LocalFieldService) GetValidDates(ctx context.Context) ([]string, error) {
+	now := l.now()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	var dates []string
+	for i := -localMonthsBack; i <= 1; i++ {
+		dates = append(dates, current.AddDate(0, i, 0).Format("2006年1月"))
+	}
+	return dates, nil
+}
+
+func (l *LocalFieldService) GetValidUsers(ctx context.Context) ([]string, error) {
+	return nil, errors.New("本地模式不支持从多维表格获取用户列表")
+}
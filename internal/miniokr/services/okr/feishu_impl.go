@@ -10,8 +10,11 @@ import (
 	"errors"
 	"fmt"
 
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+
 	"github.com/imxw/miniokr/internal/pkg/bitable"
 	"github.com/imxw/miniokr/internal/pkg/bitable/field"
+	"github.com/imxw/miniokr/internal/pkg/errno"
 	"github.com/imxw/miniokr/internal/pkg/log"
 	"github.com/imxw/miniokr/internal/pkg/model"
 	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
@@ -45,7 +48,7 @@ func NewFeishuOkrService(OTableID, KrTableID string, fm *field.Manager, rm *bita
 	}, nil
 }
 
-func (f *FeishuOkrService) ListObjectivesByOwner(ctx context.Context, username string, sortBy string, orderBy string) ([]model.Objective, error) {
+func (f *FeishuOkrService) ListObjectivesByOwner(ctx context.Context, username string, months []string, sortBy string, orderBy string) ([]model.Objective, error) {
 
 	tableID := f.OTableID
 
@@ -54,7 +57,7 @@ func (f *FeishuOkrService) ListObjectivesByOwner(ctx context.Context, username s
 		return nil, err
 	}
 
-	oResp, err := f.RecordManager.SearchRecordByUser(ctx, tableID, username, nil)
+	oResp, err := f.RecordManager.SearchRecord(ctx, tableID, nil, ownerFilter(friendlyMapping.Owner, friendlyMapping.Date, username, months).Build())
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +65,7 @@ func (f *FeishuOkrService) ListObjectivesByOwner(ctx context.Context, username s
 	return convertToObjective(oResp, &friendlyMapping, sortBy, orderBy), nil
 
 }
-func (f *FeishuOkrService) ListKeyResultsByOwner(ctx context.Context, username string, sortBy string, orderBy string) ([]model.KeyResult, error) {
+func (f *FeishuOkrService) ListKeyResultsByOwner(ctx context.Context, username string, months []string, sortBy string, orderBy string) ([]model.KeyResult, error) {
 
 	tableID := f.KrTableID
 
@@ -71,45 +74,98 @@ func (f *FeishuOkrService) ListKeyResultsByOwner(ctx context.Context, username s
 		return nil, err
 	}
 
-	KrResp, err := f.RecordManager.SearchRecordByUser(ctx, tableID, username, nil)
+	KrResp, err := f.RecordManager.SearchRecord(ctx, tableID, nil, ownerFilter(friendlyMapping.Owner, friendlyMapping.Date, username, months).Build())
 	if err != nil {
 		return nil, err
 	}
 	return convertToKeyResult(KrResp, &friendlyMapping, sortBy, orderBy), nil
 }
 
+// ListAllObjectives 获取目标表中的全部记录
+func (f *FeishuOkrService) ListAllObjectives(ctx context.Context) ([]model.Objective, error) {
+	var friendlyMapping v1.ObjectiveField
+	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, f.OTableID, &friendlyMapping); err != nil {
+		return nil, err
+	}
+
+	oResp, err := f.RecordManager.SearchRecord(ctx, f.OTableID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return convertToObjective(oResp, &friendlyMapping, "", ""), nil
+}
+
+// ListAllKeyResults 获取关键结果表中的全部记录
+func (f *FeishuOkrService) ListAllKeyResults(ctx context.Context) ([]model.KeyResult, error) {
+	var friendlyMapping v1.KeyResultField
+	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, f.KrTableID, &friendlyMapping); err != nil {
+		return nil, err
+	}
+
+	krResp, err := f.RecordManager.SearchRecord(ctx, f.KrTableID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return convertToKeyResult(krResp, &friendlyMapping, "", ""), nil
+}
+
+// GetObjective 获取目标表中的单条记录
+func (f *FeishuOkrService) GetObjective(ctx context.Context, id string) (*model.Objective, error) {
+	var friendlyMapping v1.ObjectiveField
+	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, f.OTableID, &friendlyMapping); err != nil {
+		return nil, err
+	}
+
+	record, err := f.RecordManager.GetRecord(ctx, f.OTableID, id)
+	if err != nil {
+		return nil, wrapRecordError(err)
+	}
+	objectives := convertToObjective([]*larkbitable.AppTableRecord{record}, &friendlyMapping, "", "")
+	if len(objectives) == 0 {
+		return nil, errno.ErrRecordNotFound
+	}
+	return &objectives[0], nil
+}
+
+// GetKeyResult 获取关键结果表中的单条记录
+func (f *FeishuOkrService) GetKeyResult(ctx context.Context, id string) (*model.KeyResult, error) {
+	var friendlyMapping v1.KeyResultField
+	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, f.KrTableID, &friendlyMapping); err != nil {
+		return nil, err
+	}
+
+	record, err := f.RecordManager.GetRecord(ctx, f.KrTableID, id)
+	if err != nil {
+		return nil, wrapRecordError(err)
+	}
+	krs := convertToKeyResult([]*larkbitable.AppTableRecord{record}, &friendlyMapping, "", "")
+	if len(krs) == 0 {
+		return nil, errno.ErrRecordNotFound
+	}
+	return &krs[0], nil
+}
+
 func (f *FeishuOkrService) CreateObjective(ctx context.Context, objective model.Objective) (string, error) {
 
 	tableID := f.OTableID
 
 	var friendlyMapping v1.ObjectiveField
-	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, tableID, &friendlyMapping); err != nil {
+	if err := f.FieldManager.GetWritableFieldMapping(ctx, tableID, &friendlyMapping); err != nil {
 		return "", err
 	}
 
-	fields := make(map[string]interface{})
-	fields[friendlyMapping.Title] = objective.Title
-	fields[friendlyMapping.Date] = objective.Date
-	fields[friendlyMapping.Owner] = objective.Owner
-	fields[friendlyMapping.Weight] = float32(objective.Weight) / 100.0
-
-	return f.RecordManager.CreateRecord(ctx, tableID, fields)
+	return f.RecordManager.CreateRecord(ctx, tableID, objectiveFields(&friendlyMapping, objective))
 
 }
 func (f *FeishuOkrService) UpdateObjective(ctx context.Context, objective model.Objective) error {
 	tableID := f.OTableID
 
 	var friendlyMapping v1.ObjectiveField
-	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, tableID, &friendlyMapping); err != nil {
+	if err := f.FieldManager.GetWritableFieldMapping(ctx, tableID, &friendlyMapping); err != nil {
 		return err
 	}
-	fields := make(map[string]interface{})
-	fields[friendlyMapping.Title] = objective.Title
-	fields[friendlyMapping.Date] = objective.Date
-	fields[friendlyMapping.Owner] = objective.Owner
-	fields[friendlyMapping.Weight] = float32(objective.Weight) / 100
 
-	return f.RecordManager.UpdateRecord(ctx, tableID, objective.ID, fields)
+	return f.RecordManager.UpdateRecord(ctx, tableID, objective.ID, objectiveFields(&friendlyMapping, objective))
 }
 func (f *FeishuOkrService) DeleteObjectiveByID(ctx context.Context, oid string, krids []string) error {
 	tableID := f.OTableID
@@ -137,22 +193,11 @@ func (f *FeishuOkrService) CreateKeyResult(ctx context.Context, keyResult model.
 	tableID := f.KrTableID
 
 	var friendlyMapping v1.KeyResultField
-	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, tableID, &friendlyMapping); err != nil {
+	if err := f.FieldManager.GetWritableFieldMapping(ctx, tableID, &friendlyMapping); err != nil {
 		return "", err
 	}
 
-	fields := make(map[string]interface{})
-	fields[friendlyMapping.Title] = keyResult.Title
-	fields[friendlyMapping.Date] = keyResult.Date
-	fields[friendlyMapping.Owner] = keyResult.Owner
-	fields[friendlyMapping.Weight] = float32(keyResult.Weight) / 100.0
-	fields[friendlyMapping.Completed] = keyResult.Completed
-	fields[friendlyMapping.SelfRating] = keyResult.SelfRating
-	fields[friendlyMapping.Reason] = keyResult.Reason
-	fields[friendlyMapping.Criteria] = keyResult.Criteria
-
-	fields[friendlyMapping.ObjectiveID] = []string{keyResult.ObjectiveID}
-	return f.RecordManager.CreateRecord(ctx, tableID, fields)
+	return f.RecordManager.CreateRecord(ctx, tableID, keyResultFields(&friendlyMapping, keyResult, false))
 }
 func (f *FeishuOkrService) UpdateKeyResult(ctx context.Context, keyResult model.KeyResult) error {
 
@@ -160,33 +205,118 @@ func (f *FeishuOkrService) UpdateKeyResult(ctx context.Context, keyResult model.
 	tableID := f.KrTableID
 
 	var friendlyMapping v1.KeyResultField
-	if err := f.FieldManager.GetFriendlyFieldMapping(ctx, tableID, &friendlyMapping); err != nil {
+	if err := f.FieldManager.GetWritableFieldMapping(ctx, tableID, &friendlyMapping); err != nil {
 		return err
 	}
-	fields := make(map[string]interface{})
-	fields[friendlyMapping.Title] = keyResult.Title
-	fields[friendlyMapping.Date] = keyResult.Date
-	fields[friendlyMapping.Owner] = keyResult.Owner
-	fields[friendlyMapping.Weight] = float32(keyResult.Weight) / 100
-	fields[friendlyMapping.Completed] = keyResult.Completed
-	fields[friendlyMapping.SelfRating] = keyResult.SelfRating
-	fields[friendlyMapping.Reason] = keyResult.Reason
-	fields[friendlyMapping.Criteria] = keyResult.Criteria
 
-	if keyResult.LeaderRating != nil {
-		fields[friendlyMapping.LeaderRating] = keyResult.LeaderRating
+	return f.RecordManager.UpdateRecord(ctx, tableID, keyResult.ID, keyResultFields(&friendlyMapping, keyResult, true))
+}
+func (f *FeishuOkrService) DeleteKeyResultByID(ctx context.Context, id string) error {
+	tableID := f.KrTableID
+	return f.RecordManager.DeleteRecord(ctx, tableID, id)
+
+}
+
+// SaveOkr 先保存目标，再分别批量新建和批量更新关键结果，最多三次请求
+func (f *FeishuOkrService) SaveOkr(ctx context.Context, objective model.Objective, krs []model.KeyResult) (*SaveOkrResult, error) {
+	result := &SaveOkrResult{ObjectiveID: objective.ID, KeyResults: make([]RecordResult, len(krs))}
+
+	if objective.ID == "" {
+		id, err := f.CreateObjective(ctx, objective)
+		if err != nil {
+			return nil, err
+		}
+		result.ObjectiveID = id
+	} else if err := f.UpdateObjective(ctx, objective); err != nil {
+		return nil, err
 	}
 
-	// TODO: 单独处理
-	if keyResult.ObjectiveID != "" {
+	if len(krs) == 0 {
+		return result, nil
+	}
+
+	var friendlyMapping v1.KeyResultField
+	if err := f.FieldManager.GetWritableFieldMapping(ctx, f.KrTableID, &friendlyMapping); err != nil {
+		return nil, err
+	}
+
+	var (
+		creates     []map[string]interface{}
+		createIndex []int
+		updates     []bitable.RecordUpdate
+		updateIndex []int
+	)
+	for i, kr := range krs {
+		kr.ObjectiveID = result.ObjectiveID
+		if kr.ID == "" {
+			creates = append(creates, keyResultFields(&friendlyMapping, kr, false))
+			createIndex = append(createIndex, i)
+			continue
+		}
+		updates = append(updates, bitable.RecordUpdate{RecordID: kr.ID, Fields: keyResultFields(&friendlyMapping, kr, true)})
+		updateIndex = append(updateIndex, i)
+	}
 
-		fields[friendlyMapping.ObjectiveID] = []string{keyResult.ObjectiveID}
+	if len(creates) > 0 {
+		created := f.RecordManager.BatchCreateRecords(ctx, f.KrTableID, creates)
+		for j, r := range created {
+			result.KeyResults[createIndex[j]] = RecordResult{ID: r.RecordID, Err: r.Err}
+		}
+	}
+	if len(updates) > 0 {
+		updated := f.RecordManager.BatchUpdateRecords(ctx, f.KrTableID, updates)
+		for j, r := range updated {
+			result.KeyResults[updateIndex[j]] = RecordResult{ID: r.RecordID, Err: r.Err}
+		}
 	}
 
-	return f.RecordManager.UpdateRecord(ctx, tableID, keyResult.ID, fields)
+	return result, nil
+}
+
+// wrapRecordError 将多维表格的记录不存在错误转换为业务错误码
+func wrapRecordError(err error) error {
+	if bitable.IsRecordNotFound(err) {
+		return errno.ErrRecordNotFound
+	}
+	return err
 }
-func (f *FeishuOkrService) DeleteKeyResultByID(ctx context.Context, id string) error {
-	tableID := f.KrTableID
-	return f.RecordManager.DeleteRecord(ctx, tableID, id)
 
+// ownerFilter 按字段绑定解析出的员工姓名和考核月份字段名构造筛选条件，传入 months 时只返回这些考核月份的记录
+func ownerFilter(ownerField, dateField, username string, months []string) *bitable.Filter {
+	filter := bitable.And(bitable.Cond(ownerField, bitable.OpIs, username))
+	if len(months) != 0 {
+		filter.Where(bitable.Cond(dateField, bitable.OpContains, months...))
+	}
+	return filter
+}
+
+// objectiveFields 将目标转换为多维表格的字段
+func objectiveFields(m *v1.ObjectiveField, objective model.Objective) map[string]interface{} {
+	fields := make(map[string]interface{})
+	fields[m.Title] = objective.Title
+	fields[m.Date] = objective.Date
+	fields[m.Owner] = objective.Owner
+	fields[m.Weight] = float32(objective.Weight) / 100.0
+	return fields
+}
+
+// keyResultFields 将关键结果转换为多维表格的字段，上级评分仅在更新时写入
+func keyResultFields(m *v1.KeyResultField, keyResult model.KeyResult, withLeaderRating bool) map[string]interface{} {
+	fields := make(map[string]interface{})
+	fields[m.Title] = keyResult.Title
+	fields[m.Date] = keyResult.Date
+	fields[m.Owner] = keyResult.Owner
+	fields[m.Weight] = float32(keyResult.Weight) / 100.0
+	fields[m.Completed] = keyResult.Completed
+	fields[m.SelfRating] = keyResult.SelfRating
+	fields[m.Reason] = keyResult.Reason
+	fields[m.Criteria] = keyResult.Criteria
+
+	if withLeaderRating && keyResult.LeaderRating != nil {
+		fields[m.LeaderRating] = keyResult.LeaderRating
+	}
+	if keyResult.ObjectiveID != "" {
+		fields[m.ObjectiveID] = []string{keyResult.ObjectiveID}
+	}
+	return fields
 }
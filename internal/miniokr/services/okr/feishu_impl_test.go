@@ -0,0 +1,363 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package okr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable"
+	"github.com/imxw/miniokr/internal/pkg/bitable/field"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	"github.com/imxw/miniokr/internal/pkg/retry"
+)
+
+const (
+	testObjectiveTable = "tblObjective"
+	testKeyResultTable = "tblKeyResult"
+)
+
+type staticTokenProvider string
+
+func (p staticTokenProvider) EnsureValidToken(ctx context.Context) (string, error) {
+	return string(p), nil
+}
+
+// fakeTable 是内存中的一张多维表格，records 保存写入时的字段值
+type fakeTable struct {
+	fields  []field.Field
+	records map[string]map[string]interface{}
+	order   []string
+}
+
+// fakeBitable 模拟飞书多维表格的字段和记录接口. 读取时按字段类型将写入的值转换为接口返回的格式，
+// 目标表的关键结果字段与关键结果表的关联目标字段是双向关联，由关联目标字段反向计算
+type fakeBitable struct {
+	mu     sync.Mutex
+	seq    int
+	tables map[string]*fakeTable
+}
+
+func newFakeBitable() *fakeBitable {
+	return &fakeBitable{tables: map[string]*fakeTable{
+		testObjectiveTable: {records: make(map[string]map[string]interface{}), fields: []field.Field{
+			{FieldID: "fldp1iQXFv", FieldName: "目标", Type: field.TypeText},
+			{FieldID: "fld8vFDsXz", FieldName: "员工姓名", Type: field.TypeText},
+			{FieldID: "fldc36J6LW", FieldName: "考核月份", Type: field.TypeSelect},
+			{FieldID: "fldgooDzO7", FieldName: "权重", Type: field.TypeNumber},
+			{FieldID: "fld1dLUo8S", FieldName: "关键结果", Type: field.TypeDuplexLink},
+		}},
+		testKeyResultTable: {records: make(map[string]map[string]interface{}), fields: []field.Field{
+			{FieldID: "fldrxgL9LV", FieldName: "关键结果", Type: field.TypeText},
+			{FieldID: "fldjEZmY3S", FieldName: "员工姓名", Type: field.TypeText},
+			{FieldID: "fldnMxJlKj", FieldName: "考核月份", Type: field.TypeSelect},
+			{FieldID: "fld5HXzwmN", FieldName: "权重", Type: field.TypeNumber},
+			{FieldID: "fldG2nSDTZ", FieldName: "完成情况", Type: field.TypeSelect},
+			{FieldID: "fldvjvtxRr", FieldName: "自评分", Type: field.TypeNumber},
+			{FieldID: "fldPGxpg2b", FieldName: "衡量标准", Type: field.TypeText},
+			{FieldID: "fldW8TFesB", FieldName: "关联目标", Type: field.TypeDuplexLink},
+			{FieldID: "fld6OsYad8", FieldName: "未完成原因", Type: field.TypeText},
+			{FieldID: "fldfPqFgwt", FieldName: "直属上级", Type: field.TypeLookup},
+			{FieldID: "fld6x4tz0t", FieldName: "上级评分", Type: field.TypeNumber},
+			{FieldID: "fldeDq4odj", FieldName: "部门", Type: field.TypeLookup},
+		}},
+	}}
+}
+
+func (f *fakeBitable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 路径为 /open-apis/bitable/v1/apps/:app_token/tables/:table_id/...
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/open-apis/bitable/v1/apps/"), "/")
+	if len(parts) < 4 || parts[1] != "tables" || r.Header.Get("Authorization") != "Bearer t-test" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	table, ok := f.tables[parts[2]]
+	if !ok {
+		writeFakeResponse(w, 1254041, nil)
+		return
+	}
+
+	var body struct {
+		Fields  map[string]interface{} `json:"fields"`
+		Records json.RawMessage        `json:"records"`
+		Filter  *struct {
+			Conjunction string `json:"conjunction"`
+			Conditions  []struct {
+				FieldName string   `json:"field_name"`
+				Operator  string   `json:"operator"`
+				Value     []string `json:"value"`
+			} `json:"conditions"`
+		} `json:"filter"`
+	}
+	if r.Body != nil && r.ContentLength != 0 {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	action := strings.Join(parts[3:], "/")
+	switch {
+	case action == "fields" && r.Method == http.MethodGet:
+		items := make([]map[string]interface{}, 0, len(table.fields))
+		for _, fd := range table.fields {
+			items = append(items, map[string]interface{}{"field_id": fd.FieldID, "field_name": fd.FieldName, "type": fd.Type})
+		}
+		writeFakeResponse(w, 0, map[string]interface{}{"items": items, "has_more": false, "total": len(items)})
+
+	case action == "records" && r.Method == http.MethodPost:
+		id := f.create(table, body.Fields)
+		writeFakeResponse(w, 0, map[string]interface{}{"record": f.render(table, id)})
+
+	case action == "records/search":
+		items := make([]map[string]interface{}, 0)
+		for _, id := range table.order {
+			matched := body.Filter == nil || body.Filter.Conjunction == "and"
+			if body.Filter != nil {
+				for _, c := range body.Filter.Conditions {
+					ok := matchCondition(table.records[id][c.FieldName], c.Operator, c.Value)
+					if body.Filter.Conjunction == "and" {
+						matched = matched && ok
+					} else {
+						matched = matched || ok
+					}
+				}
+			}
+			if matched {
+				items = append(items, f.render(table, id))
+			}
+		}
+		writeFakeResponse(w, 0, map[string]interface{}{"items": items, "has_more": false, "total": len(items)})
+
+	case action == "records/batch_create":
+		var records []struct {
+			Fields map[string]interface{} `json:"fields"`
+		}
+		_ = json.Unmarshal(body.Records, &records)
+		created := make([]map[string]interface{}, 0, len(records))
+		for _, rec := range records {
+			created = append(created, f.render(table, f.create(table, rec.Fields)))
+		}
+		writeFakeResponse(w, 0, map[string]interface{}{"records": created})
+
+	case action == "records/batch_update":
+		var records []struct {
+			RecordID string                 `json:"record_id"`
+			Fields   map[string]interface{} `json:"fields"`
+		}
+		_ = json.Unmarshal(body.Records, &records)
+		// 任何一条记录不存在时整批失败
+		for _, rec := range records {
+			if _, ok := table.records[rec.RecordID]; !ok {
+				writeFakeResponse(w, 1254043, nil)
+				return
+			}
+		}
+		updated := make([]map[string]interface{}, 0, len(records))
+		for _, rec := range records {
+			f.update(table, rec.RecordID, rec.Fields)
+			updated = append(updated, f.render(table, rec.RecordID))
+		}
+		writeFakeResponse(w, 0, map[string]interface{}{"records": updated})
+
+	case action == "records/batch_delete":
+		var ids []string
+		_ = json.Unmarshal(body.Records, &ids)
+		for _, id := range ids {
+			if _, ok := table.records[id]; !ok {
+				writeFakeResponse(w, 1254043, nil)
+				return
+			}
+		}
+		for _, id := range ids {
+			f.delete(table, id)
+		}
+		writeFakeResponse(w, 0, map[string]interface{}{})
+
+	case strings.HasPrefix(action, "records/"):
+		id := strings.TrimPrefix(action, "records/")
+		if _, ok := table.records[id]; !ok {
+			writeFakeResponse(w, 1254043, nil)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeFakeResponse(w, 0, map[string]interface{}{"record": f.render(table, id)})
+		case http.MethodPut:
+			f.update(table, id, body.Fields)
+			writeFakeResponse(w, 0, map[string]interface{}{"record": f.render(table, id)})
+		case http.MethodDelete:
+			f.delete(table, id)
+			writeFakeResponse(w, 0, map[string]interface{}{"deleted": true, "record_id": id})
+		}
+
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func (f *fakeBitable) create(table *fakeTable, fields map[string]interface{}) string {
+	f.seq++
+	id := fmt.Sprintf("rec%03d", f.seq)
+	table.records[id] = map[string]interface{}{}
+	table.order = append(table.order, id)
+	f.update(table, id, fields)
+	return id
+}
+
+// update 只修改请求中的字段，值为 null 时清空该字段
+func (f *fakeBitable) update(table *fakeTable, id string, fields map[string]interface{}) {
+	for name, value := range fields {
+		if value == nil {
+			delete(table.records[id], name)
+			continue
+		}
+		table.records[id][name] = value
+	}
+}
+
+func (f *fakeBitable) delete(table *fakeTable, id string) {
+	delete(table.records, id)
+	table.order = slices.DeleteFunc(table.order, func(s string) bool { return s == id })
+}
+
+// render 按字段类型返回记录在接口中的格式
+func (f *fakeBitable) render(table *fakeTable, id string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, fd := range table.fields {
+		value, ok := table.records[id][fd.FieldName]
+		switch fd.Type {
+		case field.TypeText:
+			if ok {
+				fields[fd.FieldName] = []interface{}{map[string]interface{}{"text": value, "type": "text"}}
+			}
+		case field.TypeDuplexLink:
+			if ids := f.linkedIDs(table, id, fd.FieldName); len(ids) > 0 {
+				fields[fd.FieldName] = map[string]interface{}{"link_record_ids": ids}
+			}
+		default:
+			if ok {
+				fields[fd.FieldName] = value
+			}
+		}
+	}
+
+	var created int64
+	for i, recordID := range table.order {
+		if recordID == id {
+			created = int64(i + 1)
+		}
+	}
+	return map[string]interface{}{"record_id": id, "fields": fields, "created_time": created, "last_modified_time": created}
+}
+
+// linkedIDs 返回双向关联字段关联的记录，目标表的关键结果字段由关键结果表的关联目标字段反向计算
+func (f *fakeBitable) linkedIDs(table *fakeTable, id, fieldName string) []interface{} {
+	if table != f.tables[testObjectiveTable] {
+		ids, _ := table.records[id][fieldName].([]interface{})
+		return ids
+	}
+
+	krs := f.tables[testKeyResultTable]
+	var ids []interface{}
+	for _, krID := range krs.order {
+		if linked, _ := krs.records[krID]["关联目标"].([]interface{}); slices.Contains(linked, interface{}(id)) {
+			ids = append(ids, krID)
+		}
+	}
+	return ids
+}
+
+// matchCondition 判断字段值是否满足筛选条件，contains 匹配任意一个值即可
+func matchCondition(value interface{}, operator string, values []string) bool {
+	s, _ := value.(string)
+	switch operator {
+	case bitable.OpIs:
+		return len(values) == 1 && s == values[0]
+	case bitable.OpContains:
+		for _, v := range values {
+			if strings.Contains(s, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeFakeResponse(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": http.StatusText(http.StatusOK), "data": data})
+}
+
+func newTestFeishuService(t *testing.T) *FeishuOkrService {
+	srv := httptest.NewServer(newFakeBitable())
+	t.Cleanup(srv.Close)
+
+	client := lark.NewClient("cli_test", "secret", lark.WithOpenBaseUrl(srv.URL), lark.WithEnableTokenCache(false))
+	invoker := bitable.NewInvoker(0, 1, retry.Policy{MaxAttempts: 1})
+
+	fm := field.NewManager(client, "app-token", staticTokenProvider("t-test"))
+	fm.SetCache(field.NewMemoryCache())
+	fm.SetInvoker(invoker)
+	rm := bitable.NewRecordManager(client, "app-token", staticTokenProvider("t-test"))
+	rm.SetInvoker(invoker)
+
+	svc, err := NewFeishuOkrService(testObjectiveTable, testKeyResultTable, fm, rm)
+	require.NoError(t, err)
+	return svc
+}
+
+func TestFeishuOkrService_Behavior(t *testing.T) {
+	testServiceBehavior(t, newTestFeishuService(t))
+}
+
+func TestFeishuOkrService_NotFound(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestFeishuService(t)
+
+	_, err := svc.GetObjective(ctx, "recMissing")
+	assert.ErrorIs(t, err, errno.ErrRecordNotFound)
+	_, err = svc.GetKeyResult(ctx, "recMissing")
+	assert.ErrorIs(t, err, errno.ErrRecordNotFound)
+}
+
+func TestFeishuOkrService_SaveOkr(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestFeishuService(t)
+
+	result, err := svc.SaveOkr(ctx, model.Objective{Title: "O1", Owner: "张三", Date: "2024年5月", Weight: 100}, []model.KeyResult{
+		{Title: "KR1", Owner: "张三", Date: "2024年5月", Weight: 60, Completed: "未开始"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, result.KeyResults[0].Err)
+
+	// 批量更新是原子的，其中一条记录不存在时同一批的记录都失败，新建的记录不受影响
+	result, err = svc.SaveOkr(ctx, model.Objective{ID: result.ObjectiveID, Title: "O1", Owner: "张三", Date: "2024年5月", Weight: 100}, []model.KeyResult{
+		{ID: result.KeyResults[0].ID, Title: "KR1 updated", Owner: "张三", Date: "2024年5月", Weight: 60, Completed: "已完成"},
+		{ID: "recMissing", Title: "KR?", Owner: "张三", Date: "2024年5月", Weight: 10, Completed: "未开始"},
+		{Title: "KR2", Owner: "张三", Date: "2024年5月", Weight: 40, Completed: "未开始"},
+	})
+	require.NoError(t, err)
+	assert.Error(t, result.KeyResults[0].Err)
+	assert.Error(t, result.KeyResults[1].Err)
+	assert.NoError(t, result.KeyResults[2].Err)
+
+	objective, err := svc.GetObjective(ctx, result.ObjectiveID)
+	require.NoError(t, err)
+	assert.Len(t, objective.KrsIds, 2)
+}
@@ -31,9 +31,15 @@ func convertToObjective(data []*larkbitable.AppTableRecord, of *v1.ObjectiveFiel
 			title = []interface{}{} // 默认为空切片，避免 panic
 		}
 
+		// 安全提取 Owner 字段
+		owner, ok := fields[of.Owner].([]interface{})
+		if !ok {
+			owner = []interface{}{} // 默认为空切片，避免 panic
+		}
+
 		objective := model.Objective{
 			ID:               OPrefix + *record.RecordId,
-			Owner:            extractText(fields[of.Owner].([]interface{})),
+			Owner:            extractText(owner),
 			Date:             extractString(fields, of.Date),
 			Weight:           extractFloatToInt(fields, of.Weight),
 			KrsIds:           extractRecordIDs(fields, of.KeyResultIDs, KrPrefix),
@@ -75,6 +81,12 @@ func convertToKeyResult(data []*larkbitable.AppTableRecord, of *v1.KeyResultFiel
 			reason = []interface{}{} // 默认为空切片，避免 panic
 		}
 
+		// 安全提取 Owner 字段
+		owner, ok := fields[of.Owner].([]interface{})
+		if !ok {
+			owner = []interface{}{} // 默认为空切片，避免 panic
+		}
+
 		// 安全提取 Objective ID
 		objectiveIDs := extractRecordIDs(fields, of.ObjectiveID, OPrefix)
 		var objectiveID string
@@ -84,7 +96,7 @@ func convertToKeyResult(data []*larkbitable.AppTableRecord, of *v1.KeyResultFiel
 
 		kr := model.KeyResult{
 			ID:               KrPrefix + *record.RecordId,
-			Owner:            extractText(fields[of.Owner].([]interface{})),
+			Owner:            extractText(owner),
 			Date:             extractString(fields, of.Date),
 			Weight:           extractFloatToInt(fields, of.Weight),
 			Completed:        extractString(fields, of.Completed),
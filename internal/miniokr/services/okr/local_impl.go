@@ -0,0 +1,257 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package okr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+var _ Service = (*LocalOkrService)(nil)
+
+// LocalOkrService 是基于本地数据库(gorm)的 OKR 服务实现，不依赖飞书多维表格
+type LocalOkrService struct {
+	store store.OkrStore
+}
+
+func NewLocalOkrService(s store.OkrStore) (*LocalOkrService, error) {
+	if s == nil {
+		return nil, errors.New("store cannot be nil")
+	}
+	return &LocalOkrService{store: s}, nil
+}
+
+func (l *LocalOkrService) ListObjectivesByOwner(ctx context.Context, username string, months []string, sortBy string, orderBy string) ([]model.Objective, error) {
+	records, err := l.store.ListObjectivesByOwner(ctx, username, months)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.RecordID)
+	}
+	krIDs, err := l.store.ListKeyResultIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	objectives := make([]model.Objective, 0, len(records))
+	for _, r := range records {
+		objectives = append(objectives, recordToObjective(r, krIDs[r.RecordID]))
+	}
+
+	sortObjectives(objectives, sortBy, orderBy)
+	return objectives, nil
+}
+
+func (l *LocalOkrService) ListKeyResultsByOwner(ctx context.Context, username string, months []string, sortBy string, orderBy string) ([]model.KeyResult, error) {
+	records, err := l.store.ListKeyResultsByOwner(ctx, username, months)
+	if err != nil {
+		return nil, err
+	}
+
+	krs := make([]model.KeyResult, 0, len(records))
+	for _, r := range records {
+		krs = append(krs, recordToKeyResult(r))
+	}
+
+	sortKeyResults(krs, sortBy, orderBy)
+	return krs, nil
+}
+
+func (l *LocalOkrService) GetObjective(ctx context.Context, id string) (*model.Objective, error) {
+	record, err := l.store.GetObjective(ctx, id)
+	if err != nil {
+		return nil, wrapStoreError(err)
+	}
+	krIDs, err := l.store.ListKeyResultIDs(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+
+	objective := recordToObjective(*record, krIDs[id])
+	return &objective, nil
+}
+
+func (l *LocalOkrService) GetKeyResult(ctx context.Context, id string) (*model.KeyResult, error) {
+	record, err := l.store.GetKeyResult(ctx, id)
+	if err != nil {
+		return nil, wrapStoreError(err)
+	}
+
+	kr := recordToKeyResult(*record)
+	return &kr, nil
+}
+
+func (l *LocalOkrService) CreateObjective(ctx context.Context, objective model.Objective) (string, error) {
+	record := model.ObjectiveRecord{
+		Title:  objective.Title,
+		Owner:  objective.Owner,
+		Date:   objective.Date,
+		Weight: objective.Weight,
+	}
+	if err := l.store.CreateObjective(ctx, &record); err != nil {
+		return "", fmt.Errorf("failed to create objective: %w", err)
+	}
+	return record.RecordID, nil
+}
+
+func (l *LocalOkrService) UpdateObjective(ctx context.Context, objective model.Objective) error {
+	record, err := l.store.GetObjective(ctx, objective.ID)
+	if err != nil {
+		return wrapStoreError(err)
+	}
+
+	record.Title = objective.Title
+	record.Date = objective.Date
+	record.Owner = objective.Owner
+	record.Weight = objective.Weight
+
+	if err := l.store.UpdateObjective(ctx, record); err != nil {
+		return fmt.Errorf("failed to update objective: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalOkrService) DeleteObjectiveByID(ctx context.Context, oid string, krids []string) error {
+	if err := l.store.DeleteObjective(ctx, oid, krids); err != nil {
+		return wrapStoreError(err)
+	}
+	return nil
+}
+
+func (l *LocalOkrService) CreateKeyResult(ctx context.Context, keyResult model.KeyResult) (string, error) {
+	record := model.KeyResultRecord{
+		ObjectiveID: keyResult.ObjectiveID,
+		Title:       keyResult.Title,
+		Owner:       keyResult.Owner,
+		Date:        keyResult.Date,
+		Weight:      keyResult.Weight,
+		Completed:   keyResult.Completed,
+		SelfRating:  keyResult.SelfRating,
+		Reason:      keyResult.Reason,
+		Criteria:    keyResult.Criteria,
+	}
+	if err := l.store.CreateKeyResult(ctx, &record); err != nil {
+		return "", fmt.Errorf("failed to create key result: %w", err)
+	}
+	return record.RecordID, nil
+}
+
+func (l *LocalOkrService) UpdateKeyResult(ctx context.Context, keyResult model.KeyResult) error {
+	record, err := l.store.GetKeyResult(ctx, keyResult.ID)
+	if err != nil {
+		return wrapStoreError(err)
+	}
+
+	record.Title = keyResult.Title
+	record.Date = keyResult.Date
+	record.Owner = keyResult.Owner
+	record.Weight = keyResult.Weight
+	record.Completed = keyResult.Completed
+	record.SelfRating = keyResult.SelfRating
+	record.Reason = keyResult.Reason
+	record.Criteria = keyResult.Criteria
+
+	// 与飞书实现保持一致：上级评分和关联目标仅在提供时更新
+	if keyResult.LeaderRating != nil {
+		record.LeaderRating = keyResult.LeaderRating
+	}
+	if keyResult.ObjectiveID != "" {
+		record.ObjectiveID = keyResult.ObjectiveID
+	}
+
+	if err := l.store.UpdateKeyResult(ctx, record); err != nil {
+		return fmt.Errorf("failed to update key result: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalOkrService) DeleteKeyResultByID(ctx context.Context, id string) error {
+	if err := l.store.DeleteKeyResult(ctx, id); err != nil {
+		return wrapStoreError(err)
+	}
+	return nil
+}
+
+func (l *LocalOkrService) SaveOkr(ctx context.Context, objective model.Objective, krs []model.KeyResult) (*SaveOkrResult, error) {
+	result := &SaveOkrResult{ObjectiveID: objective.ID, KeyResults: make([]RecordResult, len(krs))}
+
+	if objective.ID == "" {
+		id, err := l.CreateObjective(ctx, objective)
+		if err != nil {
+			return nil, err
+		}
+		result.ObjectiveID = id
+	} else if err := l.UpdateObjective(ctx, objective); err != nil {
+		return nil, err
+	}
+
+	for i, kr := range krs {
+		kr.ObjectiveID = result.ObjectiveID
+		if kr.ID == "" {
+			result.KeyResults[i].ID, result.KeyResults[i].Err = l.CreateKeyResult(ctx, kr)
+			continue
+		}
+		result.KeyResults[i] = RecordResult{ID: kr.ID, Err: l.UpdateKeyResult(ctx, kr)}
+	}
+	return result, nil
+}
+
+func recordToObjective(r model.ObjectiveRecord, krIDs []string) model.Objective {
+	ids := make([]string, 0, len(krIDs))
+	for _, id := range krIDs {
+		ids = append(ids, KrPrefix+id)
+	}
+	return model.Objective{
+		ID:               OPrefix + r.RecordID,
+		Title:            r.Title,
+		Owner:            r.Owner,
+		Date:             r.Date,
+		Weight:           r.Weight,
+		KrsIds:           ids,
+		CreatedTime:      r.CreatedTime,
+		LastModifiedTime: r.LastModifiedTime,
+	}
+}
+
+func recordToKeyResult(r model.KeyResultRecord) model.KeyResult {
+	var objectiveID string
+	if r.ObjectiveID != "" {
+		objectiveID = OPrefix + r.ObjectiveID
+	}
+	return model.KeyResult{
+		ID:               KrPrefix + r.RecordID,
+		Title:            r.Title,
+		Weight:           r.Weight,
+		Owner:            r.Owner,
+		Date:             r.Date,
+		Completed:        r.Completed,
+		SelfRating:       r.SelfRating,
+		Reason:           r.Reason,
+		ObjectiveID:      objectiveID,
+		Criteria:         r.Criteria,
+		Leader:           r.Leader,
+		LeaderRating:     r.LeaderRating,
+		Department:       r.Department,
+		CreatedTime:      r.CreatedTime,
+		LastModifiedTime: r.LastModifiedTime,
+	}
+}
+
+// wrapStoreError 将存储层的记录不存在错误转换为业务错误码
+func wrapStoreError(err error) error {
+	if errors.Is(err, store.ErrRecordNotFound) {
+		return errno.ErrRecordNotFound
+	}
+	return err
+}
@@ -0,0 +1,239 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package okr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// newTestDB 创建一个内存 sqlite 数据库并迁移 OKR 表
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.ObjectiveRecord{}, &model.KeyResultRecord{}))
+	return db
+}
+
+func newTestLocalService(t *testing.T) *LocalOkrService {
+	svc, err := NewLocalOkrService(store.NewOkrStore(newTestDB(t)))
+	require.NoError(t, err)
+	return svc
+}
+
+// testServiceBehavior 描述所有 Service 实现都应满足的行为
+func testServiceBehavior(t *testing.T, svc Service) {
+	ctx := context.Background()
+	owner := "张三"
+
+	// 创建目标
+	oid, err := svc.CreateObjective(ctx, model.Objective{Title: "O1: 提升交付质量", Owner: owner, Date: "2024年5月", Weight: 60})
+	require.NoError(t, err)
+	require.NotEmpty(t, oid)
+
+	// 创建关键结果
+	selfRating := 90
+	krid, err := svc.CreateKeyResult(ctx, model.KeyResult{
+		Title:       "KR1: 缺陷率下降 20%",
+		Owner:       owner,
+		Date:        "2024年5月",
+		Weight:      50,
+		Completed:   "未开始",
+		SelfRating:  &selfRating,
+		ObjectiveID: oid,
+		Criteria:    "以测试报告为准",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, krid)
+
+	objectives, err := svc.ListObjectivesByOwner(ctx, owner, nil, "", "")
+	require.NoError(t, err)
+	require.Len(t, objectives, 1)
+	assert.Equal(t, OPrefix+oid, objectives[0].ID)
+	assert.Equal(t, "O1: 提升交付质量", objectives[0].Title)
+	assert.Equal(t, 60, objectives[0].Weight)
+	assert.Equal(t, []string{KrPrefix + krid}, objectives[0].KrsIds)
+
+	krs, err := svc.ListKeyResultsByOwner(ctx, owner, nil, "", "")
+	require.NoError(t, err)
+	require.Len(t, krs, 1)
+	assert.Equal(t, KrPrefix+krid, krs[0].ID)
+	assert.Equal(t, OPrefix+oid, krs[0].ObjectiveID)
+	assert.Equal(t, 50, krs[0].Weight)
+	require.NotNil(t, krs[0].SelfRating)
+	assert.Equal(t, 90, *krs[0].SelfRating)
+	assert.Nil(t, krs[0].LeaderRating)
+
+	// 按 ID 获取单条记录，用于修改前校验负责人
+	objective, err := svc.GetObjective(ctx, oid)
+	require.NoError(t, err)
+	assert.Equal(t, owner, objective.Owner)
+	assert.Equal(t, []string{KrPrefix + krid}, objective.KrsIds)
+	kr, err := svc.GetKeyResult(ctx, krid)
+	require.NoError(t, err)
+	assert.Equal(t, owner, kr.Owner)
+
+	// 其他人看不到该目标
+	others, err := svc.ListObjectivesByOwner(ctx, "李四", nil, "", "")
+	require.NoError(t, err)
+	assert.Empty(t, others)
+
+	// 更新目标
+	require.NoError(t, svc.UpdateObjective(ctx, model.Objective{ID: oid, Title: "O1: 提升交付效率", Owner: owner, Date: "2024年5月", Weight: 40}))
+	objectives, err = svc.ListObjectivesByOwner(ctx, owner, nil, "", "")
+	require.NoError(t, err)
+	require.Len(t, objectives, 1)
+	assert.Equal(t, "O1: 提升交付效率", objectives[0].Title)
+	assert.Equal(t, 40, objectives[0].Weight)
+
+	// 更新关键结果：未提供上级评分和关联目标时保持原值
+	require.NoError(t, svc.UpdateKeyResult(ctx, model.KeyResult{ID: krid, Title: "KR1: 缺陷率下降 30%", Owner: owner, Date: "2024年5月", Weight: 70, Completed: "已完成"}))
+	krs, err = svc.ListKeyResultsByOwner(ctx, owner, nil, "", "")
+	require.NoError(t, err)
+	require.Len(t, krs, 1)
+	assert.Equal(t, "已完成", krs[0].Completed)
+	assert.Equal(t, OPrefix+oid, krs[0].ObjectiveID)
+	assert.Nil(t, krs[0].LeaderRating)
+
+	leaderRating := 100
+	require.NoError(t, svc.UpdateKeyResult(ctx, model.KeyResult{ID: krid, Title: "KR1: 缺陷率下降 30%", Owner: owner, Date: "2024年5月", Weight: 70, Completed: "已完成", LeaderRating: &leaderRating}))
+	krs, err = svc.ListKeyResultsByOwner(ctx, owner, nil, "", "")
+	require.NoError(t, err)
+	require.NotNil(t, krs[0].LeaderRating)
+	assert.Equal(t, 100, *krs[0].LeaderRating)
+
+	// 删除关键结果
+	kr2, err := svc.CreateKeyResult(ctx, model.KeyResult{Title: "KR2", Owner: owner, Date: "2024年5月", Weight: 30, Completed: "未开始", ObjectiveID: oid})
+	require.NoError(t, err)
+	require.NoError(t, svc.DeleteKeyResultByID(ctx, kr2))
+	krs, err = svc.ListKeyResultsByOwner(ctx, owner, nil, "", "")
+	require.NoError(t, err)
+	assert.Len(t, krs, 1)
+
+	// 删除目标时一并删除关键结果
+	require.NoError(t, svc.DeleteObjectiveByID(ctx, oid, []string{krid}))
+	objectives, err = svc.ListObjectivesByOwner(ctx, owner, nil, "", "")
+	require.NoError(t, err)
+	assert.Empty(t, objectives)
+	krs, err = svc.ListKeyResultsByOwner(ctx, owner, nil, "", "")
+	require.NoError(t, err)
+	assert.Empty(t, krs)
+}
+
+func TestLocalOkrService_Behavior(t *testing.T) {
+	testServiceBehavior(t, newTestLocalService(t))
+}
+
+func TestLocalOkrService_Sorting(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestLocalService(t)
+
+	for _, title := range []string{"O2: 第二", "O10: 第十", "O1: 第一"} {
+		_, err := svc.CreateObjective(ctx, model.Objective{Title: title, Owner: "张三", Date: "2024年5月"})
+		require.NoError(t, err)
+	}
+
+	objectives, err := svc.ListObjectivesByOwner(ctx, "张三", nil, "title", "asc")
+	require.NoError(t, err)
+	require.Len(t, objectives, 3)
+	assert.Equal(t, "O1: 第一", objectives[0].Title)
+	assert.Equal(t, "O2: 第二", objectives[1].Title)
+	assert.Equal(t, "O10: 第十", objectives[2].Title)
+}
+
+func TestLocalOkrService_NotFound(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestLocalService(t)
+
+	assert.Error(t, svc.UpdateObjective(ctx, model.Objective{ID: "recMissing", Title: "x"}))
+	assert.Error(t, svc.UpdateKeyResult(ctx, model.KeyResult{ID: "recMissing", Title: "x"}))
+	assert.Error(t, svc.DeleteKeyResultByID(ctx, "recMissing"))
+	assert.Error(t, svc.DeleteObjectiveByID(ctx, "recMissing", nil))
+
+	_, err := svc.GetObjective(ctx, "recMissing")
+	assert.ErrorIs(t, err, errno.ErrRecordNotFound)
+	_, err = svc.GetKeyResult(ctx, "recMissing")
+	assert.ErrorIs(t, err, errno.ErrRecordNotFound)
+}
+
+func TestLocalOkrService_SaveOkr(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestLocalService(t)
+
+	result, err := svc.SaveOkr(ctx, model.Objective{Title: "O1", Owner: "张三", Date: "2024年5月", Weight: 100}, []model.KeyResult{
+		{Title: "KR1", Owner: "张三", Date: "2024年5月", Weight: 60, Completed: "未开始"},
+		{Title: "KR2", Owner: "张三", Date: "2024年5月", Weight: 40, Completed: "未开始"},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, result.ObjectiveID)
+	require.Len(t, result.KeyResults, 2)
+
+	krs, err := svc.ListKeyResultsByOwner(ctx, "张三", nil, "title", "asc")
+	require.NoError(t, err)
+	require.Len(t, krs, 2)
+	assert.Equal(t, OPrefix+result.ObjectiveID, krs[0].ObjectiveID)
+
+	// 更新已有 KR，同时新增一条，其中一条 ID 不存在
+	result, err = svc.SaveOkr(ctx, model.Objective{ID: result.ObjectiveID, Title: "O1 updated", Owner: "张三", Date: "2024年5月", Weight: 100}, []model.KeyResult{
+		{ID: result.KeyResults[0].ID, Title: "KR1 updated", Owner: "张三", Date: "2024年5月", Weight: 60, Completed: "已完成"},
+		{ID: "recMissing", Title: "KR?", Owner: "张三", Date: "2024年5月", Weight: 10, Completed: "未开始"},
+		{Title: "KR3", Owner: "张三", Date: "2024年5月", Weight: 10, Completed: "未开始"},
+	})
+	require.NoError(t, err)
+	assert.NoError(t, result.KeyResults[0].Err)
+	assert.Error(t, result.KeyResults[1].Err)
+	assert.NoError(t, result.KeyResults[2].Err)
+
+	objectives, err := svc.ListObjectivesByOwner(ctx, "张三", nil, "", "")
+	require.NoError(t, err)
+	require.Len(t, objectives, 1)
+	assert.Equal(t, "O1 updated", objectives[0].Title)
+	assert.Len(t, objectives[0].KrsIds, 3)
+
+	// 目标不存在时整体失败
+	_, err = svc.SaveOkr(ctx, model.Objective{ID: "recMissing", Title: "x"}, nil)
+	assert.Error(t, err)
+}
+
+func TestLocalOkrService_FilterByMonths(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestLocalService(t)
+
+	for _, date := range []string{"2024年4月", "2024年5月", "2024年6月"} {
+		oid, err := svc.CreateObjective(ctx, model.Objective{Title: "O " + date, Owner: "张三", Date: date})
+		require.NoError(t, err)
+		_, err = svc.CreateKeyResult(ctx, model.KeyResult{Title: "KR " + date, Owner: "张三", Date: date, ObjectiveID: oid})
+		require.NoError(t, err)
+	}
+
+	objectives, err := svc.ListObjectivesByOwner(ctx, "张三", []string{"2024年5月", "2024年6月"}, "", "")
+	require.NoError(t, err)
+	require.Len(t, objectives, 2)
+	for _, o := range objectives {
+		assert.NotEqual(t, "2024年4月", o.Date)
+	}
+
+	krs, err := svc.ListKeyResultsByOwner(ctx, "张三", []string{"2024年4月"}, "", "")
+	require.NoError(t, err)
+	require.Len(t, krs, 1)
+	assert.Equal(t, "KR 2024年4月", krs[0].Title)
+
+	all, err := svc.ListObjectivesByOwner(ctx, "张三", nil, "", "")
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
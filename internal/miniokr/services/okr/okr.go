@@ -12,12 +12,36 @@ import (
 )
 
 type Service interface {
-	ListObjectivesByOwner(ctx context.Context, username string, sortBy string, orderBy string) ([]model.Objective, error)
-	ListKeyResultsByOwner(ctx context.Context, username string, sortBy string, orderBy string) ([]model.KeyResult, error)
+	// ListObjectivesByOwner 和 ListKeyResultsByOwner 在 months 非空时只返回这些考核月份的记录
+	ListObjectivesByOwner(ctx context.Context, username string, months []string, sortBy string, orderBy string) ([]model.Objective, error)
+	ListKeyResultsByOwner(ctx context.Context, username string, months []string, sortBy string, orderBy string) ([]model.KeyResult, error)
+	// GetObjective 和 GetKeyResult 按 ID 获取单条记录，用于修改前校验记录负责人，
+	// 记录不存在时返回 errno.ErrRecordNotFound
+	GetObjective(ctx context.Context, id string) (*model.Objective, error)
+	GetKeyResult(ctx context.Context, id string) (*model.KeyResult, error)
 	CreateObjective(context.Context, model.Objective) (string, error)
 	UpdateObjective(context.Context, model.Objective) error
 	DeleteObjectiveByID(context.Context, string, []string) error
 	CreateKeyResult(context.Context, model.KeyResult) (string, error)
 	UpdateKeyResult(context.Context, model.KeyResult) error
 	DeleteKeyResultByID(context.Context, string) error
+	// SaveOkr 一次性保存目标及其关键结果，ID 为空的记录新建，否则更新
+	SaveOkr(ctx context.Context, objective model.Objective, krs []model.KeyResult) (*SaveOkrResult, error)
+}
+
+// Resolver 返回负责人 userID 所属公司的 Service，各公司的 OKR 保存在各自的多维表格中
+type Resolver interface {
+	ForUser(ctx context.Context, userID string) (Service, error)
+}
+
+// RecordResult 是批量保存中单条记录的结果
+type RecordResult struct {
+	ID  string
+	Err error
+}
+
+// SaveOkrResult 是 SaveOkr 的结果，KeyResults 的顺序与入参一致
+type SaveOkrResult struct {
+	ObjectiveID string
+	KeyResults  []RecordResult
 }
@@ -0,0 +1,262 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package okr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// OwnerAuthorizer 判断 ctx 中的当前用户能否对负责人为 owner 的记录执行 act(HTTP 方法)，
+// scope 为可以委托的权限，为空表示该操作不能委托. 无权限时返回 errno.ErrForbidden
+type OwnerAuthorizer interface {
+	AuthorizeOwner(ctx context.Context, owner string, act string, scope string) error
+}
+
+// ownerChecked 在新建、修改和删除记录前校验当前用户能否修改记录的负责人，
+// 修改已有记录时同时校验原负责人，不经过 HTTP 接口的调用也无法绕过.
+// 只通过委托获得权限时只能修改关键结果的上级评分
+type ownerChecked struct {
+	Service
+	owners OwnerAuthorizer
+}
+
+// NewOwnerCheckedService 返回修改记录前通过 owners 校验负责人的 Service
+func NewOwnerCheckedService(svc Service, owners OwnerAuthorizer) Service {
+	return &ownerChecked{Service: svc, owners: owners}
+}
+
+func (s *ownerChecked) CreateObjective(ctx context.Context, o model.Objective) (string, error) {
+	if err := s.owners.AuthorizeOwner(ctx, o.Owner, http.MethodPost, ""); err != nil {
+		return "", err
+	}
+	return s.Service.CreateObjective(ctx, o)
+}
+
+// UpdateObjective 通过委托只能查看和评分，委托时不能修改目标
+func (s *ownerChecked) UpdateObjective(ctx context.Context, o model.Objective) error {
+	existing, err := s.Service.GetObjective(ctx, o.ID)
+	if err != nil {
+		return err
+	}
+	delegated, err := s.authorizeUpdate(ctx, existing.Owner, http.MethodPut)
+	if err != nil {
+		return err
+	}
+	if delegated {
+		if !unchangedObjective(existing, o) {
+			return errno.ErrForbidden
+		}
+	} else if err := s.owners.AuthorizeOwner(ctx, o.Owner, http.MethodPut, ""); err != nil {
+		return err
+	}
+	return s.Service.UpdateObjective(ctx, o)
+}
+
+func (s *ownerChecked) DeleteObjectiveByID(ctx context.Context, id string, krIDs []string) error {
+	objective, err := s.authorizeObjective(ctx, id, http.MethodDelete, "")
+	if err != nil {
+		return err
+	}
+	if err := s.authorizeKeyResults(ctx, objective, krIDs, http.MethodDelete, ""); err != nil {
+		return err
+	}
+	return s.Service.DeleteObjectiveByID(ctx, id, krIDs)
+}
+
+func (s *ownerChecked) CreateKeyResult(ctx context.Context, kr model.KeyResult) (string, error) {
+	if err := s.owners.AuthorizeOwner(ctx, kr.Owner, http.MethodPost, ""); err != nil {
+		return "", err
+	}
+	return s.Service.CreateKeyResult(ctx, kr)
+}
+
+// UpdateKeyResult 通过委托只能查看和评分，委托时只能修改上级评分
+func (s *ownerChecked) UpdateKeyResult(ctx context.Context, kr model.KeyResult) error {
+	existing, err := s.Service.GetKeyResult(ctx, kr.ID)
+	if err != nil {
+		return err
+	}
+	delegated, err := s.authorizeUpdate(ctx, existing.Owner, http.MethodPut)
+	if err != nil {
+		return err
+	}
+	if delegated {
+		if !ratingOnly(existing, kr) {
+			return errno.ErrForbidden
+		}
+	} else if err := s.owners.AuthorizeOwner(ctx, kr.Owner, http.MethodPut, ""); err != nil {
+		return err
+	}
+	return s.Service.UpdateKeyResult(ctx, kr)
+}
+
+func (s *ownerChecked) DeleteKeyResultByID(ctx context.Context, id string) error {
+	if err := s.authorizeKeyResult(ctx, id, http.MethodDelete, ""); err != nil {
+		return err
+	}
+	return s.Service.DeleteKeyResultByID(ctx, id)
+}
+
+// SaveOkr 只修改已有记录时可以委托，委托时只能修改目标下关键结果的上级评分
+func (s *ownerChecked) SaveOkr(ctx context.Context, objective model.Objective, krs []model.KeyResult) (*SaveOkrResult, error) {
+	delegable := objective.ID != ""
+	for _, kr := range krs {
+		if kr.ID == "" {
+			delegable = false
+		}
+	}
+
+	var existing *model.Objective
+	if objective.ID != "" {
+		var err error
+		if existing, err = s.Service.GetObjective(ctx, objective.ID); err != nil {
+			return nil, err
+		}
+		if delegable {
+			delegated, err := s.authorizeUpdate(ctx, existing.Owner, http.MethodPost)
+			if err != nil {
+				return nil, err
+			}
+			if delegated {
+				if err := s.authorizeRatings(ctx, existing, objective, krs); err != nil {
+					return nil, err
+				}
+				return s.Service.SaveOkr(ctx, objective, krs)
+			}
+		} else if err := s.owners.AuthorizeOwner(ctx, existing.Owner, http.MethodPost, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.owners.AuthorizeOwner(ctx, objective.Owner, http.MethodPost, ""); err != nil {
+		return nil, err
+	}
+	krIDs := make([]string, 0, len(krs))
+	for _, kr := range krs {
+		if kr.Owner != objective.Owner {
+			if err := s.owners.AuthorizeOwner(ctx, kr.Owner, http.MethodPost, ""); err != nil {
+				return nil, err
+			}
+		}
+		if kr.ID != "" {
+			krIDs = append(krIDs, kr.ID)
+		}
+	}
+	if err := s.authorizeKeyResults(ctx, existing, krIDs, http.MethodPost, ""); err != nil {
+		return nil, err
+	}
+
+	return s.Service.SaveOkr(ctx, objective, krs)
+}
+
+// authorizeUpdate 校验当前用户能否修改负责人为 owner 的已有记录，
+// 只通过委托获得权限时 delegated 为 true
+func (s *ownerChecked) authorizeUpdate(ctx context.Context, owner string, act string) (delegated bool, err error) {
+	err = s.owners.AuthorizeOwner(ctx, owner, act, "")
+	if !errors.Is(err, errno.ErrForbidden) {
+		return false, err
+	}
+	if err := s.owners.AuthorizeOwner(ctx, owner, act, model.DelegationScopeRate); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// authorizeRatings 校验通过委托保存的 OKR：目标不变，关键结果都属于该目标且只修改上级评分
+func (s *ownerChecked) authorizeRatings(ctx context.Context, existing *model.Objective, objective model.Objective, krs []model.KeyResult) error {
+	if !unchangedObjective(existing, objective) {
+		return errno.ErrForbidden
+	}
+	owned := make(map[string]bool)
+	for _, id := range existing.KrsIds {
+		owned[strings.TrimPrefix(id, KrPrefix)] = true
+	}
+	for _, kr := range krs {
+		if !owned[kr.ID] {
+			return errno.ErrForbidden
+		}
+		current, err := s.Service.GetKeyResult(ctx, kr.ID)
+		if err != nil {
+			return err
+		}
+		// 保存时关键结果总是关联到该目标
+		kr.ObjectiveID = ""
+		if !ratingOnly(current, kr) {
+			return errno.ErrForbidden
+		}
+	}
+	return nil
+}
+
+// unchangedObjective 判断 o 与已有目标 existing 的可修改字段是否相同
+func unchangedObjective(existing *model.Objective, o model.Objective) bool {
+	return o.Title == existing.Title && o.Owner == existing.Owner && o.Date == existing.Date && o.Weight == existing.Weight
+}
+
+// ratingOnly 判断 kr 相对已有关键结果 existing 是否只修改了上级评分，
+// ObjectiveID 为空表示不修改关联目标
+func ratingOnly(existing *model.KeyResult, kr model.KeyResult) bool {
+	return kr.Title == existing.Title && kr.Owner == existing.Owner && kr.Date == existing.Date &&
+		kr.Weight == existing.Weight && kr.Completed == existing.Completed &&
+		equalRating(kr.SelfRating, existing.SelfRating) && kr.Reason == existing.Reason &&
+		kr.Criteria == existing.Criteria &&
+		(kr.ObjectiveID == "" || kr.ObjectiveID == strings.TrimPrefix(existing.ObjectiveID, OPrefix))
+}
+
+// equalRating 判断两个可能为空的评分是否相同
+func equalRating(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// authorizeObjective 获取目标并校验当前用户能否修改其负责人的记录
+func (s *ownerChecked) authorizeObjective(ctx context.Context, id string, act string, scope string) (*model.Objective, error) {
+	objective, err := s.Service.GetObjective(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.owners.AuthorizeOwner(ctx, objective.Owner, act, scope); err != nil {
+		return nil, err
+	}
+	return objective, nil
+}
+
+// authorizeKeyResult 获取关键结果并校验当前用户能否修改其负责人的记录
+func (s *ownerChecked) authorizeKeyResult(ctx context.Context, id string, act string, scope string) error {
+	kr, err := s.Service.GetKeyResult(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.owners.AuthorizeOwner(ctx, kr.Owner, act, scope)
+}
+
+// authorizeKeyResults 校验 ids 中的关键结果，属于 objective 的关键结果已随目标校验
+func (s *ownerChecked) authorizeKeyResults(ctx context.Context, objective *model.Objective, ids []string, act string, scope string) error {
+	owned := make(map[string]bool)
+	if objective != nil {
+		for _, id := range objective.KrsIds {
+			owned[strings.TrimPrefix(id, KrPrefix)] = true
+		}
+	}
+
+	for _, id := range ids {
+		if owned[id] {
+			continue
+		}
+		if err := s.authorizeKeyResult(ctx, id, act, scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package okr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// fakeOwners 允许修改 allowed 中负责人的记录，delegated 中负责人的记录只能通过委托修改，
+// scopes 记录每次校验使用的委托权限
+type fakeOwners struct {
+	allowed   map[string]bool
+	delegated map[string]bool
+	scopes    []string
+}
+
+func (f *fakeOwners) AuthorizeOwner(ctx context.Context, owner string, act string, scope string) error {
+	f.scopes = append(f.scopes, scope)
+	if f.allowed[owner] || (scope != "" && f.delegated[owner]) {
+		return nil
+	}
+	return errno.ErrForbidden
+}
+
+func TestOwnerCheckedService(t *testing.T) {
+	ctx := context.Background()
+	local := newTestLocalService(t)
+
+	oid, err := local.CreateObjective(ctx, model.Objective{Title: "O1", Owner: "张三", Date: "2024年5月", Weight: 60})
+	require.NoError(t, err)
+	krID, err := local.CreateKeyResult(ctx, model.KeyResult{Title: "KR1", Owner: "张三", Date: "2024年5月", Weight: 50, ObjectiveID: oid})
+	require.NoError(t, err)
+	otherKrID, err := local.CreateKeyResult(ctx, model.KeyResult{Title: "KR2", Owner: "李四", Date: "2024年5月", Weight: 50})
+	require.NoError(t, err)
+
+	owners := &fakeOwners{allowed: map[string]bool{"张三": true}}
+	svc := NewOwnerCheckedService(local, owners)
+
+	t.Run("create", func(t *testing.T) {
+		_, err := svc.CreateObjective(ctx, model.Objective{Title: "O2", Owner: "李四", Date: "2024年5月"})
+		assert.Equal(t, errno.ErrForbidden, err)
+		_, err = svc.CreateKeyResult(ctx, model.KeyResult{Title: "KR3", Owner: "张三", Date: "2024年5月", ObjectiveID: oid})
+		assert.NoError(t, err)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		require.NoError(t, svc.UpdateObjective(ctx, model.Objective{ID: oid, Title: "O1", Owner: "张三", Date: "2024年5月"}))
+		// 不能把记录的负责人改为他人，也不能修改他人的记录
+		assert.Equal(t, errno.ErrForbidden, svc.UpdateObjective(ctx, model.Objective{ID: oid, Title: "O1", Owner: "李四", Date: "2024年5月"}))
+		assert.Equal(t, errno.ErrForbidden, svc.UpdateKeyResult(ctx, model.KeyResult{ID: otherKrID, Title: "KR2", Owner: "张三", Date: "2024年5月"}))
+
+		kr, err := local.GetKeyResult(ctx, otherKrID)
+		require.NoError(t, err)
+		assert.Equal(t, "李四", kr.Owner)
+	})
+
+	t.Run("save", func(t *testing.T) {
+		_, err := svc.SaveOkr(ctx, model.Objective{ID: oid, Title: "O1", Owner: "张三", Date: "2024年5月"},
+			[]model.KeyResult{{ID: krID, Title: "KR1", Owner: "张三", Date: "2024年5月"}})
+		require.NoError(t, err)
+
+		// 他人的关键结果不能随自己的目标一起保存
+		_, err = svc.SaveOkr(ctx, model.Objective{ID: oid, Title: "O1", Owner: "张三", Date: "2024年5月"},
+			[]model.KeyResult{{ID: otherKrID, Title: "KR2", Owner: "张三", Date: "2024年5月"}})
+		assert.Equal(t, errno.ErrForbidden, err)
+
+		_, err = svc.SaveOkr(ctx, model.Objective{ID: oid, Title: "O1", Owner: "张三", Date: "2024年5月"},
+			[]model.KeyResult{{Title: "KR4", Owner: "张三", Date: "2024年5月"}})
+		require.NoError(t, err)
+	})
+
+	t.Run("delegated", func(t *testing.T) {
+		delegate := NewOwnerCheckedService(local, &fakeOwners{delegated: map[string]bool{"张三": true}})
+		rating := 90
+		kr, err := local.GetKeyResult(ctx, krID)
+		require.NoError(t, err)
+		o, err := local.GetObjective(ctx, oid)
+		require.NoError(t, err)
+
+		// 通过委托只能填写上级评分
+		// 与接口传入的参数一致，记录 ID 不带前缀
+		o.ID = oid
+		rated := *kr
+		rated.ID = krID
+		rated.ObjectiveID = ""
+		rated.LeaderRating = &rating
+		require.NoError(t, delegate.UpdateKeyResult(ctx, rated))
+		_, err = delegate.SaveOkr(ctx, *o, []model.KeyResult{rated})
+		require.NoError(t, err)
+
+		retitled := rated
+		retitled.Title = "KR1 改"
+		assert.Equal(t, errno.ErrForbidden, delegate.UpdateKeyResult(ctx, retitled))
+		reowned := rated
+		reowned.Owner = "王五"
+		assert.Equal(t, errno.ErrForbidden, delegate.UpdateKeyResult(ctx, reowned))
+		_, err = delegate.SaveOkr(ctx, *o, []model.KeyResult{retitled})
+		assert.Equal(t, errno.ErrForbidden, err)
+
+		changed := *o
+		changed.Title = "O1 改"
+		assert.Equal(t, errno.ErrForbidden, delegate.UpdateObjective(ctx, changed))
+		_, err = delegate.SaveOkr(ctx, changed, []model.KeyResult{rated})
+		assert.Equal(t, errno.ErrForbidden, err)
+		// 新建记录不能委托
+		_, err = delegate.SaveOkr(ctx, *o, []model.KeyResult{{Title: "KR5", Owner: "张三", Date: "2024年5月"}})
+		assert.Equal(t, errno.ErrForbidden, err)
+
+		got, err := local.GetKeyResult(ctx, krID)
+		require.NoError(t, err)
+		assert.Equal(t, "KR1", got.Title)
+		assert.Equal(t, "张三", got.Owner)
+		assert.Equal(t, &rating, got.LeaderRating)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.Equal(t, errno.ErrForbidden, svc.DeleteKeyResultByID(ctx, otherKrID))
+		assert.Equal(t, errno.ErrForbidden, svc.DeleteObjectiveByID(ctx, oid, []string{krID, otherKrID}))
+		require.NoError(t, svc.DeleteObjectiveByID(ctx, oid, []string{krID}))
+
+		_, err := local.GetKeyResult(ctx, otherKrID)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package role
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/user"
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// RoleService 供管理员授予和撤销用户角色. 手动授予的角色不受组织架构同步影响，
+// 每次变更都会记录操作人
+type RoleService struct {
+	store store.RoleStore
+	users user.Service
+}
+
+// NewRoleService 创建角色管理服务
+func NewRoleService(s store.RoleStore, users user.Service) *RoleService {
+	return &RoleService{store: s, users: users}
+}
+
+// ListRoles 返回全部角色
+func (s *RoleService) ListRoles(ctx context.Context) ([]string, error) {
+	rs, err := s.store.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(rs))
+	for _, r := range rs {
+		names = append(names, r.RoleName)
+	}
+	return names, nil
+}
+
+// CreateRole 创建角色，新角色需要配合授权策略使用
+func (s *RoleService) CreateRole(ctx context.Context, name string) error {
+	if err := s.store.CreateRole(ctx, name); err != nil {
+		return toErrno(err)
+	}
+	return nil
+}
+
+// ListUserRoles 返回用户的角色及其来源
+func (s *RoleService) ListUserRoles(ctx context.Context, userID string) ([]v1.UserRole, error) {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		return nil, errno.ErrUserNotFound
+	}
+
+	records, err := s.store.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]v1.UserRole, 0, len(records))
+	for _, r := range records {
+		roles = append(roles, v1.UserRole{Role: r.RoleName, Source: r.Source})
+	}
+	return roles, nil
+}
+
+// AssignUserRole 由管理员 operatorID 将角色授予用户
+func (s *RoleService) AssignUserRole(ctx context.Context, operatorID, userID, roleName string) error {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		return errno.ErrUserNotFound
+	}
+	if err := s.store.AssignUserRole(ctx, userID, roleName, operatorID); err != nil {
+		return toErrno(err)
+	}
+
+	log.C(ctx).Infow("User role assigned", "userID", userID, "role", roleName, "operator", operatorID)
+	return nil
+}
+
+// RemoveUserRole 由管理员 operatorID 撤销用户手动授予的角色
+func (s *RoleService) RemoveUserRole(ctx context.Context, operatorID, userID, roleName string) error {
+	if err := s.store.RemoveUserRole(ctx, userID, roleName, operatorID); err != nil {
+		return toErrno(err)
+	}
+
+	log.C(ctx).Infow("User role removed", "userID", userID, "role", roleName, "operator", operatorID)
+	return nil
+}
+
+// ListRoleChanges 返回用户的角色变更记录
+func (s *RoleService) ListRoleChanges(ctx context.Context, userID string) ([]v1.RoleChange, error) {
+	changes, err := s.store.ListRoleChanges(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]v1.RoleChange, 0, len(changes))
+	for _, c := range changes {
+		resp = append(resp, v1.RoleChange{
+			Role:       c.RoleName,
+			Action:     c.Action,
+			Source:     c.Source,
+			OperatorID: c.OperatorID,
+			CreatedAt:  c.CreatedAt,
+		})
+	}
+	return resp, nil
+}
+
+// toErrno 将 store 层的错误转换为接口错误码
+func toErrno(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return errno.ErrRoleNotFound
+	case errors.Is(err, store.ErrRoleExists):
+		return errno.ErrRoleAlreadyExist
+	case errors.Is(err, store.ErrUserRoleExists):
+		return errno.ErrUserRoleAlreadyExist
+	case errors.Is(err, store.ErrUserRoleNotFound):
+		return errno.ErrUserRoleNotFound
+	case errors.Is(err, store.ErrUserRoleSynced):
+		return errno.ErrUserRoleSynced
+	case errors.Is(err, store.ErrLastAdmin):
+		return errno.ErrLastAdmin
+	default:
+		return err
+	}
+}
@@ -5,3 +5,10 @@ import "context"
 type Service interface {
 	SyncDepartmentsAndUsers(context.Context) error
 }
+
+// OkrSyncer 定义了本地 OKR 存储与飞书多维表格之间的同步操作
+type OkrSyncer interface {
+	Sync(ctx context.Context, mode string) (*OkrSyncReport, error)
+	Push(context.Context) (*OkrSyncReport, error)
+	Pull(context.Context) (*OkrSyncReport, error)
+}
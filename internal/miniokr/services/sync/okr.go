@@ -0,0 +1,470 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/notify"
+	"github.com/imxw/miniokr/internal/miniokr/services/okr"
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+const (
+	// OkrSyncModePush 只将本地修改推送到飞书多维表格
+	OkrSyncModePush = "push"
+	// OkrSyncModePull 只将飞书多维表格的修改拉取到本地
+	OkrSyncModePull = "pull"
+	// OkrSyncModeBoth 先拉取再推送
+	OkrSyncModeBoth = "both"
+)
+
+const (
+	// okrSyncLeaseName 是 OKR 同步租约的名称
+	okrSyncLeaseName = "okr"
+	// okrSyncLeaseTTL 是同步租约的有效期，需要大于一次同步的最长耗时
+	okrSyncLeaseTTL = 30 * time.Minute
+)
+
+// ErrOkrSyncInProgress 表示其他副本或调用正在执行 OKR 同步
+var ErrOkrSyncInProgress = errors.New("okr sync is already in progress")
+
+// OkrRemote 定义了 OKR 同步所需的飞书多维表格操作，由 okr.FeishuOkrService 实现
+type OkrRemote interface {
+	ListAllObjectives(ctx context.Context) ([]model.Objective, error)
+	ListAllKeyResults(ctx context.Context) ([]model.KeyResult, error)
+	CreateObjective(ctx context.Context, objective model.Objective) (string, error)
+	UpdateObjective(ctx context.Context, objective model.Objective) error
+	DeleteObjectiveByID(ctx context.Context, oid string, krids []string) error
+	CreateKeyResult(ctx context.Context, keyResult model.KeyResult) (string, error)
+	UpdateKeyResult(ctx context.Context, keyResult model.KeyResult) error
+	DeleteKeyResultByID(ctx context.Context, id string) error
+}
+
+var _ OkrRemote = (*okr.FeishuOkrService)(nil)
+
+var _ OkrSyncer = (*OkrSyncService)(nil)
+
+// OkrConflict 描述一条因双方同时修改而未被同步的记录
+type OkrConflict struct {
+	Kind     string
+	LocalID  string
+	RemoteID string
+	Reason   string
+}
+
+// OkrSyncReport 汇总一次同步的结果
+type OkrSyncReport struct {
+	Created   int
+	Updated   int
+	Deleted   int
+	Conflicts []OkrConflict
+}
+
+// OkrSyncService 负责在本地 OKR 存储与飞书多维表格之间双向同步目标和关键结果
+type OkrSyncService struct {
+	local    store.OkrStore
+	cursors  store.OkrSyncStorer
+	remote   OkrRemote
+	notifier notify.Notifier
+}
+
+// NewOkrSyncService 创建一个新的 OkrSyncService 实例
+func NewOkrSyncService(local store.OkrStore, cursors store.OkrSyncStorer, remote OkrRemote, notifier notify.Notifier) *OkrSyncService {
+	return &OkrSyncService{
+		local:    local,
+		cursors:  cursors,
+		remote:   remote,
+		notifier: notifier,
+	}
+}
+
+// Sync 按指定模式执行一次同步，其他副本或调用正在同步时返回 ErrOkrSyncInProgress
+func (s *OkrSyncService) Sync(ctx context.Context, mode string) (*OkrSyncReport, error) {
+	switch mode {
+	case OkrSyncModePush:
+		return s.Push(ctx)
+	case OkrSyncModePull:
+		return s.Pull(ctx)
+	case "", OkrSyncModeBoth:
+		var report *OkrSyncReport
+		err := s.withLease(ctx, func() error {
+			pulled, err := s.pull(ctx)
+			if err != nil {
+				return err
+			}
+			pushed, err := s.push(ctx)
+			if err != nil {
+				return err
+			}
+			report = &OkrSyncReport{
+				Created:   pulled.Created + pushed.Created,
+				Updated:   pulled.Updated + pushed.Updated,
+				Deleted:   pulled.Deleted + pushed.Deleted,
+				Conflicts: append(pulled.Conflicts, pushed.Conflicts...),
+			}
+			return nil
+		})
+		return report, err
+	default:
+		return nil, fmt.Errorf("unsupported okr sync mode: %q", mode)
+	}
+}
+
+// Pull 将飞书多维表格中自上次同步以来的修改应用到本地
+func (s *OkrSyncService) Pull(ctx context.Context) (*OkrSyncReport, error) {
+	var report *OkrSyncReport
+	err := s.withLease(ctx, func() (err error) {
+		report, err = s.pull(ctx)
+		return err
+	})
+	return report, err
+}
+
+// Push 将本地自上次同步以来的修改推送到飞书多维表格
+func (s *OkrSyncService) Push(ctx context.Context) (*OkrSyncReport, error) {
+	var report *OkrSyncReport
+	err := s.withLease(ctx, func() (err error) {
+		report, err = s.push(ctx)
+		return err
+	})
+	return report, err
+}
+
+// withLease 领取同步租约后执行 fn，避免多个副本或同时触发的同步重复创建远端记录
+func (s *OkrSyncService) withLease(ctx context.Context, fn func() error) error {
+	holder := uuid.NewString()
+	acquired, err := s.cursors.AcquireLease(ctx, okrSyncLeaseName, holder, okrSyncLeaseTTL)
+	if err != nil {
+		return fmt.Errorf("acquire okr sync lease: %w", err)
+	}
+	if !acquired {
+		return ErrOkrSyncInProgress
+	}
+	defer func() {
+		// 同步的 ctx 可能已取消，释放租约不受其影响
+		if err := s.cursors.ReleaseLease(context.Background(), okrSyncLeaseName, holder); err != nil {
+			log.Warnw("Failed to release okr sync lease", "err", err)
+		}
+	}()
+	return fn()
+}
+
+func (s *OkrSyncService) pull(ctx context.Context) (*OkrSyncReport, error) {
+	report := &OkrSyncReport{}
+	if err := s.run(ctx, report, func(t okrSyncTable) error { return s.pullTable(ctx, t, report) }); err != nil {
+		log.Errorw("OKR pull sync failed", "err", err)
+		s.notifier.Send("OKR pull sync task failed: " + err.Error())
+		return nil, err
+	}
+	s.reportConflicts(OkrSyncModePull, report)
+	return report, nil
+}
+
+func (s *OkrSyncService) push(ctx context.Context) (*OkrSyncReport, error) {
+	report := &OkrSyncReport{}
+	if err := s.run(ctx, report, func(t okrSyncTable) error { return s.pushTable(ctx, t, report) }); err != nil {
+		log.Errorw("OKR push sync failed", "err", err)
+		s.notifier.Send("OKR push sync task failed: " + err.Error())
+		return nil, err
+	}
+	s.reportConflicts(OkrSyncModePush, report)
+	return report, nil
+}
+
+// run 加载两端快照，并先后对目标表和关键结果表执行 fn.
+// 目标必须先于关键结果同步，因为关键结果通过游标将关联目标的ID在两端之间转换.
+func (s *OkrSyncService) run(ctx context.Context, report *OkrSyncReport, fn func(okrSyncTable) error) error {
+	snap, err := s.loadSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	objectives := &objectiveTable{s: s, snap: snap}
+	if err := fn(objectives); err != nil {
+		return fmt.Errorf("sync objectives: %w", err)
+	}
+
+	keyResults := &keyResultTable{s: s, snap: snap}
+	if err := fn(keyResults); err != nil {
+		return fmt.Errorf("sync key results: %w", err)
+	}
+
+	return nil
+}
+
+func (s *OkrSyncService) pullTable(ctx context.Context, t okrSyncTable, report *OkrSyncReport) error {
+	cursors, err := s.cursors.ListCursors(ctx, t.kind())
+	if err != nil {
+		return err
+	}
+	byRemote := make(map[string]*model.OkrSyncCursor, len(cursors))
+	for i := range cursors {
+		byRemote[cursors[i].RemoteID] = &cursors[i]
+	}
+
+	local, remote := t.localTimes(), t.remoteTimes()
+
+	for remoteID, remoteTime := range remote {
+		c, ok := byRemote[remoteID]
+		if !ok {
+			localID, localTime, err := t.pull(ctx, remoteID, "")
+			if err != nil {
+				return err
+			}
+			cursor := &model.OkrSyncCursor{Kind: t.kind(), LocalID: localID, RemoteID: remoteID, LocalModifiedTime: localTime, RemoteModifiedTime: remoteTime}
+			if err := s.cursors.SaveCursor(ctx, cursor); err != nil {
+				return err
+			}
+			t.track(cursor)
+			report.Created++
+			continue
+		}
+
+		if remoteTime <= c.RemoteModifiedTime {
+			continue
+		}
+
+		localTime, exists := local[c.LocalID]
+		if exists && localTime > c.LocalModifiedTime {
+			report.Conflicts = append(report.Conflicts, OkrConflict{Kind: t.kind(), LocalID: c.LocalID, RemoteID: remoteID, Reason: "both sides modified since last sync"})
+			continue
+		}
+
+		target := c.LocalID
+		if !exists {
+			target = ""
+		}
+		localID, localTime, err := t.pull(ctx, remoteID, target)
+		if err != nil {
+			return err
+		}
+		c.LocalID, c.LocalModifiedTime, c.RemoteModifiedTime = localID, localTime, remoteTime
+		if err := s.cursors.SaveCursor(ctx, c); err != nil {
+			return err
+		}
+		report.Updated++
+	}
+
+	// 远端已删除的记录
+	for _, c := range cursors {
+		if _, ok := remote[c.RemoteID]; ok {
+			continue
+		}
+		localTime, exists := local[c.LocalID]
+		if exists && localTime > c.LocalModifiedTime {
+			report.Conflicts = append(report.Conflicts, OkrConflict{Kind: t.kind(), LocalID: c.LocalID, RemoteID: c.RemoteID, Reason: "deleted remotely but modified locally"})
+			continue
+		}
+		if exists {
+			if err := t.deleteLocal(ctx, c.LocalID); err != nil {
+				return err
+			}
+			report.Deleted++
+		}
+		if err := s.cursors.DeleteCursor(ctx, c.ID); err != nil {
+			return err
+		}
+		t.untrack(c)
+	}
+
+	return nil
+}
+
+func (s *OkrSyncService) pushTable(ctx context.Context, t okrSyncTable, report *OkrSyncReport) error {
+	cursors, err := s.cursors.ListCursors(ctx, t.kind())
+	if err != nil {
+		return err
+	}
+	byLocal := make(map[string]*model.OkrSyncCursor, len(cursors))
+	for i := range cursors {
+		byLocal[cursors[i].LocalID] = &cursors[i]
+	}
+
+	local, remote := t.localTimes(), t.remoteTimes()
+	var pushed []*model.OkrSyncCursor
+
+	for localID, localTime := range local {
+		c, ok := byLocal[localID]
+		if !ok {
+			remoteID, err := t.push(ctx, localID, "")
+			if errors.Is(err, errSkipRecord) {
+				report.Conflicts = append(report.Conflicts, OkrConflict{Kind: t.kind(), LocalID: localID, Reason: "linked objective has not been synced"})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			cursor := &model.OkrSyncCursor{Kind: t.kind(), LocalID: localID, RemoteID: remoteID, LocalModifiedTime: localTime}
+			if err := s.cursors.SaveCursor(ctx, cursor); err != nil {
+				return err
+			}
+			t.track(cursor)
+			pushed = append(pushed, cursor)
+			report.Created++
+			continue
+		}
+
+		if localTime <= c.LocalModifiedTime {
+			continue
+		}
+
+		remoteTime, exists := remote[c.RemoteID]
+		if exists && remoteTime > c.RemoteModifiedTime {
+			report.Conflicts = append(report.Conflicts, OkrConflict{Kind: t.kind(), LocalID: localID, RemoteID: c.RemoteID, Reason: "both sides modified since last sync"})
+			continue
+		}
+
+		target := c.RemoteID
+		if !exists {
+			target = ""
+		}
+		remoteID, err := t.push(ctx, localID, target)
+		if errors.Is(err, errSkipRecord) {
+			report.Conflicts = append(report.Conflicts, OkrConflict{Kind: t.kind(), LocalID: localID, RemoteID: c.RemoteID, Reason: "linked objective has not been synced"})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.RemoteID, c.LocalModifiedTime = remoteID, localTime
+		if err := s.cursors.SaveCursor(ctx, c); err != nil {
+			return err
+		}
+		pushed = append(pushed, c)
+		report.Updated++
+	}
+
+	// 本地已删除的记录
+	for _, c := range cursors {
+		if _, ok := local[c.LocalID]; ok {
+			continue
+		}
+		remoteTime, exists := remote[c.RemoteID]
+		if exists && remoteTime > c.RemoteModifiedTime {
+			report.Conflicts = append(report.Conflicts, OkrConflict{Kind: t.kind(), LocalID: c.LocalID, RemoteID: c.RemoteID, Reason: "deleted locally but modified remotely"})
+			continue
+		}
+		if exists {
+			if err := t.deleteRemote(ctx, c.RemoteID); err != nil {
+				return err
+			}
+			report.Deleted++
+		}
+		if err := s.cursors.DeleteCursor(ctx, c.ID); err != nil {
+			return err
+		}
+		t.untrack(c)
+	}
+
+	if len(pushed) == 0 {
+		return nil
+	}
+
+	// 推送后远端记录的最后修改时间发生了变化，需要回读以避免下次同步时误判为远端修改
+	remote, err = t.reloadRemoteTimes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range pushed {
+		c.RemoteModifiedTime = remote[c.RemoteID]
+		if err := s.cursors.SaveCursor(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reportConflicts 通过通知器上报冲突，而不是静默覆盖
+func (s *OkrSyncService) reportConflicts(mode string, report *OkrSyncReport) {
+	if len(report.Conflicts) == 0 {
+		log.Infow("OKR sync succeeded", "mode", mode, "created", report.Created, "updated", report.Updated, "deleted", report.Deleted)
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "OKR %s sync finished with %d conflict(s):", mode, len(report.Conflicts))
+	for _, c := range report.Conflicts {
+		fmt.Fprintf(&b, "\n- %s local=%s remote=%s: %s", c.Kind, c.LocalID, c.RemoteID, c.Reason)
+	}
+	log.Warnw("OKR sync conflicts detected", "mode", mode, "conflicts", len(report.Conflicts))
+	s.notifier.Send(b.String())
+}
+
+// okrSnapshot 保存一次同步开始时两端的全部记录，键为不带前缀的记录ID
+type okrSnapshot struct {
+	localObjectives  map[string]model.ObjectiveRecord
+	localKeyResults  map[string]model.KeyResultRecord
+	remoteObjectives map[string]model.Objective
+	remoteKeyResults map[string]model.KeyResult
+
+	// objectiveLocalToRemote 与 objectiveRemoteToLocal 用于转换关键结果关联的目标ID
+	objectiveLocalToRemote map[string]string
+	objectiveRemoteToLocal map[string]string
+}
+
+func (s *OkrSyncService) loadSnapshot(ctx context.Context) (*okrSnapshot, error) {
+	snap := &okrSnapshot{
+		localObjectives:        make(map[string]model.ObjectiveRecord),
+		localKeyResults:        make(map[string]model.KeyResultRecord),
+		remoteObjectives:       make(map[string]model.Objective),
+		remoteKeyResults:       make(map[string]model.KeyResult),
+		objectiveLocalToRemote: make(map[string]string),
+		objectiveRemoteToLocal: make(map[string]string),
+	}
+
+	localObjectives, err := s.local.ListAllObjectives(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range localObjectives {
+		snap.localObjectives[o.RecordID] = o
+	}
+
+	localKeyResults, err := s.local.ListAllKeyResults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, kr := range localKeyResults {
+		snap.localKeyResults[kr.RecordID] = kr
+	}
+
+	remoteObjectives, err := s.remote.ListAllObjectives(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range remoteObjectives {
+		snap.remoteObjectives[strings.TrimPrefix(o.ID, okr.OPrefix)] = o
+	}
+
+	remoteKeyResults, err := s.remote.ListAllKeyResults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, kr := range remoteKeyResults {
+		snap.remoteKeyResults[strings.TrimPrefix(kr.ID, okr.KrPrefix)] = kr
+	}
+
+	cursors, err := s.cursors.ListCursors(ctx, model.OkrSyncKindObjective)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cursors {
+		snap.objectiveLocalToRemote[c.LocalID] = c.RemoteID
+		snap.objectiveRemoteToLocal[c.RemoteID] = c.LocalID
+	}
+
+	return snap, nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/okr"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// errSkipRecord 表示记录暂时无法推送(例如关联的目标尚未同步)，应作为冲突上报而不是中断同步
+var errSkipRecord = errors.New("skip record")
+
+// okrSyncTable 抽象了一张参与同步的表(目标表或关键结果表)在两端的读写操作
+type okrSyncTable interface {
+	kind() string
+	// localTimes 和 remoteTimes 返回记录ID到最后修改时间(毫秒)的映射
+	localTimes() map[string]int64
+	remoteTimes() map[string]int64
+	reloadRemoteTimes(ctx context.Context) (map[string]int64, error)
+	// pull 将远端记录写入本地，localID 为空时新建，返回本地记录ID及其最后修改时间
+	pull(ctx context.Context, remoteID, localID string) (string, int64, error)
+	// push 将本地记录写入远端，remoteID 为空时新建，返回远端记录ID
+	push(ctx context.Context, localID, remoteID string) (string, error)
+	deleteLocal(ctx context.Context, localID string) error
+	deleteRemote(ctx context.Context, remoteID string) error
+	// track 和 untrack 在游标变化时维护快照中的ID映射
+	track(c *model.OkrSyncCursor)
+	untrack(c model.OkrSyncCursor)
+}
+
+type objectiveTable struct {
+	s    *OkrSyncService
+	snap *okrSnapshot
+}
+
+func (t *objectiveTable) kind() string { return model.OkrSyncKindObjective }
+
+func (t *objectiveTable) localTimes() map[string]int64 {
+	times := make(map[string]int64, len(t.snap.localObjectives))
+	for id, o := range t.snap.localObjectives {
+		times[id] = o.LastModifiedTime
+	}
+	return times
+}
+
+func (t *objectiveTable) remoteTimes() map[string]int64 {
+	times := make(map[string]int64, len(t.snap.remoteObjectives))
+	for id, o := range t.snap.remoteObjectives {
+		times[id] = o.LastModifiedTime
+	}
+	return times
+}
+
+func (t *objectiveTable) reloadRemoteTimes(ctx context.Context) (map[string]int64, error) {
+	objectives, err := t.s.remote.ListAllObjectives(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.snap.remoteObjectives = make(map[string]model.Objective, len(objectives))
+	for _, o := range objectives {
+		t.snap.remoteObjectives[strings.TrimPrefix(o.ID, okr.OPrefix)] = o
+	}
+	return t.remoteTimes(), nil
+}
+
+func (t *objectiveTable) pull(ctx context.Context, remoteID, localID string) (string, int64, error) {
+	r := t.snap.remoteObjectives[remoteID]
+
+	record := model.ObjectiveRecord{RecordID: localID}
+	if localID != "" {
+		record = t.snap.localObjectives[localID]
+	} else if _, taken := t.snap.localObjectives[remoteID]; !taken {
+		// 尽量沿用远端记录ID，便于排查
+		record.RecordID = remoteID
+	}
+	record.Title = r.Title
+	record.Owner = r.Owner
+	record.Date = r.Date
+	record.Weight = r.Weight
+
+	var err error
+	if localID == "" {
+		err = t.s.local.CreateObjective(ctx, &record)
+	} else {
+		err = t.s.local.UpdateObjective(ctx, &record)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	t.snap.localObjectives[record.RecordID] = record
+	return record.RecordID, record.LastModifiedTime, nil
+}
+
+func (t *objectiveTable) push(ctx context.Context, localID, remoteID string) (string, error) {
+	record := t.snap.localObjectives[localID]
+	objective := model.Objective{
+		ID:     remoteID,
+		Title:  record.Title,
+		Owner:  record.Owner,
+		Date:   record.Date,
+		Weight: record.Weight,
+	}
+	if remoteID == "" {
+		return t.s.remote.CreateObjective(ctx, objective)
+	}
+	return remoteID, t.s.remote.UpdateObjective(ctx, objective)
+}
+
+func (t *objectiveTable) deleteLocal(ctx context.Context, localID string) error {
+	return t.s.local.DeleteObjective(ctx, localID, nil)
+}
+
+func (t *objectiveTable) deleteRemote(ctx context.Context, remoteID string) error {
+	return t.s.remote.DeleteObjectiveByID(ctx, remoteID, nil)
+}
+
+func (t *objectiveTable) track(c *model.OkrSyncCursor) {
+	t.snap.objectiveLocalToRemote[c.LocalID] = c.RemoteID
+	t.snap.objectiveRemoteToLocal[c.RemoteID] = c.LocalID
+}
+
+func (t *objectiveTable) untrack(c model.OkrSyncCursor) {
+	delete(t.snap.objectiveLocalToRemote, c.LocalID)
+	delete(t.snap.objectiveRemoteToLocal, c.RemoteID)
+}
+
+type keyResultTable struct {
+	s    *OkrSyncService
+	snap *okrSnapshot
+}
+
+func (t *keyResultTable) kind() string { return model.OkrSyncKindKeyResult }
+
+func (t *keyResultTable) localTimes() map[string]int64 {
+	times := make(map[string]int64, len(t.snap.localKeyResults))
+	for id, kr := range t.snap.localKeyResults {
+		times[id] = kr.LastModifiedTime
+	}
+	return times
+}
+
+func (t *keyResultTable) remoteTimes() map[string]int64 {
+	times := make(map[string]int64, len(t.snap.remoteKeyResults))
+	for id, kr := range t.snap.remoteKeyResults {
+		times[id] = kr.LastModifiedTime
+	}
+	return times
+}
+
+func (t *keyResultTable) reloadRemoteTimes(ctx context.Context) (map[string]int64, error) {
+	krs, err := t.s.remote.ListAllKeyResults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.snap.remoteKeyResults = make(map[string]model.KeyResult, len(krs))
+	for _, kr := range krs {
+		t.snap.remoteKeyResults[strings.TrimPrefix(kr.ID, okr.KrPrefix)] = kr
+	}
+	return t.remoteTimes(), nil
+}
+
+func (t *keyResultTable) pull(ctx context.Context, remoteID, localID string) (string, int64, error) {
+	r := t.snap.remoteKeyResults[remoteID]
+
+	record := model.KeyResultRecord{RecordID: localID}
+	if localID != "" {
+		record = t.snap.localKeyResults[localID]
+	} else if _, taken := t.snap.localKeyResults[remoteID]; !taken {
+		record.RecordID = remoteID
+	}
+
+	objectiveID := strings.TrimPrefix(r.ObjectiveID, okr.OPrefix)
+	if id, ok := t.snap.objectiveRemoteToLocal[objectiveID]; ok {
+		objectiveID = id
+	}
+
+	record.ObjectiveID = objectiveID
+	record.Title = r.Title
+	record.Owner = r.Owner
+	record.Date = r.Date
+	record.Weight = r.Weight
+	record.Completed = r.Completed
+	record.SelfRating = r.SelfRating
+	record.LeaderRating = r.LeaderRating
+	record.Reason = r.Reason
+	record.Criteria = r.Criteria
+	record.Leader = r.Leader
+	record.Department = r.Department
+
+	var err error
+	if localID == "" {
+		err = t.s.local.CreateKeyResult(ctx, &record)
+	} else {
+		err = t.s.local.UpdateKeyResult(ctx, &record)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	t.snap.localKeyResults[record.RecordID] = record
+	return record.RecordID, record.LastModifiedTime, nil
+}
+
+func (t *keyResultTable) push(ctx context.Context, localID, remoteID string) (string, error) {
+	record := t.snap.localKeyResults[localID]
+
+	var objectiveID string
+	if record.ObjectiveID != "" {
+		id, ok := t.snap.objectiveLocalToRemote[record.ObjectiveID]
+		if !ok {
+			return "", errSkipRecord
+		}
+		objectiveID = id
+	}
+
+	kr := model.KeyResult{
+		ID:           remoteID,
+		Title:        record.Title,
+		Owner:        record.Owner,
+		Date:         record.Date,
+		Weight:       record.Weight,
+		Completed:    record.Completed,
+		SelfRating:   record.SelfRating,
+		LeaderRating: record.LeaderRating,
+		Reason:       record.Reason,
+		Criteria:     record.Criteria,
+		ObjectiveID:  objectiveID,
+	}
+
+	if remoteID != "" {
+		return remoteID, t.s.remote.UpdateKeyResult(ctx, kr)
+	}
+
+	id, err := t.s.remote.CreateKeyResult(ctx, kr)
+	if err != nil {
+		return "", err
+	}
+	// 新建接口不写入上级评分，需要补一次更新
+	if kr.LeaderRating != nil {
+		kr.ID = id
+		if err := t.s.remote.UpdateKeyResult(ctx, kr); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+func (t *keyResultTable) deleteLocal(ctx context.Context, localID string) error {
+	return t.s.local.DeleteKeyResult(ctx, localID)
+}
+
+func (t *keyResultTable) deleteRemote(ctx context.Context, remoteID string) error {
+	return t.s.remote.DeleteKeyResultByID(ctx, remoteID)
+}
+
+func (t *keyResultTable) track(c *model.OkrSyncCursor) {}
+
+func (t *keyResultTable) untrack(c model.OkrSyncCursor) {}
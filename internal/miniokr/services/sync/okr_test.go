@@ -0,0 +1,304 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/okr"
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// fakeRemote 是内存中的飞书多维表格，每次写入都会推进最后修改时间
+type fakeRemote struct {
+	clock      int64
+	seq        int
+	objectives map[string]model.Objective
+	keyResults map[string]model.KeyResult
+}
+
+func newFakeRemote() *fakeRemote {
+	return &fakeRemote{
+		clock:      time.Now().UnixMilli(),
+		objectives: make(map[string]model.Objective),
+		keyResults: make(map[string]model.KeyResult),
+	}
+}
+
+func (f *fakeRemote) tick() int64 {
+	f.clock++
+	return f.clock
+}
+
+func (f *fakeRemote) ListAllObjectives(ctx context.Context) ([]model.Objective, error) {
+	var result []model.Objective
+	for id, o := range f.objectives {
+		o.ID = okr.OPrefix + id
+		result = append(result, o)
+	}
+	return result, nil
+}
+
+func (f *fakeRemote) ListAllKeyResults(ctx context.Context) ([]model.KeyResult, error) {
+	var result []model.KeyResult
+	for id, kr := range f.keyResults {
+		kr.ID = okr.KrPrefix + id
+		if kr.ObjectiveID != "" {
+			kr.ObjectiveID = okr.OPrefix + kr.ObjectiveID
+		}
+		result = append(result, kr)
+	}
+	return result, nil
+}
+
+func (f *fakeRemote) CreateObjective(ctx context.Context, o model.Objective) (string, error) {
+	f.seq++
+	id := fmt.Sprintf("recRemoteO%d", f.seq)
+	o.LastModifiedTime = f.tick()
+	f.objectives[id] = o
+	return id, nil
+}
+
+func (f *fakeRemote) UpdateObjective(ctx context.Context, o model.Objective) error {
+	if _, ok := f.objectives[o.ID]; !ok {
+		return fmt.Errorf("objective %s not found", o.ID)
+	}
+	o.LastModifiedTime = f.tick()
+	f.objectives[o.ID] = o
+	return nil
+}
+
+func (f *fakeRemote) DeleteObjectiveByID(ctx context.Context, oid string, krids []string) error {
+	delete(f.objectives, oid)
+	return nil
+}
+
+func (f *fakeRemote) CreateKeyResult(ctx context.Context, kr model.KeyResult) (string, error) {
+	f.seq++
+	id := fmt.Sprintf("recRemoteKR%d", f.seq)
+	kr.LeaderRating = nil
+	kr.LastModifiedTime = f.tick()
+	f.keyResults[id] = kr
+	return id, nil
+}
+
+func (f *fakeRemote) UpdateKeyResult(ctx context.Context, kr model.KeyResult) error {
+	if _, ok := f.keyResults[kr.ID]; !ok {
+		return fmt.Errorf("key result %s not found", kr.ID)
+	}
+	kr.LastModifiedTime = f.tick()
+	f.keyResults[kr.ID] = kr
+	return nil
+}
+
+func (f *fakeRemote) DeleteKeyResultByID(ctx context.Context, id string) error {
+	delete(f.keyResults, id)
+	return nil
+}
+
+// fakeNotifier 记录所有通知内容
+type fakeNotifier struct {
+	messages []string
+}
+
+func (n *fakeNotifier) Send(message string) error {
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func newTestOkrSyncService(t *testing.T) (*OkrSyncService, store.OkrStore, *fakeRemote, *fakeNotifier) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.ObjectiveRecord{}, &model.KeyResultRecord{}, &model.OkrSyncCursor{}, &model.OkrSyncLease{}))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// 内存数据库的每个连接都是独立的库，并发同步时需要使用同一个连接
+	sqlDB.SetMaxOpenConns(1)
+
+	local := store.NewOkrStore(db)
+	remote := newFakeRemote()
+	notifier := &fakeNotifier{}
+	return NewOkrSyncService(local, store.NewOkrSyncStore(db), remote, notifier), local, remote, notifier
+}
+
+func TestOkrSync_PushCreatesRemoteRecords(t *testing.T) {
+	ctx := context.Background()
+	svc, local, remote, _ := newTestOkrSyncService(t)
+
+	o := model.ObjectiveRecord{Title: "O1", Owner: "张三", Date: "2024年5月", Weight: 100}
+	require.NoError(t, local.CreateObjective(ctx, &o))
+	rating := 100
+	kr := model.KeyResultRecord{ObjectiveID: o.RecordID, Title: "KR1", Owner: "张三", Date: "2024年5月", Weight: 100, Completed: "未开始", LeaderRating: &rating}
+	require.NoError(t, local.CreateKeyResult(ctx, &kr))
+
+	report, err := svc.Push(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Empty(t, report.Conflicts)
+
+	require.Len(t, remote.objectives, 1)
+	require.Len(t, remote.keyResults, 1)
+	for id, r := range remote.keyResults {
+		assert.True(t, strings.HasPrefix(id, "recRemoteKR"))
+		_, ok := remote.objectives[r.ObjectiveID]
+		assert.True(t, ok, "key result should link to the remote objective ID")
+		require.NotNil(t, r.LeaderRating)
+		assert.Equal(t, 100, *r.LeaderRating)
+	}
+
+	// 没有修改时再次同步不应产生任何变化
+	report, err = svc.Sync(ctx, OkrSyncModeBoth)
+	require.NoError(t, err)
+	assert.Equal(t, OkrSyncReport{}, *report)
+}
+
+func TestOkrSync_PullAppliesRemoteChanges(t *testing.T) {
+	ctx := context.Background()
+	svc, local, remote, _ := newTestOkrSyncService(t)
+
+	oid, _ := remote.CreateObjective(ctx, model.Objective{Title: "O1", Owner: "李四", Date: "2024年6月", Weight: 50})
+	_, _ = remote.CreateKeyResult(ctx, model.KeyResult{Title: "KR1", Owner: "李四", Date: "2024年6月", Weight: 100, ObjectiveID: oid, Leader: "王五", Department: "研发部"})
+
+	report, err := svc.Pull(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+
+	objectives, err := local.ListObjectivesByOwner(ctx, "李四", nil)
+	require.NoError(t, err)
+	require.Len(t, objectives, 1)
+	krs, err := local.ListKeyResultsByOwner(ctx, "李四", nil)
+	require.NoError(t, err)
+	require.Len(t, krs, 1)
+	assert.Equal(t, objectives[0].RecordID, krs[0].ObjectiveID)
+	assert.Equal(t, "王五", krs[0].Leader)
+
+	// 远端修改后再次拉取
+	o := remote.objectives[oid]
+	o.ID = oid
+	o.Title = "O1 (updated)"
+	require.NoError(t, remote.UpdateObjective(ctx, o))
+
+	report, err = svc.Pull(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Updated)
+	updated, err := local.GetObjective(ctx, objectives[0].RecordID)
+	require.NoError(t, err)
+	assert.Equal(t, "O1 (updated)", updated.Title)
+
+	// 远端删除后拉取，本地同步删除
+	require.NoError(t, remote.DeleteObjectiveByID(ctx, oid, nil))
+	report, err = svc.Pull(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Deleted)
+	_, err = local.GetObjective(ctx, objectives[0].RecordID)
+	assert.ErrorIs(t, err, store.ErrRecordNotFound)
+}
+
+func TestOkrSync_ConflictIsReportedNotOverwritten(t *testing.T) {
+	ctx := context.Background()
+	svc, local, remote, notifier := newTestOkrSyncService(t)
+
+	o := model.ObjectiveRecord{Title: "O1", Owner: "张三", Date: "2024年5月", Weight: 100}
+	require.NoError(t, local.CreateObjective(ctx, &o))
+	_, err := svc.Push(ctx)
+	require.NoError(t, err)
+
+	var remoteID string
+	for id := range remote.objectives {
+		remoteID = id
+	}
+
+	// 两端同时修改
+	time.Sleep(2 * time.Millisecond)
+	o.Title = "O1 local"
+	require.NoError(t, local.UpdateObjective(ctx, &o))
+	r := remote.objectives[remoteID]
+	r.ID = remoteID
+	r.Title = "O1 remote"
+	require.NoError(t, remote.UpdateObjective(ctx, r))
+
+	report, err := svc.Sync(ctx, OkrSyncModeBoth)
+	require.NoError(t, err)
+	require.Len(t, report.Conflicts, 2) // 拉取和推送各上报一次
+	assert.Equal(t, model.OkrSyncKindObjective, report.Conflicts[0].Kind)
+
+	// 两端都保持各自的修改
+	got, err := local.GetObjective(ctx, o.RecordID)
+	require.NoError(t, err)
+	assert.Equal(t, "O1 local", got.Title)
+	assert.Equal(t, "O1 remote", remote.objectives[remoteID].Title)
+
+	require.NotEmpty(t, notifier.messages)
+	assert.Contains(t, notifier.messages[len(notifier.messages)-1], "conflict")
+}
+
+func TestOkrSync_InvalidMode(t *testing.T) {
+	svc, _, _, _ := newTestOkrSyncService(t)
+	_, err := svc.Sync(context.Background(), "sideways")
+	assert.Error(t, err)
+}
+
+// blockingRemote 在列出远端目标时通知 started 并阻塞，直到 release 被关闭
+type blockingRemote struct {
+	*fakeRemote
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingRemote) ListAllObjectives(ctx context.Context) ([]model.Objective, error) {
+	select {
+	case <-b.started:
+	default:
+		close(b.started)
+	}
+	<-b.release
+	return b.fakeRemote.ListAllObjectives(ctx)
+}
+
+func TestOkrSync_ConcurrentSyncDoesNotDoubleCreate(t *testing.T) {
+	ctx := context.Background()
+	svc, local, remote, _ := newTestOkrSyncService(t)
+	blocking := &blockingRemote{fakeRemote: remote, started: make(chan struct{}), release: make(chan struct{})}
+	svc.remote = blocking
+
+	o := model.ObjectiveRecord{Title: "O1", Owner: "张三", Date: "2024年5月", Weight: 100}
+	require.NoError(t, local.CreateObjective(ctx, &o))
+
+	done := make(chan error)
+	go func() {
+		_, err := svc.Sync(ctx, OkrSyncModeBoth)
+		done <- err
+	}()
+	<-blocking.started
+
+	// 第一次同步尚未推送时再次同步，被租约拒绝而不是重复推送
+	_, err := svc.Sync(ctx, OkrSyncModePush)
+	assert.ErrorIs(t, err, ErrOkrSyncInProgress)
+
+	close(blocking.release)
+	require.NoError(t, <-done)
+	assert.Len(t, remote.objectives, 1)
+
+	// 同步结束后释放租约，再次同步不会重复创建
+	svc.remote = remote
+	report, err := svc.Sync(ctx, OkrSyncModeBoth)
+	require.NoError(t, err)
+	assert.Zero(t, report.Created)
+	assert.Len(t, remote.objectives, 1)
+}
@@ -13,12 +13,16 @@ import (
 	"github.com/imxw/miniokr/internal/miniokr/store"
 	"github.com/imxw/miniokr/internal/pkg/log"
 	"github.com/imxw/miniokr/internal/pkg/model"
+	"github.com/imxw/miniokr/internal/pkg/retry"
 )
 
 var _ Service = (*SyncService)(nil)
 
 var rootDeptId = user.RootDeptID
 
+// dingTalkRetryPolicy 是调用钉钉接口时的重试策略
+var dingTalkRetryPolicy = retry.DefaultPolicy()
+
 type SyncService struct {
 	dingClient     *dingtalk.DingTalk
 	store          store.SyncStorer
@@ -37,14 +41,14 @@ func NewSyncService(dingClient *dingtalk.DingTalk, store store.SyncStorer, notif
 }
 
 func (s *SyncService) SyncDepartmentsAndUsers(ctx context.Context) error {
-	departments, err := fetchAndPersistAllDepartments(s.dingClient, rootDeptId, s.excludeDeptIDs)
+	departments, err := fetchAndPersistAllDepartments(ctx, s.dingClient, rootDeptId, s.excludeDeptIDs)
 	if err != nil {
 		log.Errorw("Department sync failed", "err", err)
 		s.notifier.Send("DingTalk department sync task failed: " + err.Error())
 		return err
 	}
 
-	users, userDepts, err := fetchAndPersistAllUsers(s.dingClient, rootDeptId, s.excludeDeptIDs)
+	users, userDepts, err := fetchAndPersistAllUsers(ctx, s.dingClient, rootDeptId, s.excludeDeptIDs)
 	if err != nil {
 		log.Errorw("User sync failed", "err", err)
 		s.notifier.Send("DingTalk user sync task failed: " + err.Error())
@@ -72,12 +76,25 @@ func (s *SyncService) SyncDepartmentsAndUsers(ctx context.Context) error {
 		return err
 	}
 
+	// 主管变化后同步 leader 角色，管理员手动授予的角色不受影响
+	if err := s.store.SyncLeaderRoles(ctx); err != nil {
+		log.Errorw("Sync leader roles failed", "err", err)
+		s.notifier.Send("Sync leader roles task failed: " + err.Error())
+		return err
+	}
+
+	// 组织架构已更新，使主管管理范围等缓存失效
+	if err := s.store.BumpGeneration(ctx, model.SyncGenerationOrg); err != nil {
+		log.Errorw("Bump org sync generation failed", "err", err)
+		return err
+	}
+
 	s.notifier.Send("DingTalk sync task succeeded")
 	return nil
 }
 
-func fetchAndPersistAllDepartments(dingClient *dingtalk.DingTalk, rootDeptId int, excludeDeptIDs map[int]bool) ([]model.Department, error) {
-	departments, err := getAllDeptIds(dingClient, rootDeptId, nil, excludeDeptIDs)
+func fetchAndPersistAllDepartments(ctx context.Context, dingClient *dingtalk.DingTalk, rootDeptId int, excludeDeptIDs map[int]bool) ([]model.Department, error) {
+	departments, err := getAllDeptIds(ctx, dingClient, rootDeptId, nil, excludeDeptIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +103,7 @@ func fetchAndPersistAllDepartments(dingClient *dingtalk.DingTalk, rootDeptId int
 }
 
 // 递归获取所有部门ID和名称
-func getAllDeptIds(dingClient *dingtalk.DingTalk, deptId int, parentId *int, excludeDeptIDs map[int]bool) ([]model.Department, error) {
+func getAllDeptIds(ctx context.Context, dingClient *dingtalk.DingTalk, deptId int, parentId *int, excludeDeptIDs map[int]bool) ([]model.Department, error) {
 	var departments []model.Department
 
 	// 检查当前部门是否在排除列表中
@@ -98,7 +115,7 @@ func getAllDeptIds(dingClient *dingtalk.DingTalk, deptId int, parentId *int, exc
 		DeptId: deptId,
 	}
 	var res response.DeptList
-	err := withRetry(func() error {
+	err := retry.Do(ctx, dingTalkRetryPolicy, func(context.Context) error {
 		var err error
 		res, err = dingClient.GetDeptList(req)
 		return err
@@ -120,7 +137,7 @@ func getAllDeptIds(dingClient *dingtalk.DingTalk, deptId int, parentId *int, exc
 		departments = append(departments, department)
 
 		// 递归获取子部门
-		subDepartments, err := getAllDeptIds(dingClient, dept.Id, &dept.ParentId, excludeDeptIDs)
+		subDepartments, err := getAllDeptIds(ctx, dingClient, dept.Id, &dept.ParentId, excludeDeptIDs)
 		if err != nil {
 			return nil, err
 		}
@@ -130,8 +147,8 @@ func getAllDeptIds(dingClient *dingtalk.DingTalk, deptId int, parentId *int, exc
 	return departments, nil
 }
 
-func fetchAndPersistAllUsers(dingClient *dingtalk.DingTalk, rootDeptId int, excludeDeptIDs map[int]bool) ([]model.User, []model.UserDepartment, error) {
-	users, userDepts, err := getAllDeptUsers(dingClient, rootDeptId, excludeDeptIDs)
+func fetchAndPersistAllUsers(ctx context.Context, dingClient *dingtalk.DingTalk, rootDeptId int, excludeDeptIDs map[int]bool) ([]model.User, []model.UserDepartment, error) {
+	users, userDepts, err := getAllDeptUsers(ctx, dingClient, rootDeptId, excludeDeptIDs)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -140,7 +157,7 @@ func fetchAndPersistAllUsers(dingClient *dingtalk.DingTalk, rootDeptId int, excl
 }
 
 // Get all users and user-department mappings recursively
-func getAllDeptUsers(dingClient *dingtalk.DingTalk, deptId int, excludeDeptIDs map[int]bool) ([]model.User, []model.UserDepartment, error) {
+func getAllDeptUsers(ctx context.Context, dingClient *dingtalk.DingTalk, deptId int, excludeDeptIDs map[int]bool) ([]model.User, []model.UserDepartment, error) {
 	var users []model.User
 	var userDepts []model.UserDepartment
 
@@ -170,7 +187,7 @@ func getAllDeptUsers(dingClient *dingtalk.DingTalk, deptId int, excludeDeptIDs m
 			}
 
 			var res response.DeptDetailUserInfo
-			err := withRetry(func() error {
+			err := retry.Do(ctx, dingTalkRetryPolicy, func(context.Context) error {
 				var err error
 				res, err = dingClient.GetDeptDetailUserInfo(req)
 				return err
@@ -215,7 +232,12 @@ func getAllDeptUsers(dingClient *dingtalk.DingTalk, deptId int, excludeDeptIDs m
 			cursor = res.Page.NextCursor
 		}
 
-		subDeptList, err := dingClient.GetSubDeptList(deptId)
+		var subDeptList response.SubDeptList
+		err := retry.Do(ctx, dingTalkRetryPolicy, func(context.Context) error {
+			var err error
+			subDeptList, err = dingClient.GetSubDeptList(deptId)
+			return err
+		})
 		if err != nil {
 			return err
 		}
@@ -249,19 +271,3 @@ func persistUsers(store store.SyncStorer, users []model.User) error {
 func persistUserDepartments(store store.SyncStorer, userDepts []model.UserDepartment) error {
 	return store.PersistUserDepartments(nil, userDepts)
 }
-
-// 使用重试机制的函数
-func withRetry(fn func() error) error {
-	const maxRetries = 5
-	const baseDelay = time.Second
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		err = fn()
-		if err == nil {
-			return nil
-		}
-		log.Errorw("请求失败，重试次数", "error", err, "retry", i+1)
-		time.Sleep(baseDelay * time.Duration(i+1))
-	}
-	return err
-}
@@ -0,0 +1,56 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package tenant
+
+import (
+	"context"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/field"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+var _ field.Service = (*FieldService)(nil)
+
+// FieldService 将请求转发给当前用户所属公司的 field.Service
+type FieldService struct {
+	router *Router
+}
+
+func NewFieldService(router *Router) *FieldService {
+	return &FieldService{router: router}
+}
+
+func (s *FieldService) service(ctx context.Context) (field.Service, error) {
+	t, err := s.router.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t.FieldService, nil
+}
+
+func (s *FieldService) GetFieldDefinitions(ctx context.Context) (v1.FieldMappingsResponse, error) {
+	svc, err := s.service(ctx)
+	if err != nil {
+		return v1.FieldMappingsResponse{}, err
+	}
+	return svc.GetFieldDefinitions(ctx)
+}
+
+func (s *FieldService) GetValidDates(ctx context.Context) ([]string, error) {
+	svc, err := s.service(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return svc.GetValidDates(ctx)
+}
+
+func (s *FieldService) GetValidUsers(ctx context.Context) ([]string, error) {
+	svc, err := s.service(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return svc.GetValidUsers(ctx)
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package tenant
+
+import (
+	"context"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/okr"
+)
+
+var _ okr.Resolver = (*OkrService)(nil)
+
+// OkrService 按 OKR 负责人所属的公司返回对应的 okr.Service.
+// 主管或管理员访问其他公司用户的 OKR 时，请求路由到负责人所属的公司，而不是当前用户所属的公司.
+// 返回的 okr.Service 在修改记录前通过 owners 校验记录负责人
+type OkrService struct {
+	router *Router
+	owners okr.OwnerAuthorizer
+}
+
+func NewOkrService(router *Router, owners okr.OwnerAuthorizer) *OkrService {
+	return &OkrService{router: router, owners: owners}
+}
+
+func (s *OkrService) ForUser(ctx context.Context, userID string) (okr.Service, error) {
+	t, err := s.router.ForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return okr.NewOwnerCheckedService(t.OkrService, s.owners), nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+// Package tenant 支持集团下多个子公司各自使用独立的飞书多维表格，按用户所属部门路由请求.
+package tenant // import "github.com/imxw/miniokr/internal/miniokr/services/tenant"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/field"
+	"github.com/imxw/miniokr/internal/miniokr/services/okr"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// Tenant 是一个拥有独立多维表格的公司
+type Tenant struct {
+	// Name 是公司名称，作为组织架构树的根节点
+	Name string
+	// DepartmentIDs 是属于该公司的顶层部门，其下级部门的用户都路由到该公司；为空表示默认公司
+	DepartmentIDs []int
+	FieldService  field.Service
+	OkrService    okr.Service
+}
+
+// DepartmentStore 是路由时查询用户部门和上级部门所需的存储接口
+type DepartmentStore interface {
+	GetUserDepartments(ctx context.Context, userID string) ([]model.UserDepartment, error)
+	GetDepartmentByID(ctx context.Context, departmentID int) (*model.Department, error)
+}
+
+// Router 根据用户所属部门找到对应的公司
+type Router struct {
+	store    DepartmentStore
+	tenants  []*Tenant
+	byDept   map[int]*Tenant
+	fallback *Tenant
+}
+
+// NewRouter 创建 Router. 没有配置部门的公司作为默认公司，都配置了部门时第一个公司为默认公司
+func NewRouter(store DepartmentStore, tenants []*Tenant) (*Router, error) {
+	if len(tenants) == 0 {
+		return nil, errors.New("at least one tenant is required")
+	}
+
+	r := &Router{store: store, tenants: tenants, byDept: make(map[int]*Tenant)}
+	for _, t := range tenants {
+		if len(t.DepartmentIDs) == 0 {
+			if r.fallback != nil {
+				return nil, fmt.Errorf("tenants %q and %q both have no departments", r.fallback.Name, t.Name)
+			}
+			r.fallback = t
+			continue
+		}
+		for _, id := range t.DepartmentIDs {
+			if other, ok := r.byDept[id]; ok {
+				return nil, fmt.Errorf("department %d is assigned to both %q and %q", id, other.Name, t.Name)
+			}
+			r.byDept[id] = t
+		}
+	}
+	if r.fallback == nil {
+		r.fallback = tenants[0]
+	}
+
+	return r, nil
+}
+
+// Tenants 返回所有公司
+func (r *Router) Tenants() []*Tenant {
+	return r.tenants
+}
+
+// Default 返回默认公司
+func (r *Router) Default() *Tenant {
+	return r.fallback
+}
+
+// ForDepartment 沿上级部门向上查找部门所属的公司，找不到时返回默认公司.
+// 根部门不在部门表中，查不到部门即认为已到达顶层
+func (r *Router) ForDepartment(ctx context.Context, departmentID int) *Tenant {
+	visited := make(map[int]bool)
+	for id := departmentID; id != 0 && !visited[id]; {
+		if t, ok := r.byDept[id]; ok {
+			return t
+		}
+		visited[id] = true
+
+		dept, err := r.store.GetDepartmentByID(ctx, id)
+		if err != nil || dept.ParentID == nil {
+			break
+		}
+		id = *dept.ParentID
+	}
+
+	return r.fallback
+}
+
+// ForUser 返回用户所属的公司，用户属于多个部门时取第一个能匹配到公司的部门
+func (r *Router) ForUser(ctx context.Context, userID string) (*Tenant, error) {
+	if len(r.byDept) == 0 {
+		return r.fallback, nil
+	}
+
+	userDepts, err := r.store.GetUserDepartments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ud := range userDepts {
+		if t := r.ForDepartment(ctx, ud.DepartmentID); t != r.fallback {
+			return t, nil
+		}
+	}
+
+	return r.fallback, nil
+}
+
+// FromContext 返回当前请求用户所属的公司，ctx 中没有用户信息时返回默认公司
+func (r *Router) FromContext(ctx context.Context) (*Tenant, error) {
+	userID, _ := ctx.Value(known.XUserIDKey).(string)
+	if userID == "" {
+		return r.fallback, nil
+	}
+	return r.ForUser(ctx, userID)
+}
+
+// Company 返回当前请求用户所属公司的名称和顶层部门，用于构造组织架构树
+func (r *Router) Company(ctx context.Context) (string, []int) {
+	t, err := r.FromContext(ctx)
+	if err != nil {
+		t = r.fallback
+	}
+	return t.Name, t.DepartmentIDs
+}
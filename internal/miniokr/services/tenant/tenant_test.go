@@ -0,0 +1,164 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/okr"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// fakeDepartmentStore 组织架构：根部门 1(不在部门表中) -> 集团部门 10 / 子公司 20 -> 子公司下属部门 21 -> 22
+type fakeDepartmentStore struct {
+	parents   map[int]int
+	userDepts map[string][]int
+}
+
+func newFakeDepartmentStore() *fakeDepartmentStore {
+	return &fakeDepartmentStore{
+		parents: map[int]int{10: 1, 20: 1, 21: 20, 22: 21},
+		userDepts: map[string][]int{
+			"group-user": {10},
+			"sub-user":   {22},
+			"both-user":  {10, 21},
+			"root-user":  {1},
+		},
+	}
+}
+
+func (s *fakeDepartmentStore) GetUserDepartments(_ context.Context, userID string) ([]model.UserDepartment, error) {
+	ids, ok := s.userDepts[userID]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	var uds []model.UserDepartment
+	for _, id := range ids {
+		uds = append(uds, model.UserDepartment{UserID: userID, DepartmentID: id})
+	}
+	return uds, nil
+}
+
+func (s *fakeDepartmentStore) GetDepartmentByID(_ context.Context, departmentID int) (*model.Department, error) {
+	parent, ok := s.parents[departmentID]
+	if !ok {
+		return nil, errors.New("department not found")
+	}
+	return &model.Department{DepartmentID: departmentID, ParentID: &parent}, nil
+}
+
+// namedOkrService 只实现 CreateObjective，返回所属公司名称以便断言路由结果
+type namedOkrService struct {
+	okr.Service
+	name string
+}
+
+func (s *namedOkrService) CreateObjective(context.Context, model.Objective) (string, error) {
+	return s.name, nil
+}
+
+// userContext 模拟经过认证中间件后的请求上下文
+func userContext(userID string) context.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(known.XUserIDKey, userID)
+	return c
+}
+
+func newTestRouter(t *testing.T) *Router {
+	r, err := NewRouter(newFakeDepartmentStore(), []*Tenant{
+		{Name: "集团", OkrService: &namedOkrService{name: "集团"}},
+		{Name: "子公司", DepartmentIDs: []int{20}, OkrService: &namedOkrService{name: "子公司"}},
+	})
+	require.NoError(t, err)
+	return r
+}
+
+func TestRouter_ForUser(t *testing.T) {
+	r := newTestRouter(t)
+	ctx := context.Background()
+
+	cases := map[string]string{
+		"group-user": "集团",
+		"sub-user":   "子公司", // 通过上级部门 21 -> 20 匹配
+		"both-user":  "子公司", // 任一部门属于子公司
+		"root-user":  "集团",
+	}
+	for userID, want := range cases {
+		got, err := r.ForUser(ctx, userID)
+		require.NoError(t, err, userID)
+		assert.Equal(t, want, got.Name, userID)
+	}
+
+	_, err := r.ForUser(ctx, "unknown")
+	assert.Error(t, err)
+}
+
+func TestRouter_FromContext(t *testing.T) {
+	r := newTestRouter(t)
+
+	got, err := r.FromContext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "集团", got.Name)
+
+	ctx := userContext("sub-user")
+	got, err = r.FromContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "子公司", got.Name)
+
+	name, deptIDs := r.Company(ctx)
+	assert.Equal(t, "子公司", name)
+	assert.Equal(t, []int{20}, deptIDs)
+}
+
+// allowOwners 允许修改任何负责人的记录
+type allowOwners struct{}
+
+func (allowOwners) AuthorizeOwner(context.Context, string, string, string) error { return nil }
+
+func TestOkrService_RoutesByOwner(t *testing.T) {
+	svc := NewOkrService(newTestRouter(t), allowOwners{})
+
+	cases := []struct{ caller, owner, want string }{
+		{"sub-user", "sub-user", "子公司"},
+		{"group-user", "group-user", "集团"},
+		// 集团的主管或管理员访问子公司用户的 OKR 时路由到子公司
+		{"group-user", "sub-user", "子公司"},
+		{"sub-user", "group-user", "集团"},
+	}
+	for _, c := range cases {
+		okrService, err := svc.ForUser(userContext(c.caller), c.owner)
+		require.NoError(t, err)
+		got, err := okrService.CreateObjective(context.Background(), model.Objective{})
+		require.NoError(t, err)
+		assert.Equal(t, c.want, got, c)
+	}
+}
+
+func TestNewRouter_InvalidConfig(t *testing.T) {
+	store := newFakeDepartmentStore()
+
+	_, err := NewRouter(store, nil)
+	assert.Error(t, err)
+
+	_, err = NewRouter(store, []*Tenant{{Name: "a"}, {Name: "b"}})
+	assert.Error(t, err, "两个默认公司")
+
+	_, err = NewRouter(store, []*Tenant{{Name: "a", DepartmentIDs: []int{20}}, {Name: "b", DepartmentIDs: []int{20}}})
+	assert.Error(t, err, "部门重复")
+
+	// 都配置了部门时第一个为默认公司
+	r, err := NewRouter(store, []*Tenant{{Name: "a", DepartmentIDs: []int{10}}, {Name: "b", DepartmentIDs: []int{20}}})
+	require.NoError(t, err)
+	assert.Equal(t, "a", r.Default().Name)
+}
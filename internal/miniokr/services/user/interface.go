@@ -9,8 +9,18 @@ import (
 type Service interface {
 	GetUserByID(context.Context, string) (*v1.UserResponse, error)
 	GetUserByName(context.Context, string) (*v1.UserResponse, error)
+	// GetUserIDsByName 返回同名的全部用户 ID
+	GetUserIDsByName(context.Context, string) ([]string, error)
 	GetUserRolesByID(context.Context, string) ([]string, error)
 	GetManagedUserIDs(context.Context, string) ([]string, error)
+	GetManagedDepartmentIDs(context.Context, string) ([]int, error)
+	GetManagedDepartmentTree(context.Context, string) (*v1.TreeNode, error)
+	GetDepartmentUserIDs(context.Context, []int) ([]string, error)
 	GetUserDepartmentTree(context.Context, string) (*v1.TreeNode, error)
 	GetCompanyDepartmentTree(context.Context) (*v1.TreeNode, error)
 }
+
+// CompanyResolver 返回当前请求所属公司的名称和顶层部门，顶层部门为空表示整个组织
+type CompanyResolver interface {
+	Company(ctx context.Context) (name string, departmentIDs []int)
+}
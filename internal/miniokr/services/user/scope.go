@@ -0,0 +1,196 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package user
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/imxw/miniokr/internal/pkg/model"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+// orgIndex 是某一同步代数下的部门层级，以及已计算过的主管管理范围
+type orgIndex struct {
+	generation int64
+	parents    map[int]int
+	children   map[int][]int
+
+	mu sync.RWMutex
+	// scopes 记录主管 userID 管理的部门
+	scopes map[string][]int
+	// managed 记录主管 userID 管理的用户
+	managed map[string][]string
+}
+
+func newOrgIndex(generation int64, depts []model.Department) *orgIndex {
+	idx := &orgIndex{
+		generation: generation,
+		parents:    make(map[int]int),
+		children:   make(map[int][]int),
+		scopes:     make(map[string][]int),
+		managed:    make(map[string][]string),
+	}
+	for _, dept := range depts {
+		if dept.ParentID != nil && *dept.ParentID != dept.DepartmentID {
+			idx.parents[dept.DepartmentID] = *dept.ParentID
+			idx.children[*dept.ParentID] = append(idx.children[*dept.ParentID], dept.DepartmentID)
+		}
+	}
+	return idx
+}
+
+// expand 返回 roots 及其 depth 层以内的下级部门，depth 为 1 时只包含 roots，不大于 0 时包含整个子树
+func (idx *orgIndex) expand(roots []int, depth int) []int {
+	visited := make(map[int]bool, len(roots))
+	var result []int
+	level := roots
+	for n := 1; len(level) > 0; n++ {
+		var next []int
+		for _, id := range level {
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			result = append(result, id)
+			next = append(next, idx.children[id]...)
+		}
+		if depth > 0 && n >= depth {
+			break
+		}
+		level = next
+	}
+	return result
+}
+
+// orgIndex 返回当前同步代数的部门层级，组织架构同步后重新构建
+func (s *UserService) orgIndex(ctx context.Context) (*orgIndex, error) {
+	generation, err := s.store.GetSyncGeneration(ctx, model.SyncGenerationOrg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.scopeMu.Lock()
+	defer s.scopeMu.Unlock()
+	if s.index != nil && s.index.generation == generation {
+		return s.index, nil
+	}
+
+	depts, err := s.store.ListDepartments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.index = newOrgIndex(generation, depts)
+	return s.index, nil
+}
+
+// managedDepartments 返回主管 userID 负责的部门及 leaderScopeDepth 层以内的下级部门
+func (s *UserService) managedDepartments(ctx context.Context, userID string) (*orgIndex, []int, error) {
+	idx, err := s.orgIndex(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idx.mu.RLock()
+	scope, ok := idx.scopes[userID]
+	idx.mu.RUnlock()
+	if ok {
+		return idx, scope, nil
+	}
+
+	heads, err := s.store.GetManagedDepartments(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	scope = idx.expand(heads, s.leaderScopeDepth)
+
+	idx.mu.Lock()
+	idx.scopes[userID] = scope
+	idx.mu.Unlock()
+	return idx, scope, nil
+}
+
+// GetManagedDepartmentTree 返回主管 userID 管理范围内的部门树，与 GetManagedUserIDs 的范围一致
+func (s *UserService) GetManagedDepartmentTree(ctx context.Context, userID string) (*v1.TreeNode, error) {
+	idx, scope, err := s.managedDepartments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	inScope := make(map[int]bool, len(scope))
+	for _, id := range scope {
+		inScope[id] = true
+	}
+
+	var tree []*v1.TreeNode
+	for _, id := range scope {
+		// 上级部门也在范围内时作为其子节点展示
+		if parent, ok := idx.parents[id]; ok && inScope[parent] {
+			continue
+		}
+		dept, err := s.store.GetDepartmentByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		node, err := s.buildTreeNode(ctx, *dept, inScope)
+		if err != nil {
+			return nil, err
+		}
+		tree = append(tree, node)
+	}
+	if len(tree) == 0 {
+		return nil, fmt.Errorf("user %s does not manage any departments", userID)
+	}
+	sort.Slice(tree, func(i, j int) bool {
+		return tree[i].Sort < tree[j].Sort
+	})
+
+	if len(tree) == 1 && s.getDeptIDFromKey(tree[0].Key) == RootDeptID {
+		return tree[0], nil
+	}
+	return s.withCompanyNode(ctx, tree), nil
+}
+
+// GetManagedUserIDs 返回主管 userID 管理范围内的全部用户，结果在同一同步代数内缓存
+func (s *UserService) GetManagedUserIDs(ctx context.Context, userID string) ([]string, error) {
+	idx, scope, err := s.managedDepartments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	userIDs, ok := idx.managed[userID]
+	idx.mu.RUnlock()
+	if ok {
+		return userIDs, nil
+	}
+
+	userIDs, err = s.store.GetUserIDsByDepartmentIDs(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.Lock()
+	idx.managed[userID] = userIDs
+	idx.mu.Unlock()
+	return userIDs, nil
+}
+
+// GetManagedDepartmentIDs 返回主管 userID 管理范围内的部门
+func (s *UserService) GetManagedDepartmentIDs(ctx context.Context, userID string) ([]int, error) {
+	_, scope, err := s.managedDepartments(ctx, userID)
+	return scope, err
+}
+
+// GetDepartmentUserIDs 返回 departmentIDs 及其全部下级部门中的用户
+func (s *UserService) GetDepartmentUserIDs(ctx context.Context, departmentIDs []int) ([]string, error) {
+	idx, err := s.orgIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.GetUserIDsByDepartmentIDs(ctx, idx.expand(departmentIDs, 0))
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package user
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/imxw/miniokr/internal/miniokr/store"
+	"github.com/imxw/miniokr/internal/pkg/model"
+	v1 "github.com/imxw/miniokr/pkg/api/miniokr/v1"
+)
+
+func intPtr(i int) *int { return &i }
+
+// newScopeTestDB 创建组织架构：公司(1) > 研发(2) > 后端(3) > 存储(4)，公司(1) > 销售(5).
+// lead 是研发部门的主管
+func newScopeTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Department{}, &model.User{}, &model.UserDepartment{}, &model.SyncGeneration{}))
+
+	require.NoError(t, db.Create([]model.Department{
+		{DepartmentID: 1, Name: "公司"},
+		{DepartmentID: 2, Name: "研发", ParentID: intPtr(1)},
+		{DepartmentID: 3, Name: "后端", ParentID: intPtr(2)},
+		{DepartmentID: 4, Name: "存储", ParentID: intPtr(3)},
+		{DepartmentID: 5, Name: "销售", ParentID: intPtr(1)},
+	}).Error)
+	require.NoError(t, db.Create([]model.User{
+		{UserID: "lead", Name: "Lead"},
+		{UserID: "u3", Name: "U3"},
+		{UserID: "u4", Name: "U4"},
+		{UserID: "u5", Name: "U5"},
+	}).Error)
+	require.NoError(t, db.Create([]model.UserDepartment{
+		{UserID: "lead", DepartmentID: 2, IsLeader: model.True},
+		{UserID: "u3", DepartmentID: 3},
+		{UserID: "u4", DepartmentID: 4},
+		{UserID: "u5", DepartmentID: 5},
+	}).Error)
+	return db
+}
+
+// deptKeys 返回树中全部部门节点的 key
+func deptKeys(node *v1.TreeNode) []string {
+	var keys []string
+	if strings.HasPrefix(node.Key, "dept-") {
+		keys = append(keys, node.Key)
+	}
+	for _, child := range node.Children {
+		keys = append(keys, deptKeys(child)...)
+	}
+	return keys
+}
+
+func TestLeaderScope(t *testing.T) {
+	ctx := context.Background()
+	db := newScopeTestDB(t)
+	ds := store.NewStore(db)
+
+	tests := []struct {
+		name  string
+		depth int
+		users []string
+		depts []string
+	}{
+		{"direct departments only", 1, []string{"lead"}, []string{"dept-2"}},
+		{"two levels", 2, []string{"lead", "u3"}, []string{"dept-2", "dept-3"}},
+		{"entire subtree", 0, []string{"lead", "u3", "u4"}, []string{"dept-2", "dept-3", "dept-4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewUserService(ds.Users())
+			s.SetLeaderScopeDepth(tt.depth)
+
+			userIDs, err := s.GetManagedUserIDs(ctx, "lead")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.users, userIDs)
+
+			tree, err := s.GetManagedDepartmentTree(ctx, "lead")
+			require.NoError(t, err)
+			assert.Equal(t, "dept-2", tree.Children[0].Key)
+			assert.ElementsMatch(t, append([]string{"dept-1"}, tt.depts...), deptKeys(tree))
+		})
+	}
+
+	t.Run("rebuilt after org sync", func(t *testing.T) {
+		s := NewUserService(ds.Users())
+		userIDs, err := s.GetManagedUserIDs(ctx, "lead")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"lead", "u3", "u4"}, userIDs)
+
+		// 同步前新增的部门不会出现在缓存的结果中
+		require.NoError(t, db.Create(&model.Department{DepartmentID: 6, Name: "数据库", ParentID: intPtr(4)}).Error)
+		require.NoError(t, db.Create(&model.User{UserID: "u6", Name: "U6"}).Error)
+		require.NoError(t, db.Create(&model.UserDepartment{UserID: "u6", DepartmentID: 6}).Error)
+		userIDs, err = s.GetManagedUserIDs(ctx, "lead")
+		require.NoError(t, err)
+		assert.NotContains(t, userIDs, "u6")
+
+		require.NoError(t, ds.Sync().BumpGeneration(ctx, model.SyncGenerationOrg))
+		userIDs, err = s.GetManagedUserIDs(ctx, "lead")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"lead", "u3", "u4", "u6"}, userIDs)
+	})
+}
@@ -3,6 +3,7 @@ package user
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 
 	"github.com/imxw/miniokr/internal/pkg/model"
@@ -10,10 +11,28 @@ import (
 )
 
 const RootDeptID = 1
-const CompanyName = "托普汇智(北京)科技有限公司"
 
+// GetCompanyDepartmentTree 返回当前请求用户所属公司的组织架构树
 func (s *UserService) GetCompanyDepartmentTree(ctx context.Context) (*v1.TreeNode, error) {
-	return s.getDepartmentTree(ctx, RootDeptID)
+	_, deptIDs := s.companyOf(ctx)
+	if len(deptIDs) == 0 || slices.Contains(deptIDs, RootDeptID) {
+		return s.getDepartmentTree(ctx, RootDeptID)
+	}
+
+	// 子公司只包含自己的顶层部门
+	var tree []*v1.TreeNode
+	for _, deptID := range deptIDs {
+		node, err := s.getDepartmentTree(ctx, deptID)
+		if err != nil {
+			return nil, err
+		}
+		tree = append(tree, node)
+	}
+	sort.Slice(tree, func(i, j int) bool {
+		return tree[i].Sort < tree[j].Sort
+	})
+
+	return s.withCompanyNode(ctx, tree), nil
 }
 
 func (s *UserService) GetUserDepartmentTree(ctx context.Context, userID string) (*v1.TreeNode, error) {
@@ -50,7 +69,7 @@ func (s *UserService) GetUserDepartmentTree(ctx context.Context, userID string)
 		return leaderTree[0], nil
 	}
 
-	return s.withCompanyNode(leaderTree), nil
+	return s.withCompanyNode(ctx, leaderTree), nil
 }
 
 func (s *UserService) getDepartmentTree(ctx context.Context, deptID int) (*v1.TreeNode, error) {
@@ -63,7 +82,7 @@ func (s *UserService) getDepartmentTree(ctx context.Context, deptID int) (*v1.Tr
 		return nil, err
 	}
 
-	node, err := s.buildTreeNode(ctx, *dept)
+	node, err := s.buildTreeNode(ctx, *dept, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +104,7 @@ func (s *UserService) getCompanyTree(ctx context.Context) (*v1.TreeNode, error)
 	}
 
 	for _, dept := range depts {
-		node, err := s.buildTreeNode(ctx, dept)
+		node, err := s.buildTreeNode(ctx, dept, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -101,11 +120,12 @@ func (s *UserService) getCompanyTree(ctx context.Context) (*v1.TreeNode, error)
 
 	tree = append(tree, userNodes...)
 
-	return s.withCompanyNode(tree), nil
+	return s.withCompanyNode(ctx, tree), nil
 }
 
-func (s *UserService) buildTreeNode(ctx context.Context, dept model.Department) (*v1.TreeNode, error) {
-	children, err := s.getDepartmentChildren(ctx, dept.DepartmentID)
+// buildTreeNode 构建部门及其下级部门的树，scope 不为空时只包含其中的下级部门
+func (s *UserService) buildTreeNode(ctx context.Context, dept model.Department, scope map[int]bool) (*v1.TreeNode, error) {
+	children, err := s.getDepartmentChildren(ctx, dept.DepartmentID, scope)
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +150,7 @@ func (s *UserService) buildTreeNode(ctx context.Context, dept model.Department)
 	}, nil
 }
 
-func (s *UserService) getDepartmentChildren(ctx context.Context, deptID int) ([]*v1.TreeNode, error) {
+func (s *UserService) getDepartmentChildren(ctx context.Context, deptID int, scope map[int]bool) ([]*v1.TreeNode, error) {
 	var children []*v1.TreeNode
 	depts, err := s.store.GetDepartmentsByParentID(ctx, deptID)
 	if err != nil {
@@ -138,7 +158,10 @@ func (s *UserService) getDepartmentChildren(ctx context.Context, deptID int) ([]
 	}
 
 	for _, dept := range depts {
-		node, err := s.buildTreeNode(ctx, dept)
+		if scope != nil && !scope[dept.DepartmentID] {
+			continue
+		}
+		node, err := s.buildTreeNode(ctx, dept, scope)
 		if err != nil {
 			return nil, err
 		}
@@ -218,9 +241,28 @@ func (s *UserService) getDeptIDFromKey(key string) int {
 	return deptID
 }
 
-func (s *UserService) withCompanyNode(treeNode []*v1.TreeNode) *v1.TreeNode {
+// companyOf 返回当前请求所属公司的名称和顶层部门.
+// 未配置公司名称时使用根部门的名称，钉钉中根部门即为企业本身
+func (s *UserService) companyOf(ctx context.Context) (string, []int) {
+	var (
+		name    string
+		deptIDs []int
+	)
+	if s.company != nil {
+		name, deptIDs = s.company.Company(ctx)
+	}
+	if name == "" {
+		if root, err := s.store.GetDepartmentByID(ctx, RootDeptID); err == nil {
+			name = root.Name
+		}
+	}
+	return name, deptIDs
+}
+
+func (s *UserService) withCompanyNode(ctx context.Context, treeNode []*v1.TreeNode) *v1.TreeNode {
+	name, _ := s.companyOf(ctx)
 	return &v1.TreeNode{
-		Title:    CompanyName,
+		Title:    name,
 		Key:      fmt.Sprintf("dept-%d", RootDeptID),
 		Sort:     0,
 		Children: treeNode,
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"sync"
 
 	"github.com/imxw/miniokr/internal/miniokr/store"
 	"github.com/imxw/miniokr/internal/pkg/model"
@@ -13,13 +14,30 @@ import (
 var _ Service = (*UserService)(nil)
 
 type UserService struct {
-	store store.UserStore
+	store   store.UserStore
+	company CompanyResolver
+	// leaderScopeDepth 是主管管理范围包含的部门层数，不大于 0 时包含整个子树
+	leaderScopeDepth int
+
+	scopeMu sync.Mutex
+	index   *orgIndex
 }
 
 func NewUserService(store store.UserStore) *UserService {
 	return &UserService{store: store}
 }
 
+// SetLeaderScopeDepth 设置主管管理范围包含的部门层数：1 只包含主管直接负责的部门，
+// 2 再包含其下一级部门，依此类推，不大于 0 时包含整个子树
+func (s *UserService) SetLeaderScopeDepth(depth int) {
+	s.leaderScopeDepth = depth
+}
+
+// SetCompanyResolver 设置组织架构树根节点的来源，多公司部署时按请求用户所属公司返回
+func (s *UserService) SetCompanyResolver(r CompanyResolver) {
+	s.company = r
+}
+
 func (s *UserService) GetUserByID(ctx context.Context, id string) (*v1.UserResponse, error) {
 	return s.getUser(ctx, "id", id)
 }
@@ -28,6 +46,10 @@ func (s *UserService) GetUserByName(ctx context.Context, username string) (*v1.U
 	return s.getUser(ctx, "name", username)
 }
 
+func (s *UserService) GetUserIDsByName(ctx context.Context, name string) ([]string, error) {
+	return s.store.GetUserIDsByName(ctx, name)
+}
+
 func (s *UserService) getUser(ctx context.Context, field, value string) (*v1.UserResponse, error) {
 	var user *model.User
 	var err error
@@ -114,21 +136,6 @@ func (s *UserService) getFullDepartmentName(departments []model.Department) stri
 	return strings.Join(names, "-")
 }
 
-func (s *UserService) GetManagedUserIDs(ctx context.Context, userID string) ([]string, error) {
-
-	managedDepartments, err := s.store.GetManagedDepartments(ctx, userID)
-	if err != nil {
-		return nil, err
-	}
-
-	userIDs, err := s.store.GetUserIDsByDepartmentIDs(ctx, managedDepartments)
-	if err != nil {
-		return nil, err
-	}
-
-	return userIDs, nil
-}
-
 func (s *UserService) GetUserRolesByID(ctx context.Context, userID string) ([]string, error) {
 	return s.store.GetUserRolesByID(ctx, userID)
 }
@@ -0,0 +1,126 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// ErrCredentialExists 表示用户名或用户 ID 已被本地账号使用.
+var ErrCredentialExists = errors.New("credential already exists")
+
+// CredentialStore 保存本地账号的用户名密码，未找到账号时返回 gorm.ErrRecordNotFound
+type CredentialStore interface {
+	// CreateCredential 在同一事务中创建用户、账号，并在 roleName 不为空时授予角色
+	CreateCredential(ctx context.Context, user *model.User, cred *model.Credential, roleName string) error
+	GetCredential(ctx context.Context, username string) (*model.Credential, error)
+	GetCredentialByUserID(ctx context.Context, userID string) (*model.Credential, error)
+	UpdateCredential(ctx context.Context, username string, fields map[string]interface{}) error
+	// RecordFailedLogin 增加失败次数，达到 maxAttempts 时锁定账号到 lockedUntil 并清零失败次数
+	RecordFailedLogin(ctx context.Context, username string, maxAttempts int, lockedUntil time.Time) error
+}
+
+// CredentialStore 接口的实现.
+type credentials struct {
+	db *gorm.DB
+}
+
+// 确保 credentials 实现了 CredentialStore 接口.
+var _ CredentialStore = (*credentials)(nil)
+
+// NewCredentialStore 创建一个基于 gorm 的 CredentialStore 实例
+func NewCredentialStore(db *gorm.DB) CredentialStore {
+	return &credentials{db}
+}
+
+func (s *credentials) CreateCredential(ctx context.Context, user *model.User, cred *model.Credential, roleName string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Credential{}).
+			Where("username = ? OR user_id = ?", cred.Username, cred.UserID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrCredentialExists
+		}
+
+		if err := tx.Omit("UserDepartments").Create(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(cred).Error; err != nil {
+			return err
+		}
+		if roleName == "" {
+			return nil
+		}
+
+		var role model.Role
+		if err := tx.Where("role_name = ?", roleName).FirstOrCreate(&role, model.Role{RoleName: roleName}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&model.UserRole{UserID: user.UserID, RoleID: role.RoleID, Source: model.RoleSourceManual}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.RoleChange{
+			UserID:   user.UserID,
+			RoleName: roleName,
+			Action:   model.RoleChangeAssign,
+			Source:   model.RoleSourceManual,
+		}).Error
+	})
+}
+
+func (s *credentials) GetCredential(ctx context.Context, username string) (*model.Credential, error) {
+	var cred model.Credential
+	if err := s.db.WithContext(ctx).First(&cred, "username = ?", username).Error; err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+func (s *credentials) GetCredentialByUserID(ctx context.Context, userID string) (*model.Credential, error) {
+	var cred model.Credential
+	if err := s.db.WithContext(ctx).First(&cred, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+func (s *credentials) UpdateCredential(ctx context.Context, username string, fields map[string]interface{}) error {
+	result := s.db.WithContext(ctx).Model(&model.Credential{}).Where("username = ?", username).Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *credentials) RecordFailedLogin(ctx context.Context, username string, maxAttempts int, lockedUntil time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 用表达式自增，避免并发登录时丢失失败次数
+		result := tx.Model(&model.Credential{}).Where("username = ?", username).
+			Update("failed_attempts", gorm.Expr("failed_attempts + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Model(&model.Credential{}).
+			Where("username = ? AND failed_attempts >= ?", username, maxAttempts).
+			Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": lockedUntil}).Error
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// DelegationStore 保存主管的委托及被委托人的操作记录，未找到委托时返回 gorm.ErrRecordNotFound
+type DelegationStore interface {
+	Create(ctx context.Context, d *model.Delegation) error
+	Get(ctx context.Context, id uint) (*model.Delegation, error)
+	// List 返回 userID 授予他人或被授予的全部委托，新创建的在前
+	List(ctx context.Context, userID string) ([]model.Delegation, error)
+	// ListActive 返回 granteeID 在 now 时仍有效的委托
+	ListActive(ctx context.Context, granteeID string, now time.Time) ([]model.Delegation, error)
+	Revoke(ctx context.Context, id uint, at time.Time) error
+	CreateAction(ctx context.Context, action *model.DelegatedAction) error
+	// ListActions 返回委托下的操作记录，新发生的在前
+	ListActions(ctx context.Context, delegationID uint) ([]model.DelegatedAction, error)
+}
+
+// DelegationStore 接口的实现.
+type delegations struct {
+	db *gorm.DB
+}
+
+// 确保 delegations 实现了 DelegationStore 接口.
+var _ DelegationStore = (*delegations)(nil)
+
+// NewDelegationStore 创建一个基于 gorm 的 DelegationStore 实例
+func NewDelegationStore(db *gorm.DB) DelegationStore {
+	return &delegations{db}
+}
+
+func (s *delegations) Create(ctx context.Context, d *model.Delegation) error {
+	// 委托的部门随委托一起创建
+	return s.db.WithContext(ctx).Create(d).Error
+}
+
+func (s *delegations) Get(ctx context.Context, id uint) (*model.Delegation, error) {
+	var d model.Delegation
+	if err := s.db.WithContext(ctx).Preload("Departments").First(&d, id).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (s *delegations) List(ctx context.Context, userID string) ([]model.Delegation, error) {
+	var ds []model.Delegation
+	err := s.db.WithContext(ctx).Preload("Departments").
+		Where("grantor_id = ? OR grantee_id = ?", userID, userID).
+		Order("id DESC").
+		Find(&ds).Error
+	return ds, err
+}
+
+func (s *delegations) ListActive(ctx context.Context, granteeID string, now time.Time) ([]model.Delegation, error) {
+	var ds []model.Delegation
+	err := s.db.WithContext(ctx).Preload("Departments").
+		Where("grantee_id = ? AND revoked_at IS NULL AND expires_at > ?", granteeID, now).
+		Find(&ds).Error
+	return ds, err
+}
+
+func (s *delegations) Revoke(ctx context.Context, id uint, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&model.Delegation{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+}
+
+func (s *delegations) CreateAction(ctx context.Context, action *model.DelegatedAction) error {
+	return s.db.WithContext(ctx).Create(action).Error
+}
+
+func (s *delegations) ListActions(ctx context.Context, delegationID uint) ([]model.DelegatedAction, error) {
+	var actions []model.DelegatedAction
+	err := s.db.WithContext(ctx).
+		Where("delegation_id = ?", delegationID).
+		Order("id DESC").
+		Find(&actions).Error
+	return actions, err
+}
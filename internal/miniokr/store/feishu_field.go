@@ -0,0 +1,75 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable/field"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+var _ field.Cache = (*FieldCacheStore)(nil)
+
+// FieldCacheStore 将多维表格的字段映射缓存在数据库中，按 app token 区分，
+// 多个副本共享同一份表结构，一个副本刷新或清除后其他副本立即可见
+type FieldCacheStore struct {
+	db       *gorm.DB
+	appToken string
+}
+
+func NewFieldCacheStore(db *gorm.DB, appToken string) *FieldCacheStore {
+	return &FieldCacheStore{db: db, appToken: appToken}
+}
+
+// Load 读取表 tableID 的字段映射，记录不存在时返回 field.ErrCacheMiss
+func (s *FieldCacheStore) Load(ctx context.Context, tableID string) (field.MappingData, error) {
+	var m model.FeishuFieldMapping
+	err := s.db.WithContext(ctx).Where("app_token = ? AND table_id = ?", s.appToken, tableID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return field.MappingData{}, field.ErrCacheMiss
+	}
+	if err != nil {
+		return field.MappingData{}, err
+	}
+
+	data := field.MappingData{LastUpdate: m.LastUpdate}
+	if err := json.Unmarshal([]byte(m.Fields), &data.FieldMapping); err != nil {
+		return field.MappingData{}, fmt.Errorf("failed to unmarshal field mapping of table %s: %w", tableID, err)
+	}
+	return data, nil
+}
+
+// Save 保存表 tableID 的字段映射，已存在时覆盖
+func (s *FieldCacheStore) Save(ctx context.Context, tableID string, data field.MappingData) error {
+	fields, err := json.Marshal(data.FieldMapping)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "app_token"}, {Name: "table_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"fields", "last_update", "updated_at"}),
+	}).Create(&model.FeishuFieldMapping{
+		AppToken:   s.appToken,
+		TableID:    tableID,
+		Fields:     string(fields),
+		LastUpdate: data.LastUpdate,
+	}).Error
+}
+
+// Delete 删除表 tableID 的字段映射
+func (s *FieldCacheStore) Delete(ctx context.Context, tableID string) error {
+	return s.db.WithContext(ctx).
+		Where("app_token = ? AND table_id = ?", s.appToken, tableID).
+		Delete(&model.FeishuFieldMapping{}).Error
+}
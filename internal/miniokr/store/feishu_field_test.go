@@ -0,0 +1,58 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable/field"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+func TestFieldCacheStore(t *testing.T) {
+	db := newTokenTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.FeishuFieldMapping{}))
+	ctx := context.Background()
+
+	replicaA := NewFieldCacheStore(db, "app-a")
+	replicaB := NewFieldCacheStore(db, "app-a")
+	otherApp := NewFieldCacheStore(db, "app-b")
+
+	_, err := replicaA.Load(ctx, "tblO")
+	assert.ErrorIs(t, err, field.ErrCacheMiss)
+
+	data := field.MappingData{
+		FieldMapping: []field.Field{{FieldID: "fldDate", FieldName: "考核月份", Type: field.TypeSelect, Options: []field.Option{{ID: "opt1", Name: "2024年5月"}}}},
+		LastUpdate:   time.Now().Unix(),
+	}
+	require.NoError(t, replicaA.Save(ctx, "tblO", data))
+
+	// 其他副本读到同一份字段映射，其他应用互不影响
+	got, err := replicaB.Load(ctx, "tblO")
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	_, err = otherApp.Load(ctx, "tblO")
+	assert.ErrorIs(t, err, field.ErrCacheMiss)
+
+	// 再次保存时覆盖
+	data.FieldMapping = append(data.FieldMapping, field.Field{FieldID: "fldNew", FieldName: "新字段"})
+	data.LastUpdate++
+	require.NoError(t, replicaB.Save(ctx, "tblO", data))
+	got, err = replicaA.Load(ctx, "tblO")
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	// 一个副本清除后其他副本也会重新拉取
+	require.NoError(t, replicaA.Delete(ctx, "tblO"))
+	_, err = replicaB.Load(ctx, "tblO")
+	assert.ErrorIs(t, err, field.ErrCacheMiss)
+	require.NoError(t, replicaA.Delete(ctx, "tblO"))
+}
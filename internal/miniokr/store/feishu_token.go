@@ -0,0 +1,134 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	larkToken "github.com/imxw/miniokr/internal/pkg/bitable/token"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+var (
+	_ larkToken.Storage       = (*TokenStore)(nil)
+	_ larkToken.RefreshLocker = (*TokenStore)(nil)
+)
+
+// TokenStore 将飞书 tenant_access_token 保存在数据库中，按 app ID 区分，
+// 多个副本共享同一份令牌，通过刷新租约避免同时刷新，并通过版本号避免相互覆盖
+type TokenStore struct {
+	db    *gorm.DB
+	appID string
+}
+
+func NewTokenStore(db *gorm.DB, appID string) *TokenStore {
+	return &TokenStore{db: db, appID: appID}
+}
+
+// LoadTokenFromFile 读取令牌，path 参数仅为兼容 Storage 接口，不会被使用.
+// 记录不存在时返回 os.ErrNotExist
+func (s *TokenStore) LoadTokenFromFile(_ string) (larkToken.Data, error) {
+	var t model.FeishuToken
+	err := s.db.Where("app_id = ?", s.appID).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return larkToken.Data{}, fmt.Errorf("token of app %s: %w", s.appID, os.ErrNotExist)
+	}
+	if err != nil {
+		return larkToken.Data{}, err
+	}
+
+	return larkToken.Data{
+		TenantAccessToken: t.TenantAccessToken,
+		Expire:            t.Expire,
+		SavedAt:           t.SavedAt,
+		Version:           t.Version,
+	}, nil
+}
+
+// SaveTokenToFile 保存令牌并释放刷新租约，只有 data.Version 与库中版本一致时才会写入，
+// 否则返回 larkToken.ErrVersionConflict
+func (s *TokenStore) SaveTokenToFile(data larkToken.Data, _ string) error {
+	result := s.db.Model(&model.FeishuToken{}).
+		Where("app_id = ? AND version = ?", s.appID, data.Version).
+		Updates(map[string]interface{}{
+			"tenant_access_token": data.TenantAccessToken,
+			"expire":              data.Expire,
+			"saved_at":            data.SavedAt,
+			"version":             gorm.Expr("version + 1"),
+			"refreshing_by":       "",
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	if data.Version != 0 {
+		return larkToken.ErrVersionConflict
+	}
+
+	// 首次保存，记录已存在说明其他副本抢先写入了
+	result = s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.FeishuToken{
+		AppID:             s.appID,
+		TenantAccessToken: data.TenantAccessToken,
+		Expire:            data.Expire,
+		SavedAt:           data.SavedAt,
+		Version:           1,
+		RefreshUntil:      data.SavedAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return larkToken.ErrVersionConflict
+	}
+	return nil
+}
+
+// ClaimRefresh 领取刷新租约，租约由其他副本持有且未过期时返回 larkToken.ErrRefreshInProgress.
+// 记录不存在时插入版本号为 0 的空令牌并领取租约
+func (s *TokenStore) ClaimRefresh(owner string, ttl time.Duration) error {
+	now := time.Now()
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.FeishuToken{
+		AppID:        s.appID,
+		SavedAt:      now,
+		RefreshingBy: owner,
+		RefreshUntil: now.Add(ttl),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	result = s.db.Model(&model.FeishuToken{}).
+		Where("app_id = ? AND (refreshing_by = '' OR refreshing_by = ? OR refresh_until < ?)", s.appID, owner, now).
+		Updates(map[string]interface{}{
+			"refreshing_by": owner,
+			"refresh_until": now.Add(ttl),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return larkToken.ErrRefreshInProgress
+	}
+	return nil
+}
+
+// ReleaseRefresh 释放 owner 持有的刷新租约
+func (s *TokenStore) ReleaseRefresh(owner string) error {
+	return s.db.Model(&model.FeishuToken{}).
+		Where("app_id = ? AND refreshing_by = ?", s.appID, owner).
+		Update("refreshing_by", "").Error
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	larkToken "github.com/imxw/miniokr/internal/pkg/bitable/token"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+func newTokenTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.FeishuToken{}))
+	return db
+}
+
+func TestTokenStore_OptimisticLock(t *testing.T) {
+	db := newTokenTestDB(t)
+	replicaA := NewTokenStore(db, "cli_a")
+	replicaB := NewTokenStore(db, "cli_a")
+
+	_, err := replicaA.LoadTokenFromFile("")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// 两个副本同时首次保存，只有一个成功
+	now := time.Now().Truncate(time.Second)
+	require.NoError(t, replicaA.SaveTokenToFile(larkToken.Data{TenantAccessToken: "t-a", Expire: 7200, SavedAt: now}, ""))
+	assert.ErrorIs(t, replicaB.SaveTokenToFile(larkToken.Data{TenantAccessToken: "t-b", Expire: 7200, SavedAt: now}, ""), larkToken.ErrVersionConflict)
+
+	data, err := replicaB.LoadTokenFromFile("")
+	require.NoError(t, err)
+	assert.Equal(t, "t-a", data.TenantAccessToken)
+	assert.Equal(t, 1, data.Version)
+
+	// 基于同一版本的两次更新，后到的被拒绝
+	require.NoError(t, replicaB.SaveTokenToFile(larkToken.Data{TenantAccessToken: "t-b", Expire: 7200, SavedAt: now, Version: 1}, ""))
+	assert.ErrorIs(t, replicaA.SaveTokenToFile(larkToken.Data{TenantAccessToken: "t-a2", Expire: 7200, SavedAt: now, Version: 1}, ""), larkToken.ErrVersionConflict)
+
+	data, err = replicaA.LoadTokenFromFile("")
+	require.NoError(t, err)
+	assert.Equal(t, "t-b", data.TenantAccessToken)
+	assert.Equal(t, 2, data.Version)
+	assert.True(t, now.Equal(data.SavedAt))
+}
+
+func TestTokenStore_KeyedByAppID(t *testing.T) {
+	db := newTokenTestDB(t)
+	require.NoError(t, NewTokenStore(db, "cli_a").SaveTokenToFile(larkToken.Data{TenantAccessToken: "t-a", Expire: 7200, SavedAt: time.Now()}, ""))
+
+	_, err := NewTokenStore(db, "cli_b").LoadTokenFromFile("")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestTokenStore_RefreshLease(t *testing.T) {
+	db := newTokenTestDB(t)
+	replicaA := NewTokenStore(db, "cli_a")
+	replicaB := NewTokenStore(db, "cli_a")
+
+	// 首次领取租约时插入版本号为 0 的空令牌
+	require.NoError(t, replicaA.ClaimRefresh("a", time.Minute))
+	assert.ErrorIs(t, replicaB.ClaimRefresh("b", time.Minute), larkToken.ErrRefreshInProgress)
+	data, err := replicaB.LoadTokenFromFile("")
+	require.NoError(t, err)
+	assert.Empty(t, data.TenantAccessToken)
+	assert.Equal(t, 0, data.Version)
+
+	// 保存新令牌时释放租约
+	require.NoError(t, replicaA.SaveTokenToFile(larkToken.Data{TenantAccessToken: "t-a", Expire: 7200, SavedAt: time.Now()}, ""))
+	require.NoError(t, replicaB.ClaimRefresh("b", time.Minute))
+	assert.ErrorIs(t, replicaA.ClaimRefresh("a", time.Minute), larkToken.ErrRefreshInProgress)
+
+	// 刷新失败时释放租约，只能释放自己持有的租约
+	require.NoError(t, replicaA.ReleaseRefresh("a"))
+	assert.ErrorIs(t, replicaA.ClaimRefresh("a", time.Minute), larkToken.ErrRefreshInProgress)
+	require.NoError(t, replicaB.ReleaseRefresh("b"))
+	require.NoError(t, replicaA.ClaimRefresh("a", -time.Second))
+
+	// 持有租约的副本退出后，租约到期即可被其他副本领取
+	require.NoError(t, replicaB.ClaimRefresh("b", time.Minute))
+}
+
+// slowTokenService 模拟耗时的飞书令牌接口，并记录调用次数
+type slowTokenService struct {
+	calls atomic.Int32
+}
+
+func (s *slowTokenService) GetNewToken(ctx context.Context, appID string, appSecret string) (*larkToken.Response, error) {
+	n := s.calls.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return &larkToken.Response{Data: larkToken.Data{TenantAccessToken: fmt.Sprintf("t-%d", n), Expire: 7200}}, nil
+}
+
+func TestTokenStore_ConcurrentRefresh(t *testing.T) {
+	db := newTokenTestDB(t)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// 内存数据库的每个连接都是独立的库
+	sqlDB.SetMaxOpenConns(1)
+
+	service := &slowTokenService{}
+	replicas := make([]*larkToken.Manager, 3)
+	for i := range replicas {
+		replicas[i] = larkToken.NewManager(service, NewTokenStore(db, "cli_a"), larkToken.NewRealClock(), "cli_a", "secret")
+		replicas[i].RefreshWaitInterval = 5 * time.Millisecond
+	}
+
+	// 多个副本同时发现令牌过期，只有领取到租约的副本向飞书获取新令牌
+	tokens := make([]string, len(replicas))
+	var wg sync.WaitGroup
+	for i, m := range replicas {
+		wg.Add(1)
+		go func(i int, m *larkToken.Manager) {
+			defer wg.Done()
+			token, err := m.EnsureValidToken(context.Background())
+			assert.NoError(t, err)
+			tokens[i] = token
+		}(i, m)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), service.calls.Load())
+	for _, token := range tokens {
+		assert.Equal(t, "t-1", token)
+	}
+}
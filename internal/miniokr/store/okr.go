@@ -0,0 +1,191 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// ErrRecordNotFound 表示目标或关键结果记录不存在.
+var ErrRecordNotFound = errors.New("record not found")
+
+type OkrStore interface {
+	ListAllObjectives(ctx context.Context) ([]model.ObjectiveRecord, error)
+	ListAllKeyResults(ctx context.Context) ([]model.KeyResultRecord, error)
+	// ListObjectivesByOwner 和 ListKeyResultsByOwner 在 months 非空时只返回这些考核月份的记录
+	ListObjectivesByOwner(ctx context.Context, owner string, months []string) ([]model.ObjectiveRecord, error)
+	ListKeyResultsByOwner(ctx context.Context, owner string, months []string) ([]model.KeyResultRecord, error)
+	ListKeyResultIDs(ctx context.Context, objectiveIDs []string) (map[string][]string, error)
+	GetObjective(ctx context.Context, id string) (*model.ObjectiveRecord, error)
+	GetKeyResult(ctx context.Context, id string) (*model.KeyResultRecord, error)
+	CreateObjective(ctx context.Context, objective *model.ObjectiveRecord) error
+	UpdateObjective(ctx context.Context, objective *model.ObjectiveRecord) error
+	DeleteObjective(ctx context.Context, id string, krIDs []string) error
+	CreateKeyResult(ctx context.Context, kr *model.KeyResultRecord) error
+	UpdateKeyResult(ctx context.Context, kr *model.KeyResultRecord) error
+	DeleteKeyResult(ctx context.Context, id string) error
+}
+
+// OkrStore 接口的实现.
+type okrs struct {
+	db *gorm.DB
+}
+
+// 确保 okrs 实现了 OkrStore 接口.
+var _ OkrStore = (*okrs)(nil)
+
+func newOkrs(db *gorm.DB) *okrs {
+	return &okrs{db}
+}
+
+// NewOkrStore 创建一个基于 gorm 的 OkrStore 实例
+func NewOkrStore(db *gorm.DB) OkrStore {
+	return newOkrs(db)
+}
+
+// newRecordID 生成与飞书多维表格 record_id 风格一致的记录ID
+func newRecordID() string {
+	return "rec" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+func (s *okrs) ListAllObjectives(ctx context.Context) ([]model.ObjectiveRecord, error) {
+	var objectives []model.ObjectiveRecord
+	if err := s.db.WithContext(ctx).Find(&objectives).Error; err != nil {
+		return nil, err
+	}
+	return objectives, nil
+}
+
+func (s *okrs) ListAllKeyResults(ctx context.Context) ([]model.KeyResultRecord, error) {
+	var krs []model.KeyResultRecord
+	if err := s.db.WithContext(ctx).Find(&krs).Error; err != nil {
+		return nil, err
+	}
+	return krs, nil
+}
+
+func (s *okrs) ListObjectivesByOwner(ctx context.Context, owner string, months []string) ([]model.ObjectiveRecord, error) {
+	var objectives []model.ObjectiveRecord
+	if err := s.ownerAndMonths(ctx, owner, months).Find(&objectives).Error; err != nil {
+		return nil, err
+	}
+	return objectives, nil
+}
+
+func (s *okrs) ListKeyResultsByOwner(ctx context.Context, owner string, months []string) ([]model.KeyResultRecord, error) {
+	var krs []model.KeyResultRecord
+	if err := s.ownerAndMonths(ctx, owner, months).Find(&krs).Error; err != nil {
+		return nil, err
+	}
+	return krs, nil
+}
+
+func (s *okrs) ownerAndMonths(ctx context.Context, owner string, months []string) *gorm.DB {
+	tx := s.db.WithContext(ctx).Where("owner = ?", owner)
+	if len(months) > 0 {
+		tx = tx.Where("date IN (?)", months)
+	}
+	return tx
+}
+
+// ListKeyResultIDs 返回每个目标下关联的关键结果ID
+func (s *okrs) ListKeyResultIDs(ctx context.Context, objectiveIDs []string) (map[string][]string, error) {
+	result := make(map[string][]string, len(objectiveIDs))
+	if len(objectiveIDs) == 0 {
+		return result, nil
+	}
+
+	var krs []model.KeyResultRecord
+	if err := s.db.WithContext(ctx).Select("record_id", "objective_id").
+		Where("objective_id IN (?)", objectiveIDs).Order("created_time").Find(&krs).Error; err != nil {
+		return nil, err
+	}
+	for _, kr := range krs {
+		result[kr.ObjectiveID] = append(result[kr.ObjectiveID], kr.RecordID)
+	}
+	return result, nil
+}
+
+func (s *okrs) GetObjective(ctx context.Context, id string) (*model.ObjectiveRecord, error) {
+	var objective model.ObjectiveRecord
+	if err := s.db.WithContext(ctx).First(&objective, "record_id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &objective, nil
+}
+
+func (s *okrs) GetKeyResult(ctx context.Context, id string) (*model.KeyResultRecord, error) {
+	var kr model.KeyResultRecord
+	if err := s.db.WithContext(ctx).First(&kr, "record_id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &kr, nil
+}
+
+func (s *okrs) CreateObjective(ctx context.Context, objective *model.ObjectiveRecord) error {
+	if objective.RecordID == "" {
+		objective.RecordID = newRecordID()
+	}
+	return s.db.WithContext(ctx).Create(objective).Error
+}
+
+func (s *okrs) UpdateObjective(ctx context.Context, objective *model.ObjectiveRecord) error {
+	return s.db.WithContext(ctx).Save(objective).Error
+}
+
+// DeleteObjective 在同一事务中删除目标及其关键结果
+func (s *okrs) DeleteObjective(ctx context.Context, id string, krIDs []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.ObjectiveRecord{}, "record_id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		if len(krIDs) > 0 {
+			if err := tx.Delete(&model.KeyResultRecord{}, "record_id IN (?)", krIDs).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *okrs) CreateKeyResult(ctx context.Context, kr *model.KeyResultRecord) error {
+	if kr.RecordID == "" {
+		kr.RecordID = newRecordID()
+	}
+	return s.db.WithContext(ctx).Create(kr).Error
+}
+
+func (s *okrs) UpdateKeyResult(ctx context.Context, kr *model.KeyResultRecord) error {
+	return s.db.WithContext(ctx).Save(kr).Error
+}
+
+func (s *okrs) DeleteKeyResult(ctx context.Context, id string) error {
+	result := s.db.WithContext(ctx).Delete(&model.KeyResultRecord{}, "record_id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+type OkrSyncStorer interface {
+	ListCursors(ctx context.Context, kind string) ([]model.OkrSyncCursor, error)
+	SaveCursor(ctx context.Context, cursor *model.OkrSyncCursor) error
+	DeleteCursor(ctx context.Context, id uint) error
+	// AcquireLease 领取名为 name 的租约，租约由其他持有者持有且未过期时返回 false
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// ReleaseLease 释放 holder 持有的租约
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+var _ OkrSyncStorer = (*OkrSyncStore)(nil)
+
+type OkrSyncStore struct {
+	db *gorm.DB
+}
+
+func NewOkrSyncStore(db *gorm.DB) *OkrSyncStore {
+	return &OkrSyncStore{db: db}
+}
+
+// ListCursors 获取指定类型(目标/关键结果)的全部同步游标
+func (s *OkrSyncStore) ListCursors(ctx context.Context, kind string) ([]model.OkrSyncCursor, error) {
+	var cursors []model.OkrSyncCursor
+	if err := s.db.WithContext(ctx).Where("kind = ?", kind).Find(&cursors).Error; err != nil {
+		return nil, err
+	}
+	return cursors, nil
+}
+
+// SaveCursor 新增或更新同步游标
+func (s *OkrSyncStore) SaveCursor(ctx context.Context, cursor *model.OkrSyncCursor) error {
+	return s.db.WithContext(ctx).Save(cursor).Error
+}
+
+func (s *OkrSyncStore) DeleteCursor(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Delete(&model.OkrSyncCursor{}, id).Error
+}
+
+func (s *OkrSyncStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.OkrSyncLease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	result = s.db.WithContext(ctx).Model(&model.OkrSyncLease{}).
+		Where("name = ? AND (holder = '' OR expires_at < ?)", name, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": now.Add(ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *OkrSyncStore) ReleaseLease(ctx context.Context, name, holder string) error {
+	return s.db.WithContext(ctx).Model(&model.OkrSyncLease{}).
+		Where("name = ? AND holder = ?", name, holder).
+		Update("holder", "").Error
+}
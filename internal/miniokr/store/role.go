@@ -0,0 +1,238 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+var (
+	// ErrRoleExists 表示角色已经存在.
+	ErrRoleExists = errors.New("role already exists")
+	// ErrUserRoleExists 表示用户已被手动授予该角色.
+	ErrUserRoleExists = errors.New("user already has the role")
+	// ErrUserRoleNotFound 表示用户没有该角色.
+	ErrUserRoleNotFound = errors.New("user does not have the role")
+	// ErrUserRoleSynced 表示用户的角色由组织架构同步授予，不能手动撤销.
+	ErrUserRoleSynced = errors.New("user role is managed by sync")
+	// ErrLastAdmin 表示撤销后将没有任何管理员.
+	ErrLastAdmin = errors.New("cannot remove the last admin")
+)
+
+// UserRoleRecord 是用户拥有的一个角色及其来源
+type UserRoleRecord struct {
+	RoleName string
+	Source   string
+}
+
+// RoleStore 管理角色和用户角色，每次授予或撤销都在同一事务中写入 RoleChange.
+// 角色不存在时返回 gorm.ErrRecordNotFound
+type RoleStore interface {
+	ListRoles(ctx context.Context) ([]model.Role, error)
+	CreateRole(ctx context.Context, name string) error
+	ListUserRoles(ctx context.Context, userID string) ([]UserRoleRecord, error)
+	// AssignUserRole 手动授予角色，用户已通过同步拥有该角色时改为手动授予，之后同步不再撤销
+	AssignUserRole(ctx context.Context, userID, roleName, operatorID string) error
+	// RemoveUserRole 撤销手动授予的角色，同步授予的 leader 角色返回 ErrUserRoleSynced
+	RemoveUserRole(ctx context.Context, userID, roleName, operatorID string) error
+	// ListRoleChanges 返回用户的角色变更记录，新发生的在前
+	ListRoleChanges(ctx context.Context, userID string) ([]model.RoleChange, error)
+}
+
+// RoleStore 接口的实现.
+type roles struct {
+	db *gorm.DB
+}
+
+// 确保 roles 实现了 RoleStore 接口.
+var _ RoleStore = (*roles)(nil)
+
+// NewRoleStore 创建一个基于 gorm 的 RoleStore 实例
+func NewRoleStore(db *gorm.DB) RoleStore {
+	return &roles{db}
+}
+
+func (s *roles) ListRoles(ctx context.Context) ([]model.Role, error) {
+	var rs []model.Role
+	err := s.db.WithContext(ctx).Order("role_id").Find(&rs).Error
+	return rs, err
+}
+
+func (s *roles) CreateRole(ctx context.Context, name string) error {
+	result := s.db.WithContext(ctx).Where("role_name = ?", name).FirstOrCreate(&model.Role{RoleName: name})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRoleExists
+	}
+	return nil
+}
+
+func (s *roles) ListUserRoles(ctx context.Context, userID string) ([]UserRoleRecord, error) {
+	var records []UserRoleRecord
+	err := s.db.WithContext(ctx).Table("user_roles").
+		Select("roles.role_name, user_roles.source").
+		Joins("JOIN roles ON roles.role_id = user_roles.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.role_id").
+		Scan(&records).Error
+	return records, err
+}
+
+func (s *roles) AssignUserRole(ctx context.Context, userID, roleName, operatorID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var role model.Role
+		if err := tx.Where("role_name = ?", roleName).First(&role).Error; err != nil {
+			return err
+		}
+
+		var ur model.UserRole
+		err := tx.Where("user_id = ? AND role_id = ?", userID, role.RoleID).First(&ur).Error
+		switch {
+		case err == nil && ur.Source == model.RoleSourceManual:
+			return ErrUserRoleExists
+		case err == nil:
+			err = tx.Model(&model.UserRole{}).
+				Where("user_id = ? AND role_id = ?", userID, role.RoleID).
+				Update("source", model.RoleSourceManual).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err = tx.Create(&model.UserRole{UserID: userID, RoleID: role.RoleID, Source: model.RoleSourceManual}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&model.RoleChange{
+			UserID:     userID,
+			RoleName:   roleName,
+			Action:     model.RoleChangeAssign,
+			Source:     model.RoleSourceManual,
+			OperatorID: operatorID,
+		}).Error
+	})
+}
+
+func (s *roles) RemoveUserRole(ctx context.Context, userID, roleName, operatorID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var role model.Role
+		if err := tx.Where("role_name = ?", roleName).First(&role).Error; err != nil {
+			return err
+		}
+
+		var ur model.UserRole
+		err := tx.Where("user_id = ? AND role_id = ?", userID, role.RoleID).First(&ur).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserRoleNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// 同步授予的 leader 角色跟随组织架构，手动撤销会在下次同步时恢复
+		if roleName == known.LeaderRoleName && ur.Source != model.RoleSourceManual {
+			return ErrUserRoleSynced
+		}
+		if roleName == known.AdminRoleName {
+			var admins int64
+			if err := tx.Model(&model.UserRole{}).Where("role_id = ?", role.RoleID).
+				Distinct("user_id").Count(&admins).Error; err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		if err := tx.Where("user_id = ? AND role_id = ?", userID, role.RoleID).Delete(&model.UserRole{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.RoleChange{
+			UserID:     userID,
+			RoleName:   roleName,
+			Action:     model.RoleChangeRemove,
+			Source:     model.RoleSourceManual,
+			OperatorID: operatorID,
+		}).Error
+	})
+}
+
+func (s *roles) ListRoleChanges(ctx context.Context, userID string) ([]model.RoleChange, error) {
+	var changes []model.RoleChange
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Find(&changes).Error
+	return changes, err
+}
+
+// syncLeaderRoles 根据部门主管标记同步 leader 角色：为新主管授予角色，撤销不再是主管的用户
+// 通过同步获得的角色. 手动授予的角色不受影响，已有角色的用户不会重复授予
+func syncLeaderRoles(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var role model.Role
+		if err := tx.Where("role_name = ?", known.LeaderRoleName).
+			FirstOrCreate(&role, model.Role{RoleName: known.LeaderRoleName}).Error; err != nil {
+			return err
+		}
+
+		var leaders []string
+		if err := tx.Model(&model.UserDepartment{}).Where("is_leader = ?", model.True).
+			Distinct().Pluck("user_id", &leaders).Error; err != nil {
+			return err
+		}
+		var existing []model.UserRole
+		if err := tx.Where("role_id = ?", role.RoleID).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		isLeader := make(map[string]bool, len(leaders))
+		for _, id := range leaders {
+			isLeader[id] = true
+		}
+		assigned := make(map[string]bool, len(existing))
+		manual := make(map[string]bool)
+		for _, ur := range existing {
+			assigned[ur.UserID] = true
+			if ur.Source == model.RoleSourceManual {
+				manual[ur.UserID] = true
+			}
+		}
+
+		var changes []model.RoleChange
+		removed := make(map[string]bool)
+		for _, ur := range existing {
+			if manual[ur.UserID] || isLeader[ur.UserID] || removed[ur.UserID] {
+				continue
+			}
+			removed[ur.UserID] = true
+			if err := tx.Where("user_id = ? AND role_id = ? AND source = ?", ur.UserID, role.RoleID, model.RoleSourceSync).
+				Delete(&model.UserRole{}).Error; err != nil {
+				return err
+			}
+			changes = append(changes, model.RoleChange{UserID: ur.UserID, RoleName: role.RoleName, Action: model.RoleChangeRemove, Source: model.RoleSourceSync})
+		}
+		for _, id := range leaders {
+			if assigned[id] {
+				continue
+			}
+			if err := tx.Create(&model.UserRole{UserID: id, RoleID: role.RoleID, Source: model.RoleSourceSync}).Error; err != nil {
+				return err
+			}
+			changes = append(changes, model.RoleChange{UserID: id, RoleName: role.RoleName, Action: model.RoleChangeAssign, Source: model.RoleSourceSync})
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+		return tx.Create(&changes).Error
+	})
+}
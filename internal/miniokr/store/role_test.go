@@ -0,0 +1,103 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+func newRoleTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	ds := &datastore{db}
+	require.NoError(t, ds.AutoMigrate())
+	require.NoError(t, ds.InitRoles())
+	return db
+}
+
+// userRoles 返回用户拥有的角色及来源
+func userRoles(t *testing.T, s RoleStore, userID string) []UserRoleRecord {
+	records, err := s.ListUserRoles(context.Background(), userID)
+	require.NoError(t, err)
+	return records
+}
+
+func TestSyncLeaderRoles(t *testing.T) {
+	ctx := context.Background()
+	db := newRoleTestDB(t)
+	s := NewRoleStore(db)
+
+	require.NoError(t, db.Create([]model.UserDepartment{
+		{UserID: "lead", DepartmentID: 2, IsLeader: model.True},
+		{UserID: "lead", DepartmentID: 3, IsLeader: model.True},
+		{UserID: "former", DepartmentID: 2},
+		{UserID: "acting", DepartmentID: 2},
+	}).Error)
+	require.NoError(t, NewSyncStore(db).SyncLeaderRoles(ctx))
+	assert.Equal(t, []UserRoleRecord{{RoleName: "leader", Source: model.RoleSourceSync}}, userRoles(t, s, "lead"))
+
+	// former 的 leader 角色由同步授予，acting 的由管理员手动授予
+	require.NoError(t, db.Create(&model.UserRole{UserID: "former", RoleID: 2, Source: model.RoleSourceSync}).Error)
+	require.NoError(t, s.AssignUserRole(ctx, "acting", "leader", "admin"))
+
+	// 再次同步不会重复授予，也不会撤销手动授予的角色
+	require.NoError(t, NewSyncStore(db).SyncLeaderRoles(ctx))
+	assert.Len(t, userRoles(t, s, "lead"), 1)
+	assert.Empty(t, userRoles(t, s, "former"))
+	assert.Equal(t, []UserRoleRecord{{RoleName: "leader", Source: model.RoleSourceManual}}, userRoles(t, s, "acting"))
+
+	changes, err := s.ListRoleChanges(ctx, "former")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, model.RoleChangeRemove, changes[0].Action)
+	assert.Equal(t, model.RoleSourceSync, changes[0].Source)
+	assert.Empty(t, changes[0].OperatorID)
+}
+
+func TestAssignAndRemoveUserRole(t *testing.T) {
+	ctx := context.Background()
+	db := newRoleTestDB(t)
+	s := NewRoleStore(db)
+
+	require.NoError(t, s.AssignUserRole(ctx, "u1", "admin", "root"))
+	assert.ErrorIs(t, s.AssignUserRole(ctx, "u1", "admin", "root"), ErrUserRoleExists)
+	assert.ErrorIs(t, s.AssignUserRole(ctx, "u1", "unknown", "root"), gorm.ErrRecordNotFound)
+
+	// 不能撤销最后一个管理员
+	assert.ErrorIs(t, s.RemoveUserRole(ctx, "u1", "admin", "root"), ErrLastAdmin)
+	require.NoError(t, s.AssignUserRole(ctx, "u2", "admin", "u1"))
+	require.NoError(t, s.RemoveUserRole(ctx, "u1", "admin", "u2"))
+	assert.ErrorIs(t, s.RemoveUserRole(ctx, "u1", "admin", "u2"), ErrUserRoleNotFound)
+
+	changes, err := s.ListRoleChanges(ctx, "u1")
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, model.RoleChangeRemove, changes[0].Action)
+	assert.Equal(t, "u2", changes[0].OperatorID)
+	assert.Equal(t, model.RoleChangeAssign, changes[1].Action)
+	assert.Equal(t, "root", changes[1].OperatorID)
+
+	// 同步授予的 leader 角色不能手动撤销，手动授予后同步不再管理
+	require.NoError(t, db.Create(&model.UserRole{UserID: "lead", RoleID: 2, Source: model.RoleSourceSync}).Error)
+	assert.ErrorIs(t, s.RemoveUserRole(ctx, "lead", "leader", "u2"), ErrUserRoleSynced)
+	require.NoError(t, s.AssignUserRole(ctx, "lead", "leader", "u2"))
+	assert.Equal(t, []UserRoleRecord{{RoleName: "leader", Source: model.RoleSourceManual}}, userRoles(t, s, "lead"))
+	require.NoError(t, s.RemoveUserRole(ctx, "lead", "leader", "u2"))
+
+	require.NoError(t, s.CreateRole(ctx, "hr"))
+	assert.ErrorIs(t, s.CreateRole(ctx, "hr"), ErrRoleExists)
+}
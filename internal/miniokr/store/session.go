@@ -0,0 +1,137 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// ErrRefreshTokenUsed 表示刷新令牌已被轮换或吊销.
+var ErrRefreshTokenUsed = errors.New("refresh token already used")
+
+// SessionStore 保存刷新令牌和访问令牌的吊销记录，未找到刷新令牌时返回 gorm.ErrRecordNotFound
+type SessionStore interface {
+	CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	// RotateRefreshToken 吊销 oldHash 并创建 next，oldHash 已被吊销时返回 ErrRefreshTokenUsed
+	RotateRefreshToken(ctx context.Context, oldHash string, next *model.RefreshToken) error
+	RevokeSession(ctx context.Context, sessionID string) error
+	// RevokeUserSessions 吊销用户的全部刷新令牌，并使 at 及之前签发的访问令牌失效
+	RevokeUserSessions(ctx context.Context, userID string, at time.Time) error
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	// IsTokenRevoked 判断访问令牌是否被单独吊销，或签发时间不晚于用户全部会话被吊销的时间
+	IsTokenRevoked(ctx context.Context, jti string, userID string, issuedAt time.Time) (bool, error)
+	// DeleteExpired 删除 before 之前过期的刷新令牌和吊销记录
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+// SessionStore 接口的实现.
+type sessions struct {
+	db *gorm.DB
+}
+
+// 确保 sessions 实现了 SessionStore 接口.
+var _ SessionStore = (*sessions)(nil)
+
+// NewSessionStore 创建一个基于 gorm 的 SessionStore 实例
+func NewSessionStore(db *gorm.DB) SessionStore {
+	return &sessions{db}
+}
+
+func (s *sessions) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	return s.db.WithContext(ctx).Create(token).Error
+}
+
+func (s *sessions) GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := s.db.WithContext(ctx).First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *sessions) RotateRefreshToken(ctx context.Context, oldHash string, next *model.RefreshToken) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发刷新时只有一个请求能轮换成功
+		result := tx.Model(&model.RefreshToken{}).
+			Where("token_hash = ? AND revoked_at IS NULL", oldHash).
+			Update("revoked_at", next.CreatedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenUsed
+		}
+		return tx.Create(next).Error
+	})
+}
+
+func (s *sessions) RevokeSession(ctx context.Context, sessionID string) error {
+	return s.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (s *sessions) RevokeUserSessions(ctx context.Context, userID string, at time.Time) error {
+	// 访问令牌的 iat 精确到毫秒，吊销时间按相同精度保存，不会被数据库向上取整
+	at = at.Truncate(time.Millisecond)
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", at).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"revoked_at"}),
+		}).Create(&model.UserTokenRevocation{UserID: userID, RevokedAt: at}).Error
+	})
+}
+
+func (s *sessions) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func (s *sessions) IsTokenRevoked(ctx context.Context, jti string, userID string, issuedAt time.Time) (bool, error) {
+	db := s.db.WithContext(ctx)
+
+	if jti != "" {
+		var count int64
+		if err := db.Model(&model.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+
+	var revocation model.UserTokenRevocation
+	err := db.First(&revocation, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// 与吊销时间在同一毫秒内签发的令牌也视为已吊销
+	return !issuedAt.After(revocation.RevokedAt), nil
+}
+
+func (s *sessions) DeleteExpired(ctx context.Context, before time.Time) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Where("expires_at < ?", before).Delete(&model.RefreshToken{}).Error; err != nil {
+		return err
+	}
+	return db.Where("expires_at < ?", before).Delete(&model.RevokedToken{}).Error
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"sync"
 
 	"gorm.io/gorm"
@@ -19,6 +20,12 @@ type IStore interface {
 	DB() *gorm.DB
 	Users() UserStore
 	Sync() SyncStorer
+	Okrs() OkrStore
+	OkrSync() OkrSyncStorer
+	Credentials() CredentialStore
+	Sessions() SessionStore
+	Delegations() DelegationStore
+	Roles() RoleStore
 	AutoMigrate() error
 	InitRoles() error
 	InitUserRoles() error
@@ -58,6 +65,35 @@ func (ds *datastore) Sync() SyncStorer {
 	return NewSyncStore(ds.db)
 }
 
+// Okrs 返回一个实现了 OkrStore 接口的实例.
+func (ds *datastore) Okrs() OkrStore {
+	return newOkrs(ds.db)
+}
+
+func (ds *datastore) OkrSync() OkrSyncStorer {
+	return NewOkrSyncStore(ds.db)
+}
+
+// Credentials 返回一个实现了 CredentialStore 接口的实例.
+func (ds *datastore) Credentials() CredentialStore {
+	return NewCredentialStore(ds.db)
+}
+
+// Sessions 返回一个实现了 SessionStore 接口的实例.
+func (ds *datastore) Sessions() SessionStore {
+	return NewSessionStore(ds.db)
+}
+
+// Delegations 返回一个实现了 DelegationStore 接口的实例.
+func (ds *datastore) Delegations() DelegationStore {
+	return NewDelegationStore(ds.db)
+}
+
+// Roles 返回一个实现了 RoleStore 接口的实例.
+func (ds *datastore) Roles() RoleStore {
+	return NewRoleStore(ds.db)
+}
+
 // AutoMigrate 进行自动迁移
 func (ds *datastore) AutoMigrate() error {
 	// 按照依赖顺序迁移表
@@ -78,7 +114,49 @@ func (ds *datastore) AutoMigrate() error {
 		return err
 	}
 
-	if err := ds.db.AutoMigrate(&model.UserRole{}); err != nil {
+	if err := ds.db.AutoMigrate(&model.UserRole{}, &model.RoleChange{}); err != nil {
+		return err
+	}
+	// 只有 leader 角色由同步授予，此前授予的其他角色都是手动授予的
+	if err := ds.db.Model(&model.UserRole{}).
+		Where("source = ? AND role_id NOT IN (?)", model.RoleSourceSync, ds.db.Model(&model.Role{}).Select("role_id").Where("role_name = ?", "leader")).
+		Update("source", model.RoleSourceManual).Error; err != nil {
+		return err
+	}
+
+	if err := ds.db.AutoMigrate(&model.ObjectiveRecord{}); err != nil {
+		return err
+	}
+
+	if err := ds.db.AutoMigrate(&model.KeyResultRecord{}); err != nil {
+		return err
+	}
+
+	if err := ds.db.AutoMigrate(&model.OkrSyncCursor{}, &model.OkrSyncLease{}); err != nil {
+		return err
+	}
+
+	if err := ds.db.AutoMigrate(&model.FeishuToken{}); err != nil {
+		return err
+	}
+
+	if err := ds.db.AutoMigrate(&model.FeishuFieldMapping{}); err != nil {
+		return err
+	}
+
+	if err := ds.db.AutoMigrate(&model.Credential{}); err != nil {
+		return err
+	}
+
+	if err := ds.db.AutoMigrate(&model.RefreshToken{}, &model.RevokedToken{}, &model.UserTokenRevocation{}); err != nil {
+		return err
+	}
+
+	if err := ds.db.AutoMigrate(&model.SyncGeneration{}); err != nil {
+		return err
+	}
+
+	if err := ds.db.AutoMigrate(&model.Delegation{}, &model.DelegationDepartment{}, &model.DelegatedAction{}); err != nil {
 		return err
 	}
 
@@ -108,26 +186,7 @@ func (ds *datastore) InitRoles() error {
 	return nil
 }
 
-// InitUserRoles 初始化用户角色
+// InitUserRoles 根据部门主管标记初始化用户的 leader 角色，手动授予的角色不受影响
 func (ds *datastore) InitUserRoles() error {
-	var userDepartments []model.UserDepartment
-	if err := ds.db.Where("is_leader = ?", model.True).Find(&userDepartments).Error; err != nil {
-		return err
-	}
-
-	var leaderRole model.Role
-	if err := ds.db.Where("role_name = ?", "leader").First(&leaderRole).Error; err != nil {
-		return err
-	}
-
-	for _, ud := range userDepartments {
-		userRole := model.UserRole{
-			UserID: ud.UserID,
-			RoleID: leaderRole.RoleID,
-		}
-		if err := ds.db.Where("user_id = ? AND role_id = ?", ud.UserID, leaderRole.RoleID).FirstOrCreate(&userRole).Error; err != nil {
-			return err
-		}
-	}
-	return nil
+	return syncLeaderRoles(context.Background(), ds.db)
 }
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -13,6 +14,10 @@ type SyncStorer interface {
 	PersistUsers(context.Context, []model.User) error
 	PersistDepartments(context.Context, []model.Department) error
 	PersistUserDepartments(context.Context, []model.UserDepartment) error
+	// BumpGeneration 将 name 对应的同步代数加一，使依赖同步数据的缓存失效
+	BumpGeneration(ctx context.Context, name string) error
+	// SyncLeaderRoles 根据部门主管标记授予或撤销通过同步获得的 leader 角色
+	SyncLeaderRoles(ctx context.Context) error
 }
 
 var _ SyncStorer = (*SyncStore)(nil)
@@ -63,3 +68,17 @@ func (s *SyncStore) PersistUserDepartments(ctx context.Context, userDepts []mode
 	}
 	return nil
 }
+
+func (s *SyncStore) BumpGeneration(ctx context.Context, name string) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"generation": gorm.Expr("generation + 1"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&model.SyncGeneration{Name: name, Generation: 1}).Error
+}
+
+func (s *SyncStore) SyncLeaderRoles(ctx context.Context) error {
+	return syncLeaderRoles(ctx, s.db)
+}
@@ -12,6 +12,11 @@ import (
 type UserStore interface {
 	GetUserByID(ctx context.Context, id string) (*model.User, error)
 	GetUserByName(ctx context.Context, username string) (*model.User, error)
+	// GetUserIDsByName 返回名称为 name 的全部用户 ID，同名用户可能有多个
+	GetUserIDsByName(ctx context.Context, name string) ([]string, error)
+	GetUserByMobile(ctx context.Context, mobile string) (*model.User, error)
+	GetUserByJobNumber(ctx context.Context, jobNumber string) (*model.User, error)
+	CreateUser(ctx context.Context, user *model.User) error
 	GetUserDepartments(ctx context.Context, userID string) ([]model.UserDepartment, error)
 	GetDepartmentByID(ctx context.Context, departmentID int) (*model.Department, error)
 	GetUserRolesByID(ctx context.Context, userID string) ([]string, error)
@@ -21,6 +26,10 @@ type UserStore interface {
 	GetUsersByDepartmentID(ctx context.Context, departmentID int) ([]model.User, error)
 	GetUserDepartment(ctx context.Context, userID string, departmentID int) (*model.UserDepartment, error)
 	GetParentDepartment(ctx context.Context, departmentID int) (*model.Department, error)
+	// ListDepartments 返回全部部门，用于构建部门层级
+	ListDepartments(ctx context.Context) ([]model.Department, error)
+	// GetSyncGeneration 返回 name 对应的同步代数，从未同步时返回 0
+	GetSyncGeneration(ctx context.Context, name string) (int64, error)
 }
 
 // UserStore 接口的实现.
@@ -51,6 +60,35 @@ func (s *users) GetUserByName(ctx context.Context, username string) (*model.User
 	return &user, nil
 }
 
+func (s *users) GetUserIDsByName(ctx context.Context, name string) ([]string, error) {
+	var userIDs []string
+	err := s.db.WithContext(ctx).Model(&model.User{}).Where("name = ?", name).Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// GetUserByMobile 根据手机号获取用户，未找到时返回 gorm.ErrRecordNotFound
+func (s *users) GetUserByMobile(ctx context.Context, mobile string) (*model.User, error) {
+	var user model.User
+	if err := s.db.WithContext(ctx).First(&user, "mobile = ?", mobile).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByJobNumber 根据工号获取用户，未找到时返回 gorm.ErrRecordNotFound
+func (s *users) GetUserByJobNumber(ctx context.Context, jobNumber string) (*model.User, error) {
+	var user model.User
+	if err := s.db.WithContext(ctx).First(&user, "job_number = ?", jobNumber).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser 创建用户，用于 OIDC 登录时创建不在钉钉通讯录中的用户. 用户 id 已存在时返回错误，不会覆盖已有用户
+func (s *users) CreateUser(ctx context.Context, user *model.User) error {
+	return s.db.WithContext(ctx).Omit("UserDepartments").Create(user).Error
+}
+
 // GetUserRolesByID 根据用户ID获取用户的角色
 func (s *users) GetUserRolesByID(ctx context.Context, userID string) ([]string, error) {
 	var roles []model.Role
@@ -162,3 +200,17 @@ func (s *users) GetParentDepartment(ctx context.Context, departmentID int) (*mod
 
 	return parentDept, nil
 }
+
+func (s *users) ListDepartments(ctx context.Context) ([]model.Department, error) {
+	var depts []model.Department
+	if err := s.db.WithContext(ctx).Order("sort").Find(&depts).Error; err != nil {
+		return nil, err
+	}
+	return depts, nil
+}
+
+func (s *users) GetSyncGeneration(ctx context.Context, name string) (int64, error) {
+	var gen model.SyncGeneration
+	err := s.db.WithContext(ctx).Where("name = ?", name).Limit(1).Find(&gen).Error
+	return gen.Generation, err
+}
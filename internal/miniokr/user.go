@@ -0,0 +1,97 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package miniokr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/imxw/miniokr/internal/miniokr/services/auth"
+	"github.com/imxw/miniokr/internal/pkg/model"
+)
+
+// newUserCommand 创建 `miniokr user` 命令，用于管理本地账号.
+func newUserCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage local username/password accounts",
+	}
+	cmd.AddCommand(newUserCreateAdminCommand())
+	return cmd
+}
+
+// newUserCreateAdminCommand 创建 `miniokr user create-admin` 命令.
+func newUserCreateAdminCommand() *cobra.Command {
+	var (
+		account       = auth.Account{Kind: model.CredentialKindAdmin}
+		passwordStdin bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create-admin",
+		Short: "Create a local admin account that can log in when DingTalk is unavailable",
+		Long: `Create a local account with the admin role in the configured database.
+The account logs in with POST /api/v1/auth/password and can then manage other
+local accounts under /api/v1/admin/accounts.
+
+The password is read from the first line of standard input with --password-stdin,
+so it does not end up in the shell history:
+
+	echo "$ADMIN_PASSWORD" | miniokr user create-admin --username ops --password-stdin`,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if passwordStdin {
+				password, err := readPassword(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				account.Password = password
+			}
+			return createAdmin(cmd.Context(), cmd.OutOrStdout(), account)
+		},
+	}
+
+	cmd.Flags().StringVar(&account.Username, "username", "", "Username of the account.")
+	cmd.Flags().StringVar(&account.Name, "name", "", "Display name of the account. Defaults to the username.")
+	cmd.Flags().StringVar(&account.Password, "password", "", fmt.Sprintf("Password of the account, at least %d characters.", auth.MinPasswordLength))
+	cmd.Flags().BoolVar(&passwordStdin, "password-stdin", false, "Read the password from standard input.")
+	_ = cmd.MarkFlagRequired("username")
+	cmd.MarkFlagsMutuallyExclusive("password", "password-stdin")
+	cmd.MarkFlagsOneRequired("password", "password-stdin")
+
+	return cmd
+}
+
+// createAdmin 连接配置的数据库并创建本地管理员账号.
+func createAdmin(ctx context.Context, w io.Writer, account auth.Account) error {
+	if _, err := initStore(); err != nil {
+		return err
+	}
+
+	uid, err := newPasswordAuthService(newSessionService()).CreateAccount(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "已创建管理员账号 %s (用户 ID: %s)\n", account.Username, uid)
+	return nil
+}
+
+// readPassword 读取输入的第一行作为密码.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
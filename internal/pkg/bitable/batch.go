@@ -0,0 +1,147 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package bitable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+
+	"github.com/imxw/miniokr/internal/pkg/log"
+)
+
+// MaxBatchSize 是飞书批量新增/更新记录接口单次请求允许的最大记录数
+const MaxBatchSize = 500
+
+// RecordUpdate 是批量更新中的一条记录
+type RecordUpdate struct {
+	RecordID string
+	Fields   map[string]interface{}
+}
+
+// BatchResult 是批量操作中单条记录的结果，顺序与入参一致
+type BatchResult struct {
+	RecordID string
+	Err      error
+}
+
+// BatchResults 是批量操作的结果列表
+type BatchResults []BatchResult
+
+// Err 汇总所有失败记录的错误，全部成功时返回 nil
+func (rs BatchResults) Err() error {
+	var errs []error
+	for i, r := range rs {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("record %d: %w", i, r.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// BatchCreateRecords 批量新增记录，超过 MaxBatchSize 时自动分批请求.
+// 飞书单次批量请求是原子的，某一批失败时该批内所有记录都会带上同一个错误，其余批次不受影响.
+// 错误记录在每条记录的结果中，需要整体判断时使用 BatchResults.Err
+func (r *RecordManager) BatchCreateRecords(ctx context.Context, tableID string, records []map[string]interface{}) BatchResults {
+	results := make(BatchResults, len(records))
+
+	for start := 0; start < len(records); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(records))
+
+		items := make([]*larkbitable.AppTableRecord, 0, end-start)
+		for _, fields := range records[start:end] {
+			items = append(items, larkbitable.NewAppTableRecordBuilder().Fields(fields).Build())
+		}
+
+		// client_token 保证重试时不会重复创建记录
+		req := larkbitable.NewBatchCreateAppTableRecordReqBuilder().
+			AppToken(r.AppToken).
+			TableId(tableID).
+			ClientToken(uuid.NewString()).
+			Body(larkbitable.NewBatchCreateAppTableRecordReqBodyBuilder().
+				Records(items).
+				Build()).Build()
+
+		var resp *larkbitable.BatchCreateAppTableRecordResp
+		err := r.invoke(ctx, func(ctx context.Context, t string) error {
+			var err error
+			resp, err = r.Client.Bitable.AppTableRecord.BatchCreate(ctx, req, larkcore.WithTenantAccessToken(t))
+			if err != nil {
+				return err
+			}
+			return CheckResponse(resp.ApiResp, resp.CodeError)
+		})
+		if err == nil && (resp.Data == nil || len(resp.Data.Records) != end-start) {
+			err = errors.New("unexpected number of records in response")
+		}
+		if err != nil {
+			log.C(ctx).Errorw("failed to batch create records", "error", err, "tableID", tableID, "from", start, "to", end)
+			fillBatchError(results[start:end], fmt.Errorf("failed to create records: %w", err))
+			continue
+		}
+
+		for i, record := range resp.Data.Records {
+			if record.RecordId == nil {
+				results[start+i].Err = errors.New("failed to create record: empty record id")
+				continue
+			}
+			results[start+i].RecordID = *record.RecordId
+		}
+	}
+
+	return results
+}
+
+// BatchUpdateRecords 批量更新记录，超过 MaxBatchSize 时自动分批请求，失败语义同 BatchCreateRecords
+func (r *RecordManager) BatchUpdateRecords(ctx context.Context, tableID string, records []RecordUpdate) BatchResults {
+	results := make(BatchResults, len(records))
+	for i, record := range records {
+		results[i].RecordID = record.RecordID
+	}
+
+	for start := 0; start < len(records); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(records))
+
+		items := make([]*larkbitable.AppTableRecord, 0, end-start)
+		for _, record := range records[start:end] {
+			items = append(items, larkbitable.NewAppTableRecordBuilder().
+				RecordId(record.RecordID).
+				Fields(record.Fields).
+				Build())
+		}
+
+		req := larkbitable.NewBatchUpdateAppTableRecordReqBuilder().
+			AppToken(r.AppToken).
+			TableId(tableID).
+			Body(larkbitable.NewBatchUpdateAppTableRecordReqBodyBuilder().
+				Records(items).
+				Build()).Build()
+
+		err := r.invoke(ctx, func(ctx context.Context, t string) error {
+			resp, err := r.Client.Bitable.AppTableRecord.BatchUpdate(ctx, req, larkcore.WithTenantAccessToken(t))
+			if err != nil {
+				return err
+			}
+			return CheckResponse(resp.ApiResp, resp.CodeError)
+		})
+		if err != nil {
+			log.C(ctx).Errorw("failed to batch update records", "error", err, "tableID", tableID, "from", start, "to", end)
+			fillBatchError(results[start:end], fmt.Errorf("failed to update records: %w", err))
+		}
+	}
+
+	return results
+}
+
+func fillBatchError(results BatchResults, err error) {
+	for i := range results {
+		results[i].Err = err
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package bitable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBatchBitable 模拟飞书多维表格的批量新增/更新接口，failCall 指定第几次请求(从 1 开始)返回错误
+type fakeBatchBitable struct {
+	mu       sync.Mutex
+	calls    []int // 每次请求的记录数
+	failCall int
+	seq      int
+}
+
+func (f *fakeBatchBitable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body struct {
+		Records []struct {
+			RecordID string                 `json:"record_id"`
+			Fields   map[string]interface{} `json:"fields"`
+		} `json:"records"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.calls = append(f.calls, len(body.Records))
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(f.calls) == f.failCall {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 1254045, "msg": "FieldNameNotFound"})
+		return
+	}
+
+	records := make([]map[string]interface{}, 0, len(body.Records))
+	for _, rec := range body.Records {
+		id := rec.RecordID
+		if strings.HasSuffix(r.URL.Path, "/batch_create") {
+			f.seq++
+			id = fmt.Sprintf("rec%d", f.seq)
+		}
+		records = append(records, map[string]interface{}{"record_id": id, "fields": rec.Fields})
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "msg": "success", "data": map[string]interface{}{"records": records}})
+}
+
+func newBatchTestRecordManager(t *testing.T, fake *fakeBatchBitable) *RecordManager {
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client := lark.NewClient("cli_test", "secret", lark.WithOpenBaseUrl(srv.URL), lark.WithEnableTokenCache(false))
+	rm := NewRecordManager(client, "app-token", staticTokenProvider("t-test"))
+	rm.SetInvoker(newTestInvoker())
+	return rm
+}
+
+func TestBatchCreateRecords_Chunks(t *testing.T) {
+	fake := &fakeBatchBitable{}
+	rm := newBatchTestRecordManager(t, fake)
+
+	records := make([]map[string]interface{}, MaxBatchSize+3)
+	for i := range records {
+		records[i] = map[string]interface{}{"标题": fmt.Sprintf("KR%d", i)}
+	}
+
+	results := rm.BatchCreateRecords(context.Background(), "tbl1", records)
+	require.NoError(t, results.Err())
+	require.Len(t, results, MaxBatchSize+3)
+	assert.Equal(t, []int{MaxBatchSize, 3}, fake.calls)
+	assert.Equal(t, "rec1", results[0].RecordID)
+	assert.Equal(t, fmt.Sprintf("rec%d", MaxBatchSize+3), results[MaxBatchSize+2].RecordID)
+}
+
+func TestBatchCreateRecords_PartialFailure(t *testing.T) {
+	fake := &fakeBatchBitable{failCall: 1}
+	rm := newBatchTestRecordManager(t, fake)
+
+	records := make([]map[string]interface{}, MaxBatchSize+1)
+	for i := range records {
+		records[i] = map[string]interface{}{"标题": "KR"}
+	}
+
+	results := rm.BatchCreateRecords(context.Background(), "tbl1", records)
+	assert.Error(t, results.Err())
+
+	// 第一批失败，第二批成功
+	for _, r := range results[:MaxBatchSize] {
+		assert.Error(t, r.Err)
+		assert.Empty(t, r.RecordID)
+	}
+	assert.NoError(t, results[MaxBatchSize].Err)
+	assert.NotEmpty(t, results[MaxBatchSize].RecordID)
+}
+
+func TestBatchUpdateRecords(t *testing.T) {
+	fake := &fakeBatchBitable{failCall: 2}
+	rm := newBatchTestRecordManager(t, fake)
+
+	records := make([]RecordUpdate, MaxBatchSize+2)
+	for i := range records {
+		records[i] = RecordUpdate{RecordID: fmt.Sprintf("rec%d", i), Fields: map[string]interface{}{"标题": "KR"}}
+	}
+
+	results := rm.BatchUpdateRecords(context.Background(), "tbl1", records)
+	assert.Equal(t, []int{MaxBatchSize, 2}, fake.calls)
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "rec0", results[0].RecordID)
+	assert.Error(t, results[MaxBatchSize+1].Err)
+	assert.Equal(t, fmt.Sprintf("rec%d", MaxBatchSize+1), results[MaxBatchSize+1].RecordID)
+}
+
+func TestBatchCreateRecords_Empty(t *testing.T) {
+	fake := &fakeBatchBitable{}
+	rm := newBatchTestRecordManager(t, fake)
+
+	results := rm.BatchCreateRecords(context.Background(), "tbl1", nil)
+	assert.Empty(t, results)
+	assert.Empty(t, fake.calls)
+}
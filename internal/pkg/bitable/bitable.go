@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/google/uuid"
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
 	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
@@ -24,6 +25,8 @@ type RecordManager struct {
 	Client        *lark.Client
 	AppToken      string
 	tokenProvider token.Provider
+	invoker       *Invoker
+	searchOpts    []SearchOption
 }
 
 func NewRecordManager(client *lark.Client, appToken string, tp token.Provider) *RecordManager {
@@ -31,92 +34,118 @@ func NewRecordManager(client *lark.Client, appToken string, tp token.Provider) *
 		Client:        client,
 		AppToken:      appToken,
 		tokenProvider: tp,
+		invoker:       DefaultInvoker,
 	}
 }
 
+// SetInvoker 设置调用飞书 API 时使用的限流和重试策略
+func (r *RecordManager) SetInvoker(invoker *Invoker) {
+	r.invoker = invoker
+}
+
+// invoke 在限流和重试策略下执行一次飞书 API 调用，fn 的参数 t 是当前有效的 tenant_access_token
+func (r *RecordManager) invoke(ctx context.Context, fn func(ctx context.Context, t string) error) error {
+	return r.invoker.InvokeWithToken(ctx, r.AppToken, r.tokenProvider, fn)
+}
+
 func (r *RecordManager) CreateRecord(ctx context.Context, tableID string, fields map[string]interface{}) (string, error) {
+	// client_token 保证重试时不会重复创建记录
 	req := larkbitable.NewCreateAppTableRecordReqBuilder().
 		AppToken(r.AppToken).
 		TableId(tableID).
+		ClientToken(uuid.NewString()).
 		AppTableRecord(larkbitable.NewAppTableRecordBuilder().
 			Fields(fields).Build()).Build()
 
-	// fmt.Println("查看当前fields", larkcore.Prettify(fields))
-	t, err := r.tokenProvider.EnsureValidToken(ctx)
-	if err != nil {
-		log.C(ctx).Errorw("获取token失败", "error", err)
-		return "", errors.New("获取token失败")
-	}
-	resp, err := r.Client.Bitable.AppTableRecord.Create(context.Background(), req, larkcore.WithTenantAccessToken(t))
+	var recordID string
+	err := r.invoke(ctx, func(ctx context.Context, t string) error {
+		resp, err := r.Client.Bitable.AppTableRecord.Create(ctx, req, larkcore.WithTenantAccessToken(t))
+		if err != nil {
+			return err
+		}
+		if err := CheckResponse(resp.ApiResp, resp.CodeError); err != nil {
+			return err
+		}
+		recordID = *resp.Data.Record.RecordId
+		return nil
+	})
 	if err != nil {
 		log.C(ctx).Errorw("failed to create record", "error", err, "tableID", tableID)
-		return "", fmt.Errorf("failed to create record: %v", err)
-	}
-
-	if !resp.Success() {
-		log.C(ctx).Errorw("failed to create record", "errMsg", resp.Msg, "tableID", tableID)
-		return "", fmt.Errorf("failed to create record: code=%d, msg=%s, requestId=%s\n", resp.Code, resp.Msg, resp.RequestId())
+		return "", fmt.Errorf("failed to create record: %w", err)
 	}
 
-	return *resp.Data.Record.RecordId, nil
+	return recordID, nil
 }
 
 func (r *RecordManager) UpdateRecord(ctx context.Context, tableID string, recordID string, fields map[string]interface{}) error {
-	t, err := r.tokenProvider.EnsureValidToken(ctx)
-	if err != nil {
-		log.C(ctx).Errorw("获取token失败", "error", err)
-		return errors.New("获取token失败")
-	}
 
-	// fmt.Println("查看当前fields", recordID, larkcore.Prettify(fields))
 	req := larkbitable.NewUpdateAppTableRecordReqBuilder().
 		AppToken(r.AppToken).
 		TableId(tableID).
 		RecordId(recordID).
 		AppTableRecord(larkbitable.NewAppTableRecordBuilder().Fields(fields).Build()).Build()
-	resp, err := r.Client.Bitable.AppTableRecord.Update(ctx, req, larkcore.WithTenantAccessToken(t))
+
+	err := r.invoke(ctx, func(ctx context.Context, t string) error {
+		resp, err := r.Client.Bitable.AppTableRecord.Update(ctx, req, larkcore.WithTenantAccessToken(t))
+		if err != nil {
+			return err
+		}
+		return CheckResponse(resp.ApiResp, resp.CodeError)
+	})
 	if err != nil {
 		log.C(ctx).Errorw("failed to update record", "error", err, "tableID", tableID, "recordID", recordID)
-		return fmt.Errorf("failed to update record: %v", err)
-	}
-	if !resp.Success() {
-		log.C(ctx).Errorw("failed to update record", "errMsg", resp.Msg, "tableID", tableID, "recordID", recordID)
-		return fmt.Errorf("failed to update record: code=%d, msg=%s, requestId=%s\n", resp.Code, resp.Msg, resp.RequestId())
+		return fmt.Errorf("failed to update record: %w", err)
 	}
 	return nil
 }
 
-func (r *RecordManager) DeleteRecord(ctx context.Context, tableID, recordID string) error {
-	t, err := r.tokenProvider.EnsureValidToken(ctx)
+// GetRecord 按 ID 获取单条记录，记录不存在时返回的错误满足 IsRecordNotFound
+func (r *RecordManager) GetRecord(ctx context.Context, tableID string, recordID string) (*larkbitable.AppTableRecord, error) {
+	req := larkbitable.NewGetAppTableRecordReqBuilder().
+		AppToken(r.AppToken).
+		TableId(tableID).
+		RecordId(recordID).Build()
+
+	var record *larkbitable.AppTableRecord
+	err := r.invoke(ctx, func(ctx context.Context, t string) error {
+		resp, err := r.Client.Bitable.AppTableRecord.Get(ctx, req, larkcore.WithTenantAccessToken(t))
+		if err != nil {
+			return err
+		}
+		if err := CheckResponse(resp.ApiResp, resp.CodeError); err != nil {
+			return err
+		}
+		record = resp.Data.Record
+		return nil
+	})
 	if err != nil {
-		log.C(ctx).Errorw("获取token失败", "error", err)
-		return errors.New("获取token失败")
+		return nil, fmt.Errorf("failed to get record: %w", err)
 	}
+	return record, nil
+}
+
+func (r *RecordManager) DeleteRecord(ctx context.Context, tableID, recordID string) error {
 
-	// fmt.Println("查看传入的id: ", recordID)
 	req := larkbitable.NewDeleteAppTableRecordReqBuilder().
 		AppToken(r.AppToken).
 		TableId(tableID).
 		RecordId(recordID).Build()
 
-	resp, err := r.Client.Bitable.AppTableRecord.Delete(ctx, req, larkcore.WithTenantAccessToken(t))
+	err := r.invoke(ctx, func(ctx context.Context, t string) error {
+		resp, err := r.Client.Bitable.AppTableRecord.Delete(ctx, req, larkcore.WithTenantAccessToken(t))
+		if err != nil {
+			return err
+		}
+		return CheckResponse(resp.ApiResp, resp.CodeError)
+	})
 	if err != nil {
 		log.C(ctx).Errorw("failed to delete record", "error", err, "recordId", recordID, "tableId", tableID)
-		return fmt.Errorf("failed to delete record: %v", err)
-	}
-	if !resp.Success() {
-		log.C(ctx).Errorw("failed to delete record", "error", resp.CodeError.Error(), "recordId", recordID, "tableId", tableID)
-		return fmt.Errorf("failed to delete record: %s", resp.CodeError.Error())
+		return fmt.Errorf("failed to delete record: %w", err)
 	}
 	return nil
 }
 
 func (r *RecordManager) BatchDeleteRecord(ctx context.Context, tableID string, records []string) error {
-	t, err := r.tokenProvider.EnsureValidToken(ctx)
-	if err != nil {
-		log.C(ctx).Errorw("获取token失败", "error", err)
-		return errors.New("获取token失败")
-	}
 
 	req := larkbitable.NewBatchDeleteAppTableRecordReqBuilder().
 		AppToken(r.AppToken).
@@ -125,76 +154,32 @@ func (r *RecordManager) BatchDeleteRecord(ctx context.Context, tableID string, r
 			Records(records).
 			Build()).Build()
 
-	resp, err := r.Client.Bitable.AppTableRecord.BatchDelete(ctx, req, larkcore.WithTenantAccessToken(t))
+	err := r.invoke(ctx, func(ctx context.Context, t string) error {
+		resp, err := r.Client.Bitable.AppTableRecord.BatchDelete(ctx, req, larkcore.WithTenantAccessToken(t))
+		if err != nil {
+			return err
+		}
+		return CheckResponse(resp.ApiResp, resp.CodeError)
+	})
 	if err != nil {
 		log.C(ctx).Errorw("failed to delete records", "error", err, "tableId", tableID, "records", records)
-		return fmt.Errorf("failed to delete records: %v", err)
-	}
-	if !resp.Success() {
-		log.C(ctx).Errorw("failed to delete records", "error", resp.CodeError.Error(), "tableId", tableID, "records", records)
-		return fmt.Errorf("failed to delete records: %s", resp.CodeError.Error())
+		return fmt.Errorf("failed to delete records: %w", err)
 	}
 	return nil
 }
 
-func (r *RecordManager) SearchRecord(ctx context.Context, tableID string, fieldNames []string, filter *larkbitable.FilterInfo) ([]*larkbitable.AppTableRecord, error) {
-	t, err := r.tokenProvider.EnsureValidToken(ctx)
-	if err != nil {
-		log.C(ctx).Errorw("获取token失败", "error", err)
-		return nil, errors.New("获取token失败")
-	}
-
-	// 创建请求对象
-	req := larkbitable.NewSearchAppTableRecordReqBuilder().
-		AppToken(r.AppToken).
-		TableId(tableID).
-		PageSize(100).
-		Body(larkbitable.NewSearchAppTableRecordReqBodyBuilder().
-			FieldNames(fieldNames).
-			Filter(filter).
-			AutomaticFields(true).
-			Build()).
-		Build()
-	// 发起请求
-	resp, err := r.Client.Bitable.AppTableRecord.Search(context.Background(), req, larkcore.WithTenantAccessToken(t))
-
-	// 处理错误
-	if err != nil {
-		// TODO: 优化错误处理
-		log.C(ctx).Errorw("查询错误", "error", err)
-		return nil, err
-	}
-	// 服务端错误处理
-	if !resp.Success() {
-
-		if resp.Code == 1254018 || resp.Msg == "InvalidFilter" {
+// SearchRecord 查询符合条件的全部记录，会自动遍历所有分页
+func (r *RecordManager) SearchRecord(ctx context.Context, tableID string, fieldNames []string, filter *FilterInfo, opts ...SearchOption) ([]*larkbitable.AppTableRecord, error) {
+	var records []*larkbitable.AppTableRecord
 
-			return nil, ErrInvalidUser
+	it := r.SearchRecordIterator(tableID, fieldNames, filter, opts...)
+	for it.HasNext() {
+		items, err := it.Next(ctx)
+		if err != nil {
+			return nil, err
 		}
-
-		log.C(ctx).Errorw("failed to list record", "errMsg", resp.Msg, "tableID", tableID)
-
-		return nil, fmt.Errorf("failed to list record: code=%d, msg=%s, requestId=%s\n", resp.Code, resp.Msg, resp.RequestId())
-	}
-
-	return resp.Data.Items, nil
-}
-
-func (r *RecordManager) SearchRecordByUser(ctx context.Context, tableID string, username string, fieldNames []string) ([]*larkbitable.AppTableRecord, error) {
-
-	OC := []*larkbitable.Condition{
-		larkbitable.NewConditionBuilder().FieldName("员工姓名").Operator(`is`).Value([]string{username}).Build(),
+		records = append(records, items...)
 	}
 
-	// if len(months) != 0 {
-	// 	OC = append(OC, larkbitable.NewConditionBuilder().FieldName("考核月份").Operator(`contains`).Value(months).Build())
-	// }
-
-	filter := larkbitable.NewFilterInfoBuilder().
-		Conjunction(`and`).
-		Conditions(OC).
-		Build()
-
-	return r.SearchRecord(ctx, tableID, fieldNames, filter)
-
+	return records, nil
 }
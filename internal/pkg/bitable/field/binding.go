@@ -0,0 +1,99 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Binding 声明结构体字段与多维表格字段的对应关系.
+// key 是结构体字段的 json 名(不区分大小写)，value 是多维表格中的字段 ID 或字段名，
+// 没有声明的结构体字段使用 field 标签中的字段 ID
+type Binding map[string]string
+
+// normalize 将 key 统一为小写，viper 读取配置时会把 key 转为小写
+func (b Binding) normalize() Binding {
+	n := make(Binding, len(b))
+	for k, v := range b {
+		n[strings.ToLower(k)] = v
+	}
+	return n
+}
+
+// Resolution 是一个结构体字段的解析结果
+type Resolution struct {
+	// Key 是结构体字段的 json 名，即 Binding 中的 key
+	Key string
+	// Ref 是配置或 field 标签中声明的字段 ID 或字段名
+	Ref string
+	// Field 是 Ref 对应的多维表格字段，未找到时为 nil
+	Field *Field
+
+	index int
+}
+
+// Resolve 按 binding 和 field 标签将 schema(结构体或其指针) 的每个字段解析为 fields 中的字段.
+// Ref 先按字段 ID 匹配，再按字段名匹配；既没有配置也没有 field 标签的结构体字段会被忽略
+func Resolve(fields []Field, schema interface{}, binding Binding) []Resolution {
+	byID := make(map[string]*Field, len(fields))
+	byName := make(map[string]*Field, len(fields))
+	for i := range fields {
+		byID[fields[i].FieldID] = &fields[i]
+		byName[fields[i].FieldName] = &fields[i]
+	}
+	binding = binding.normalize()
+
+	t := reflect.TypeOf(schema)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	resolutions := make([]Resolution, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := jsonName(sf)
+		ref, ok := binding[strings.ToLower(key)]
+		if !ok || ref == "" {
+			ref = sf.Tag.Get("field")
+		}
+		if ref == "" {
+			continue
+		}
+
+		r := Resolution{Key: key, Ref: ref, index: i}
+		if f, ok := byID[ref]; ok {
+			r.Field = f
+		} else if f, ok := byName[ref]; ok {
+			r.Field = f
+		}
+		resolutions = append(resolutions, r)
+	}
+	return resolutions
+}
+
+// jsonName 返回结构体字段的 json 名，没有 json 标签时使用字段名
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+// BindingOf 将结构体(或其指针)中填写的字段名转换为 Binding，空值字段会被忽略
+func BindingOf(names interface{}) Binding {
+	v := reflect.Indirect(reflect.ValueOf(names))
+	t := v.Type()
+
+	b := make(Binding, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name := v.Field(i).String(); name != "" {
+			b[strings.ToLower(jsonName(t.Field(i)))] = name
+		}
+	}
+	return b
+}
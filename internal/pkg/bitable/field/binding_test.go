@@ -0,0 +1,67 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type boundSchema struct {
+	Title        string `json:"title" field:"fldTitle"`
+	KeyResultIDs string `json:"keyResultIds" field:"fldKR"`
+	Date         string `json:"date"`
+	Ignored      string
+}
+
+var boundFields = []Field{
+	{FieldID: "fldTitle", FieldName: "标题"},
+	{FieldID: "fldOther", FieldName: "关键结果"},
+	{FieldID: "fldMonth", FieldName: "考核月份"},
+}
+
+func TestResolve(t *testing.T) {
+	resolutions := Resolve(boundFields, &boundSchema{}, Binding{
+		"KEYRESULTIDS": "fldOther", // viper 会把 key 转为小写，匹配时不区分大小写
+		"date":         "考核月份",
+	})
+
+	require.Len(t, resolutions, 3)
+	assert.Equal(t, "title", resolutions[0].Key)
+	assert.Equal(t, "fldTitle", resolutions[0].Ref)
+	assert.Equal(t, "标题", resolutions[0].Field.FieldName)
+
+	// 配置覆盖 field 标签，按字段 ID 匹配
+	assert.Equal(t, "fldOther", resolutions[1].Field.FieldID)
+
+	// 按字段名匹配
+	assert.Equal(t, "date", resolutions[2].Key)
+	assert.Equal(t, "fldMonth", resolutions[2].Field.FieldID)
+}
+
+func TestResolve_Missing(t *testing.T) {
+	err := ValidateSchema("tbl", boundFields, &boundSchema{}, Binding{"date": "月份"})
+
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, []MissingField{{Key: "keyResultIds", Ref: "fldKR"}, {Key: "date", Ref: "月份"}}, schemaErr.Missing)
+}
+
+func TestManager_GetFriendlyFieldMappingWithBinding(t *testing.T) {
+	fake := &fakeFieldBitable{
+		tables: map[string][]Field{"tblO": boundFields},
+		calls:  map[string]int{},
+	}
+	m := newTestManager(t, fake)
+	m.SetBinding("tblO", Binding{"keyResultIds": "关键结果", "date": "fldMonth"})
+
+	var obj boundSchema
+	require.NoError(t, m.GetFriendlyFieldMapping(context.Background(), "tblO", &obj))
+	assert.Equal(t, boundSchema{Title: "标题", KeyResultIDs: "关键结果", Date: "考核月份"}, obj)
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrCacheMiss 表示缓存中没有该表的字段映射
+var ErrCacheMiss = errors.New("field mapping not cached")
+
+// Cache 保存各表的字段映射，Manager 通过它加载和保存字段映射.
+// 多副本部署时使用数据库等共享的实现，所有副本读到同一份表结构
+type Cache interface {
+	// Load 读取表 tableID 的字段映射，不存在时返回 ErrCacheMiss
+	Load(ctx context.Context, tableID string) (MappingData, error)
+	// Save 保存表 tableID 的字段映射
+	Save(ctx context.Context, tableID string, data MappingData) error
+	// Delete 删除表 tableID 的字段映射，不存在时不返回错误
+	Delete(ctx context.Context, tableID string) error
+}
+
+// NewMemoryCache 创建只保存在内存中的字段映射缓存，进程重启后需要重新从 API 拉取
+func NewMemoryCache() Cache {
+	return &memoryCache{data: make(map[string]MappingData)}
+}
+
+type memoryCache struct {
+	mu   sync.RWMutex
+	data map[string]MappingData
+}
+
+func (c *memoryCache) Load(_ context.Context, tableID string) (MappingData, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	data, ok := c.data[tableID]
+	if !ok {
+		return MappingData{}, ErrCacheMiss
+	}
+	return copyMappingData(data), nil
+}
+
+func (c *memoryCache) Save(_ context.Context, tableID string, data MappingData) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[tableID] = copyMappingData(data)
+	return nil
+}
+
+func (c *memoryCache) Delete(_ context.Context, tableID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, tableID)
+	return nil
+}
+
+// copyMappingData 复制字段列表，避免调用方修改缓存中的数据
+func copyMappingData(data MappingData) MappingData {
+	fields := make([]Field, len(data.FieldMapping))
+	copy(fields, data.FieldMapping)
+	data.FieldMapping = fields
+	return data
+}
+
+// NewFileCache 创建将字段映射保存在 dir 目录下 field_mapping_<tableID>.json 文件中的缓存，dir 为空时使用当前目录.
+// 文件先写入临时文件再原子重命名，其他进程不会读到写了一半的文件
+func NewFileCache(dir string) Cache {
+	return &fileCache{dir: dir}
+}
+
+type fileCache struct {
+	dir string
+}
+
+// path 返回表 tableID 的字段映射文件路径
+func (c *fileCache) path(tableID string) string {
+	return filepath.Join(c.dir, "field_mapping_"+tableID+".json")
+}
+
+func (c *fileCache) Load(_ context.Context, tableID string) (MappingData, error) {
+	var data MappingData
+	b, err := os.ReadFile(c.path(tableID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, ErrCacheMiss
+		}
+		return data, err
+	}
+	err = json.Unmarshal(b, &data)
+	return data, err
+}
+
+func (c *fileCache) Save(_ context.Context, tableID string, data MappingData) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	dir := c.dir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	// CreateTemp 创建的文件权限为 0600
+	f, err := os.CreateTemp(dir, ".field_mapping_*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path(tableID))
+}
+
+func (c *fileCache) Delete(_ context.Context, tableID string) error {
+	if err := os.Remove(c.path(tableID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
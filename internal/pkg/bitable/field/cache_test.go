@@ -0,0 +1,68 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCache(t *testing.T, cache Cache) {
+	ctx := context.Background()
+
+	_, err := cache.Load(ctx, "tblO")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	data := MappingData{
+		FieldMapping: []Field{{FieldID: "fldDate", FieldName: "考核月份", Type: TypeSelect, Options: []Option{{ID: "opt1", Name: "2024年5月"}}}},
+		LastUpdate:   time.Now().Unix(),
+	}
+	require.NoError(t, cache.Save(ctx, "tblO", data))
+
+	got, err := cache.Load(ctx, "tblO")
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	// 修改读到的数据不影响缓存
+	got.FieldMapping[0].FieldName = "changed"
+	got, err = cache.Load(ctx, "tblO")
+	require.NoError(t, err)
+	assert.Equal(t, "考核月份", got.FieldMapping[0].FieldName)
+
+	_, err = cache.Load(ctx, "tblKR")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	require.NoError(t, cache.Delete(ctx, "tblO"))
+	require.NoError(t, cache.Delete(ctx, "tblO"))
+	_, err = cache.Load(ctx, "tblO")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+}
+
+func TestMemoryCache(t *testing.T) {
+	testCache(t, NewMemoryCache())
+}
+
+func TestFileCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	testCache(t, NewFileCache(dir))
+
+	// 目录不存在时自动创建，文件只对当前用户可读写
+	require.NoError(t, NewFileCache(dir).Save(context.Background(), "tblO", MappingData{}))
+	info, err := os.Stat(filepath.Join(dir, "field_mapping_tblO.json"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// 不残留临时文件
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -7,8 +7,8 @@ package field
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"reflect"
 	"sync"
 	"time"
@@ -16,7 +16,9 @@ import (
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
 	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+	larkdrive "github.com/larksuite/oapi-sdk-go/v3/service/drive/v1"
 
+	"github.com/imxw/miniokr/internal/pkg/bitable"
 	"github.com/imxw/miniokr/internal/pkg/bitable/token"
 	"github.com/imxw/miniokr/internal/pkg/log"
 )
@@ -25,8 +27,11 @@ type Manager struct {
 	Client        *lark.Client
 	AppToken      string
 	tokenProvider token.Provider
-	fieldCache    map[string][]Field
-	lastUpdate    time.Time
+	invoker       *bitable.Invoker
+	cache         Cache
+	schemas       map[string]interface{}
+	bindings      map[string]Binding
+	notifier      Notifier
 	mutex         sync.RWMutex
 }
 
@@ -38,6 +43,7 @@ type Option struct {
 type Field struct {
 	FieldID   string   `json:"field_id"`
 	FieldName string   `json:"field_name"`
+	Type      int      `json:"type,omitempty"`
 	Options   []Option `json:"options,omitempty"` // omitempty表示如果Options为空，则在JSON中省略该字段
 }
 
@@ -48,105 +54,148 @@ type MappingData struct {
 
 const UpdateInterval = 24 * time.Hour // 定义更新间隔
 
-// 根据表ID生成字段映射文件的路径
-func getFieldMappingFilePath(tableID string) string {
-	return "field_mapping_" + tableID + ".json"
-}
-
 func NewManager(client *lark.Client, appToken string, tokenProvider token.Provider) *Manager {
 	m := &Manager{
 		Client:        client,
 		AppToken:      appToken,
 		tokenProvider: tokenProvider,
-		fieldCache:    make(map[string][]Field, 0),
-		lastUpdate:    time.Time{},
+		invoker:       bitable.DefaultInvoker,
+		cache:         NewFileCache(""),
+		schemas:       make(map[string]interface{}),
+		bindings:      make(map[string]Binding),
 	}
 	return m
 }
 
-func (m *Manager) LoadOrRefreshFieldMapping(ctx context.Context, tableID string) {
+// RegisterSchema 登记表 tableID 期望的字段结构(带 field 标签的结构体)，加载和刷新字段映射时会进行校验
+func (m *Manager) RegisterSchema(tableID string, schema interface{}) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.schemas[tableID] = schema
+}
+
+// SetBinding 设置表 tableID 的字段绑定，用配置中的字段 ID 或字段名覆盖 field 标签
+func (m *Manager) SetBinding(tableID string, binding Binding) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bindings[tableID] = binding.normalize()
+}
+
+// Binding 返回表 tableID 的字段绑定
+func (m *Manager) Binding(tableID string) Binding {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.bindings[tableID]
+}
+
+// SetNotifier 设置表结构校验失败时的告警通道
+func (m *Manager) SetNotifier(n Notifier) {
+	m.notifier = n
+}
+
+// SetCache 设置字段映射的缓存，默认保存在当前目录下的文件中
+func (m *Manager) SetCache(cache Cache) {
+	m.cache = cache
+}
+
+// SetInvoker 设置调用飞书 API 时使用的限流和重试策略
+func (m *Manager) SetInvoker(invoker *bitable.Invoker) {
+	m.invoker = invoker
+}
+
+// LoadOrRefreshFieldMapping 在启动时加载字段映射并校验表结构，缓存不存在或过期时从 API 刷新.
+// 刷新失败或已登记的字段未能绑定时返回错误，后者为 *SchemaError
+func (m *Manager) LoadOrRefreshFieldMapping(ctx context.Context, tableID string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	filePath := getFieldMappingFilePath(tableID)
-	mappingData, err := LoadFieldMappingFromFile(filePath)
+	fields, ok := m.load(ctx, tableID)
+	if !ok {
+		var err error
+		if fields, err = m.refresh(ctx, tableID); err != nil {
+			log.C(ctx).Errorw("Error refreshing field mapping", "tableID", tableID, "error", err)
+			return err
+		}
+	}
+	return m.checkSchema(ctx, tableID, fields)
+}
+
+// load 从缓存中读取未过期的字段映射，调用方需持有锁
+func (m *Manager) load(ctx context.Context, tableID string) ([]Field, bool) {
+	data, err := m.cache.Load(ctx, tableID)
 	if err != nil {
-		if os.IsNotExist(err) {
-			m.RefreshAndSaveFieldMapping(ctx, tableID)
-		} else {
-			fmt.Printf("Error loading field mapping: %v\n", err)
+		if !errors.Is(err, ErrCacheMiss) {
+			log.C(ctx).Warnw("Error loading field mapping from cache", "tableID", tableID, "error", err)
 		}
-	} else if time.Since(time.Unix(mappingData.LastUpdate, 0)) > UpdateInterval {
-		m.RefreshAndSaveFieldMapping(ctx, tableID)
-	} else {
-		m.fieldCache[tableID] = mappingData.FieldMapping
-		m.lastUpdate = time.Unix(mappingData.LastUpdate, 0)
+		return nil, false
 	}
+	return data.FieldMapping, time.Since(time.Unix(data.LastUpdate, 0)) < UpdateInterval
 }
 
-// GetFieldMapping 从本地文件加载字段映射，如果不存在或过期则从API刷新
+// GetFieldMapping 从缓存中读取字段映射，如果不存在或过期则从API刷新
 func (m *Manager) GetFieldMapping(ctx context.Context, tableID string) ([]Field, error) {
 	m.mutex.RLock() // 对检查操作加读锁
-	// 检查内存中的缓存是否有效
-	if fields, ok := m.fieldCache[tableID]; ok && time.Since(m.lastUpdate) < UpdateInterval {
-		cacheCopy := make([]Field, len(fields))
-		copy(cacheCopy, fields) // 创建缓存的副本，避免外部修改
-		m.mutex.RUnlock()       // 读取完成，释放读锁
-		return cacheCopy, nil
+	fields, ok := m.load(ctx, tableID)
+	m.mutex.RUnlock()
+	if ok {
+		return fields, nil
 	}
-	m.mutex.RUnlock() // 读取完成，释放读锁
 
 	m.mutex.Lock() // 对更新操作加写锁
+	defer m.mutex.Unlock()
 	// 再次检查缓存，防止在获取锁的过程中缓存已被更新
-	if fields, ok := m.fieldCache[tableID]; ok && time.Since(m.lastUpdate) < UpdateInterval {
-		cacheCopy := make([]Field, len(fields))
-		copy(cacheCopy, fields)
-		m.mutex.Unlock()
-		return cacheCopy, nil
-	}
-
-	// 从文件中加载字段映射数据
-	filePath := getFieldMappingFilePath(tableID)
-	mappingData, err := LoadFieldMappingFromFile(filePath)
-	if err != nil || time.Since(time.Unix(mappingData.LastUpdate, 0)) > UpdateInterval {
-		m.mutex.Unlock() // 释放写锁前启动刷新
-		return m.RefreshAndSaveFieldMapping(ctx, tableID)
+	if fields, ok := m.load(ctx, tableID); ok {
+		return fields, nil
 	}
+	return m.refreshAndSave(ctx, tableID)
+}
 
-	// 更新内存缓存并返回结果
-	m.fieldCache[tableID] = mappingData.FieldMapping
-	m.lastUpdate = time.Unix(mappingData.LastUpdate, 0)
-	m.mutex.Unlock() // 更新完成，释放写锁
-	return mappingData.FieldMapping, nil
+// ListFields 直接从 API 获取表 tableID 的所有字段，不读写缓存
+func (m *Manager) ListFields(ctx context.Context, tableID string) ([]Field, error) {
+	return m.fieldMapping(ctx, tableID)
 }
 
 // fieldMapping 获取飞书多维表格中字段映射
 func (m *Manager) fieldMapping(ctx context.Context, tableID string) ([]Field, error) {
-	// Ensure a valid token is available
-	t, err := m.tokenProvider.EnsureValidToken(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error ensuring valid token: %v", err)
-	}
-
-	// Build the request with provided table ID and app token
-	req := larkbitable.NewListAppTableFieldReqBuilder().
-		AppToken(m.AppToken).
-		TableId(tableID).
-		Build()
-
-	// List fields using the bitable app table field API
-	resp, err := m.Client.Bitable.AppTableField.List(ctx, req, larkcore.WithTenantAccessToken(t))
-	if err != nil {
-		return nil, fmt.Errorf("failed to list fields: %v", err)
-	}
+	var items []*larkbitable.AppTableFieldForList
+	pageToken := ""
+	for {
+		// Build the request with provided table ID and app token
+		builder := larkbitable.NewListAppTableFieldReqBuilder().
+			AppToken(m.AppToken).
+			TableId(tableID).
+			PageSize(100)
+		if pageToken != "" {
+			builder.PageToken(pageToken)
+		}
+		req := builder.Build()
+
+		// List fields using the bitable app table field API
+		var resp *larkbitable.ListAppTableFieldResp
+		err := m.invoker.InvokeWithToken(ctx, m.AppToken, m.tokenProvider, func(ctx context.Context, t string) error {
+			var err error
+			resp, err = m.Client.Bitable.AppTableField.List(ctx, req, larkcore.WithTenantAccessToken(t))
+			if err != nil {
+				return err
+			}
+			return bitable.CheckResponse(resp.ApiResp, resp.CodeError)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list fields: %w", err)
+		}
+		if resp.Data == nil {
+			break
+		}
 
-	// Check the success of the response
-	if !resp.Success() {
-		return nil, fmt.Errorf("failed to list fields: %s", larkcore.Prettify(resp))
+		items = append(items, resp.Data.Items...)
+		if resp.Data.HasMore == nil || !*resp.Data.HasMore || resp.Data.PageToken == nil {
+			break
+		}
+		pageToken = *resp.Data.PageToken
 	}
 
 	var fields []Field
-	for _, item := range resp.Data.Items {
+	for _, item := range items {
 		// Skip computed fields
 		// if *item.Type == 19 || *item.Type == 20 {
 		// 	continue
@@ -158,6 +207,9 @@ func (m *Manager) fieldMapping(ctx context.Context, tableID string) ([]Field, er
 				FieldID:   *item.FieldId,
 				FieldName: *item.FieldName,
 			}
+			if item.Type != nil {
+				field.Type = *item.Type
+			}
 
 			// Handle options if they are present
 			if item.Property != nil && item.Property.Options != nil {
@@ -178,48 +230,139 @@ func (m *Manager) fieldMapping(ctx context.Context, tableID string) ([]Field, er
 	return fields, nil
 }
 
+// RefreshAndSaveFieldMapping 从 API 刷新表 tableID 的字段映射并保存到缓存，只影响该表的更新时间
 func (m *Manager) RefreshAndSaveFieldMapping(ctx context.Context, tableID string) ([]Field, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.refreshAndSave(ctx, tableID)
+}
+
+// refreshAndSave 刷新字段映射并校验表结构，表结构异常只告警不返回错误，调用方需持有写锁
+func (m *Manager) refreshAndSave(ctx context.Context, tableID string) ([]Field, error) {
+	fieldMapping, err := m.refresh(ctx, tableID)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = m.checkSchema(ctx, tableID, fieldMapping)
+
+	return fieldMapping, nil
+}
+
+// refresh 从 API 获取字段映射并保存到缓存，调用方需持有写锁
+func (m *Manager) refresh(ctx context.Context, tableID string) ([]Field, error) {
 	fieldMapping, err := m.fieldMapping(ctx, tableID)
 	if err != nil {
 		return nil, fmt.Errorf("error refreshing field mapping: %v", err)
 	}
 
-	// 保存字段映射到文件
+	// 缓存写入失败不影响本次返回，下次读取时重新拉取
 	mappingData := MappingData{
 		FieldMapping: fieldMapping,
 		LastUpdate:   time.Now().Unix(),
 	}
-	filePath := getFieldMappingFilePath(tableID)
-	if err = SaveFieldMappingToFile(mappingData, filePath); err != nil {
-		return nil, fmt.Errorf("error saving field mapping to file: %v", err)
+	if err = m.cache.Save(ctx, tableID, mappingData); err != nil {
+		log.C(ctx).Errorw("Error saving field mapping to cache", "tableID", tableID, "error", err)
 	}
 
-	// 更新内存缓存
-	m.fieldCache[tableID] = fieldMapping
-	m.lastUpdate = time.Now()
-
 	return fieldMapping, nil
 }
 
-// GetFriendlyFieldMapping 获取友好的字段映射
-func (m *Manager) GetFriendlyFieldMapping(ctx context.Context, tableID string, obj interface{}) error {
+// Invalidate 清除表 tableID 的字段映射缓存，下次读取时从 API 刷新
+func (m *Manager) Invalidate(ctx context.Context, tableID string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	fielding, err := m.GetFieldMapping(ctx, tableID)
+	if err := m.cache.Delete(ctx, tableID); err != nil {
+		log.C(ctx).Errorw("Failed to delete field mapping from cache", "tableID", tableID, "error", err)
+	}
+}
+
+// Subscribe 订阅多维表格的字段和记录变更事件，订阅后飞书才会向事件回调地址推送该多维表格的事件
+func (m *Manager) Subscribe(ctx context.Context) error {
+	req := larkdrive.NewSubscribeFileReqBuilder().
+		FileToken(m.AppToken).
+		FileType("bitable").
+		Build()
+
+	err := m.invoker.InvokeWithToken(ctx, m.AppToken, m.tokenProvider, func(ctx context.Context, t string) error {
+		resp, err := m.Client.Drive.File.Subscribe(ctx, req, larkcore.WithTenantAccessToken(t))
+		if err != nil {
+			return err
+		}
+		return bitable.CheckResponse(resp.ApiResp, resp.CodeError)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe bitable events: %w", err)
+	}
+	return nil
+}
+
+// ValidateSchema 使用当前的字段映射校验表 tableID 的结构，失败时记录日志并发送告警
+func (m *Manager) ValidateSchema(ctx context.Context, tableID string) error {
+	fields, err := m.GetFieldMapping(ctx, tableID)
 	if err != nil {
 		return err
 	}
 
-	var friendlyMapping = make(map[string]string, len(fielding))
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.checkSchema(ctx, tableID, fields)
+}
+
+// checkSchema 校验已登记的表结构，调用方需持有锁
+func (m *Manager) checkSchema(ctx context.Context, tableID string, fields []Field) error {
+	schema, ok := m.schemas[tableID]
+	if !ok {
+		return nil
+	}
+
+	err := ValidateSchema(tableID, fields, schema, m.bindings[tableID])
+	if err == nil {
+		return nil
+	}
 
-	for _, x := range fielding {
-		friendlyMapping[x.FieldID] = x.FieldName
+	log.C(ctx).Errorw("多维表格字段与期望不一致，请检查字段是否被删除或重建", "tableID", tableID, "error", err)
+	if m.notifier != nil {
+		if nerr := m.notifier.Send(fmt.Sprintf("【miniokr】多维表格结构异常: %v", err)); nerr != nil {
+			log.C(ctx).Errorw("Failed to send schema alert", "error", nerr)
+		}
+	}
+	return err
+}
+
+// GetFriendlyFieldMapping 获取友好的字段映射，按字段绑定将 obj 的每个字段填充为多维表格中的字段名.
+// 缺失的字段保持为空，读取记录时对应的值为空，写入记录前应使用 GetWritableFieldMapping
+func (m *Manager) GetFriendlyFieldMapping(ctx context.Context, tableID string, obj interface{}) error {
+	err := m.friendlyFieldMapping(ctx, tableID, obj)
+	var schemaErr *SchemaError
+	if errors.As(err, &schemaErr) {
+		// 表结构告警由加载和刷新时的校验负责
+		log.C(ctx).Warnw("Error mapping fields", "tableID", tableID, "error", err)
+		return nil
 	}
-	err = mapFields(obj, friendlyMapping)
+	return err
+}
+
+// GetWritableFieldMapping 与 GetFriendlyFieldMapping 相同，但有字段未能绑定时返回 *SchemaError，
+// 避免以空字段名向飞书写入记录
+func (m *Manager) GetWritableFieldMapping(ctx context.Context, tableID string, obj interface{}) error {
+	return m.friendlyFieldMapping(ctx, tableID, obj)
+}
+
+// friendlyFieldMapping 按字段绑定填充 obj，有字段缺失时返回 *SchemaError
+func (m *Manager) friendlyFieldMapping(ctx context.Context, tableID string, obj interface{}) error {
+	fielding, err := m.GetFieldMapping(ctx, tableID)
 	if err != nil {
-		log.C(ctx).Errorw("Error mapping fields", "error", err)
+		return err
 	}
 
-	return nil
+	err = mapFields(obj, fielding, m.Binding(tableID))
+	var schemaErr *SchemaError
+	if errors.As(err, &schemaErr) {
+		schemaErr.TableID = tableID
+	}
+	return err
 }
 
 // ExtractFieldOptions 根据字段名从字段映射中提取选项
@@ -243,21 +386,21 @@ func (m *Manager) ExtractFieldOptions(ctx context.Context, tableId, fieldName st
 	return nil, fmt.Errorf("field '%s' not found", fieldName)
 }
 
-// mapFields maps data from a map with field IDs as keys to the Objective struct using field tags.
-func mapFields(obj interface{}, data map[string]string) error {
-	t := reflect.TypeOf(obj).Elem()
+// mapFields 按字段绑定将 obj 的每个字段设置为对应的多维表格字段名.
+// 缺失的字段不会中断映射，最后以 *SchemaError 返回
+func mapFields(obj interface{}, fields []Field, binding Binding) error {
 	v := reflect.ValueOf(obj).Elem()
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		fieldID := field.Tag.Get("field")
-		if value, ok := data[fieldID]; ok {
-			v.Field(i).SetString(value) // Directly set the string since data is map[string]string
-		} else {
-			// Consider whether you want to report missing fields as an error
-			fmt.Printf("No data provided for field '%s' (field ID: '%s') - %+v\n", field.Name, fieldID, obj)
+	resolutions := Resolve(fields, obj, binding)
+	for _, r := range resolutions {
+		if r.Field != nil {
+			v.Field(r.index).SetString(r.Field.FieldName)
 		}
 	}
+
+	if missing := missingFields(resolutions); len(missing) > 0 {
+		return &SchemaError{Missing: missing}
+	}
 	return nil
 }
 
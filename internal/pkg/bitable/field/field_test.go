@@ -169,15 +169,9 @@ func TestFieldMap(t *testing.T) {
 		return
 	}
 
-	//var o1 = make(map[string]interface{}, len(OMapping))
-	var OfieldMapping = make(map[string]string, len(OMapping))
-	for _, x := range OMapping {
-		OfieldMapping[x.FieldID] = x.FieldName
-	}
-
 	var obj v1.ObjectiveField
 	//var obj KeyResult
-	err = mapFields(&obj, OfieldMapping)
+	err = mapFields(&obj, OMapping, nil)
 	if err != nil {
 		fmt.Println("Error mapping fields:", err)
 		return
@@ -0,0 +1,57 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Notifier 用于在表结构异常时发送告警，notify.Notifier 满足该接口
+type Notifier interface {
+	Send(message string) error
+}
+
+// MissingField 是期望存在、但在多维表格中找不到的字段
+type MissingField struct {
+	// Key 是结构体字段的 json 名
+	Key string
+	// Ref 是配置或 field 标签中声明的字段 ID 或字段名
+	Ref string
+}
+
+// SchemaError 表示多维表格的字段与期望的不一致，通常是字段在多维表格中被删除、重建或改名
+type SchemaError struct {
+	TableID string
+	Missing []MissingField
+}
+
+func (e *SchemaError) Error() string {
+	parts := make([]string, 0, len(e.Missing))
+	for _, f := range e.Missing {
+		parts = append(parts, fmt.Sprintf("%s(%s)", f.Key, f.Ref))
+	}
+	return fmt.Sprintf("table %s is missing required fields: %s", e.TableID, strings.Join(parts, ", "))
+}
+
+// ValidateSchema 检查 schema(带 field 标签的结构体或其指针) 的字段按 binding 是否都能在 fields 中找到，
+// 有缺失时返回 *SchemaError
+func ValidateSchema(tableID string, fields []Field, schema interface{}, binding Binding) error {
+	if missing := missingFields(Resolve(fields, schema, binding)); len(missing) > 0 {
+		return &SchemaError{TableID: tableID, Missing: missing}
+	}
+	return nil
+}
+
+func missingFields(resolutions []Resolution) []MissingField {
+	var missing []MissingField
+	for _, r := range resolutions {
+		if r.Field == nil {
+			missing = append(missing, MissingField{Key: r.Key, Ref: r.Ref})
+		}
+	}
+	return missing
+}
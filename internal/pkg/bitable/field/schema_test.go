@@ -0,0 +1,229 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable"
+	"github.com/imxw/miniokr/internal/pkg/retry"
+)
+
+type testSchema struct {
+	Title string `field:"fldTitle"`
+	Owner string `field:"fldOwner"`
+	Date  string `field:"fldDate"`
+}
+
+type staticTokenProvider string
+
+func (p staticTokenProvider) EnsureValidToken(context.Context) (string, error) {
+	return string(p), nil
+}
+
+type fakeNotifier struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (n *fakeNotifier) Send(message string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+// fakeFieldBitable 模拟列出字段接口，tables 为每张表当前的字段
+type fakeFieldBitable struct {
+	mu     sync.Mutex
+	tables map[string][]Field
+	calls  map[string]int
+}
+
+func (f *fakeFieldBitable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// /open-apis/bitable/v1/apps/:app_token/tables/:table_id/fields
+	parts := strings.Split(r.URL.Path, "/")
+	tableID := parts[len(parts)-2]
+	f.calls[tableID]++
+
+	items := make([]map[string]interface{}, 0)
+	for _, fd := range f.tables[tableID] {
+		items = append(items, map[string]interface{}{"field_id": fd.FieldID, "field_name": fd.FieldName})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "msg": "success", "data": map[string]interface{}{
+		"items": items, "has_more": false, "total": len(items),
+	}})
+}
+
+// newTestManager 在临时目录中运行，避免字段映射文件写入源码目录
+func newTestManager(t *testing.T, fake *fakeFieldBitable) *Manager {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client := lark.NewClient("cli_test", "secret", lark.WithOpenBaseUrl(srv.URL), lark.WithEnableTokenCache(false))
+	m := NewManager(client, "app-token", staticTokenProvider("t-test"))
+	m.SetInvoker(bitable.NewInvoker(0, 1, retry.Policy{MaxAttempts: 1}))
+	return m
+}
+
+func TestValidateSchema(t *testing.T) {
+	fields := []Field{{FieldID: "fldTitle"}, {FieldID: "fldOwner"}, {FieldID: "fldDate"}}
+	assert.NoError(t, ValidateSchema("tbl", fields, &testSchema{}, nil))
+	assert.NoError(t, ValidateSchema("tbl", fields, testSchema{}, nil))
+
+	err := ValidateSchema("tbl", fields[:1], &testSchema{}, nil)
+	var schemaErr *SchemaError
+	require.True(t, errors.As(err, &schemaErr))
+	assert.Equal(t, "tbl", schemaErr.TableID)
+	assert.Equal(t, []MissingField{{Key: "Owner", Ref: "fldOwner"}, {Key: "Date", Ref: "fldDate"}}, schemaErr.Missing)
+	assert.Contains(t, err.Error(), "Owner(fldOwner)")
+}
+
+func TestManager_PerTableFreshness(t *testing.T) {
+	fake := &fakeFieldBitable{
+		tables: map[string][]Field{
+			"tblO":  {{FieldID: "fldTitle", FieldName: "标题"}},
+			"tblKR": {{FieldID: "fldKR", FieldName: "KR"}},
+		},
+		calls: map[string]int{},
+	}
+	m := newTestManager(t, fake)
+	ctx := context.Background()
+
+	_, err := m.GetFieldMapping(ctx, "tblO")
+	require.NoError(t, err)
+
+	// 目标表的缓存过期
+	stale := MappingData{FieldMapping: fake.tables["tblO"], LastUpdate: time.Now().Add(-2 * UpdateInterval).Unix()}
+	require.NoError(t, m.cache.Save(ctx, "tblO", stale))
+
+	// 刷新 KR 表不影响目标表的新鲜度
+	_, err = m.RefreshAndSaveFieldMapping(ctx, "tblKR")
+	require.NoError(t, err)
+	_, err = m.GetFieldMapping(ctx, "tblO")
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, fake.calls["tblO"])
+	assert.Equal(t, 1, fake.calls["tblKR"])
+
+	// 两张表都已刷新，再次读取走缓存
+	_, err = m.GetFieldMapping(ctx, "tblO")
+	require.NoError(t, err)
+	_, err = m.GetFieldMapping(ctx, "tblKR")
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.calls["tblO"])
+	assert.Equal(t, 1, fake.calls["tblKR"])
+}
+
+func TestManager_Invalidate(t *testing.T) {
+	fake := &fakeFieldBitable{
+		tables: map[string][]Field{"tblO": {{FieldID: "fldDate", FieldName: "考核月份"}}},
+		calls:  map[string]int{},
+	}
+	m := newTestManager(t, fake)
+	ctx := context.Background()
+
+	_, err := m.GetFieldMapping(ctx, "tblO")
+	require.NoError(t, err)
+
+	// 收到字段变更事件后，即使缓存未过期也重新拉取
+	fake.tables["tblO"] = append(fake.tables["tblO"], Field{FieldID: "fldNew", FieldName: "新字段"})
+	m.Invalidate(ctx, "tblO")
+	_, err = m.cache.Load(ctx, "tblO")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	fields, err := m.GetFieldMapping(ctx, "tblO")
+	require.NoError(t, err)
+	assert.Len(t, fields, 2)
+	assert.Equal(t, 2, fake.calls["tblO"])
+}
+
+func TestManager_SchemaDriftNotifies(t *testing.T) {
+	fake := &fakeFieldBitable{
+		tables: map[string][]Field{
+			"tblO": {{FieldID: "fldTitle", FieldName: "标题"}, {FieldID: "fldOwner", FieldName: "员工姓名"}, {FieldID: "fldDate", FieldName: "考核月份"}},
+		},
+		calls: map[string]int{},
+	}
+	m := newTestManager(t, fake)
+	notifier := &fakeNotifier{}
+	m.SetNotifier(notifier)
+	m.RegisterSchema("tblO", &testSchema{})
+	ctx := context.Background()
+
+	// 启动时结构正常
+	require.NoError(t, m.LoadOrRefreshFieldMapping(ctx, "tblO"))
+	assert.NoError(t, m.ValidateSchema(ctx, "tblO"))
+	assert.Empty(t, notifier.messages)
+
+	// 考核月份字段被删除后重建，字段 ID 变化
+	fake.tables["tblO"][2] = Field{FieldID: "fldDateNew", FieldName: "考核月份"}
+	_, err := m.RefreshAndSaveFieldMapping(ctx, "tblO")
+	require.NoError(t, err)
+
+	require.Len(t, notifier.messages, 1)
+	assert.Contains(t, notifier.messages[0], "Date(fldDate)")
+
+	var schemaErr *SchemaError
+	assert.True(t, errors.As(m.ValidateSchema(ctx, "tblO"), &schemaErr))
+	assert.Len(t, notifier.messages, 2)
+}
+
+func TestManager_UnboundFieldFailsEarly(t *testing.T) {
+	fake := &fakeFieldBitable{
+		tables: map[string][]Field{
+			"tblO": {{FieldID: "fldTitle", FieldName: "标题"}, {FieldID: "fldOwner", FieldName: "员工姓名"}},
+		},
+		calls: map[string]int{},
+	}
+	m := newTestManager(t, fake)
+	m.RegisterSchema("tblO", &testSchema{})
+	ctx := context.Background()
+
+	// 启动时返回表结构错误
+	var schemaErr *SchemaError
+	require.ErrorAs(t, m.LoadOrRefreshFieldMapping(ctx, "tblO"), &schemaErr)
+	assert.Equal(t, "tblO", schemaErr.TableID)
+
+	// 读取时缺失的字段保持为空，写入前拒绝以空字段名写入
+	var obj testSchema
+	require.NoError(t, m.GetFriendlyFieldMapping(ctx, "tblO", &obj))
+	assert.Empty(t, obj.Date)
+	require.ErrorAs(t, m.GetWritableFieldMapping(ctx, "tblO", &obj), &schemaErr)
+	assert.Equal(t, []MissingField{{Key: "Date", Ref: "fldDate"}}, schemaErr.Missing)
+}
+
+func TestMapFields_ReportsMissing(t *testing.T) {
+	var obj testSchema
+	err := mapFields(&obj, []Field{{FieldID: "fldTitle", FieldName: "标题"}, {FieldID: "fldOwner", FieldName: "员工姓名"}}, nil)
+
+	var schemaErr *SchemaError
+	require.True(t, errors.As(err, &schemaErr))
+	assert.Equal(t, []MissingField{{Key: "Date", Ref: "fldDate"}}, schemaErr.Missing)
+	assert.Equal(t, testSchema{Title: "标题", Owner: "员工姓名"}, obj)
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable"
+)
+
+// Spec 描述创建或更新字段时的字段定义
+type Spec struct {
+	Name string
+	Type int
+	// Options 是单选、多选字段的选项，更新字段时需要带上已有选项的 ID，否则已有选项会被替换
+	Options []Option
+	// Formatter 是数字字段的显示格式，例如 "0%"
+	Formatter string
+	// LinkTableID 是关联字段关联的数据表
+	LinkTableID string
+	// BackFieldName 是双向关联字段在关联表中对应字段的名字
+	BackFieldName string
+	// Multiple 表示关联字段允许关联多条记录
+	Multiple bool
+}
+
+// property 生成字段属性，不需要属性的字段返回 nil
+func (s Spec) property() *larkbitable.AppTableFieldProperty {
+	if len(s.Options) == 0 && s.Formatter == "" && s.LinkTableID == "" {
+		return nil
+	}
+
+	builder := larkbitable.NewAppTableFieldPropertyBuilder()
+	if len(s.Options) > 0 {
+		options := make([]*larkbitable.AppTableFieldPropertyOption, 0, len(s.Options))
+		for _, opt := range s.Options {
+			ob := larkbitable.NewAppTableFieldPropertyOptionBuilder().Name(opt.Name)
+			if opt.ID != "" {
+				ob.Id(opt.ID)
+			}
+			options = append(options, ob.Build())
+		}
+		builder.Options(options)
+	}
+	if s.Formatter != "" {
+		builder.Formatter(s.Formatter)
+	}
+	if s.LinkTableID != "" {
+		builder.TableId(s.LinkTableID).Multiple(s.Multiple)
+		if s.BackFieldName != "" {
+			builder.BackFieldName(s.BackFieldName)
+		}
+	}
+	return builder.Build()
+}
+
+// Header 生成创建数据表时使用的初始字段定义
+func (s Spec) Header() *larkbitable.AppTableCreateHeader {
+	builder := larkbitable.NewAppTableCreateHeaderBuilder().FieldName(s.Name).Type(s.Type)
+	if p := s.property(); p != nil {
+		builder.Property(p)
+	}
+	return builder.Build()
+}
+
+func (s Spec) field() *larkbitable.AppTableField {
+	builder := larkbitable.NewAppTableFieldBuilder().FieldName(s.Name).Type(s.Type)
+	if p := s.property(); p != nil {
+		builder.Property(p)
+	}
+	return builder.Build()
+}
+
+// CreateField 在表 tableID 中创建字段
+func (m *Manager) CreateField(ctx context.Context, tableID string, spec Spec) (Field, error) {
+	req := larkbitable.NewCreateAppTableFieldReqBuilder().
+		AppToken(m.AppToken).
+		TableId(tableID).
+		AppTableField(spec.field()).
+		Build()
+
+	var resp *larkbitable.CreateAppTableFieldResp
+	err := m.invoker.InvokeWithToken(ctx, m.AppToken, m.tokenProvider, func(ctx context.Context, t string) error {
+		var err error
+		resp, err = m.Client.Bitable.AppTableField.Create(ctx, req, larkcore.WithTenantAccessToken(t))
+		if err != nil {
+			return err
+		}
+		return bitable.CheckResponse(resp.ApiResp, resp.CodeError)
+	})
+	if err != nil {
+		return Field{}, fmt.Errorf("failed to create field %s: %w", spec.Name, err)
+	}
+	if resp.Data == nil || resp.Data.Field == nil {
+		return Field{}, errors.New("failed to create field: empty response")
+	}
+
+	return toField(resp.Data.Field), nil
+}
+
+// UpdateField 更新表 tableID 中的字段，飞书会用 spec 整体替换字段定义
+func (m *Manager) UpdateField(ctx context.Context, tableID, fieldID string, spec Spec) (Field, error) {
+	req := larkbitable.NewUpdateAppTableFieldReqBuilder().
+		AppToken(m.AppToken).
+		TableId(tableID).
+		FieldId(fieldID).
+		AppTableField(spec.field()).
+		Build()
+
+	var resp *larkbitable.UpdateAppTableFieldResp
+	err := m.invoker.InvokeWithToken(ctx, m.AppToken, m.tokenProvider, func(ctx context.Context, t string) error {
+		var err error
+		resp, err = m.Client.Bitable.AppTableField.Update(ctx, req, larkcore.WithTenantAccessToken(t))
+		if err != nil {
+			return err
+		}
+		return bitable.CheckResponse(resp.ApiResp, resp.CodeError)
+	})
+	if err != nil {
+		return Field{}, fmt.Errorf("failed to update field %s: %w", spec.Name, err)
+	}
+	if resp.Data == nil || resp.Data.Field == nil {
+		return Field{}, errors.New("failed to update field: empty response")
+	}
+
+	return toField(resp.Data.Field), nil
+}
+
+// toField 转换飞书返回的字段，缺少的值保持为零值
+func toField(item *larkbitable.AppTableField) Field {
+	var f Field
+	if item.FieldId != nil {
+		f.FieldID = *item.FieldId
+	}
+	if item.FieldName != nil {
+		f.FieldName = *item.FieldName
+	}
+	if item.Type != nil {
+		f.Type = *item.Type
+	}
+	if item.Property != nil {
+		for _, opt := range item.Property.Options {
+			if opt.Id != nil && opt.Name != nil {
+				f.Options = append(f.Options, Option{ID: *opt.Id, Name: *opt.Name})
+			}
+		}
+	}
+	return f
+}
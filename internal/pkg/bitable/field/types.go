@@ -0,0 +1,65 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package field
+
+import "strconv"
+
+// 飞书多维表格的字段类型
+const (
+	TypeText       = 1
+	TypeNumber     = 2
+	TypeSelect     = 3
+	TypeMultiple   = 4
+	TypeDate       = 5
+	TypeCheckbox   = 7
+	TypeUser       = 11
+	TypePhone      = 13
+	TypeURL        = 15
+	TypeAttachment = 17
+	TypeLink       = 18
+	TypeLookup     = 19
+	TypeFormula    = 20
+	TypeDuplexLink = 21
+	TypeLocation   = 22
+	TypeGroupChat  = 23
+	TypeCreatedAt  = 1001
+	TypeModifiedAt = 1002
+	TypeCreatedBy  = 1003
+	TypeModifiedBy = 1004
+	TypeAutoNumber = 1005
+)
+
+var typeNames = map[int]string{
+	TypeText:       "多行文本",
+	TypeNumber:     "数字",
+	TypeSelect:     "单选",
+	TypeMultiple:   "多选",
+	TypeDate:       "日期",
+	TypeCheckbox:   "复选框",
+	TypeUser:       "人员",
+	TypePhone:      "电话号码",
+	TypeURL:        "超链接",
+	TypeAttachment: "附件",
+	TypeLink:       "单向关联",
+	TypeLookup:     "查找引用",
+	TypeFormula:    "公式",
+	TypeDuplexLink: "双向关联",
+	TypeLocation:   "地理位置",
+	TypeGroupChat:  "群组",
+	TypeCreatedAt:  "创建时间",
+	TypeModifiedAt: "最后更新时间",
+	TypeCreatedBy:  "创建人",
+	TypeModifiedBy: "修改人",
+	TypeAutoNumber: "自动编号",
+}
+
+// TypeName 返回字段类型的中文名称，未知类型返回类型编号
+func TypeName(t int) string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return strconv.Itoa(t)
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package bitable
+
+import (
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+)
+
+// 飞书多维表格查询记录接口支持的条件运算符
+const (
+	OpIs             = "is"
+	OpIsNot          = "isNot"
+	OpContains       = "contains"
+	OpDoesNotContain = "doesNotContain"
+	OpIsEmpty        = "isEmpty"
+	OpIsNotEmpty     = "isNotEmpty"
+	OpIsGreater      = "isGreater"
+	OpIsGreaterEqual = "isGreaterEqual"
+	OpIsLess         = "isLess"
+	OpIsLessEqual    = "isLessEqual"
+)
+
+// 条件之间的逻辑连接词
+const (
+	ConjunctionAnd = "and"
+	ConjunctionOr  = "or"
+)
+
+// Filter 用于构造查询记录时的筛选条件.
+// 一个 Filter 是由同一个连接词(and/or)组合起来的一组条件和子筛选组，
+// 例如 And(Cond("员工姓名", OpIs, "张三"), Cond("考核月份", OpContains, "2024年5月", "2024年6月")).
+// 子筛选组内的条件使用子组自己的连接词，例如 (A and B) or C 为 Or(C).Group(AllOf(A, B))
+type Filter struct {
+	conjunction string
+	conditions  []*larkbitable.Condition
+	children    []*larkbitable.ChildrenFilter
+}
+
+// FilterInfo 是查询记录接口的筛选条件.
+// SDK 中的 larkbitable.FilterInfo 没有 children 字段，无法表达子筛选组，查询时使用该类型
+type FilterInfo struct {
+	Conjunction *string                       `json:"conjunction,omitempty"`
+	Conditions  []*larkbitable.Condition      `json:"conditions,omitempty"`
+	Children    []*larkbitable.ChildrenFilter `json:"children,omitempty"`
+}
+
+// Cond 创建一个筛选条件，isEmpty/isNotEmpty 等运算符不需要传入 values
+func Cond(fieldName, operator string, values ...string) *larkbitable.Condition {
+	builder := larkbitable.NewConditionBuilder().FieldName(fieldName).Operator(operator)
+	if len(values) > 0 {
+		builder.Value(values)
+	}
+	return builder.Build()
+}
+
+// And 创建一个所有条件都需满足的筛选组
+func And(conditions ...*larkbitable.Condition) *Filter {
+	return &Filter{conjunction: ConjunctionAnd, conditions: conditions}
+}
+
+// Or 创建一个满足任一条件即可的筛选组
+func Or(conditions ...*larkbitable.Condition) *Filter {
+	return &Filter{conjunction: ConjunctionOr, conditions: conditions}
+}
+
+// AllOf 创建一个所有条件都需满足的子筛选组，飞书只支持一层嵌套，子组中只能包含条件
+func AllOf(conditions ...*larkbitable.Condition) *larkbitable.ChildrenFilter {
+	return childrenFilter(ConjunctionAnd, conditions)
+}
+
+// AnyOf 创建一个满足任一条件即可的子筛选组
+func AnyOf(conditions ...*larkbitable.Condition) *larkbitable.ChildrenFilter {
+	return childrenFilter(ConjunctionOr, conditions)
+}
+
+// childrenFilter 创建子筛选组，nil 条件会被忽略，没有任何条件时返回 nil
+func childrenFilter(conjunction string, conditions []*larkbitable.Condition) *larkbitable.ChildrenFilter {
+	var valid []*larkbitable.Condition
+	for _, c := range conditions {
+		if c != nil {
+			valid = append(valid, c)
+		}
+	}
+	if len(valid) == 0 {
+		return nil
+	}
+	return larkbitable.NewChildrenFilterBuilder().Conjunction(conjunction).Conditions(valid).Build()
+}
+
+// Group 向筛选组中追加子筛选组，子组之间以及子组与条件之间使用筛选组的连接词，nil 子组会被忽略
+func (f *Filter) Group(children ...*larkbitable.ChildrenFilter) *Filter {
+	for _, c := range children {
+		if c != nil {
+			f.children = append(f.children, c)
+		}
+	}
+	return f
+}
+
+// Where 向筛选组中追加条件，nil 条件会被忽略，便于按需拼接
+func (f *Filter) Where(conditions ...*larkbitable.Condition) *Filter {
+	for _, c := range conditions {
+		if c != nil {
+			f.conditions = append(f.conditions, c)
+		}
+	}
+	return f
+}
+
+// Len 返回筛选组中的条件和子筛选组数量
+func (f *Filter) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.conditions) + len(f.children)
+}
+
+// Build 生成查询接口使用的 FilterInfo，没有任何条件时返回 nil 表示不过滤
+func (f *Filter) Build() *FilterInfo {
+	if f.Len() == 0 {
+		return nil
+	}
+	conjunction := f.conjunction
+	return &FilterInfo{
+		Conjunction: &conjunction,
+		Conditions:  f.conditions,
+		Children:    f.children,
+	}
+}
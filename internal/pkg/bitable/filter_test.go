@@ -0,0 +1,72 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package bitable
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_Build(t *testing.T) {
+	filter := And(Cond("员工姓名", OpIs, "张三")).
+		Where(Cond("考核月份", OpContains, "2024年5月", "2024年6月"), nil)
+
+	data, err := json.Marshal(filter.Build())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"conjunction": "and",
+		"conditions": [
+			{"field_name": "员工姓名", "operator": "is", "value": ["张三"]},
+			{"field_name": "考核月份", "operator": "contains", "value": ["2024年5月", "2024年6月"]}
+		]
+	}`, string(data))
+}
+
+func TestFilter_OrAndEmptyValues(t *testing.T) {
+	data, err := json.Marshal(Or(Cond("上级评分", OpIsEmpty), Cond("完成情况", OpIsNot, "已完成")).Build())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"conjunction": "or",
+		"conditions": [
+			{"field_name": "上级评分", "operator": "isEmpty"},
+			{"field_name": "完成情况", "operator": "isNot", "value": ["已完成"]}
+		]
+	}`, string(data))
+}
+
+func TestFilter_NestedGroups(t *testing.T) {
+	// (员工姓名 = 张三 and 考核月份包含 2024年5月) or 上级评分为空
+	filter := Or(Cond("上级评分", OpIsEmpty)).
+		Group(AllOf(Cond("员工姓名", OpIs, "张三"), Cond("考核月份", OpContains, "2024年5月")), AnyOf(), nil)
+	assert.Equal(t, 2, filter.Len())
+
+	data, err := json.Marshal(filter.Build())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"conjunction": "or",
+		"conditions": [
+			{"field_name": "上级评分", "operator": "isEmpty"}
+		],
+		"children": [
+			{
+				"conjunction": "and",
+				"conditions": [
+					{"field_name": "员工姓名", "operator": "is", "value": ["张三"]},
+					{"field_name": "考核月份", "operator": "contains", "value": ["2024年5月"]}
+				]
+			}
+		]
+	}`, string(data))
+}
+
+func TestFilter_EmptyBuildsNil(t *testing.T) {
+	assert.Nil(t, And().Build())
+	var f *Filter
+	assert.Nil(t, f.Build())
+}
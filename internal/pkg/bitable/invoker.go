@@ -0,0 +1,160 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package bitable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable/token"
+	"github.com/imxw/miniokr/internal/pkg/log"
+	"github.com/imxw/miniokr/internal/pkg/retry"
+)
+
+const (
+	// DefaultQPS 是每个 app token 默认的请求速率，飞书多维表格接口单应用的频率上限约为 10~20 QPS
+	DefaultQPS = 10
+	// DefaultBurst 是令牌桶默认的容量
+	DefaultBurst = 10
+)
+
+// retryableCodes 是飞书开放平台中可以重试的错误码
+var retryableCodes = map[int]bool{
+	99991400: true, // 应用频率限制
+	1254290:  true, // TooManyRequest
+	1254291:  true, // Write conflict
+	1254607:  true, // Data not ready
+	1255001:  true, // InternalError
+	1255002:  true, // RpcError
+	1255040:  true, // 请求超时
+}
+
+// invalidTokenCodes 是 tenant_access_token 无效或已被吊销时返回的错误码
+var invalidTokenCodes = map[int]bool{
+	99991663: true, // Invalid access token
+	99991668: true, // Invalid token
+}
+
+// APIError 是飞书开放平台返回的业务错误
+type APIError struct {
+	StatusCode int
+	Code       int
+	Msg        string
+	RequestID  string
+}
+
+func newAPIError(resp *larkcore.ApiResp, codeErr larkcore.CodeError) *APIError {
+	e := &APIError{Code: codeErr.Code, Msg: codeErr.Msg}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+		e.RequestID = resp.RequestId()
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("code=%d, msg=%s, requestId=%s", e.Code, e.Msg, e.RequestID)
+}
+
+// IsRetryable 判断飞书 API 调用的错误是否可以重试：限流、服务端错误和网络错误可以重试
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return retryableCodes[apiErr.Code] ||
+			apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// IsInvalidToken 判断错误是否由 tenant_access_token 无效引起
+func IsInvalidToken(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && invalidTokenCodes[apiErr.Code]
+}
+
+// recordNotFoundCode 是记录不存在时返回的错误码 RecordIdNotFound
+const recordNotFoundCode = 1254043
+
+// IsRecordNotFound 判断错误是否由记录不存在引起
+func IsRecordNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == recordNotFoundCode
+}
+
+// Invoker 为飞书 OpenAPI 调用提供按 app token 的限流，以及可重试错误的指数退避重试
+type Invoker struct {
+	limiter *retry.KeyedLimiter
+	policy  retry.Policy
+}
+
+// NewInvoker 创建一个 Invoker，policy.Retryable 为空时使用 IsRetryable
+func NewInvoker(qps float64, burst int, policy retry.Policy) *Invoker {
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+	return &Invoker{
+		limiter: retry.NewKeyedLimiter(qps, burst),
+		policy:  policy,
+	}
+}
+
+// DefaultInvoker 是未单独设置 Invoker 时使用的默认实例，同一进程内的调用共享限流额度
+var DefaultInvoker = NewInvoker(DefaultQPS, DefaultBurst, retry.DefaultPolicy())
+
+// Invoke 在 appToken 对应的令牌桶限流下执行 fn，失败时按重试策略重试
+func (i *Invoker) Invoke(ctx context.Context, appToken string, fn func(ctx context.Context) error) error {
+	return retry.Do(ctx, i.policy, func(ctx context.Context) error {
+		if err := i.limiter.Wait(ctx, appToken); err != nil {
+			return err
+		}
+		return fn(ctx)
+	})
+}
+
+// InvokeWithToken 从 tp 获取 tenant_access_token 后调用 Invoke.
+// 如果飞书提示令牌无效且 tp 支持强制刷新，刷新令牌后再重试一次
+func (i *Invoker) InvokeWithToken(ctx context.Context, appToken string, tp token.Provider, fn func(ctx context.Context, token string) error) error {
+	t, err := tp.EnsureValidToken(ctx)
+	if err != nil {
+		log.C(ctx).Errorw("获取token失败", "error", err)
+		return fmt.Errorf("获取token失败: %w", err)
+	}
+
+	call := func(ctx context.Context) error { return fn(ctx, t) }
+	err = i.Invoke(ctx, appToken, call)
+
+	refresher, ok := tp.(token.Refresher)
+	if !ok || !IsInvalidToken(err) {
+		return err
+	}
+
+	log.C(ctx).Warnw("token 已失效，强制刷新后重试", "error", err)
+	if t, err = refresher.ForceRefresh(ctx, t); err != nil {
+		log.C(ctx).Errorw("强制刷新token失败", "error", err)
+		return fmt.Errorf("获取token失败: %w", err)
+	}
+	return i.Invoke(ctx, appToken, call)
+}
+
+// CheckResponse 将飞书接口返回的业务错误转换为 *APIError，成功时返回 nil
+func CheckResponse(resp *larkcore.ApiResp, codeErr larkcore.CodeError) error {
+	if codeErr.Code == 0 {
+		return nil
+	}
+	return newAPIError(resp, codeErr)
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package bitable
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imxw/miniokr/internal/pkg/retry"
+)
+
+// newTestInvoker 返回一个不限流、不等待的 Invoker，避免测试变慢
+func newTestInvoker() *Invoker {
+	return NewInvoker(0, 1, retry.Policy{MaxAttempts: 3})
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(&APIError{Code: 1254290}))
+	assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", &APIError{Code: 99991400})))
+	assert.True(t, IsRetryable(&APIError{StatusCode: http.StatusBadGateway, Code: 1}))
+	assert.True(t, IsRetryable(&APIError{StatusCode: http.StatusTooManyRequests, Code: 1}))
+	assert.False(t, IsRetryable(&APIError{StatusCode: http.StatusOK, Code: 1254045}))
+	assert.False(t, IsRetryable(context.Canceled))
+	assert.False(t, IsRetryable(errors.New("boom")))
+	assert.False(t, IsRetryable(nil))
+}
+
+// newFlakyServer 前 failures 次请求返回 code，之后返回一条记录的查询结果
+func newFlakyServer(t *testing.T, failures int32, status, code int) (*RecordManager, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		if n <= failures {
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": "TooManyRequest"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "msg": "success", "data": map[string]interface{}{
+			"items":    []map[string]interface{}{{"record_id": "rec1", "fields": map[string]interface{}{}}},
+			"has_more": false,
+		}})
+	}))
+	t.Cleanup(srv.Close)
+
+	client := lark.NewClient("cli_test", "secret", lark.WithOpenBaseUrl(srv.URL), lark.WithEnableTokenCache(false))
+	rm := NewRecordManager(client, "app-token", staticTokenProvider("t-test"))
+	rm.SetInvoker(newTestInvoker())
+	return rm, &calls
+}
+
+func TestRecordManager_RetriesRateLimit(t *testing.T) {
+	rm, calls := newFlakyServer(t, 2, http.StatusOK, 1254290)
+
+	records, err := rm.SearchRecord(context.Background(), "tbl1", nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestRecordManager_GivesUpAfterMaxAttempts(t *testing.T) {
+	rm, calls := newFlakyServer(t, 5, http.StatusServiceUnavailable, 1255001)
+
+	_, err := rm.SearchRecord(context.Background(), "tbl1", nil, nil)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 1255001, apiErr.Code)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestRecordManager_DoesNotRetryClientErrors(t *testing.T) {
+	rm, calls := newFlakyServer(t, 5, http.StatusOK, 1254045)
+
+	err := rm.UpdateRecord(context.Background(), "tbl1", "rec1", map[string]interface{}{"标题": "x"})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+// refreshingTokenProvider 记录强制刷新的次数，每次刷新返回新令牌
+type refreshingTokenProvider struct {
+	mu        sync.Mutex
+	token     string
+	refreshes int
+}
+
+func (p *refreshingTokenProvider) EnsureValidToken(context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.token, nil
+}
+
+func (p *refreshingTokenProvider) ForceRefresh(_ context.Context, stale string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token == stale {
+		p.refreshes++
+		p.token = fmt.Sprintf("t-new-%d", p.refreshes)
+	}
+	return p.token, nil
+}
+
+// newInvalidTokenServer 只接受 validToken，其余令牌返回 code
+func newInvalidTokenServer(t *testing.T, validToken string, code int) (*httptest.Server, *[]string) {
+	var (
+		mu     sync.Mutex
+		tokens []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tok := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		mu.Lock()
+		tokens = append(tokens, tok)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if tok != validToken {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": "Invalid access token for authorization."})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "msg": "success", "data": map[string]interface{}{}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &tokens
+}
+
+func TestRecordManager_RefreshesInvalidToken(t *testing.T) {
+	for _, code := range []int{99991663, 99991668} {
+		t.Run(fmt.Sprint(code), func(t *testing.T) {
+			srv, tokens := newInvalidTokenServer(t, "t-new-1", code)
+			tp := &refreshingTokenProvider{token: "t-revoked"}
+
+			client := lark.NewClient("cli_test", "secret", lark.WithOpenBaseUrl(srv.URL), lark.WithEnableTokenCache(false))
+			rm := NewRecordManager(client, "app-token", tp)
+			rm.SetInvoker(newTestInvoker())
+
+			err := rm.UpdateRecord(context.Background(), "tbl1", "rec1", map[string]interface{}{"标题": "x"})
+			require.NoError(t, err)
+			assert.Equal(t, 1, tp.refreshes)
+			assert.Equal(t, []string{"t-revoked", "t-new-1"}, *tokens)
+		})
+	}
+}
+
+func TestRecordManager_RetriesInvalidTokenOnce(t *testing.T) {
+	srv, tokens := newInvalidTokenServer(t, "never-valid", 99991663)
+	tp := &refreshingTokenProvider{token: "t-revoked"}
+
+	client := lark.NewClient("cli_test", "secret", lark.WithOpenBaseUrl(srv.URL), lark.WithEnableTokenCache(false))
+	rm := NewRecordManager(client, "app-token", tp)
+	rm.SetInvoker(newTestInvoker())
+
+	err := rm.DeleteRecord(context.Background(), "tbl1", "rec1")
+	assert.True(t, IsInvalidToken(err))
+	assert.Equal(t, 1, tp.refreshes)
+	assert.Len(t, *tokens, 2)
+}
+
+func TestRecordManager_InvalidTokenWithoutRefresher(t *testing.T) {
+	srv, tokens := newInvalidTokenServer(t, "never-valid", 99991663)
+
+	client := lark.NewClient("cli_test", "secret", lark.WithOpenBaseUrl(srv.URL), lark.WithEnableTokenCache(false))
+	rm := NewRecordManager(client, "app-token", staticTokenProvider("t-test"))
+	rm.SetInvoker(newTestInvoker())
+
+	err := rm.DeleteRecord(context.Background(), "tbl1", "rec1")
+	assert.True(t, IsInvalidToken(err))
+	assert.Len(t, *tokens, 1)
+}
@@ -0,0 +1,185 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package bitable
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+
+	"github.com/imxw/miniokr/internal/pkg/log"
+)
+
+const (
+	// DefaultSearchPageSize 是查询记录时默认的分页大小
+	DefaultSearchPageSize = 100
+	// MaxSearchPageSize 是飞书查询记录接口允许的最大分页大小
+	MaxSearchPageSize = 500
+)
+
+// searchRecordPath 是飞书查询记录接口的路径
+const searchRecordPath = "/open-apis/bitable/v1/apps/:app_token/tables/:table_id/records/search"
+
+// searchRecordBody 是查询记录接口的请求体
+type searchRecordBody struct {
+	FieldNames      []string    `json:"field_names,omitempty"`
+	Filter          *FilterInfo `json:"filter,omitempty"`
+	AutomaticFields bool        `json:"automatic_fields"`
+}
+
+// ErrMaxPagesExceeded 表示查询结果超出了允许的最大页数，返回部分数据会导致静默丢失，因此直接报错
+var ErrMaxPagesExceeded = errors.New("查询结果超出最大页数限制")
+
+type searchOptions struct {
+	pageSize int
+	maxPages int // 0 表示不限制
+}
+
+// SearchOption 用于配置记录查询的分页行为
+type SearchOption func(*searchOptions)
+
+// WithPageSize 设置每页记录数，取值范围为 1~500
+func WithPageSize(size int) SearchOption {
+	return func(o *searchOptions) {
+		if size > 0 && size <= MaxSearchPageSize {
+			o.pageSize = size
+		}
+	}
+}
+
+// WithMaxPages 设置最多查询的页数，0 表示不限制
+func WithMaxPages(n int) SearchOption {
+	return func(o *searchOptions) {
+		if n >= 0 {
+			o.maxPages = n
+		}
+	}
+}
+
+// SetSearchOptions 设置 SearchRecord 的默认分页参数
+func (r *RecordManager) SetSearchOptions(opts ...SearchOption) {
+	r.searchOpts = opts
+}
+
+// RecordIterator 按页遍历查询结果
+type RecordIterator struct {
+	r          *RecordManager
+	tableID    string
+	fieldNames []string
+	filter     *FilterInfo
+	opts       searchOptions
+
+	pageToken string
+	pages     int
+	done      bool
+}
+
+// SearchRecordIterator 返回一个按页遍历查询结果的迭代器，
+// opts 会覆盖通过 SetSearchOptions 设置的默认值
+func (r *RecordManager) SearchRecordIterator(tableID string, fieldNames []string, filter *FilterInfo, opts ...SearchOption) *RecordIterator {
+	o := searchOptions{pageSize: DefaultSearchPageSize}
+	for _, opt := range r.searchOpts {
+		opt(&o)
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &RecordIterator{
+		r:          r,
+		tableID:    tableID,
+		fieldNames: fieldNames,
+		filter:     filter,
+		opts:       o,
+	}
+}
+
+// HasNext 判断是否还有下一页
+func (it *RecordIterator) HasNext() bool {
+	return !it.done
+}
+
+// Next 返回下一页记录，已达到最大页数但仍有数据时返回 ErrMaxPagesExceeded
+func (it *RecordIterator) Next(ctx context.Context) ([]*larkbitable.AppTableRecord, error) {
+	if it.done {
+		return nil, nil
+	}
+	if it.opts.maxPages > 0 && it.pages >= it.opts.maxPages {
+		it.done = true
+		log.C(ctx).Errorw("查询结果超出最大页数限制", "tableID", it.tableID, "maxPages", it.opts.maxPages)
+		return nil, fmt.Errorf("%w: tableID=%s, maxPages=%d", ErrMaxPagesExceeded, it.tableID, it.opts.maxPages)
+	}
+
+	// SDK 的请求体不支持子筛选组，按查询记录接口的格式自行构造请求
+	req := &larkcore.ApiReq{
+		HttpMethod:  http.MethodPost,
+		ApiPath:     searchRecordPath,
+		PathParams:  larkcore.PathParams{},
+		QueryParams: larkcore.QueryParams{},
+		Body: &searchRecordBody{
+			FieldNames:      it.fieldNames,
+			Filter:          it.filter,
+			AutomaticFields: true,
+		},
+		SupportedAccessTokenTypes: []larkcore.AccessTokenType{larkcore.AccessTokenTypeTenant},
+	}
+	req.PathParams.Set("app_token", it.r.AppToken)
+	req.PathParams.Set("table_id", it.tableID)
+	req.QueryParams.Set("page_size", strconv.Itoa(it.opts.pageSize))
+	if it.pageToken != "" {
+		req.QueryParams.Set("page_token", it.pageToken)
+	}
+
+	var resp *larkbitable.SearchAppTableRecordResp
+	err := it.r.invoke(ctx, func(ctx context.Context, t string) error {
+		apiResp, err := it.r.Client.Do(ctx, req, larkcore.WithTenantAccessToken(t))
+		if err != nil {
+			return err
+		}
+		resp = &larkbitable.SearchAppTableRecordResp{ApiResp: apiResp}
+		if err := json.Unmarshal(apiResp.RawBody, resp); err != nil {
+			return err
+		}
+		return CheckResponse(resp.ApiResp, resp.CodeError)
+	})
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && (apiErr.Code == 1254018 || apiErr.Msg == "InvalidFilter") {
+			return nil, ErrInvalidUser
+		}
+
+		log.C(ctx).Errorw("failed to list record", "error", err, "tableID", it.tableID)
+		return nil, fmt.Errorf("failed to list record: %w", err)
+	}
+
+	it.pages++
+	if resp.Data == nil {
+		it.done = true
+		return nil, nil
+	}
+
+	hasMore := resp.Data.HasMore != nil && *resp.Data.HasMore
+	pageToken := ""
+	if resp.Data.PageToken != nil {
+		pageToken = *resp.Data.PageToken
+	}
+	if !hasMore {
+		it.done = true
+	} else if pageToken == "" || pageToken == it.pageToken {
+		// 服务端返回了空或重复的 page_token，继续遍历会死循环
+		it.done = true
+		log.C(ctx).Errorw("invalid page token", "tableID", it.tableID, "pageToken", pageToken)
+		return nil, fmt.Errorf("failed to list record: invalid page token %q, tableID=%s", pageToken, it.tableID)
+	}
+	it.pageToken = pageToken
+
+	return resp.Data.Items, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package bitable
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticTokenProvider string
+
+func (p staticTokenProvider) EnsureValidToken(ctx context.Context) (string, error) {
+	return string(p), nil
+}
+
+// fakeBitable 模拟飞书多维表格的记录查询接口，按 page_token 分页返回 total 条记录
+type fakeBitable struct {
+	mu        sync.Mutex
+	total     int
+	requests  []pageRequest
+	filters   []string // 每次请求体中的 filter
+	badTokens bool     // 为 true 时始终返回同一个 page_token
+}
+
+type pageRequest struct {
+	pageSize  string
+	pageToken string
+}
+
+func (f *fakeBitable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasSuffix(r.URL.Path, "/records/search") || r.Header.Get("Authorization") != "Bearer t-test" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	f.requests = append(f.requests, pageRequest{pageSize: q.Get("page_size"), pageToken: q.Get("page_token")})
+	var body struct {
+		Filter json.RawMessage `json:"filter"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	f.filters = append(f.filters, string(body.Filter))
+
+	size, _ := strconv.Atoi(q.Get("page_size"))
+	offset := 0
+	if token := q.Get("page_token"); token != "" {
+		offset, _ = strconv.Atoi(strings.TrimPrefix(token, "p"))
+	}
+	end := offset + size
+	if end > f.total {
+		end = f.total
+	}
+
+	items := make([]map[string]interface{}, 0, end-offset)
+	for i := offset; i < end; i++ {
+		items = append(items, map[string]interface{}{
+			"record_id": fmt.Sprintf("rec%d", i),
+			"fields":    map[string]interface{}{"标题": fmt.Sprintf("O%d", i)},
+		})
+	}
+
+	data := map[string]interface{}{
+		"items":    items,
+		"has_more": end < f.total,
+		"total":    f.total,
+	}
+	if end < f.total {
+		data["page_token"] = fmt.Sprintf("p%d", end)
+		if f.badTokens {
+			data["page_token"] = "p0"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "msg": "success", "data": data})
+}
+
+func newTestRecordManager(t *testing.T, fake *fakeBitable) *RecordManager {
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client := lark.NewClient("cli_test", "secret", lark.WithOpenBaseUrl(srv.URL), lark.WithEnableTokenCache(false))
+	rm := NewRecordManager(client, "app-token", staticTokenProvider("t-test"))
+	rm.SetInvoker(newTestInvoker())
+	return rm
+}
+
+func TestSearchRecord_FollowsAllPages(t *testing.T) {
+	fake := &fakeBitable{total: 250}
+	rm := newTestRecordManager(t, fake)
+
+	records, err := rm.SearchRecord(context.Background(), "tbl1", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, records, 250)
+	assert.Equal(t, "rec0", *records[0].RecordId)
+	assert.Equal(t, "rec249", *records[249].RecordId)
+
+	require.Len(t, fake.requests, 3)
+	assert.Equal(t, pageRequest{pageSize: "100"}, fake.requests[0])
+	assert.Equal(t, pageRequest{pageSize: "100", pageToken: "p100"}, fake.requests[1])
+	assert.Equal(t, pageRequest{pageSize: "100", pageToken: "p200"}, fake.requests[2])
+}
+
+func TestSearchRecord_EmptyTable(t *testing.T) {
+	fake := &fakeBitable{}
+	rm := newTestRecordManager(t, fake)
+
+	records, err := rm.SearchRecord(context.Background(), "tbl1", nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	assert.Len(t, fake.requests, 1)
+}
+
+func TestSearchRecord_PageSizeOptions(t *testing.T) {
+	fake := &fakeBitable{total: 250}
+	rm := newTestRecordManager(t, fake)
+	rm.SetSearchOptions(WithPageSize(500))
+
+	records, err := rm.SearchRecord(context.Background(), "tbl1", nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, records, 250)
+	require.Len(t, fake.requests, 1)
+	assert.Equal(t, "500", fake.requests[0].pageSize)
+
+	// 调用时传入的参数优先于默认值，非法值被忽略
+	fake.requests = nil
+	records, err = rm.SearchRecord(context.Background(), "tbl1", nil, nil, WithPageSize(50), WithPageSize(1000))
+	require.NoError(t, err)
+	assert.Len(t, records, 250)
+	assert.Len(t, fake.requests, 5)
+}
+
+func TestSearchRecord_MaxPagesExceeded(t *testing.T) {
+	fake := &fakeBitable{total: 250}
+	rm := newTestRecordManager(t, fake)
+
+	records, err := rm.SearchRecord(context.Background(), "tbl1", nil, nil, WithMaxPages(2))
+	assert.ErrorIs(t, err, ErrMaxPagesExceeded)
+	assert.Nil(t, records)
+	assert.Len(t, fake.requests, 2)
+
+	// 刚好在限制内时不报错
+	fake.requests = nil
+	records, err = rm.SearchRecord(context.Background(), "tbl1", nil, nil, WithMaxPages(3))
+	require.NoError(t, err)
+	assert.Len(t, records, 250)
+}
+
+func TestSearchRecord_RepeatedPageToken(t *testing.T) {
+	fake := &fakeBitable{total: 250, badTokens: true}
+	rm := newTestRecordManager(t, fake)
+
+	_, err := rm.SearchRecord(context.Background(), "tbl1", nil, nil)
+	assert.Error(t, err)
+	assert.Len(t, fake.requests, 2)
+}
+
+func TestRecordIterator(t *testing.T) {
+	fake := &fakeBitable{total: 5}
+	rm := newTestRecordManager(t, fake)
+
+	it := rm.SearchRecordIterator("tbl1", nil, nil, WithPageSize(2))
+	var pages [][]string
+	for it.HasNext() {
+		items, err := it.Next(context.Background())
+		require.NoError(t, err)
+		var ids []string
+		for _, item := range items {
+			ids = append(ids, *item.RecordId)
+		}
+		pages = append(pages, ids)
+	}
+
+	assert.Equal(t, [][]string{{"rec0", "rec1"}, {"rec2", "rec3"}, {"rec4"}}, pages)
+}
+
+func TestSearchRecord_SendsNestedFilter(t *testing.T) {
+	fake := &fakeBitable{total: 1}
+	rm := newTestRecordManager(t, fake)
+
+	filter := Or(Cond("标题", OpIs, "O1")).Group(AllOf(Cond("员工姓名", OpIs, "张三"), Cond("考核月份", OpIs, "2024年5月")))
+	_, err := rm.SearchRecord(context.Background(), "tbl1", nil, filter.Build())
+	require.NoError(t, err)
+
+	require.Len(t, fake.filters, 1)
+	assert.JSONEq(t, `{
+		"conjunction": "or",
+		"conditions": [{"field_name": "标题", "operator": "is", "value": ["O1"]}],
+		"children": [{
+			"conjunction": "and",
+			"conditions": [
+				{"field_name": "员工姓名", "operator": "is", "value": ["张三"]},
+				{"field_name": "考核月份", "operator": "is", "value": ["2024年5月"]}
+			]
+		}]
+	}`, fake.filters[0])
+}
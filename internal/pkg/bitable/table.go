@@ -0,0 +1,119 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package bitable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+
+	"github.com/imxw/miniokr/internal/pkg/bitable/token"
+)
+
+// Table 是多维表格中的一张数据表
+type Table struct {
+	TableID string
+	Name    string
+}
+
+// TableManager 管理多维表格中的数据表
+type TableManager struct {
+	Client        *lark.Client
+	AppToken      string
+	tokenProvider token.Provider
+	invoker       *Invoker
+}
+
+func NewTableManager(client *lark.Client, appToken string, tp token.Provider) *TableManager {
+	return &TableManager{
+		Client:        client,
+		AppToken:      appToken,
+		tokenProvider: tp,
+		invoker:       DefaultInvoker,
+	}
+}
+
+// SetInvoker 设置调用飞书 API 时使用的限流和重试策略
+func (t *TableManager) SetInvoker(invoker *Invoker) {
+	t.invoker = invoker
+}
+
+// ListTables 获取多维表格中的所有数据表
+func (t *TableManager) ListTables(ctx context.Context) ([]Table, error) {
+	var tables []Table
+	pageToken := ""
+	for {
+		builder := larkbitable.NewListAppTableReqBuilder().AppToken(t.AppToken).PageSize(100)
+		if pageToken != "" {
+			builder.PageToken(pageToken)
+		}
+		req := builder.Build()
+
+		var resp *larkbitable.ListAppTableResp
+		err := t.invoker.InvokeWithToken(ctx, t.AppToken, t.tokenProvider, func(ctx context.Context, tk string) error {
+			var err error
+			resp, err = t.Client.Bitable.AppTable.List(ctx, req, larkcore.WithTenantAccessToken(tk))
+			if err != nil {
+				return err
+			}
+			return CheckResponse(resp.ApiResp, resp.CodeError)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		if resp.Data == nil {
+			break
+		}
+
+		for _, item := range resp.Data.Items {
+			if item.TableId != nil && item.Name != nil {
+				tables = append(tables, Table{TableID: *item.TableId, Name: *item.Name})
+			}
+		}
+		if resp.Data.HasMore == nil || !*resp.Data.HasMore || resp.Data.PageToken == nil {
+			break
+		}
+		pageToken = *resp.Data.PageToken
+	}
+
+	return tables, nil
+}
+
+// CreateTable 创建数据表，fields 为初始字段，第一个字段是索引列
+func (t *TableManager) CreateTable(ctx context.Context, name string, fields []*larkbitable.AppTableCreateHeader) (string, error) {
+	req := larkbitable.NewCreateAppTableReqBuilder().
+		AppToken(t.AppToken).
+		Body(larkbitable.NewCreateAppTableReqBodyBuilder().
+			Table(larkbitable.NewReqTableBuilder().
+				Name(name).
+				DefaultViewName("表格").
+				Fields(fields).
+				Build()).
+			Build()).
+		Build()
+
+	var resp *larkbitable.CreateAppTableResp
+	err := t.invoker.InvokeWithToken(ctx, t.AppToken, t.tokenProvider, func(ctx context.Context, tk string) error {
+		var err error
+		resp, err = t.Client.Bitable.AppTable.Create(ctx, req, larkcore.WithTenantAccessToken(tk))
+		if err != nil {
+			return err
+		}
+		return CheckResponse(resp.ApiResp, resp.CodeError)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create table %s: %w", name, err)
+	}
+	if resp.Data == nil || resp.Data.TableId == nil {
+		return "", errors.New("failed to create table: empty table id")
+	}
+
+	return *resp.Data.TableId, nil
+}
@@ -7,28 +7,78 @@ package token
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/imxw/miniokr/internal/pkg/log"
 )
 
+// TokenFilePath 是令牌文件的默认路径
 const TokenFilePath = "tenant_access_token.json"
 
+const (
+	// DefaultRefreshAhead 是默认的提前刷新窗口，令牌剩余有效期小于该值时后台刷新
+	DefaultRefreshAhead = 10 * time.Minute
+	// DefaultRefresherInterval 是后台刷新默认的检查间隔，需要小于提前刷新窗口
+	DefaultRefresherInterval = 5 * time.Minute
+	// DefaultRefreshLease 是刷新租约的默认有效期，持有租约的实例退出后其他实例最多等待该时长
+	DefaultRefreshLease = 30 * time.Second
+	// DefaultRefreshWaitInterval 是等待其他实例刷新令牌时重新加载的默认间隔
+	DefaultRefreshWaitInterval = 200 * time.Millisecond
+)
+
+// 令牌刷新的原因，记录在日志中用于观察令牌的更换频率
+const (
+	refreshReasonInitialize   = "initialize"
+	refreshReasonExpired      = "expired"
+	refreshReasonForced       = "forced"
+	refreshReasonRefreshAhead = "refresh-ahead"
+)
+
+// ErrVersionConflict 表示令牌已被其他实例更新，本次保存被乐观锁拒绝
+var ErrVersionConflict = errors.New("token has been updated by another instance")
+
+// ErrRefreshInProgress 表示刷新租约由其他实例持有，令牌正在被其他实例刷新
+var ErrRefreshInProgress = errors.New("token is being refreshed by another instance")
+
 type Provider interface {
 	EnsureValidToken(ctx context.Context) (string, error)
 }
 
+// Refresher 是支持强制刷新的 Provider.
+// 飞书可能在令牌到期前将其吊销，调用方收到令牌无效的错误时通过 ForceRefresh 换取新令牌
+type Refresher interface {
+	Provider
+	ForceRefresh(ctx context.Context, stale string) (string, error)
+}
+
 type Service interface {
 	GetNewToken(ctx context.Context, appID string, appSecret string) (*Response, error)
 }
 
+// Storage 负责持久化令牌.
+// 支持多副本的实现需要按 Data.Version 做乐观锁：版本号一致时保存成功并将版本号加一，否则返回 ErrVersionConflict，
+// 并实现 RefreshLocker 避免多个实例同时刷新
 type Storage interface {
 	LoadTokenFromFile(path string) (Data, error)
 	SaveTokenToFile(data Data, path string) error
 }
 
+// RefreshLocker 是多副本共享的 Storage 可以实现的刷新租约.
+// 刷新前先领取租约，同一时间只有一个实例向飞书获取新令牌，其他实例等待其保存后重新加载.
+// 租约被其他实例持有且未过期时 ClaimRefresh 返回 ErrRefreshInProgress，
+// SaveTokenToFile 保存成功时释放租约
+type RefreshLocker interface {
+	ClaimRefresh(owner string, ttl time.Duration) error
+	ReleaseRefresh(owner string) error
+}
+
 type Manager struct {
 	Service                Service
 	AppID                  string
@@ -36,15 +86,27 @@ type Manager struct {
 	rwMutex                sync.RWMutex
 	cachedToken            string
 	expiryTime             time.Time
+	version                int
 	Storage                Storage
 	Clock                  Clock
 	TokenRefresherInterval time.Duration
+	// RefreshAhead 是提前刷新窗口，后台刷新时剩余有效期小于该值的令牌会被换掉
+	RefreshAhead time.Duration
+	refreshes    atomic.Int64
+	// FilePath 是 Storage 保存令牌的路径，默认为 TokenFilePath
+	FilePath string
+	// RefreshLease 和 RefreshWaitInterval 仅在 Storage 实现了 RefreshLocker 时使用
+	RefreshLease        time.Duration
+	RefreshWaitInterval time.Duration
+	// instanceID 标识本实例持有的刷新租约
+	instanceID string
 }
 
 type Data struct {
 	TenantAccessToken string    `json:"tenant_access_token"`
 	Expire            int       `json:"expire"`
 	SavedAt           time.Time `json:"saved_at"`
+	Version           int       `json:"version,omitempty"`
 }
 
 type Response struct {
@@ -53,6 +115,8 @@ type Response struct {
 	Data
 }
 
+var _ Refresher = (*Manager)(nil)
+
 func NewManager(service Service, storage Storage, clock Clock, appID, appSecret string) *Manager {
 	m := &Manager{
 		Service:                service,
@@ -61,8 +125,13 @@ func NewManager(service Service, storage Storage, clock Clock, appID, appSecret
 		cachedToken:            "",
 		expiryTime:             time.Time{},
 		Storage:                storage,
-		TokenRefresherInterval: 1 * time.Hour,
+		TokenRefresherInterval: DefaultRefresherInterval,
+		RefreshAhead:           DefaultRefreshAhead,
 		Clock:                  clock,
+		FilePath:               TokenFilePath,
+		RefreshLease:           DefaultRefreshLease,
+		RefreshWaitInterval:    DefaultRefreshWaitInterval,
+		instanceID:             uuid.NewString(),
 	}
 	return m
 }
@@ -72,7 +141,10 @@ func (m *Manager) Initialize(ctx context.Context) error {
 }
 
 func (m *Manager) initializeTokenCache(ctx context.Context) error {
-	tokenData, err := m.Storage.LoadTokenFromFile(TokenFilePath)
+	tokenData, err := m.Storage.LoadTokenFromFile(m.FilePath)
+	if err == nil {
+		m.version = tokenData.Version
+	}
 
 	// 如果文件读取失败或token已过期，尝试刷新token
 	if err != nil || tokenData.SavedAt.IsZero() || time.Now().After(tokenData.SavedAt.Add(time.Duration(tokenData.Expire)*time.Second)) {
@@ -81,7 +153,7 @@ func (m *Manager) initializeTokenCache(ctx context.Context) error {
 		} else {
 			log.Errorw("Loaded token is expired or has invalid SavedAt, attempting to refresh", "SavedAt", tokenData.SavedAt)
 		}
-		_, refreshErr := m.refreshToken(ctx)
+		_, refreshErr := m.refreshToken(ctx, refreshReasonInitialize)
 		if refreshErr != nil {
 			log.Errorw("Failed to refresh token", "error", refreshErr)
 			return refreshErr
@@ -91,6 +163,7 @@ func (m *Manager) initializeTokenCache(ctx context.Context) error {
 	}
 
 	// 文件存在且令牌有效，设置缓存
+	m.version = tokenData.Version
 	m.cachedToken = tokenData.TenantAccessToken
 	m.expiryTime = tokenData.SavedAt.Add(time.Duration(tokenData.Expire) * time.Second)
 	log.Infow("Token loaded and cache initialized successfully.")
@@ -116,10 +189,16 @@ func (m *Manager) EnsureValidToken(ctx context.Context) (string, error) {
 	}
 
 	// 从文件中重新加载，以处理系统重启或令牌文件外部更新的情况
-	tokenData, err := m.Storage.LoadTokenFromFile(TokenFilePath)
+	tokenData, err := m.Storage.LoadTokenFromFile(m.FilePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("加载Token文件失败: %w", err)
+	}
+	if err == nil {
+		m.version = tokenData.Version
+	}
 	if err != nil || time.Now().After(tokenData.SavedAt.Add(time.Duration(tokenData.Expire)*time.Second)) {
-		// 文件加载失败或令牌过期，尝试刷新令牌
-		return m.refreshToken(ctx)
+		// 文件不存在或令牌过期，尝试刷新令牌
+		return m.refreshToken(ctx, refreshReasonExpired)
 	}
 
 	// 更新内存中的缓存
@@ -129,30 +208,186 @@ func (m *Manager) EnsureValidToken(ctx context.Context) (string, error) {
 	return m.cachedToken, nil
 }
 
-func (m *Manager) refreshToken(ctx context.Context) (string, error) {
+// ForceRefresh 忽略本地记录的过期时间，立即从飞书获取新令牌.
+// stale 是调用方刚刚被拒绝的令牌，如果缓存中的令牌已经被其他调用方换掉，直接返回新令牌而不重复刷新
+func (m *Manager) ForceRefresh(ctx context.Context, stale string) (string, error) {
+	m.rwMutex.Lock()
+	defer m.rwMutex.Unlock()
+
+	if m.cachedToken != "" && m.cachedToken != stale && time.Now().Before(m.expiryTime) {
+		return m.cachedToken, nil
+	}
+
+	// 多副本部署时其他实例可能已经换了新令牌
+	tokenData, err := m.Storage.LoadTokenFromFile(m.FilePath)
+	if err == nil {
+		m.version = tokenData.Version
+		if tokenData.TenantAccessToken != stale && time.Now().Before(tokenData.SavedAt.Add(time.Duration(tokenData.Expire)*time.Second)) {
+			m.cachedToken = tokenData.TenantAccessToken
+			m.expiryTime = tokenData.SavedAt.Add(time.Duration(tokenData.Expire) * time.Second)
+			return m.cachedToken, nil
+		}
+	}
+
+	log.C(ctx).Infow("Token is rejected by server, forcing refresh")
+	return m.refreshToken(ctx, refreshReasonForced)
+}
+
+// RefreshIfExpiring 在令牌剩余有效期小于 RefreshAhead 时提前刷新，避免请求时才发现令牌过期
+func (m *Manager) RefreshIfExpiring(ctx context.Context) (string, error) {
+	m.rwMutex.Lock()
+	defer m.rwMutex.Unlock()
+
+	if time.Until(m.expiryTime) > m.RefreshAhead {
+		return m.cachedToken, nil
+	}
+
+	// 多副本部署时其他实例可能已经提前刷新过了
+	tokenData, err := m.Storage.LoadTokenFromFile(m.FilePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("加载Token文件失败: %w", err)
+	}
+	if err == nil {
+		m.version = tokenData.Version
+		expiryTime := tokenData.SavedAt.Add(time.Duration(tokenData.Expire) * time.Second)
+		if time.Until(expiryTime) > m.RefreshAhead {
+			m.cachedToken = tokenData.TenantAccessToken
+			m.expiryTime = expiryTime
+			return m.cachedToken, nil
+		}
+	}
+
+	return m.refreshToken(ctx, refreshReasonRefreshAhead)
+}
+
+// RefreshCount 返回从飞书获取新令牌的次数
+func (m *Manager) RefreshCount() int64 {
+	return m.refreshes.Load()
+}
+
+func (m *Manager) refreshToken(ctx context.Context, reason string) (string, error) {
+	locker, shared := m.Storage.(RefreshLocker)
+	if shared {
+		token, claimed, err := m.claimRefresh(ctx, locker)
+		if err != nil || !claimed {
+			return token, err
+		}
+	}
+
 	// 从Token服务获取新的令牌响应
 	resp, err := m.Service.GetNewToken(ctx, m.AppID, m.AppSecret)
 	if err != nil {
+		log.C(ctx).Errorw("Failed to refresh tenant access token", "appID", m.AppID, "reason", reason, "error", err)
+		if shared {
+			m.releaseRefresh(ctx, locker)
+		}
 		return "", err
 	}
+	m.refreshes.Add(1)
 
 	// 从响应中提取令牌数据并更新内存中的缓存
 	tokenData := Data{
 		TenantAccessToken: resp.Data.TenantAccessToken,
 		Expire:            resp.Data.Expire, // 直接保存剩余秒数
 		SavedAt:           time.Now(),
+		Version:           m.version,
 	}
 	m.cachedToken = tokenData.TenantAccessToken
 	m.expiryTime = tokenData.SavedAt.Add(time.Duration(tokenData.Expire) * time.Second)
 
 	// 将新令牌保存到文件
-	if err := m.Storage.SaveTokenToFile(tokenData, TokenFilePath); err != nil {
+	err = m.Storage.SaveTokenToFile(tokenData, m.FilePath)
+	if errors.Is(err, ErrVersionConflict) {
+		return m.adoptStoredToken(ctx, tokenData), nil
+	}
+	if err != nil {
 		return "", fmt.Errorf("保存Token到文件失败: %v", err)
 	}
+	m.version++
+
+	log.C(ctx).Infow("Tenant access token refreshed", "appID", m.AppID, "reason", reason,
+		"expireAt", m.expiryTime, "refreshes", m.refreshes.Load())
 
 	return tokenData.TenantAccessToken, nil
 }
 
+// claimRefresh 在向飞书获取新令牌前领取刷新租约.
+// 租约由其他实例持有时等待其保存新令牌并使用该令牌，此时 claimed 为 false
+func (m *Manager) claimRefresh(ctx context.Context, locker RefreshLocker) (token string, claimed bool, err error) {
+	version := m.version
+	for {
+		err := locker.ClaimRefresh(m.instanceID, m.RefreshLease)
+		if err == nil {
+			// 领取租约前其他实例可能刚保存了新令牌
+			if token, ok := m.loadRefreshedToken(version); ok {
+				m.releaseRefresh(ctx, locker)
+				return token, false, nil
+			}
+			return "", true, nil
+		}
+		if !errors.Is(err, ErrRefreshInProgress) {
+			return "", false, fmt.Errorf("领取Token刷新租约失败: %w", err)
+		}
+
+		log.C(ctx).Infow("Token is being refreshed by another instance, waiting", "appID", m.AppID, "version", version)
+		select {
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		case <-time.After(m.RefreshWaitInterval):
+		}
+		if token, ok := m.loadRefreshedToken(version); ok {
+			log.C(ctx).Infow("Token has been refreshed by another instance", "version", m.version)
+			return token, false, nil
+		}
+	}
+}
+
+// loadRefreshedToken 重新加载令牌，存储中的版本号比 version 新且令牌未过期时使用该令牌
+func (m *Manager) loadRefreshedToken(version int) (string, bool) {
+	stored, err := m.Storage.LoadTokenFromFile(m.FilePath)
+	if err != nil || stored.Version <= version {
+		return "", false
+	}
+	expiryTime := stored.SavedAt.Add(time.Duration(stored.Expire) * time.Second)
+	if stored.TenantAccessToken == "" || !time.Now().Before(expiryTime) {
+		return "", false
+	}
+
+	m.version = stored.Version
+	m.cachedToken = stored.TenantAccessToken
+	m.expiryTime = expiryTime
+	return m.cachedToken, true
+}
+
+// releaseRefresh 在没有保存新令牌时释放刷新租约，释放失败时租约到期后自动失效
+func (m *Manager) releaseRefresh(ctx context.Context, locker RefreshLocker) {
+	if err := locker.ReleaseRefresh(m.instanceID); err != nil {
+		log.C(ctx).Warnw("Failed to release token refresh lease", "appID", m.AppID, "error", err)
+	}
+}
+
+// adoptStoredToken 在保存被乐观锁拒绝后重新加载其他实例保存的令牌和版本号，与其他实例使用同一个令牌.
+// 加载失败或保存的令牌已过期时继续在内存中使用本实例刚获取的令牌 fresh
+func (m *Manager) adoptStoredToken(ctx context.Context, fresh Data) string {
+	stored, err := m.Storage.LoadTokenFromFile(m.FilePath)
+	if err != nil {
+		log.C(ctx).Errorw("Failed to reload token after version conflict", "version", m.version, "error", err)
+		return fresh.TenantAccessToken
+	}
+	m.version = stored.Version
+
+	expiryTime := stored.SavedAt.Add(time.Duration(stored.Expire) * time.Second)
+	if stored.TenantAccessToken == "" || !time.Now().Before(expiryTime) {
+		log.C(ctx).Warnw("Stored token is expired after version conflict, using refreshed token in memory", "version", m.version)
+		return fresh.TenantAccessToken
+	}
+
+	m.cachedToken = stored.TenantAccessToken
+	m.expiryTime = expiryTime
+	log.C(ctx).Infow("Token has been refreshed by another instance", "version", m.version)
+	return m.cachedToken
+}
+
 func (m *Manager) StartTokenRefresher(ctx context.Context) {
 	tick := m.Clock.NewTicker(m.TokenRefresherInterval) // 使用 Clock 接口创建 Ticker
 	// tick := time.NewTicker(m.TokenRefresherInterval)
@@ -164,10 +399,10 @@ func (m *Manager) StartTokenRefresher(ctx context.Context) {
 			log.Infow("Context cancelled, stopping refresher", "now", time.Now())
 			return
 		case t := <-tick.C():
-			log.Infow("Tick at ", "tick", t)
-			_, err := m.EnsureValidToken(ctx)
+			log.Debugw("Tick at ", "tick", t)
+			_, err := m.RefreshIfExpiring(ctx)
 			if err != nil {
-				log.Infow("Error refreshing token", "error", err)
+				log.Errorw("Error refreshing token", "error", err)
 				// 可以选择重试或发送告警
 			}
 		}
@@ -8,6 +8,7 @@ package token
 import (
 	"context"
 	"errors"
+	"os"
 	"testing"
 	"time"
 
@@ -40,6 +41,21 @@ func (m *MockStorage) SaveTokenToFile(data Data, path string) error {
 }
 
 // MockTicker 模拟一个定时器
+// MockLockingStorage 是实现了 RefreshLocker 的 MockStorage
+type MockLockingStorage struct {
+	MockStorage
+}
+
+func (m *MockLockingStorage) ClaimRefresh(owner string, ttl time.Duration) error {
+	args := m.Called(owner, ttl)
+	return args.Error(0)
+}
+
+func (m *MockLockingStorage) ReleaseRefresh(owner string) error {
+	args := m.Called(owner)
+	return args.Error(0)
+}
+
 type MockTicker struct {
 	mock.Mock
 	CChan chan time.Time
@@ -488,3 +504,324 @@ func TestStartTokenRefresher_faild(t *testing.T) {
 	mockTicker.AssertExpectations(t)
 	mockStorage.AssertExpectations(t)
 }
+
+// TestForceRefresh_IgnoresExpiry 令牌未过期但被服务端拒绝，强制刷新
+func TestForceRefresh_IgnoresExpiry(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	mockClock := new(MockClock)
+	manager := NewManager(mockService, mockStorage, mockClock, "appID", "appSecret")
+	manager.cachedToken = "revoked_token"
+	manager.expiryTime = time.Now().Add(time.Hour)
+
+	// 文件中也是被拒绝的令牌
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(Data{
+		TenantAccessToken: "revoked_token", Expire: 3600, SavedAt: time.Now(),
+	}, nil).Once()
+	mockService.On("GetNewToken", ctx, "appID", "appSecret").Return(&Response{
+		Data: Data{TenantAccessToken: "new_token", Expire: 7200},
+	}, nil).Once()
+	mockStorage.On("SaveTokenToFile", mock.AnythingOfType("Data"), TokenFilePath).Return(nil).Once()
+
+	token, err := manager.ForceRefresh(ctx, "revoked_token")
+	assert.NoError(t, err)
+	assert.Equal(t, "new_token", token)
+	assert.Equal(t, "new_token", manager.cachedToken)
+
+	// 刷新后 EnsureValidToken 直接返回新令牌，不会再次读取文件
+	token, err = manager.EnsureValidToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "new_token", token)
+
+	mockService.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestForceRefresh_AlreadyRefreshed 其他调用方已经换了新令牌，不再重复刷新
+func TestForceRefresh_AlreadyRefreshed(t *testing.T) {
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	mockClock := new(MockClock)
+	manager := NewManager(mockService, mockStorage, mockClock, "appID", "appSecret")
+	manager.cachedToken = "fresh_token"
+	manager.expiryTime = time.Now().Add(time.Hour)
+
+	token, err := manager.ForceRefresh(context.Background(), "revoked_token")
+	assert.NoError(t, err)
+	assert.Equal(t, "fresh_token", token)
+
+	mockService.AssertNotCalled(t, "GetNewToken", mock.Anything, mock.Anything, mock.Anything)
+	mockStorage.AssertNotCalled(t, "SaveTokenToFile", mock.Anything, mock.Anything)
+}
+
+// TestForceRefresh_RefreshFails 强制刷新失败时返回错误
+func TestForceRefresh_RefreshFails(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	mockClock := new(MockClock)
+	manager := NewManager(mockService, mockStorage, mockClock, "appID", "appSecret")
+	manager.cachedToken = "revoked_token"
+	manager.expiryTime = time.Now().Add(time.Hour)
+
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(Data{}, os.ErrNotExist).Once()
+	mockService.On("GetNewToken", ctx, "appID", "appSecret").Return((*Response)(nil), errors.New("refresh failed")).Once()
+
+	token, err := manager.ForceRefresh(ctx, "revoked_token")
+	assert.Error(t, err)
+	assert.Empty(t, token)
+	mockService.AssertExpectations(t)
+}
+
+// TestForceRefresh_StorageHasNewerToken 其他副本已将新令牌写入存储，直接使用而不再请求飞书
+func TestForceRefresh_StorageHasNewerToken(t *testing.T) {
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	manager := NewManager(mockService, mockStorage, new(MockClock), "appID", "appSecret")
+	manager.cachedToken = "revoked_token"
+	manager.expiryTime = time.Now().Add(time.Hour)
+
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(Data{
+		TenantAccessToken: "replica_token", Expire: 3600, SavedAt: time.Now(), Version: 3,
+	}, nil).Once()
+
+	token, err := manager.ForceRefresh(context.Background(), "revoked_token")
+	assert.NoError(t, err)
+	assert.Equal(t, "replica_token", token)
+	assert.Equal(t, 3, manager.version)
+
+	mockService.AssertNotCalled(t, "GetNewToken", mock.Anything, mock.Anything, mock.Anything)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestRefreshToken_VersionConflict 保存时版本冲突，说明其他副本已刷新，改用其保存的令牌和版本号
+func TestRefreshToken_VersionConflict(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	manager := NewManager(mockService, mockStorage, new(MockClock), "appID", "appSecret")
+
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(Data{
+		TenantAccessToken: "expired_token", Expire: 3600, SavedAt: time.Now().Add(-2 * time.Hour), Version: 5,
+	}, nil).Once()
+	mockService.On("GetNewToken", ctx, "appID", "appSecret").Return(&Response{
+		Data: Data{TenantAccessToken: "new_token", Expire: 7200},
+	}, nil).Once()
+	// 保存时带上读取到的版本号
+	mockStorage.On("SaveTokenToFile", mock.MatchedBy(func(d Data) bool { return d.Version == 5 }), TokenFilePath).
+		Return(ErrVersionConflict).Once()
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(Data{
+		TenantAccessToken: "replica_token", Expire: 7200, SavedAt: time.Now(), Version: 6,
+	}, nil).Once()
+
+	token, err := manager.EnsureValidToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "replica_token", token)
+	assert.Equal(t, 6, manager.version)
+
+	// 下次刷新使用新的版本号保存
+	mockService.On("GetNewToken", ctx, "appID", "appSecret").Return(&Response{
+		Data: Data{TenantAccessToken: "next_token", Expire: 7200},
+	}, nil).Once()
+	mockStorage.On("SaveTokenToFile", mock.MatchedBy(func(d Data) bool { return d.Version == 6 }), TokenFilePath).
+		Return(nil).Once()
+	token, err = manager.refreshToken(ctx, refreshReasonForced)
+	assert.NoError(t, err)
+	assert.Equal(t, "next_token", token)
+	assert.Equal(t, 7, manager.version)
+
+	mockService.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestRefreshToken_VersionConflictStoredExpired 冲突后重新加载的令牌已过期，使用本次获取的令牌
+func TestRefreshToken_VersionConflictStoredExpired(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	manager := NewManager(mockService, mockStorage, new(MockClock), "appID", "appSecret")
+	manager.version = 5
+
+	mockService.On("GetNewToken", ctx, "appID", "appSecret").Return(&Response{
+		Data: Data{TenantAccessToken: "new_token", Expire: 7200},
+	}, nil).Once()
+	mockStorage.On("SaveTokenToFile", mock.AnythingOfType("Data"), TokenFilePath).Return(ErrVersionConflict).Once()
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(Data{
+		TenantAccessToken: "stale_token", Expire: 3600, SavedAt: time.Now().Add(-2 * time.Hour), Version: 8,
+	}, nil).Once()
+
+	token, err := manager.refreshToken(ctx, refreshReasonForced)
+	assert.NoError(t, err)
+	assert.Equal(t, "new_token", token)
+	assert.Equal(t, "new_token", manager.cachedToken)
+	assert.Equal(t, 8, manager.version)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestManager_CustomFilePath 使用配置的令牌文件路径
+func TestManager_CustomFilePath(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	manager := NewManager(mockService, mockStorage, nil, "appID", "appSecret")
+	manager.FilePath = "/var/lib/miniokr/token.json"
+
+	mockStorage.On("LoadTokenFromFile", manager.FilePath).Return(Data{}, os.ErrNotExist).Once()
+	mockService.On("GetNewToken", ctx, "appID", "appSecret").Return(&Response{
+		Data: Data{TenantAccessToken: "new_token", Expire: 7200},
+	}, nil).Once()
+	mockStorage.On("SaveTokenToFile", mock.AnythingOfType("Data"), manager.FilePath).Return(nil).Once()
+
+	assert.NoError(t, manager.Initialize(ctx))
+	assert.Equal(t, 1, manager.version)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestRefreshIfExpiring_OutsideWindow 剩余有效期大于提前刷新窗口，不刷新
+func TestRefreshIfExpiring_OutsideWindow(t *testing.T) {
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	manager := NewManager(mockService, mockStorage, new(MockClock), "appID", "appSecret")
+	manager.cachedToken = "valid_token"
+	manager.expiryTime = time.Now().Add(time.Hour)
+
+	token, err := manager.RefreshIfExpiring(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "valid_token", token)
+	assert.Equal(t, int64(0), manager.RefreshCount())
+
+	mockStorage.AssertNotCalled(t, "LoadTokenFromFile", mock.Anything)
+	mockService.AssertNotCalled(t, "GetNewToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestRefreshIfExpiring_InsideWindow 令牌还没过期但即将过期，提前刷新
+func TestRefreshIfExpiring_InsideWindow(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	manager := NewManager(mockService, mockStorage, new(MockClock), "appID", "appSecret")
+	expiring := Data{TenantAccessToken: "expiring_token", Expire: 3600, SavedAt: time.Now().Add(-55 * time.Minute)}
+	manager.cachedToken = expiring.TenantAccessToken
+	manager.expiryTime = expiring.SavedAt.Add(time.Hour)
+
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(expiring, nil).Once()
+	mockService.On("GetNewToken", ctx, "appID", "appSecret").Return(&Response{
+		Data: Data{TenantAccessToken: "new_token", Expire: 7200},
+	}, nil).Once()
+	mockStorage.On("SaveTokenToFile", mock.AnythingOfType("Data"), TokenFilePath).Return(nil).Once()
+
+	token, err := manager.RefreshIfExpiring(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "new_token", token)
+	assert.Equal(t, int64(1), manager.RefreshCount())
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), manager.expiryTime, time.Second)
+
+	mockService.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestRefreshIfExpiring_StorageAlreadyRefreshed 其他副本已提前刷新，直接使用存储中的令牌
+func TestRefreshIfExpiring_StorageAlreadyRefreshed(t *testing.T) {
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	manager := NewManager(mockService, mockStorage, new(MockClock), "appID", "appSecret")
+	manager.cachedToken = "expiring_token"
+	manager.expiryTime = time.Now().Add(5 * time.Minute)
+
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(Data{
+		TenantAccessToken: "replica_token", Expire: 7200, SavedAt: time.Now(), Version: 2,
+	}, nil).Once()
+
+	token, err := manager.RefreshIfExpiring(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "replica_token", token)
+	assert.Equal(t, int64(0), manager.RefreshCount())
+
+	mockService.AssertNotCalled(t, "GetNewToken", mock.Anything, mock.Anything, mock.Anything)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestStartTokenRefresher_RefreshAhead 后台刷新在令牌过期前换新令牌
+func TestStartTokenRefresher_RefreshAhead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mockService := new(MockService)
+	mockStorage := new(MockStorage)
+	mockClock := new(MockClock)
+	mockTicker := new(MockTicker)
+	mockTicker.CChan = make(chan time.Time, 1)
+
+	manager := NewManager(mockService, mockStorage, mockClock, "appID", "appSecret")
+	expiring := Data{TenantAccessToken: "expiring_token", Expire: 3600, SavedAt: time.Now().Add(-55 * time.Minute)}
+	manager.cachedToken = expiring.TenantAccessToken
+	manager.expiryTime = expiring.SavedAt.Add(time.Hour)
+
+	mockClock.On("NewTicker", DefaultRefresherInterval).Return(mockTicker)
+	mockTicker.On("Stop").Once()
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(expiring, nil).Once()
+	mockService.On("GetNewToken", mock.Anything, "appID", "appSecret").Return(&Response{
+		Data: Data{TenantAccessToken: "new_token", Expire: 7200},
+	}, nil).Once()
+	mockStorage.On("SaveTokenToFile", mock.AnythingOfType("Data"), TokenFilePath).Return(nil).Once()
+
+	done := make(chan struct{})
+	go func() {
+		manager.StartTokenRefresher(ctx)
+		close(done)
+	}()
+
+	mockTicker.CChan <- time.Now()
+	assert.Eventually(t, func() bool { return manager.RefreshCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+
+	token, err := manager.EnsureValidToken(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "new_token", token)
+
+	mockClock.AssertExpectations(t)
+	mockTicker.AssertExpectations(t)
+	mockService.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestRefreshToken_WaitsForLeaseHolder 刷新租约由其他副本持有时等待其保存新令牌，不再请求飞书
+func TestRefreshToken_WaitsForLeaseHolder(t *testing.T) {
+	mockService := new(MockService)
+	mockStorage := new(MockLockingStorage)
+	manager := NewManager(mockService, mockStorage, new(MockClock), "appID", "appSecret")
+	manager.RefreshWaitInterval = time.Millisecond
+	manager.version = 1
+
+	mockStorage.On("ClaimRefresh", manager.instanceID, DefaultRefreshLease).Return(ErrRefreshInProgress).Once()
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(Data{
+		TenantAccessToken: "replica_token", Expire: 3600, SavedAt: time.Now(), Version: 2,
+	}, nil).Once()
+
+	token, err := manager.refreshToken(context.Background(), refreshReasonExpired)
+	assert.NoError(t, err)
+	assert.Equal(t, "replica_token", token)
+	assert.Equal(t, 2, manager.version)
+	mockService.AssertNotCalled(t, "GetNewToken", mock.Anything, mock.Anything, mock.Anything)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestRefreshToken_ReleasesLeaseOnFailure 刷新失败时释放租约，其他副本无需等待租约到期
+func TestRefreshToken_ReleasesLeaseOnFailure(t *testing.T) {
+	ctx := context.Background()
+	mockService := new(MockService)
+	mockStorage := new(MockLockingStorage)
+	manager := NewManager(mockService, mockStorage, new(MockClock), "appID", "appSecret")
+
+	mockStorage.On("ClaimRefresh", manager.instanceID, DefaultRefreshLease).Return(nil).Once()
+	mockStorage.On("LoadTokenFromFile", TokenFilePath).Return(Data{}, os.ErrNotExist).Once()
+	mockService.On("GetNewToken", ctx, "appID", "appSecret").Return((*Response)(nil), errors.New("refresh failed")).Once()
+	mockStorage.On("ReleaseRefresh", manager.instanceID).Return(nil).Once()
+
+	_, err := manager.refreshToken(ctx, refreshReasonExpired)
+	assert.Error(t, err)
+	mockService.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+}
@@ -9,12 +9,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
 )
 
 func NewTokenStorage() Storage {
 	return &fileStorage{}
 }
 
+// NewMemoryStorage 创建只保存在内存中的令牌存储，用于命令行工具等不需要持久化令牌的场景
+func NewMemoryStorage() Storage {
+	return &memoryStorage{}
+}
+
 type fileStorage struct{}
 
 func (f *fileStorage) LoadTokenFromFile(path string) (Data, error) {
@@ -25,6 +31,27 @@ func (f *fileStorage) SaveTokenToFile(data Data, path string) error {
 	return saveTokenToFile(data, path)
 }
 
+type memoryStorage struct {
+	mu   sync.Mutex
+	data *Data
+}
+
+func (m *memoryStorage) LoadTokenFromFile(string) (Data, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		return Data{}, os.ErrNotExist
+	}
+	return *m.data, nil
+}
+
+func (m *memoryStorage) SaveTokenToFile(data Data, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = &data
+	return nil
+}
+
 func saveTokenToFile(data Data, filePath string) error {
 	d, err := json.Marshal(data)
 	if err != nil {
@@ -84,3 +84,17 @@ func TestLoadTokenFromFile(t *testing.T) {
 	_, err = store.LoadTokenFromFile(tmpfile.Name())
 	assert.Error(t, err, "should have an error due to invalid JSON")
 }
+
+func TestMemoryStorage(t *testing.T) {
+	store := NewMemoryStorage()
+
+	_, err := store.LoadTokenFromFile("ignored")
+	assert.ErrorIs(t, err, os.ErrNotExist, "should report not exist before saving")
+
+	data := Data{TenantAccessToken: "t-memory", Expire: 7200, SavedAt: time.Now()}
+	assert.NoError(t, store.SaveTokenToFile(data, "ignored"))
+
+	loaded, err := store.LoadTokenFromFile("ignored")
+	assert.NoError(t, err)
+	assert.Equal(t, data.TenantAccessToken, loaded.TenantAccessToken)
+}
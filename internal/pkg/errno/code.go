@@ -35,4 +35,52 @@ var (
 
 	// ErrUserNotFound 标识用户没有找到
 	ErrUserNotFound = &Errno{HTTP: 404, Code: "ResourceNotFound.UserNotFound", Message: "User not found."}
+
+	// ErrRecordNotFound 表示目标或关键结果记录没有找到.
+	ErrRecordNotFound = &Errno{HTTP: 404, Code: "ResourceNotFound.RecordNotFound", Message: "Record not found."}
+
+	// ErrPasswordIncorrect 表示本地账号不存在或密码错误.
+	ErrPasswordIncorrect = &Errno{HTTP: 401, Code: "AuthFailure.PasswordIncorrect", Message: "Username or password is incorrect."}
+
+	// ErrAccountLocked 表示本地账号因多次密码错误被临时锁定.
+	ErrAccountLocked = &Errno{HTTP: 403, Code: "AuthFailure.AccountLocked", Message: "Account is locked due to too many failed attempts."}
+
+	// ErrAccountDisabled 表示本地账号已被停用.
+	ErrAccountDisabled = &Errno{HTTP: 403, Code: "AuthFailure.AccountDisabled", Message: "Account is disabled."}
+
+	// ErrAccountAlreadyExist 表示本地账号已经存在.
+	ErrAccountAlreadyExist = &Errno{HTTP: 400, Code: "FailedOperation.AccountAlreadyExist", Message: "Account already exist."}
+
+	// ErrPasswordTooShort 表示本地账号的密码长度不足.
+	ErrPasswordTooShort = &Errno{HTTP: 400, Code: "InvalidParameter.PasswordTooShort", Message: "Password must be at least 8 characters."}
+
+	// ErrAccountNotFound 表示本地账号没有找到.
+	ErrAccountNotFound = &Errno{HTTP: 404, Code: "ResourceNotFound.AccountNotFound", Message: "Account not found."}
+
+	// ErrPolicyAlreadyExist 表示授权策略已经存在.
+	ErrPolicyAlreadyExist = &Errno{HTTP: 400, Code: "FailedOperation.PolicyAlreadyExist", Message: "Policy already exist."}
+
+	// ErrPolicyNotFound 表示授权策略没有找到.
+	ErrPolicyNotFound = &Errno{HTTP: 404, Code: "ResourceNotFound.PolicyNotFound", Message: "Policy not found."}
+
+	// ErrDelegationNotFound 表示委托没有找到.
+	ErrDelegationNotFound = &Errno{HTTP: 404, Code: "ResourceNotFound.DelegationNotFound", Message: "Delegation not found."}
+
+	// ErrRoleAlreadyExist 表示角色已经存在.
+	ErrRoleAlreadyExist = &Errno{HTTP: 400, Code: "FailedOperation.RoleAlreadyExist", Message: "Role already exist."}
+
+	// ErrRoleNotFound 表示角色没有找到.
+	ErrRoleNotFound = &Errno{HTTP: 404, Code: "ResourceNotFound.RoleNotFound", Message: "Role not found."}
+
+	// ErrUserRoleAlreadyExist 表示用户已被手动授予该角色.
+	ErrUserRoleAlreadyExist = &Errno{HTTP: 400, Code: "FailedOperation.UserRoleAlreadyExist", Message: "User already has the role."}
+
+	// ErrUserRoleNotFound 表示用户没有该角色.
+	ErrUserRoleNotFound = &Errno{HTTP: 404, Code: "ResourceNotFound.UserRoleNotFound", Message: "User does not have the role."}
+
+	// ErrUserRoleSynced 表示角色由组织架构同步授予，不能手动撤销.
+	ErrUserRoleSynced = &Errno{HTTP: 400, Code: "FailedOperation.UserRoleSynced", Message: "Role is synchronized from the organization and cannot be removed manually."}
+
+	// ErrLastAdmin 表示不能撤销最后一个管理员.
+	ErrLastAdmin = &Errno{HTTP: 400, Code: "FailedOperation.LastAdmin", Message: "Cannot remove the last admin."}
 )
@@ -15,7 +15,25 @@ const (
 	// XUsernameKey 用来定义 Gin 上下文的键，代表请求的所有者.
 	XUserIDKey = "X-UserID"
 
+	// XSessionIDKey 用来定义 Gin 上下文和 JWT 的键，代表令牌所属的登录会话.
+	XSessionIDKey = "X-SessionID"
+
+	// XTokenIDKey 用来定义 Gin 上下文的键，代表访问令牌的 jti.
+	XTokenIDKey = "X-TokenID"
+
+	// XTokenExpiresAtKey 用来定义 Gin 上下文的键，代表访问令牌的过期时间.
+	XTokenExpiresAtKey = "X-TokenExpiresAt"
+
 	AdminRoleName  = "admin"
 	LeaderRoleName = "leader"
 	UserRolesKey   = "roles"
+
+	// MemberRoleName 是所有登录用户隐含拥有的角色，用于授权时匹配普通用户的策略.
+	MemberRoleName = "member"
+
+	// DelegateRoleName 是存在有效委托的被委托人在请求中拥有的角色，不保存在用户角色表中.
+	DelegateRoleName = "delegate"
+
+	// AnyUserOkrObject 是授权策略中的资源，拥有该资源权限的角色可以查看和修改任何用户的 OKR.
+	AnyUserOkrObject = "okrs:any-user"
 )
@@ -6,6 +6,9 @@
 package middleware
 
 import (
+	"context"
+	"time"
+
 	"github.com/gin-gonic/gin"
 
 	"github.com/imxw/miniokr/internal/miniokr/services/user"
@@ -17,6 +20,19 @@ import (
 
 type MiddlewareServiceContainer struct {
 	UserService user.Service
+	Revocation  RevocationChecker
+	Authz       Authorizer
+	Delegations DelegationChecker
+}
+
+// RevocationChecker 判断访问令牌是否已被吊销，如用户已登出或被管理员吊销全部会话.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, claims map[string]interface{}) (bool, error)
+}
+
+// DelegationChecker 判断用户是否被主管授予了仍有效的委托. 每个请求都会调用，实现需要缓存结果.
+type DelegationChecker interface {
+	HasActiveDelegation(ctx context.Context, userID string) (bool, error)
 }
 
 // Authn 是认证中间件，用来从 gin.Context 中提取 token 并验证 token 是否合法，
@@ -32,6 +48,18 @@ func Authn(services *MiddlewareServiceContainer) gin.HandlerFunc {
 			return
 		}
 
+		revoked, err := services.Revocation.IsRevoked(c, claims)
+		if err != nil {
+			core.WriteResponse(c, errno.InternalServerError, nil)
+			c.Abort()
+			return
+		}
+		if revoked {
+			core.WriteResponse(c, errno.ErrTokenInvalid, nil)
+			c.Abort()
+			return
+		}
+
 		// 提取并设置用户名和用户ID
 		username, usernameOk := claims[known.XUsernameKey].(string)
 		userID, userIDOk := claims[known.XUserIDKey].(string)
@@ -49,10 +77,30 @@ func Authn(services *MiddlewareServiceContainer) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// 被委托人在委托有效期内拥有 delegate 角色
+		delegated, err := services.Delegations.HasActiveDelegation(c, userID)
+		if err != nil {
+			core.WriteResponse(c, errno.InternalServerError, nil)
+			c.Abort()
+			return
+		}
+		if delegated {
+			roles = append(roles, known.DelegateRoleName)
+		}
 
 		c.Set(known.XUsernameKey, username)
 		c.Set(known.XUserIDKey, userID)
 		c.Set(known.UserRolesKey, roles)
+		// 登出时吊销当前令牌和会话
+		if jti, ok := claims["jti"].(string); ok {
+			c.Set(known.XTokenIDKey, jti)
+		}
+		if sid, ok := claims[known.XSessionIDKey].(string); ok {
+			c.Set(known.XSessionIDKey, sid)
+		}
+		if exp, ok := claims["exp"].(float64); ok {
+			c.Set(known.XTokenExpiresAtKey, time.Unix(int64(exp), 0))
+		}
 		c.Next()
 	}
 }
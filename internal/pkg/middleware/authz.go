@@ -0,0 +1,45 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/imxw/miniokr/internal/pkg/core"
+	"github.com/imxw/miniokr/internal/pkg/errno"
+	"github.com/imxw/miniokr/internal/pkg/known"
+	"github.com/imxw/miniokr/internal/pkg/log"
+)
+
+// Authorizer 判断角色是否被允许访问资源.
+type Authorizer interface {
+	AuthorizeRoles(roles []string, obj, act string) (bool, error)
+}
+
+// Authz 是授权中间件，按 (角色, 请求路径, 请求方法) 匹配授权策略，需要在 Authn 之后使用.
+// 所有登录用户都拥有 member 角色.
+func Authz(a Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, _ := c.Value(known.UserRolesKey).([]string)
+		subjects := append([]string{known.MemberRoleName}, roles...)
+
+		allowed, err := a.AuthorizeRoles(subjects, c.Request.URL.Path, c.Request.Method)
+		if err != nil {
+			log.C(c).Errorw("Failed to authorize request", "err", err)
+			core.WriteResponse(c, errno.InternalServerError, nil)
+			c.Abort()
+			return
+		}
+		if !allowed {
+			log.C(c).Warnw("Request forbidden", "userID", c.GetString(known.XUserIDKey), "roles", roles)
+			core.WriteResponse(c, errno.ErrForbidden, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
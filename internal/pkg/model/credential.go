@@ -0,0 +1,36 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package model
+
+import "time"
+
+// 本地账号的类型
+const (
+	// CredentialKindAdmin 是钉钉不可用时使用的管理员账号，创建时授予 admin 角色
+	CredentialKindAdmin = "admin"
+	// CredentialKindService 是供系统集成使用的服务账号
+	CredentialKindService = "service"
+)
+
+// Credential 是本地账号的用户名密码，UserID 对应 users 表中的用户
+type Credential struct {
+	Username       string `gorm:"primaryKey;size:64"`
+	UserID         string `gorm:"size:255;not null;uniqueIndex"`
+	PasswordHash   string `gorm:"size:255;not null"`
+	Kind           string `gorm:"size:20;not null"`
+	Disabled       bool   `gorm:"not null;default:false"`
+	FailedAttempts int    `gorm:"not null;default:0"`
+	// LockedUntil 不为空且晚于当前时间时账号被锁定
+	LockedUntil *time.Time
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// TableName 指定本地账号表名
+func (Credential) TableName() string {
+	return "credentials"
+}
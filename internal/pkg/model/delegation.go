@@ -0,0 +1,71 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package model
+
+import "time"
+
+const (
+	// DelegationScopeView 允许被委托人查看委托部门成员的 OKR
+	DelegationScopeView = "view"
+	// DelegationScopeRate 在查看之外允许被委托人填写已有关键结果的上级评分，不能修改其他内容
+	DelegationScopeRate = "rate"
+)
+
+// Delegation 是主管 GrantorID 授予 GranteeID 的权限，范围为 Departments 及其下级部门中
+// 仍由主管管理的成员. ExpiresAt 之后或被吊销后失效
+type Delegation struct {
+	ID          uint   `gorm:"primaryKey"`
+	GrantorID   string `gorm:"size:255;not null;index"`
+	GranteeID   string `gorm:"size:255;not null;index"`
+	Scope       string `gorm:"size:20;not null"`
+	Departments []DelegationDepartment
+	ExpiresAt   time.Time
+	// RevokedAt 不为空表示委托已被委托人吊销
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TableName 指定委托表名
+func (Delegation) TableName() string {
+	return "delegations"
+}
+
+// DepartmentIDs 返回委托的部门
+func (d *Delegation) DepartmentIDs() []int {
+	ids := make([]int, 0, len(d.Departments))
+	for _, dept := range d.Departments {
+		ids = append(ids, dept.DepartmentID)
+	}
+	return ids
+}
+
+// DelegationDepartment 是委托包含的部门
+type DelegationDepartment struct {
+	DelegationID uint `gorm:"primaryKey"`
+	DepartmentID int  `gorm:"primaryKey"`
+}
+
+// TableName 指定委托部门表名
+func (DelegationDepartment) TableName() string {
+	return "delegation_departments"
+}
+
+// DelegatedAction 记录被委托人 GranteeID 通过委托访问 TargetUserID 的 OKR 的请求
+type DelegatedAction struct {
+	ID           uint   `gorm:"primaryKey"`
+	DelegationID uint   `gorm:"not null;index"`
+	GrantorID    string `gorm:"size:255;not null"`
+	GranteeID    string `gorm:"size:255;not null"`
+	TargetUserID string `gorm:"size:255;not null"`
+	Method       string `gorm:"size:10;not null"`
+	Path         string `gorm:"size:255;not null"`
+	CreatedAt    time.Time
+}
+
+// TableName 指定委托操作记录表名
+func (DelegatedAction) TableName() string {
+	return "delegated_actions"
+}
@@ -0,0 +1,22 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package model
+
+import "time"
+
+// FeishuFieldMapping 缓存多维表格中一张表的字段映射，按 app token 和 table ID 区分
+type FeishuFieldMapping struct {
+	AppToken   string `gorm:"primaryKey;size:64"`
+	TableID    string `gorm:"primaryKey;size:64"`
+	Fields     string `gorm:"type:text;not null"` // JSON 格式的字段列表
+	LastUpdate int64  `gorm:"not null"`           // 从 API 拉取的时间(Unix 秒)
+	UpdatedAt  time.Time
+}
+
+// TableName 指定字段映射缓存表名
+func (FeishuFieldMapping) TableName() string {
+	return "feishu_field_mappings"
+}
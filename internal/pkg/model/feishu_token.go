@@ -0,0 +1,26 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package model
+
+import "time"
+
+// FeishuToken 保存飞书应用的 tenant_access_token，Version 用于多副本之间的乐观锁.
+// RefreshingBy 和 RefreshUntil 是刷新租约，租约有效期内只有持有租约的副本向飞书获取新令牌
+type FeishuToken struct {
+	AppID             string `gorm:"primaryKey;size:64"`
+	TenantAccessToken string `gorm:"size:255;not null"`
+	Expire            int
+	SavedAt           time.Time
+	Version           int    `gorm:"not null;default:0"`
+	RefreshingBy      string `gorm:"size:64;not null;default:''"`
+	RefreshUntil      time.Time
+	UpdatedAt         time.Time
+}
+
+// TableName 指定飞书令牌表名
+func (FeishuToken) TableName() string {
+	return "feishu_tokens"
+}
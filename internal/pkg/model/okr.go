@@ -0,0 +1,78 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package model
+
+import "time"
+
+// ObjectiveRecord 是本地存储中的目标(O)表结构
+type ObjectiveRecord struct {
+	RecordID         string `gorm:"primaryKey;size:64"`
+	Title            string `gorm:"type:text"`
+	Owner            string `gorm:"size:255;index"`
+	Date             string `gorm:"size:50;index"`
+	Weight           int
+	CreatedTime      int64 `gorm:"autoCreateTime:milli"`
+	LastModifiedTime int64 `gorm:"autoUpdateTime:milli"`
+}
+
+// TableName 指定目标表名
+func (ObjectiveRecord) TableName() string {
+	return "objectives"
+}
+
+// KeyResultRecord 是本地存储中的关键结果(KR)表结构
+type KeyResultRecord struct {
+	RecordID         string `gorm:"primaryKey;size:64"`
+	ObjectiveID      string `gorm:"size:64;index"`
+	Title            string `gorm:"type:text"`
+	Owner            string `gorm:"size:255;index"`
+	Date             string `gorm:"size:50;index"`
+	Weight           int
+	Completed        string `gorm:"size:20"`
+	SelfRating       *int
+	LeaderRating     *int
+	Reason           string `gorm:"type:text"`
+	Criteria         string `gorm:"type:text"`
+	Leader           string `gorm:"size:255"`
+	Department       string `gorm:"size:255"`
+	CreatedTime      int64  `gorm:"autoCreateTime:milli"`
+	LastModifiedTime int64  `gorm:"autoUpdateTime:milli"`
+}
+
+// TableName 指定关键结果表名
+func (KeyResultRecord) TableName() string {
+	return "key_results"
+}
+
+const (
+	// OkrSyncKindObjective 表示目标记录的同步游标
+	OkrSyncKindObjective = "objective"
+	// OkrSyncKindKeyResult 表示关键结果记录的同步游标
+	OkrSyncKindKeyResult = "key_result"
+)
+
+// OkrSyncCursor 记录本地 OKR 记录与飞书多维表格记录之间的同步游标
+type OkrSyncCursor struct {
+	ID                 uint      `gorm:"primaryKey;autoIncrement"`
+	Kind               string    `gorm:"size:20;not null;uniqueIndex:idx_kind_local;uniqueIndex:idx_kind_remote"`
+	LocalID            string    `gorm:"size:64;not null;uniqueIndex:idx_kind_local"`
+	RemoteID           string    `gorm:"size:64;not null;uniqueIndex:idx_kind_remote"`
+	LocalModifiedTime  int64     // 上次同步时本地记录的最后修改时间(毫秒)
+	RemoteModifiedTime int64     // 上次同步时远端记录的最后修改时间(毫秒)
+	SyncedAt           time.Time `gorm:"autoUpdateTime"`
+}
+
+// OkrSyncLease 是 OKR 同步的租约，多副本部署时同一时间只有持有租约的副本执行同步
+type OkrSyncLease struct {
+	Name      string    `gorm:"primaryKey;size:64"`
+	Holder    string    `gorm:"size:64;not null;default:''"`
+	ExpiresAt time.Time // 持有者异常退出时，租约过期后可以被其他副本领取
+}
+
+// TableName 指定 OKR 同步租约表名
+func (OkrSyncLease) TableName() string {
+	return "okr_sync_leases"
+}
@@ -1,5 +1,18 @@
 package model
 
+import "time"
+
+const (
+	// RoleSourceSync 表示角色由组织架构同步授予，如部门主管的 leader 角色，同步时会随组织架构撤销
+	RoleSourceSync = "sync"
+	// RoleSourceManual 表示角色由管理员手动授予，同步不会修改
+	RoleSourceManual = "manual"
+
+	// RoleChangeAssign 和 RoleChangeRemove 是角色变更记录的操作类型
+	RoleChangeAssign = "assign"
+	RoleChangeRemove = "remove"
+)
+
 // Role 结构体
 type Role struct {
 	RoleID   uint   `gorm:"primaryKey;autoIncrement"`
@@ -10,4 +23,21 @@ type Role struct {
 type UserRole struct {
 	UserID string `gorm:"size:255;not null"`
 	RoleID uint   `gorm:"not null"`
+	Source string `gorm:"size:20;not null;default:sync"`
+}
+
+// RoleChange 记录一次用户角色的授予或撤销，由同步或创建本地账号产生的变更 OperatorID 为空
+type RoleChange struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     string `gorm:"size:255;not null;index"`
+	RoleName   string `gorm:"size:50;not null"`
+	Action     string `gorm:"size:10;not null"`
+	Source     string `gorm:"size:20;not null"`
+	OperatorID string `gorm:"size:255"`
+	CreatedAt  time.Time
+}
+
+// TableName 指定角色变更记录表名
+func (RoleChange) TableName() string {
+	return "role_changes"
 }
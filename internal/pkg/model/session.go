@@ -0,0 +1,49 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package model
+
+import "time"
+
+// RefreshToken 是一个刷新令牌，只保存令牌的 SHA-256 摘要.
+// 同一次登录中轮换出的刷新令牌属于同一个 SessionID
+type RefreshToken struct {
+	TokenHash string `gorm:"primaryKey;size:64"`
+	SessionID string `gorm:"size:36;not null;index"`
+	UserID    string `gorm:"size:255;not null;index"`
+	Username  string `gorm:"size:255;not null"`
+	ExpiresAt time.Time
+	// RevokedAt 不为空表示令牌已被轮换或吊销
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TableName 指定刷新令牌表名
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// RevokedToken 是被吊销的访问令牌，按 jti 记录，ExpiresAt 之后可以删除
+type RevokedToken struct {
+	JTI       string `gorm:"primaryKey;size:36"`
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// TableName 指定吊销令牌表名
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// UserTokenRevocation 记录吊销用户全部会话的时间，之前签发的访问令牌都失效
+type UserTokenRevocation struct {
+	UserID    string `gorm:"primaryKey;size:255"`
+	RevokedAt time.Time
+}
+
+// TableName 指定用户令牌吊销表名
+func (UserTokenRevocation) TableName() string {
+	return "user_token_revocations"
+}
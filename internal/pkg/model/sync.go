@@ -0,0 +1,24 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package model
+
+import "time"
+
+// SyncGenerationOrg 是钉钉组织架构同步的代数名称
+const SyncGenerationOrg = "org"
+
+// SyncGeneration 记录某类数据的同步代数，每次同步成功后加一.
+// 依赖同步数据的缓存在代数变化时失效，多副本部署时各副本都能感知
+type SyncGeneration struct {
+	Name       string `gorm:"primaryKey;size:50"`
+	Generation int64  `gorm:"not null"`
+	UpdatedAt  time.Time
+}
+
+// TableName 指定同步代数表名
+func (SyncGeneration) TableName() string {
+	return "sync_generations"
+}
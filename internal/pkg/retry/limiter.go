@@ -0,0 +1,54 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package retry
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// KeyedLimiter 为每个 key(例如飞书 app token)维护一个独立的令牌桶
+type KeyedLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+// NewKeyedLimiter 创建一个按 key 限流的令牌桶，qps 小于等于 0 时不限流
+func NewKeyedLimiter(qps float64, burst int) *KeyedLimiter {
+	limit := rate.Limit(qps)
+	if qps <= 0 {
+		limit = rate.Inf
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &KeyedLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// Wait 阻塞直到 key 对应的令牌桶中有可用令牌，或 ctx 被取消
+func (l *KeyedLimiter) Wait(ctx context.Context, key string) error {
+	return l.get(key).Wait(ctx)
+}
+
+func (l *KeyedLimiter) get(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[key] = limiter
+	}
+	return limiter
+}
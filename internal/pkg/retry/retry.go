@@ -0,0 +1,88 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+// Package retry 提供带指数退避、随机抖动和限流的通用重试工具.
+package retry // import "github.com/imxw/miniokr/internal/pkg/retry"
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/imxw/miniokr/internal/pkg/log"
+)
+
+// Policy 定义了重试策略
+type Policy struct {
+	// MaxAttempts 是最多尝试的次数(包含第一次)，小于 1 时按 1 处理
+	MaxAttempts int
+	// BaseDelay 是第一次重试前的等待时间，之后每次翻倍
+	BaseDelay time.Duration
+	// MaxDelay 是单次等待时间的上限
+	MaxDelay time.Duration
+	// Retryable 判断错误是否可以重试，为 nil 时所有错误都会重试
+	Retryable func(error) bool
+}
+
+// DefaultPolicy 返回默认的重试策略: 最多 5 次，等待 1s、2s、4s、8s，上限 10s
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// Backoff 返回第 attempt 次重试(从 1 开始)前的等待时间.
+// 采用 "equal jitter"：在指数退避时间的 [1/2, 1] 区间内随机取值，避免多个调用方同时重试
+func (p Policy) Backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt && i < 32 && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// Do 按照重试策略执行 fn，直到成功、遇到不可重试的错误、达到最大次数或 ctx 被取消.
+// 返回最后一次调用的错误；等待期间 ctx 被取消时返回 ctx.Err()
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for i := 1; ; i++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		err = fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if i >= attempts || (p.Retryable != nil && !p.Retryable(err)) {
+			return err
+		}
+
+		delay := p.Backoff(i)
+		log.C(ctx).Warnw("请求失败，稍后重试", "error", err, "retry", i, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
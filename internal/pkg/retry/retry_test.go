@@ -0,0 +1,108 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTemporary = errors.New("temporary")
+
+func TestDo_RetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), Policy{MaxAttempts: 5}, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errTemporary
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDo_StopsAtMaxAttempts(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), Policy{MaxAttempts: 3}, func(context.Context) error {
+		calls++
+		return errTemporary
+	})
+	assert.ErrorIs(t, err, errTemporary)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDo_NonRetryableError(t *testing.T) {
+	permanent := errors.New("permanent")
+	calls := 0
+	p := Policy{MaxAttempts: 5, Retryable: func(err error) bool { return errors.Is(err, errTemporary) }}
+	err := Do(context.Background(), p, func(context.Context) error {
+		calls++
+		return permanent
+	})
+	assert.ErrorIs(t, err, permanent)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDo_ContextCanceledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, Policy{MaxAttempts: 5, BaseDelay: time.Minute}, func(context.Context) error {
+		calls++
+		return errTemporary
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestBackoff_ExponentialWithJitter(t *testing.T) {
+	p := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(1)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 100*time.Millisecond)
+
+		d = p.Backoff(3)
+		assert.GreaterOrEqual(t, d, 200*time.Millisecond)
+		assert.LessOrEqual(t, d, 400*time.Millisecond)
+
+		// 超过上限后不再增长
+		d = p.Backoff(10)
+		assert.GreaterOrEqual(t, d, 500*time.Millisecond)
+		assert.LessOrEqual(t, d, time.Second)
+	}
+}
+
+func TestKeyedLimiter(t *testing.T) {
+	l := NewKeyedLimiter(10, 1)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, l.Wait(ctx, "app-a"))
+	}
+	// 第一个令牌立即可用，之后每 100ms 一个
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	// 不同 key 之间互不影响
+	start = time.Now()
+	assert.NoError(t, l.Wait(ctx, "app-b"))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// ctx 取消时立即返回
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Error(t, l.Wait(canceled, "app-a"))
+}
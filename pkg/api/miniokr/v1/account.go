@@ -0,0 +1,25 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package v1
+
+// CreateAccountRequest 指定了 `POST /api/v1/admin/accounts` 接口的请求参数.
+// Kind 为 admin 时账号被授予管理员角色，service 为系统集成使用的服务账号.
+type CreateAccountRequest struct {
+	Username string `json:"username" binding:"required,max=64"`
+	Name     string `json:"name" binding:"omitempty,max=255"`
+	Password string `json:"password" binding:"required"`
+	Kind     string `json:"kind" binding:"required,oneof=admin service"`
+}
+
+// CreateAccountResponse 指定了 `POST /api/v1/admin/accounts` 接口的返回参数.
+type CreateAccountResponse struct {
+	UserID string `json:"uid"`
+}
+
+// ResetPasswordRequest 指定了 `PUT /api/v1/admin/accounts/:username/password` 接口的请求参数.
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
@@ -5,12 +5,27 @@
 
 package v1
 
-// AuthRequest 指定了 `POST /api/v1/auth/dingtalk` 接口的请求参数.
+// AuthRequest 指定了 `POST /api/v1/auth/:provider` 接口的请求参数，AuthCode 为钉钉、飞书或 OIDC 的登录授权码.
+// State 为 OIDC 登录回调地址中的 state.
 type AuthRequest struct {
 	AuthCode string `json:"authCode" valid:"required"`
+	State    string `json:"state"`
 }
 
-// AuthResponse 指定了 `POST /api/v1/auth/dingtalk` 接口的返回参数.
+// AuthResponse 指定了 `POST /api/v1/auth/:provider` 等登录接口的返回参数.
+// Token 为短期有效的访问令牌，过期后使用 RefreshToken 调用 `POST /api/v1/auth/refresh` 换取新的令牌.
 type AuthResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RefreshTokenRequest 指定了 `POST /api/v1/auth/refresh` 接口的请求参数.
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// PasswordAuthRequest 指定了 `POST /api/v1/auth/password` 接口的请求参数，用于本地账号登录.
+type PasswordAuthRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
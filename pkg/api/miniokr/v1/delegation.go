@@ -0,0 +1,53 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package v1
+
+import "time"
+
+// CreateDelegationRequest 指定了 `POST /api/v1/delegations` 接口的请求参数.
+// Scope 为 view 时只能查看，为 rate 时还可以填写已有关键结果的上级评分.
+type CreateDelegationRequest struct {
+	GranteeID     string    `json:"granteeId" binding:"required"`
+	Scope         string    `json:"scope" binding:"required,oneof=view rate"`
+	DepartmentIDs []int     `json:"departmentIds" binding:"required,min=1"`
+	ExpiresAt     time.Time `json:"expiresAt" binding:"required"`
+}
+
+// DelegationURI 指定了 `/api/v1/delegations/:id` 下各接口的路径参数.
+type DelegationURI struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+// Delegation 是主管 GrantorID 授予 GranteeID 的委托.
+type Delegation struct {
+	ID            uint       `json:"id"`
+	GrantorID     string     `json:"grantorId"`
+	GranteeID     string     `json:"granteeId"`
+	Scope         string     `json:"scope"`
+	DepartmentIDs []int      `json:"departmentIds"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	RevokedAt     *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// ListDelegationResponse 指定了 `GET /api/v1/delegations` 接口的返回参数.
+type ListDelegationResponse struct {
+	Delegations []Delegation `json:"delegations"`
+}
+
+// DelegatedAction 是被委托人通过委托访问他人 OKR 的一次请求.
+type DelegatedAction struct {
+	GranteeID    string    `json:"granteeId"`
+	TargetUserID string    `json:"targetUserId"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// ListDelegatedActionResponse 指定了 `GET /api/v1/delegations/:id/actions` 接口的返回参数.
+type ListDelegatedActionResponse struct {
+	Actions []DelegatedAction `json:"actions"`
+}
@@ -84,6 +84,52 @@ type DeleteRecordReq struct {
 	ID string `uri:"id" binding:"required"`
 }
 
+// DeleteKeyResultReq 删除关键结果，删除他人的关键结果时通过 userId 指定负责人
+type DeleteKeyResultReq struct {
+	DeleteRecordReq
+	UserId string `form:"userId" binding:"omitempty"`
+}
+
 type UpdateRecordReq struct {
 	ID string `uri:"id" binding:"required"`
 }
+
+// BatchSaveObjective 是批量保存接口中的目标，ID 为空时新建
+type BatchSaveObjective struct {
+	ID     string `json:"id" binding:"omitempty"`
+	Title  string `json:"title" binding:"required"`
+	Date   string `json:"date" binding:"required,monthYearFormat"`
+	Weight int    `json:"weight" binding:"omitempty,min=0,max=100"`
+}
+
+// BatchSaveKeyResult 是批量保存接口中的关键结果，ID 为空时新建
+type BatchSaveKeyResult struct {
+	ID           string `json:"id" binding:"omitempty"`
+	Title        string `json:"title" binding:"required"`
+	Weight       int    `json:"weight" binding:"required,min=1,max=100"`
+	Date         string `json:"date" binding:"required,monthYearFormat"`
+	Completed    string `json:"completed" binding:"required,oneof=未开始 已完成 未完成"`
+	SelfRating   *int   `json:"selfRating" binding:"omitempty,min=0,max=120"`
+	Reason       string `json:"reason" binding:"omitempty"`
+	LeaderRating *int   `json:"leaderRating,omitempty" binding:"omitempty"`
+	Criteria     string `json:"criteria" binding:"omitempty"`
+}
+
+// BatchSaveOkrRequest 指定了 `POST /api/v1/okrs/batch` 接口的请求参数.
+type BatchSaveOkrRequest struct {
+	Objective  BatchSaveObjective   `json:"objective" binding:"required"`
+	KeyResults []BatchSaveKeyResult `json:"keyResults" binding:"omitempty,max=100,dive"`
+	UserId     string               `json:"userId,omitempty"`
+}
+
+// BatchSaveResult 是批量保存中单条记录的结果，Error 为空表示成功
+type BatchSaveResult struct {
+	ID    string `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+// BatchSaveOkrResponse 指定了 `POST /api/v1/okrs/batch` 接口的返回参数.
+type BatchSaveOkrResponse struct {
+	ObjectiveID string            `json:"objectiveId"`
+	KeyResults  []BatchSaveResult `json:"keyResults"`
+}
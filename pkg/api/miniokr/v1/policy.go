@@ -0,0 +1,19 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package v1
+
+// Policy 是一条授权策略，允许角色 Role 以匹配 Method 正则的方法访问匹配 Path 的路径.
+// Path 支持 * 通配，如 /api/v1/admin/*.
+type Policy struct {
+	Role   string `json:"role" binding:"required,max=50"`
+	Path   string `json:"path" binding:"required"`
+	Method string `json:"method" binding:"required"`
+}
+
+// ListPolicyResponse 指定了 `GET /api/v1/admin/policies` 接口的返回参数.
+type ListPolicyResponse struct {
+	Policies []Policy `json:"policies"`
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package v1
+
+import "time"
+
+// CreateRoleRequest 指定了 `POST /api/v1/admin/roles` 接口的请求参数.
+type CreateRoleRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
+
+// ListRoleResponse 指定了 `GET /api/v1/admin/roles` 接口的返回参数.
+type ListRoleResponse struct {
+	Roles []string `json:"roles"`
+}
+
+// AssignUserRoleRequest 指定了 `POST /api/v1/admin/users/:id/roles` 接口的请求参数.
+type AssignUserRoleRequest struct {
+	Role string `json:"role" binding:"required,max=50"`
+}
+
+// UserRole 是用户拥有的角色. Source 为 sync 表示由组织架构同步授予，为 manual 表示由管理员手动授予.
+type UserRole struct {
+	Role   string `json:"role"`
+	Source string `json:"source"`
+}
+
+// ListUserRoleResponse 指定了 `GET /api/v1/admin/users/:id/roles` 接口的返回参数.
+type ListUserRoleResponse struct {
+	Roles []UserRole `json:"roles"`
+}
+
+// RoleChange 是一次用户角色的授予或撤销，OperatorID 为空表示由系统产生.
+type RoleChange struct {
+	Role       string    `json:"role"`
+	Action     string    `json:"action"`
+	Source     string    `json:"source"`
+	OperatorID string    `json:"operatorId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ListRoleChangeResponse 指定了 `GET /api/v1/admin/users/:id/roles/changes` 接口的返回参数.
+type ListRoleChangeResponse struct {
+	Changes []RoleChange `json:"changes"`
+}
@@ -60,3 +60,38 @@ func NewAuthz(db *gorm.DB) (*Authz, error) {
 func (a *Authz) Authorize(sub, obj, act string) (bool, error) {
 	return a.Enforce(sub, obj, act)
 }
+
+// AuthorizeRoles 判断 roles 中是否有任一角色被允许对 obj 执行 act.
+func (a *Authz) AuthorizeRoles(roles []string, obj, act string) (bool, error) {
+	for _, role := range roles {
+		ok, err := a.Enforce(role, obj, act)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+// SeedPolicies 逐条写入 policies 中缺少的默认策略，按 (sub, obj, act) 判断是否已存在，可以在每次启动时调用.
+// 升级后新增的默认策略会被补齐，管理员新增的策略不受影响. 返回写入的策略数量.
+func (a *Authz) SeedPolicies(policies [][]string) (int, error) {
+	var missing [][]string
+	for _, p := range policies {
+		ok, err := a.HasPolicy(p)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	if _, err := a.AddPolicies(missing); err != nil {
+		return 0, err
+	}
+	return len(missing), nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestAuthz(t *testing.T) *Authz {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	a, err := NewAuthz(db)
+	require.NoError(t, err)
+	t.Cleanup(a.StopAutoLoadPolicy)
+	return a
+}
+
+func TestSeedPolicies(t *testing.T) {
+	a := newTestAuthz(t)
+	defaults := [][]string{
+		{"member", "/api/v1/okrs", "GET|POST"},
+		{"admin", "/api/v1/*", ".*"},
+	}
+
+	seeded, err := a.SeedPolicies(defaults)
+	require.NoError(t, err)
+	assert.Equal(t, 2, seeded)
+
+	// 再次启动时只补齐缺少的默认策略，管理员新增的策略保留
+	_, err = a.RemovePolicy("member", "/api/v1/okrs", "GET|POST")
+	require.NoError(t, err)
+	_, err = a.AddPolicy("member", "/api/v1/okrs", "GET")
+	require.NoError(t, err)
+	seeded, err = a.SeedPolicies(defaults)
+	require.NoError(t, err)
+	assert.Equal(t, 1, seeded)
+
+	seeded, err = a.SeedPolicies(defaults)
+	require.NoError(t, err)
+	assert.Zero(t, seeded)
+
+	policies, err := a.GetPolicy()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, [][]string{
+		{"admin", "/api/v1/*", ".*"},
+		{"member", "/api/v1/okrs", "GET"},
+		{"member", "/api/v1/okrs", "GET|POST"},
+	}, policies)
+}
+
+func TestAuthorizeRoles(t *testing.T) {
+	a := newTestAuthz(t)
+	_, err := a.SeedPolicies([][]string{
+		{"member", "/api/v1/objectives/*", "PUT|DELETE"},
+		{"admin", "/api/v1/*", ".*"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		roles  []string
+		obj    string
+		act    string
+		expect bool
+	}{
+		{[]string{"member"}, "/api/v1/objectives/rec1", "DELETE", true},
+		{[]string{"member"}, "/api/v1/objectives/rec1", "GET", false},
+		{[]string{"member"}, "/api/v1/admin/policies", "GET", false},
+		{[]string{"member", "admin"}, "/api/v1/admin/policies", "GET", true},
+		{nil, "/api/v1/objectives/rec1", "DELETE", false},
+	}
+	for _, tt := range tests {
+		allowed, err := a.AuthorizeRoles(tt.roles, tt.obj, tt.act)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expect, allowed, "%v %s %s", tt.roles, tt.act, tt.obj)
+	}
+}
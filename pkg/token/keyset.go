@@ -0,0 +1,236 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// keyFileExt 是密钥目录中私钥文件的扩展名，文件名即 kid
+const keyFileExt = ".pem"
+
+// Key 是一个签名密钥，ID 即 JWT 头中的 kid
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// NotAfter 之后不再接受该密钥签发的 token，零值表示不限制
+	NotAfter time.Time
+
+	signKey   interface{}
+	verifyKey interface{}
+	// activatedAt 是密钥目录中私钥文件的修改时间，用于决定签发密钥
+	activatedAt time.Time
+}
+
+// NewHMACKey 创建 HS256 共享密钥. HMAC 密钥没有 kid，也不会出现在 JWKS 中
+func NewHMACKey(secret string) *Key {
+	return &Key{Method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+}
+
+// NewKey 根据私钥类型创建 RS256 或 EdDSA 密钥
+func NewKey(id string, private crypto.Signer) (*Key, error) {
+	if id == "" {
+		return nil, errors.New("key id must not be empty")
+	}
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, signKey: private, verifyKey: private.Public()}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: private, verifyKey: private.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+}
+
+// LoadKeyFile 读取 PEM 格式的 RSA 或 Ed25519 私钥，id 为空时使用不含扩展名的文件名
+func LoadKeyFile(id string, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		id = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	var private interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key type %T", path, private)
+	}
+
+	return NewKey(id, signer)
+}
+
+// KeySet 是 token 签发和校验使用的密钥集合. 只有一个密钥用于签发，
+// 其余密钥仅用于校验轮换前签发、尚未过期的 token
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet 创建密钥集合，signing 用于签发，previous 仅用于校验
+func NewKeySet(signing *Key, previous ...*Key) *KeySet {
+	s := &KeySet{signing: signing, keys: make(map[string]*Key, len(previous)+1)}
+	for _, k := range append([]*Key{signing}, previous...) {
+		if _, ok := s.keys[k.ID]; !ok {
+			s.keys[k.ID] = k
+		}
+	}
+	return s
+}
+
+// LoadKeyDir 加载目录中的全部 *.pem 私钥，文件名即 kid.
+// 修改时间最新的私钥用于签发；较旧的私钥在下一个私钥生效后的 grace 时间内仍用于校验，
+// 之后即使文件仍在目录中也不再接受
+func LoadKeyDir(dir string, grace time.Duration, now time.Time) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != keyFileExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		key, err := LoadKeyFile("", filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		key.activatedAt = info.ModTime()
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no %s key found in %s", keyFileExt, dir)
+	}
+
+	// 从新到旧排列，每个旧密钥在比它新的密钥生效后进入宽限期
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].activatedAt.Equal(keys[j].activatedAt) {
+			return keys[i].ID > keys[j].ID
+		}
+		return keys[i].activatedAt.After(keys[j].activatedAt)
+	})
+	previous := make([]*Key, 0, len(keys)-1)
+	for i := 1; i < len(keys); i++ {
+		keys[i].NotAfter = keys[i-1].activatedAt.Add(grace)
+		if now.Before(keys[i].NotAfter) {
+			previous = append(previous, keys[i])
+		}
+	}
+
+	return NewKeySet(keys[0], previous...), nil
+}
+
+// WatchKeyDir 每隔 interval 重新加载密钥目录，向目录中放入新私钥即可完成轮换，ctx 取消时退出
+func WatchKeyDir(ctx context.Context, dir string, grace time.Duration, interval time.Duration, onError func(error)) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			keys, err := LoadKeyDir(dir, grace, time.Now())
+			if err != nil {
+				// 加载失败时继续使用当前密钥
+				onError(err)
+				continue
+			}
+			SetKeySet(keys)
+		}
+	}
+}
+
+// SigningKeyID 返回当前签发密钥的 kid
+func (s *KeySet) SigningKeyID() string {
+	return s.signing.ID
+}
+
+// JWKS 返回当前仍被接受的非对称密钥的公钥
+func (s *KeySet) JWKS(now time.Time) JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, k := range s.sortedKeys() {
+		if k.ID == "" || !k.valid(now) {
+			continue
+		}
+		jwk, err := NewJSONWebKey(k.ID, k.Method.Alg(), k.verifyKey)
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func (s *KeySet) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	if s.signing.ID != "" {
+		token.Header["kid"] = s.signing.ID
+	}
+	return token.SignedString(s.signing.signKey)
+}
+
+// keyFunc 根据 token 头中的 kid 选择校验密钥，并确保算法与密钥一致
+func (s *KeySet) keyFunc(now time.Time) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok || !key.valid(now) {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return key.verifyKey, nil
+	}
+}
+
+// sortedKeys 按 kid 排序返回全部密钥，使 JWKS 输出稳定
+func (s *KeySet) sortedKeys() []*Key {
+	keys := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+func (k *Key) valid(now time.Time) bool {
+	return k.NotAfter.IsZero() || now.Before(k.NotAfter)
+}
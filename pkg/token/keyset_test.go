@@ -0,0 +1,150 @@
+// Copyright 2024 Roy(徐武) <ixw1991@126.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file. The original repo for
+// this file is https://github.com/imxw/miniokr.
+
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKey 在 dir 中写入 PKCS8 私钥文件 kid.pem，并将修改时间设置为 activatedAt
+func writeKey(t *testing.T, dir string, kid string, private crypto.Signer, activatedAt time.Time) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	path := filepath.Join(dir, kid+keyFileExt)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	require.NoError(t, os.Chtimes(path, activatedAt, activatedAt))
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func TestSignAndParseWithKeyDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeKey(t, dir, "2024-01", newRSAKey(t), now.Add(-time.Hour))
+	writeKey(t, dir, "2024-02", newEd25519Key(t), now.Add(-time.Minute))
+
+	keys, err := LoadKeyDir(dir, 10*time.Minute, now)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-02", keys.SigningKeyID())
+
+	Init(keys, time.Minute)
+	tokenString, err := Sign(map[string]interface{}{"x-user-id": "u1"})
+	require.NoError(t, err)
+
+	parsed, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+	assert.Equal(t, "2024-02", parsed.Header["kid"])
+
+	claims, err := Parse(tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", claims["x-user-id"])
+
+	// 上一个密钥在新密钥生效后 10 分钟内仍在 JWKS 中
+	jwks := JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "2024-01", jwks.Keys[0].Kid)
+	assert.Equal(t, "RS256", jwks.Keys[0].Alg)
+	assert.Equal(t, "OKP", jwks.Keys[1].Kty)
+}
+
+func TestPreviousKeyGracePeriod(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	oldKey := newRSAKey(t)
+	writeKey(t, dir, "old", oldKey, now.Add(-time.Hour))
+
+	keys, err := LoadKeyDir(dir, 10*time.Minute, now)
+	require.NoError(t, err)
+	Init(keys, time.Hour)
+	oldToken, err := Sign(map[string]interface{}{"x-user-id": "u1"})
+	require.NoError(t, err)
+
+	// 轮换后宽限期内仍接受旧密钥签发的 token，新 token 使用新密钥
+	writeKey(t, dir, "new", newRSAKey(t), now.Add(-5*time.Minute))
+	keys, err = LoadKeyDir(dir, 10*time.Minute, now)
+	require.NoError(t, err)
+	SetKeySet(keys)
+	assert.Equal(t, "new", keys.SigningKeyID())
+	_, err = Parse(oldToken)
+	assert.NoError(t, err)
+
+	// 超过宽限期后即使旧私钥文件仍在目录中也不再接受
+	writeKey(t, dir, "new", newRSAKey(t), now.Add(-15*time.Minute))
+	keys, err = LoadKeyDir(dir, 10*time.Minute, now)
+	require.NoError(t, err)
+	SetKeySet(keys)
+	_, err = Parse(oldToken)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Len(t, JWKS().Keys, 1)
+}
+
+func TestParseRejectsAlgorithmMismatch(t *testing.T) {
+	private := newRSAKey(t)
+	key, err := NewKey("rsa", private)
+	require.NoError(t, err)
+	Init(NewKeySet(key), time.Minute)
+
+	// 使用 RSA 公钥作为 HMAC 密钥伪造 token
+	pub, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"x-user-id": "admin"})
+	forged.Header["kid"] = "rsa"
+	tokenString, err := forged.SignedString(pub)
+	require.NoError(t, err)
+
+	_, err = Parse(tokenString)
+	assert.ErrorIs(t, err, jwt.ErrSignatureInvalid)
+}
+
+func TestConfiguredKeyNotAfter(t *testing.T) {
+	current, err := NewKey("current", newEd25519Key(t))
+	require.NoError(t, err)
+	previous, err := NewKey("previous", newEd25519Key(t))
+	require.NoError(t, err)
+
+	Init(NewKeySet(previous), time.Minute)
+	tokenString, err := Sign(nil)
+	require.NoError(t, err)
+
+	previous.NotAfter = time.Now().Add(-time.Second)
+	SetKeySet(NewKeySet(current, previous))
+	_, err = Parse(tokenString)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestJSONWebKeyRoundTrip(t *testing.T) {
+	private := newRSAKey(t)
+	jwk, err := NewJSONWebKey("rsa", "RS256", &private.PublicKey)
+	require.NoError(t, err)
+
+	pub, err := jwk.PublicKey()
+	require.NoError(t, err)
+	assert.True(t, private.PublicKey.Equal(pub))
+}
@@ -13,47 +13,65 @@ import (
 
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
 // Config 包括 token 包的配置选项.
 type Config struct {
-	key string
+	mu   sync.RWMutex
+	keys *KeySet
 	// identityKey string
 	expiration time.Duration // 存储默认的 token 过期时间
 }
 
-// ErrMissingHeader 表示 `Authorization` 请求头为空.
-var ErrMissingHeader = errors.New("the length of the `Authorization` header is zero")
-
 var (
-	config = Config{"Rtg8BPKNEf2mB4mgvKONGPZZQSaJWNLijxR42qRgq0iBb5", 24 * time.Hour}
-	once   sync.Once
+	// ErrMissingHeader 表示 `Authorization` 请求头为空.
+	ErrMissingHeader = errors.New("the length of the `Authorization` header is zero")
+	// ErrNoSigningKey 表示未调用 Init 设置签发密钥.
+	ErrNoSigningKey = errors.New("token signing key is not configured")
+	// ErrUnknownKey 表示 token 的 kid 不在当前接受的密钥中，可能已超过轮换宽限期.
+	ErrUnknownKey = errors.New("token is signed by an unknown key")
 )
 
+var config = Config{expiration: 24 * time.Hour}
+
 // Init 设置包级别的配置 config, config 会用于本包后面的 token 签发和解析.
-func Init(key string, expiration time.Duration) {
-	once.Do(func() {
-		if key != "" {
-			config.key = key
-			fmt.Println("key is: ", key)
-		}
-		if expiration > 0 {
-			config.expiration = expiration
-		}
-	})
+func Init(keys *KeySet, expiration time.Duration) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+
+	config.keys = keys
+	if expiration > 0 {
+		config.expiration = expiration
+	}
 }
 
-// Parse 使用指定的密钥 key 解析 token，解析成功返回 token 上下文，否则报错.
-func Parse(tokenString string, key string) (map[string]interface{}, error) {
-	// 解析 token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// 确保 token 加密算法是预期的加密算法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
+// SetKeySet 替换签发和校验使用的密钥集合，用于密钥轮换.
+func SetKeySet(keys *KeySet) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
 
-		return []byte(key), nil
-	})
+	config.keys = keys
+}
+
+// JWKS 返回当前接受的公钥集合，供其他服务校验 token.
+func JWKS() JSONWebKeySet {
+	keys := currentKeys()
+	if keys == nil {
+		return JSONWebKeySet{Keys: []JSONWebKey{}}
+	}
+	return keys.JWKS(time.Now())
+}
+
+// Parse 使用当前密钥集合解析 token，解析成功返回 token 上下文，否则报错.
+func Parse(tokenString string) (map[string]interface{}, error) {
+	keys := currentKeys()
+	if keys == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	// 解析 token
+	token, err := jwt.Parse(tokenString, keys.keyFunc(time.Now()))
 	// 解析失败
 	if err != nil {
 		return nil, err
@@ -82,24 +100,41 @@ func ParseRequest(c *gin.Context) (map[string]interface{}, error) {
 	// 从请求头中取出 token
 	fmt.Sscanf(header, "Bearer %s", &t)
 
-	return Parse(t, config.key)
+	return Parse(t)
 }
 
-// Sign 使用 jwtSecret 签发 token，token 的 claims 中会存放传入的 subject.
+// Sign 使用当前签发密钥签发 token，token 的 claims 中会存放传入的 subject.
+// 每个 token 带有唯一的 jti，用于吊销单个 token.
 func Sign(claims map[string]interface{}) (tokenString string, err error) {
-	// Token 的内容
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"nbf": time.Now().Unix(),
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(config.expiration).Unix(),
-	})
+	keys := currentKeys()
+	if keys == nil {
+		return "", ErrNoSigningKey
+	}
+
+	config.mu.RLock()
+	expiration := config.expiration
+	config.mu.RUnlock()
+
+	// Token 的内容，iat 精确到毫秒，用于和吊销时间比较
+	now := time.Now()
+	mapClaims := jwt.MapClaims{
+		"jti": uuid.New().String(),
+		"nbf": now.Unix(),
+		"iat": float64(now.UnixMilli()) / 1e3,
+		"exp": now.Add(expiration).Unix(),
+	}
 
 	for k, v := range claims {
-		token.Claims.(jwt.MapClaims)[k] = v
+		mapClaims[k] = v
 	}
 
 	// 签发 token
-	tokenString, err = token.SignedString([]byte(config.key))
+	return keys.sign(mapClaims)
+}
+
+func currentKeys() *KeySet {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
 
-	return
+	return config.keys
 }